@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// analyzeAB compares the two targets of an A/B experiment run by run.
+// Both sides of a run share its time window and network conditions, so
+// the per-run differences are paired and tested with a paired t-test,
+// which is much tighter than comparing separate blocks of runs. Only
+// latencies are compared: alternation gives both targets the same request
+// count over the same window, so their throughput is equal by design.
+func analyzeAB(rows []map[string]string, a, b Target) {
+	metrics := []string{"average"}
+	for _, p := range []float64{50, 95, 99} {
+		if hasPercentile(p) {
+			metrics = append(metrics, percentileKey(p))
+		}
+	}
+	// the two rows of a run share its run number and concurrency
+	type side struct{ a, b map[string]string }
+	runs := map[string]*side{}
+	var order []string
+	for _, row := range rows {
+		key := row["concurrency"] + "/" + row["run_id"][strings.LastIndex(row["run_id"], "-")+1:]
+		s, ok := runs[key]
+		if !ok {
+			s = &side{}
+			runs[key] = s
+			order = append(order, key)
+		}
+		switch row["target"] + " " + row["route"] {
+		case a.Name + " " + a.Route:
+			s.a = row
+		case b.Name + " " + b.Route:
+			s.b = row
+		}
+	}
+
+	var table [][]string
+	for _, m := range metrics {
+		var as, bs, ds []float64
+		for _, key := range order {
+			s := runs[key]
+			if s.a == nil || s.b == nil {
+				continue
+			}
+			va, okA := rowFloat(s.a, m)
+			vb, okB := rowFloat(s.b, m)
+			if okA && okB {
+				as, bs, ds = append(as, va), append(bs, vb), append(ds, vb-va)
+			}
+		}
+		if len(ds) == 0 {
+			continue
+		}
+		name := withUnit(m)
+		change, p := "n/a", pairedP(ds)
+		if mean(as) != 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*(mean(bs)-mean(as))/mean(as))
+		}
+		verdict := "not significant"
+		if !math.IsNaN(p) && p < 0.05 {
+			verdict = "✅ significant"
+		}
+		table = append(table, []string{name, fmt.Sprint(len(ds)),
+			fmt.Sprintf("%.2f", displayValue(m, mean(as))), fmt.Sprintf("%.2f", displayValue(m, mean(bs))),
+			change, fmt.Sprintf("%.4f", p), verdict})
+		fmt.Printf("→ A/B %s: B %s vs A over %d paired runs (p = %.4f)\n", name, change, len(ds), p)
+	}
+	if len(table) == 0 {
+		return
+	}
+	body := fmt.Sprintf("Requests alternated between A = %s and B = %s within every run; each run's two results are compared as a pair (paired t-test, p < 0.05).\n\n", a.label(), b.label())
+	addReportSection("A/B experiment", body+markdownTable([]string{"metric", "runs", "A", "B", "Δ B vs A", "p", "result"}, table))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// abortPolicy gives up on a target whose runs keep failing (--abort-after)
+// or whose error rate spikes (--abort-on-error-rate), so a dead target
+// doesn't burn every repeat on timeouts; the rest of the suite goes on.
+type abortPolicy struct {
+	maxFailures  int
+	maxErrorRate float64 // percent
+}
+
+var heyStatusRe = regexp.MustCompile(`^\s+\[(\d{3})\]\s+(\d+) responses`)
+
+// parseFailures reads a consecutive failure count, "3" or "3-failures".
+func parseFailures(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "-failures"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --abort-after %q, want e.g. 3-failures", s)
+	}
+	return n, nil
+}
+
+// parseRate reads a percentage, "50%" or "50".
+func parseRate(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v <= 0 || v > 100 {
+		return 0, fmt.Errorf("invalid --abort-on-error-rate %q, want e.g. 50%%", s)
+	}
+	return v, nil
+}
+
+// errorRate returns the percentage of a run's requests that failed or
+// got a 4xx/5xx, from its summary's status and error distribution.
+func errorRate(row map[string]string) (rate float64, total int, err error) {
+	codes, errs, err := runStatuses(row["file"])
+	if err != nil {
+		return 0, 0, err
+	}
+	failed := errs
+	total = errs
+	for code, count := range codes {
+		total += count
+		if code >= 400 {
+			failed += count
+		}
+	}
+	if total == 0 {
+		return 100, 0, nil
+	}
+	return 100 * float64(failed) / float64(total), total, nil
+}
+
+// runStatuses reads a run's summary for its responses by status code and
+// its count of requests that got no response.
+func runStatuses(file string) (codes map[int]int, errs int, err error) {
+	f, err := openOutput(filepath.Join(outDir, file))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	codes = map[int]int{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			section = line
+			continue
+		}
+		switch section {
+		case "Status code distribution:":
+			if m := heyStatusRe.FindStringSubmatch(line); m != nil {
+				code, _ := strconv.Atoi(m[1])
+				count, _ := strconv.Atoi(m[2])
+				codes[code] += count
+			}
+		case "Error distribution:":
+			if m := heyErrorRe.FindStringSubmatch(line); m != nil {
+				count, _ := strconv.Atoi(m[1])
+				errs += count
+			}
+		}
+	}
+	return codes, errs, scanner.Err()
+}
+
+// addErrorRate stores the run's error rate in the row, for the error
+// chart and thresholds such as error_rate<1.
+func addErrorRate(row map[string]string) {
+	if rate, _, err := errorRate(row); err == nil {
+		row["error_rate"] = fmt.Sprintf("%.4f", rate)
+	}
+}
+
+// targetHealth follows one target's runs against the policy.
+type targetHealth struct {
+	failures int
+	last     string
+}
+
+// check records a run, err being why it failed to run at all, and returns
+// why the target should be aborted, or "".
+func (h *targetHealth) check(p abortPolicy, row map[string]string, err error) string {
+	rate := 100.0
+	if err == nil {
+		var rerr error
+		if rate, _, rerr = errorRate(row); rerr != nil {
+			rate = 0 // an unreadable summary isn't evidence against the target
+		}
+	}
+	switch {
+	case err != nil:
+		h.failures++
+		h.last = err.Error()
+	case rate >= 100:
+		h.failures++
+		h.last = "every request failed"
+	default:
+		h.failures = 0
+	}
+	if p.maxFailures > 0 && h.failures >= p.maxFailures {
+		return fmt.Sprintf("%d consecutive failed runs (%s)", h.failures, h.last)
+	}
+	if p.maxErrorRate > 0 && err == nil && rate >= p.maxErrorRate {
+		return fmt.Sprintf("error rate %.1f%% ≥ %v%%", rate, p.maxErrorRate)
+	}
+	return ""
+}
+
+// jobHealth is the health of each target a job runs, by rowTargetKey:
+// one for a target, mix or scenario, and both sides of an A/B run.
+type jobHealth map[string]*targetHealth
+
+// check records a run of the job by its targetRows, err being why it
+// failed to run at all, which counts against every target. It returns why
+// the job should be aborted, or "".
+func (h jobHealth) check(p abortPolicy, rows []map[string]string, err error) string {
+	byKey := map[string]map[string]string{}
+	if err == nil {
+		for _, row := range targetRows(rows) {
+			byKey[rowTargetKey(row)] = row
+		}
+	} else {
+		for key := range h {
+			byKey[key] = nil
+		}
+		if len(byKey) == 0 {
+			byKey[""] = nil
+		}
+	}
+	var keys []string
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	reason := ""
+	for _, key := range keys {
+		if h[key] == nil {
+			h[key] = &targetHealth{}
+		}
+		// every target's run is recorded, even after one trips the policy
+		if r := h[key].check(p, byKey[key], err); r != "" && reason == "" {
+			reason = r
+			if len(keys) > 1 {
+				reason = key + ": " + r
+			}
+		}
+	}
+	return reason
+}
+
+// abortedTargets maps each aborted target's label to the reason, for the
+// report and the suite's JSON.
+var abortedTargets = map[string]string{}
+
+func reportAborts() {
+	if len(abortedTargets) == 0 {
+		return
+	}
+	var labels []string
+	for label := range abortedTargets {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	var table [][]string
+	for _, label := range labels {
+		table = append(table, []string{label, "❌ " + abortedTargets[label]})
+	}
+	addReportSection("Aborted targets", "These targets were given up on, so their results are incomplete.\n\n"+
+		markdownTable([]string{"target", "reason"}, table))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// summaryRow writes a hey summary with ok 200s and failed 500s and
+// returns a row for it.
+func summaryRow(t *testing.T, target string, ok, failed int) map[string]string {
+	t.Helper()
+	file := fmt.Sprintf("%s-%d-%d.txt", target, ok, failed)
+	summary := fmt.Sprintf("Status code distribution:\n  [200]\t%d responses\n  [500]\t%d responses\n", ok, failed)
+	if err := os.WriteFile(filepath.Join(outDir, file), []byte(summary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{"target": target, "route": "", "file": file}
+}
+
+func TestJobHealth(t *testing.T) {
+	saved := outDir
+	defer func() { outDir = saved }()
+	outDir = t.TempDir()
+	policy := abortPolicy{maxErrorRate: 50}
+
+	tests := []struct {
+		name string
+		rows []map[string]string
+		want string // a prefix of the reason, or "" for none
+	}{
+		{"healthy single", []map[string]string{summaryRow(t, "api", 100, 0)}, ""},
+		{"failing single", []map[string]string{summaryRow(t, "api", 20, 80)}, "error rate 80.0%"},
+		{"A/B with failing A", []map[string]string{summaryRow(t, "blue", 10, 90), summaryRow(t, "green", 100, 0)}, "blue: error rate"},
+		{"A/B with failing B", []map[string]string{summaryRow(t, "blue", 100, 0), summaryRow(t, "green", 10, 90)}, "green: error rate"},
+		{"A/B both healthy", []map[string]string{summaryRow(t, "blue", 99, 1), summaryRow(t, "green", 98, 2)}, ""},
+		{"unreadable summary", []map[string]string{{"target": "api", "file": "missing.txt"}}, ""},
+	}
+	for _, tt := range tests {
+		got := jobHealth{}.check(policy, tt.rows, nil)
+		if (tt.want == "") != (got == "") || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: check = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJobHealthFailures(t *testing.T) {
+	saved := outDir
+	defer func() { outDir = saved }()
+	outDir = t.TempDir()
+	policy := abortPolicy{maxFailures: 2}
+	blue, green := summaryRow(t, "blue", 0, 100), summaryRow(t, "green", 100, 0)
+
+	h := jobHealth{}
+	if got := h.check(policy, []map[string]string{blue, green}, nil); got != "" {
+		t.Fatalf("after blue's first failed run: %q, want no abort yet", got)
+	}
+	// a run that fails outright counts against both sides
+	if got := h.check(policy, nil, errors.New("exit status 1")); !strings.HasPrefix(got, "blue: 2 consecutive") {
+		t.Errorf("after blue's second failed run: %q, want blue aborted", got)
+	}
+	if h["green"].failures != 1 {
+		t.Errorf("green has %d failures, want 1", h["green"].failures)
+	}
+
+	if got := (jobHealth{}).check(policy, nil, errors.New("exit status 1")); got != "" {
+		t.Errorf("first run failing outright: %q, want no abort yet", got)
+	}
+}
+
+func TestConvergence(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg.CIMetrics, cfg.CIWidth, cfg.MinRepeat = []string{"rps"}, 0.05, 3
+
+	run := func(c *convergence, blue, green string) {
+		c.add([]map[string]string{
+			{"target": "blue", "requests_per_sec": blue},
+			{"target": "green", "requests_per_sec": green},
+		})
+	}
+	spread := newConvergence()
+	run(spread, "100", "50")
+	run(spread, "100", "150")
+	run(spread, "100", "90")
+	if spread.converged() {
+		t.Errorf("converged with green still spread out: %s", spread.summary())
+	}
+	if s := spread.summary(); !strings.HasPrefix(s, "blue: rps ±0.0%; green: rps ±") {
+		t.Errorf("summary = %q, want both targets", s)
+	}
+
+	steady := newConvergence()
+	for i := 0; i < 3; i++ {
+		run(steady, "100", "80")
+	}
+	if !steady.converged() {
+		t.Errorf("steady runs didn't converge: %s", steady.summary())
+	}
+	if newConvergence().converged() {
+		t.Error("converged with no runs")
+	}
+}
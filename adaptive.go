@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Adaptive stopping: with ci_width set, each target keeps being run until
+// the 95% confidence interval of the mean of every ci_metrics metric is at
+// most ci_width of that mean (its full width, so 0.05 is ±2.5%), with
+// repeat as the cap and min_repeat as the floor.
+
+// tTable holds two-sided 95% Student t critical values for 1-30 degrees
+// of freedom; beyond that the normal 1.96 is close enough.
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tCritical95(df int) float64 {
+	if df < 1 {
+		return math.Inf(1)
+	}
+	if df <= len(tTable) {
+		return tTable[df-1]
+	}
+	return 1.96
+}
+
+// ciRelWidth is the width of the 95% confidence interval of the mean of
+// xs relative to that mean.
+func ciRelWidth(xs []float64) float64 {
+	m := mean(xs)
+	if len(xs) < 2 || m == 0 {
+		return math.Inf(1)
+	}
+	return 2 * tCritical95(len(xs)-1) * stddev(xs) / math.Sqrt(float64(len(xs))) / math.Abs(m)
+}
+
+// convergence collects one job's runs for adaptive stopping, per target
+// the job runs: both sides of an A/B run must converge, not just one.
+type convergence struct {
+	values map[string]map[string][]float64 // by rowTargetKey, then metric
+	keys   []string
+	runs   int
+}
+
+func newConvergence() *convergence {
+	return &convergence{values: map[string]map[string][]float64{}}
+}
+
+// add records a run from its targetRows.
+func (c *convergence) add(rows []map[string]string) {
+	c.runs++
+	for _, row := range rows {
+		key := rowTargetKey(row)
+		if c.values[key] == nil {
+			c.values[key] = map[string][]float64{}
+			c.keys = append(c.keys, key)
+		}
+		for _, m := range cfg.CIMetrics {
+			if v, ok := rowFloat(row, metricColumn(m)); ok {
+				c.values[key][m] = append(c.values[key][m], v)
+			}
+		}
+	}
+}
+
+func (c *convergence) converged() bool {
+	if c.runs < cfg.MinRepeat || len(c.keys) == 0 {
+		return false
+	}
+	for _, key := range c.keys {
+		for _, m := range cfg.CIMetrics {
+			if ciRelWidth(c.values[key][m]) > cfg.CIWidth {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// summary lists each metric's current relative CI width, e.g.
+// "rps ±1.2%, p95 ±3.4%", per target when the job runs several.
+func (c *convergence) summary() string {
+	var targets []string
+	for _, key := range c.keys {
+		var parts []string
+		for _, m := range cfg.CIMetrics {
+			w := ciRelWidth(c.values[key][m])
+			if math.IsInf(w, 1) {
+				parts = append(parts, m+" ±?")
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s ±%.1f%%", m, w*50))
+		}
+		s := strings.Join(parts, ", ")
+		if len(c.keys) > 1 {
+			s = key + ": " + s
+		}
+		targets = append(targets, s)
+	}
+	return strings.Join(targets, "; ")
+}
+
+// metricColumn maps a metric name as written in ci_metrics to its column.
+func metricColumn(m string) string {
+	if m == "rps" {
+		return "requests_per_sec"
+	}
+	return m
+}
+
+// validateCIMetrics checks every metric is a numeric column of the rows
+// this run will write, so e.g. p95 needs 95 among the percentiles.
+func validateCIMetrics(metrics []string) error {
+	if len(metrics) == 0 {
+		return fmt.Errorf("ci_metrics is empty")
+	}
+	produced := map[string]bool{}
+	names := []string{"rps"}
+	for _, h := range resultHeaders() {
+		if numericColumn(h) && h != "schema" {
+			produced[h] = true
+			if h != "requests_per_sec" {
+				names = append(names, h)
+			}
+		}
+	}
+	for _, m := range metrics {
+		if !produced[metricColumn(m)] {
+			return fmt.Errorf("%s isn't a numeric column of this run's results; want one of %s", m, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+type adaptiveResult struct {
+	label     string
+	runs      int
+	converged bool
+	summary   string
+}
+
+var adaptiveResults []adaptiveResult
+
+// reportAdaptive adds how many runs each target needed to the report.
+func reportAdaptive() {
+	if len(adaptiveResults) == 0 {
+		return
+	}
+	sort.SliceStable(adaptiveResults, func(i, j int) bool { return adaptiveResults[i].runs > adaptiveResults[j].runs })
+	var table [][]string
+	for _, r := range adaptiveResults {
+		status := "✅ converged"
+		if !r.converged {
+			status = "⚠️ hit repeat"
+		}
+		table = append(table, []string{r.label, strconv.Itoa(r.runs), status, r.summary})
+	}
+	body := fmt.Sprintf("Runs stopped once the 95%% CI of every metric's mean was within %.1f%% of it (±%.1f%%), after at least %d and at most %d runs.\n\n",
+		cfg.CIWidth*100, cfg.CIWidth*50, cfg.MinRepeat, cfg.Repeat)
+	addReportSection("Adaptive stopping", body+markdownTable([]string{"target", "runs", "result", "95% CI"}, table))
+}
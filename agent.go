@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// agents are the host:port addresses of remote agents set with --agents.
+// When any are configured the coordinator splits each run's requests,
+// concurrency and rate evenly across them and merges what they measured.
+var agents []string
+
+// agentToken is the shared token every job is sent with, and the only
+// thing that stops anyone who can reach an agent from using it as a load
+// generator against any URL.
+var agentToken string
+
+const defaultAgentTokenEnv = "PERTOOLS_AGENT_TOKEN"
+
+// AgentJob is what the coordinator posts to an agent's /run endpoint.
+type AgentJob struct {
+	Targets     []Target `json:"targets"`
+	Requests    int      `json:"requests"`
+	Concurrency int      `json:"concurrency"`
+	Rate        float64  `json:"rate"`
+	NoCookies   bool     `json:"no_cookies"`
+	Interleave  bool     `json:"interleave"`
+	RetryAfter  bool     `json:"retry_after"`
+	Network     string   `json:"network,omitempty"`
+	Seed        int64    `json:"seed,omitempty"`
+}
+
+func (job AgentJob) validate() error {
+	switch {
+	case len(job.Targets) == 0:
+		return fmt.Errorf("no targets")
+	case job.Requests <= 0:
+		return fmt.Errorf("requests must be positive, got %d", job.Requests)
+	case job.Concurrency <= 0:
+		return fmt.Errorf("concurrency must be positive, got %d", job.Concurrency)
+	case job.Rate < 0 || math.IsNaN(job.Rate) || math.IsInf(job.Rate, 0):
+		return fmt.Errorf("invalid rate %v", job.Rate)
+	}
+	return nil
+}
+
+// agentJobSlack covers what a job's worst case doesn't: sending the
+// samples back, and network shaping's added latency.
+const agentJobSlack = 30 * time.Second
+
+// maxDuration is the longest job can take if every request runs into its
+// timeout, one round per worker after another, or all n spread out at
+// its rate.
+func (job AgentJob) maxDuration() time.Duration {
+	var slowest time.Duration
+	for _, t := range job.Targets {
+		slowest = max(slowest, timeoutFor(t))
+	}
+	rounds := (job.Requests + job.Concurrency - 1) / job.Concurrency
+	if job.Targets[0].Step > 0 {
+		rounds *= len(job.Targets) // every iteration runs each step
+	}
+	d := time.Duration(rounds) * slowest
+	if job.Rate > 0 {
+		d = max(d, time.Duration(float64(job.Requests)/job.Rate*float64(time.Second))+slowest)
+	}
+	return d + agentJobSlack
+}
+
+// RunMetrics is an agent's answer: every sample of the run, so the
+// coordinator can compute exact percentiles over the merged load.
+type RunMetrics struct {
+	Agent   string        `json:"agent"`
+	Total   time.Duration `json:"total_ns"`
+	Samples []WireSample  `json:"samples"`
+}
+
+type WireSample struct {
+	Target  int           `json:"target"`
+	Offset  time.Duration `json:"offset_ns"`
+	Latency time.Duration `json:"latency_ns"`
+	Status  int           `json:"status,omitempty"`
+	Size    int64         `json:"size,omitempty"`
+	Err     string        `json:"err,omitempty"`
+	NewConn bool          `json:"new_conn,omitempty"`
+	Reused  bool          `json:"reused,omitempty"`
+	TLS     bool          `json:"tls,omitempty"`
+}
+
+func toRunMetrics(agent string, run nativeRun) RunMetrics {
+	m := RunMetrics{Agent: agent, Total: run.total}
+	for _, s := range run.samples {
+		m.Samples = append(m.Samples, WireSample{
+			Target: s.target, Offset: s.offset, Latency: s.latency,
+			Status: s.status, Size: s.size, Err: s.err,
+			NewConn: s.newConn, Reused: s.reused, TLS: s.tlsHandshake,
+		})
+	}
+	return m
+}
+
+func (m RunMetrics) run() nativeRun {
+	run := nativeRun{total: m.Total}
+	for _, s := range m.Samples {
+		run.samples = append(run.samples, sample{
+			target: s.Target, offset: s.Offset, latency: s.Latency,
+			status: s.Status, size: s.Size, err: s.Err,
+			newConn: s.NewConn, reused: s.Reused, tlsHandshake: s.TLS,
+		})
+	}
+	return run
+}
+
+// runAgent implements `agent --listen :9090`: it waits for jobs from a
+// coordinator and runs them one at a time on the native engine. Only jobs
+// carrying the token are taken.
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:9090", "address to accept coordinator jobs on, e.g. :9090 for every interface")
+	tokenEnv := fs.String("token-env", defaultAgentTokenEnv, "environment variable holding the token coordinators must send")
+	tlsCert := fs.String("tls-cert", "", "serve HTTPS with this certificate file; coordinators then address the agent as https://host:port")
+	tlsKey := fs.String("tls-key", "", "the certificate's private key file")
+	fs.Parse(args)
+
+	token := os.Getenv(*tokenEnv)
+	if token == "" {
+		fmt.Printf("❌ %s isn't set; an agent only takes jobs carrying the token its coordinators share\n", *tokenEnv)
+		os.Exit(1)
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fmt.Println("❌ --tls-cert and --tls-key go together")
+		os.Exit(1)
+	}
+
+	name, _ := os.Hostname()
+	http.Handle("/run", agentHandler(token, name))
+
+	var err error
+	if *tlsCert != "" {
+		fmt.Printf("✅ Agent %s listening on https://%s\n", name, *listen)
+		err = http.ListenAndServeTLS(*listen, *tlsCert, *tlsKey, nil)
+	} else {
+		fmt.Printf("✅ Agent %s listening on %s\n", name, *listen)
+		err = http.ListenAndServe(*listen, nil)
+	}
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+}
+
+// agentHandler serves /run jobs carrying token, one at a time, answering
+// with the samples measured as agent name.
+func agentHandler(token, name string) http.Handler {
+	var busy sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hmac.Equal([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a job", http.StatusMethodNotAllowed)
+			return
+		}
+		var job AgentJob
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, "invalid job", http.StatusBadRequest)
+			return
+		}
+		if err := job.validate(); err != nil {
+			http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		busy.Lock()
+		defer busy.Unlock()
+
+		opts := nativeOptions{noCookies: job.NoCookies, interleave: job.Interleave, retryAfter: job.RetryAfter, seed: job.Seed}
+		if job.Network != "" {
+			var err error
+			if opts.shape, err = parseNetworkShape(job.Network); err != nil {
+				http.Error(w, "invalid network: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		fmt.Printf("→ Running %d requests against %s at c=%d\n", job.Requests, job.Targets[0].Name, job.Concurrency)
+		run := runLocal(job.Targets, job.Requests, job.Concurrency, job.Rate, opts)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toRunMetrics(name, run))
+	})
+}
+
+// parseAgents reads "host1:9090,host2:9090"; an entry may be named after
+// its region as eu=host1:9090.
+func parseAgents(s string) []string {
+	var out []string
+	for _, a := range parseOrigins(s) {
+		if !strings.Contains(a, "://") {
+			a = "http://" + a
+		}
+		out = append(out, strings.TrimRight(a, "/"))
+	}
+	return out
+}
+
+// agentRows holds one result row per agent per run, for the per-agent
+// report section.
+var agentRows []map[string]string
+
+// runDistributed fans run i of j out to every agent at once and merges
+// their samples into a single run. Each agent's share is also summarised
+// on its own so results can be attributed to the machine that measured
+// them.
+func runDistributed(j job, n, i int) (nativeRun, error) {
+	metrics := make([]RunMetrics, len(agents))
+	errs := make([]error, len(agents))
+	// each agent draws its own stream, taken here in a fixed order
+	seeds := make([]int64, len(agents))
+	for k := range seeds {
+		seeds[k] = nextStream()
+	}
+	var wg sync.WaitGroup
+	for k, addr := range agents {
+		wg.Add(1)
+		go func(k int, addr string) {
+			defer wg.Done()
+			metrics[k], errs[k] = postAgentJob(addr, AgentJob{
+				Targets:     j.targets,
+				Requests:    share(n, len(agents), k),
+				Concurrency: share(concurrencyFor(j.targets[0]), len(agents), k),
+				Rate:        cfg.Rate / float64(len(agents)),
+				NoCookies:   *noCookies,
+				Interleave:  *abMode,
+				RetryAfter:  *retryAfter,
+				Network:     *networkSpec,
+				Seed:        seeds[k],
+			})
+		}(k, addr)
+	}
+	wg.Wait()
+
+	lead := j.targets[0]
+	lead.Name = j.name
+	var merged nativeRun
+	for k, m := range metrics {
+		if errs[k] != nil {
+			return merged, fmt.Errorf("agent %s: %w", agents[k], errs[k])
+		}
+		run := m.run()
+		merged.samples = append(merged.samples, run.samples...)
+		if run.total > merged.total {
+			merged.total = run.total
+		}
+
+		agent := strings.TrimPrefix(agents[k], "http://")
+		if m.Agent != "" {
+			agent = m.Agent + " @ " + agent
+		}
+		if originNames[k] != "" {
+			agent = originNames[k]
+		}
+		if err := recordAgentShare(lead, i, k, agent, run); err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
+
+// recordAgentShare summarises what machine k measured during run i so the
+// report can attribute results per agent.
+func recordAgentShare(t Target, i, k int, agent string, run nativeRun) error {
+	file := filepath.Join(outDir, fmt.Sprintf("hey_result_%s_agent%d.txt", runStem(t.Slug, i), k+1))
+	sum, err := writeHeySummary(file, run.samples, run.total)
+	if err != nil {
+		return err
+	}
+	row := sum.metrics(file)
+	if gz, err := compressOutput(file); err == nil {
+		row["file"] = filepath.Base(gz)
+	}
+	row["target"] = t.Name
+	row["agent"] = agent
+	row["run_id"] = fmt.Sprintf("%s-agent%d", runID(t.Slug, i), k+1)
+	row["concurrency"] = strconv.Itoa(concurrencyFor(t))
+	agentRows = append(agentRows, row)
+	return nil
+}
+
+// share splits total into parts as evenly as possible, at least 1 each.
+func share(total, parts, k int) int {
+	n := total / parts
+	if k < total%parts {
+		n++
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func postAgentJob(addr string, job AgentJob) (RunMetrics, error) {
+	var m RunMetrics
+	body, err := json.Marshal(job)
+	if err != nil {
+		return m, err
+	}
+	// suiteCtx ends the job at --max-duration or a signal, and the timeout
+	// when an agent stops answering
+	req, err := http.NewRequestWithContext(suiteCtx, http.MethodPost, addr+"/run", bytes.NewReader(body))
+	if err != nil {
+		return m, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+agentToken)
+	client := &http.Client{Timeout: job.maxDuration()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return m, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return m, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&m)
+	return m, err
+}
+
+// checkAgents makes sure every agent answers, and takes the token, before
+// the suite starts. An agent refuses a GET once the token checks out.
+func checkAgents() error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, a := range agents {
+		req, err := http.NewRequest(http.MethodGet, a+"/run", nil)
+		if err != nil {
+			return fmt.Errorf("agent %s: %w", a, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+agentToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("agent %s unreachable: %w", a, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("agent %s refused the token", a)
+		}
+	}
+	return nil
+}
+
+// reportAgents adds a table of each agent's mean results per target.
+func reportAgents() {
+	if len(agentRows) == 0 {
+		return
+	}
+	type key struct{ target, agent, concurrency string }
+	var order []key
+	sums := map[key][]float64{}
+	counts := map[key]int{}
+	metrics := []string{"requests_per_sec", "average", "p95", "p99"}
+	for _, row := range agentRows {
+		k := key{row["target"], row["agent"], row["concurrency"]}
+		if _, ok := sums[k]; !ok {
+			order = append(order, k)
+			sums[k] = make([]float64, len(metrics))
+		}
+		for m, name := range metrics {
+			v, _ := rowFloat(row, name)
+			sums[k][m] += v
+		}
+		counts[k]++
+	}
+
+	var table [][]string
+	for _, k := range order {
+		line := []string{k.target, k.agent, k.concurrency, strconv.Itoa(counts[k])}
+		for m := range metrics {
+			line = append(line, fmt.Sprintf("%.4f", displayValue(metrics[m], sums[k][m]/float64(counts[k]))))
+		}
+		table = append(table, line)
+	}
+	addReportSection("Results per agent",
+		"Mean of each agent's share of the load. The agents' concurrency is the suite total; each ran an even part of it.\n\n"+
+			markdownTable([]string{"target", "agent", "concurrency", "runs", "rps", withUnit("average"), withUnit("p95"), withUnit("p99")}, table))
+}
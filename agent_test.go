@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentHandler(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	agent := httptest.NewServer(agentHandler("s3cret", "agent-1"))
+	defer agent.Close()
+
+	job, _ := json.Marshal(AgentJob{Targets: []Target{{Name: "api", Method: "GET", URL: target.URL}}, Requests: 4, Concurrency: 2})
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+		body   string
+		want   int
+	}{
+		{"no token", "POST", "", string(job), http.StatusUnauthorized},
+		{"wrong token", "POST", "Bearer guess", string(job), http.StatusUnauthorized},
+		{"token without scheme", "POST", "s3cret", string(job), http.StatusUnauthorized},
+		{"GET with the token, as checkAgents sends", "GET", "Bearer s3cret", "", http.StatusMethodNotAllowed},
+		{"not json", "POST", "Bearer s3cret", "{", http.StatusBadRequest},
+		{"no requests", "POST", "Bearer s3cret", `{"targets":[{"URL":"` + target.URL + `"}],"requests":0,"concurrency":2}`, http.StatusBadRequest},
+		{"negative rate", "POST", "Bearer s3cret", `{"targets":[{"URL":"` + target.URL + `"}],"requests":1,"concurrency":1,"rate":-1}`, http.StatusBadRequest},
+		{"job", "POST", "Bearer s3cret", string(job), http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, agent.URL+"/run", strings.NewReader(tt.body))
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var m RunMetrics
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&m)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.want)
+			continue
+		}
+		if tt.want == http.StatusOK && (err != nil || m.Agent != "agent-1" || len(m.Samples) != 4) {
+			t.Errorf("%s: got %s with %d samples, %v; want agent-1 with 4", tt.name, m.Agent, len(m.Samples), err)
+		}
+	}
+}
+
+func TestPostAgentJob(t *testing.T) {
+	saved := agentToken
+	defer func() { agentToken = saved }()
+	agentToken = "s3cret"
+	var auth string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(RunMetrics{Agent: "a", Samples: []WireSample{{Status: 200}}})
+	}))
+	defer agent.Close()
+
+	m, err := postAgentJob(agent.URL, AgentJob{Targets: []Target{{}}, Requests: 1, Concurrency: 1})
+	if err != nil || m.Agent != "a" || len(m.Samples) != 1 {
+		t.Errorf("postAgentJob = %+v, %v", m, err)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("sent Authorization %q, want the bearer token", auth)
+	}
+
+	refusing := httptest.NewServer(agentHandler("other", "b"))
+	defer refusing.Close()
+	if _, err := postAgentJob(refusing.URL, AgentJob{Targets: []Target{{}}, Requests: 1, Concurrency: 1}); err == nil || !strings.HasPrefix(err.Error(), "401") {
+		t.Errorf("postAgentJob to an agent with another token: %v, want a 401", err)
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		total, parts int
+		want         []int
+	}{
+		{10, 1, []int{10}},
+		{10, 2, []int{5, 5}},
+		{10, 3, []int{4, 3, 3}},
+		{11, 4, []int{3, 3, 3, 2}},
+		{2, 3, []int{1, 1, 1}}, // never 0, so every agent takes part
+	}
+	for _, tt := range tests {
+		var got []int
+		for k := 0; k < tt.parts; k++ {
+			got = append(got, share(tt.total, tt.parts, k))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("share(%d, %d, k) = %v, want %v", tt.total, tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestAgentJobValidate(t *testing.T) {
+	one := []Target{{URL: "http://a.example/"}}
+	tests := []struct {
+		name string
+		job  AgentJob
+		ok   bool
+	}{
+		{"closed loop", AgentJob{Targets: one, Requests: 10, Concurrency: 2}, true},
+		{"with a rate", AgentJob{Targets: one, Requests: 10, Concurrency: 2, Rate: 5}, true},
+		{"no targets", AgentJob{Requests: 10, Concurrency: 2}, false},
+		{"no requests", AgentJob{Targets: one, Concurrency: 2}, false},
+		{"negative requests", AgentJob{Targets: one, Requests: -1, Concurrency: 2}, false},
+		{"no concurrency", AgentJob{Targets: one, Requests: 10}, false},
+		{"negative rate", AgentJob{Targets: one, Requests: 10, Concurrency: 2, Rate: -1}, false},
+		{"NaN rate", AgentJob{Targets: one, Requests: 10, Concurrency: 2, Rate: math.NaN()}, false},
+		{"infinite rate", AgentJob{Targets: one, Requests: 10, Concurrency: 2, Rate: math.Inf(1)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.job.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestAgentJobMaxDuration(t *testing.T) {
+	fast := Target{Timeout: time.Second}
+	slow := Target{Timeout: 5 * time.Second}
+	tests := []struct {
+		name string
+		job  AgentJob
+		want time.Duration
+	}{
+		{"one round per worker", AgentJob{Targets: []Target{fast}, Requests: 10, Concurrency: 5}, 2 * time.Second},
+		{"partial last round", AgentJob{Targets: []Target{fast}, Requests: 11, Concurrency: 5}, 3 * time.Second},
+		{"slowest target", AgentJob{Targets: []Target{fast, slow}, Requests: 4, Concurrency: 4}, 5 * time.Second},
+		{"default timeout", AgentJob{Targets: []Target{{}}, Requests: 1, Concurrency: 1}, nativeTimeout},
+		{"scenario steps", AgentJob{Targets: []Target{{Step: 1, Timeout: time.Second}, {Step: 2, Timeout: time.Second}}, Requests: 2, Concurrency: 1}, 4 * time.Second},
+		{"rate spreads requests", AgentJob{Targets: []Target{fast}, Requests: 100, Concurrency: 50, Rate: 10}, 11 * time.Second},
+		{"workers slower than the rate", AgentJob{Targets: []Target{slow}, Requests: 100, Concurrency: 10, Rate: 50}, 50 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.job.maxDuration(); got != tt.want+agentJobSlack {
+			t.Errorf("%s: maxDuration() = %v, want %v", tt.name, got, tt.want+agentJobSlack)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// minOutlierRuns is how many runs a series needs before any of them is
+// called an outlier; with fewer the fences mean little.
+const minOutlierRuns = 4
+
+// outliers returns why each outlying value of a series is one, by index:
+// it lies beyond 3σ of the series' mean, or outside its IQR fences,
+// Q1 − 1.5·IQR to Q3 + 1.5·IQR. NaNs are gaps and never outliers.
+func outliers(xs []float64) map[int]string {
+	var vs []float64
+	for _, x := range xs {
+		if !math.IsNaN(x) {
+			vs = append(vs, x)
+		}
+	}
+	if len(vs) < minOutlierRuns {
+		return nil
+	}
+	m, sd := mean(vs), stddev(vs)
+	q1, q3 := quantile(vs, 0.25), quantile(vs, 0.75)
+	lo, hi := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	out := map[int]string{}
+	for i, x := range xs {
+		switch {
+		case math.IsNaN(x):
+		case sd > 0 && math.Abs(x-m) > 3*sd:
+			out[i] = fmt.Sprintf("%+.1fσ from the mean", (x-m)/sd)
+		case x < lo:
+			out[i] = "below the IQR fence"
+		case x > hi:
+			out[i] = "above the IQR fence"
+		}
+	}
+	return out
+}
+
+// anomalyMetrics are the columns analyzeAnomalies looks for outliers in.
+func anomalyMetrics() []string {
+	metrics := []string{"requests_per_sec", "total", "average"}
+	for _, p := range cfg.Percentiles {
+		metrics = append(metrics, percentileKey(p))
+	}
+	return append(metrics, "error_rate")
+}
+
+// analyzeAnomalies lists in the report every run whose throughput,
+// duration, latency or error rate is an outlier of its series, points the
+// charts mark, so that they can be looked into before trusting a mean.
+func analyzeAnomalies(rows []map[string]string) {
+	var table [][]string
+	for _, metric := range anomalyMetrics() {
+		// each series' cells in run order, NaN where the run has none
+		series := map[string][]float64{}
+		runs := map[string][]map[string]string{}
+		var order []string
+		for _, row := range rows {
+			key := rowSeriesKey(row)
+			if _, ok := series[key]; !ok {
+				order = append(order, key)
+			}
+			v, ok := rowFloat(row, metric)
+			if !ok {
+				v = math.NaN()
+			}
+			series[key] = append(series[key], v)
+			runs[key] = append(runs[key], row)
+		}
+		for _, key := range order {
+			xs := series[key]
+			found := outliers(xs)
+			for i := range xs {
+				why, ok := found[i]
+				if !ok {
+					continue
+				}
+				var rest []float64
+				for j, x := range xs {
+					if j != i && !math.IsNaN(x) {
+						rest = append(rest, x)
+					}
+				}
+				table = append(table, []string{key, strconv.Itoa(i + 1), runs[key][i]["run_id"], withUnit(metric),
+					fmt.Sprintf("%.4g", displayValue(metric, xs[i])), fmt.Sprintf("%.4g", displayValue(metric, mean(rest))), why})
+			}
+		}
+	}
+	if len(table) == 0 {
+		return
+	}
+	addReportSection("Anomalies",
+		fmt.Sprintf("Runs beyond 3σ of their series' mean or outside its IQR fences, of series with at least %d runs. They're marked on the charts.\n\n", minOutlierRuns)+
+			markdownTable([]string{"target", "run", "run_id", "metric", "value", "mean of the rest", "why"}, table))
+	fmt.Printf("⚠️ %d anomalous points, listed in the report\n", len(table))
+}
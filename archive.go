@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writeTarGz bundles dirs (stored under their base names) and extra
+// in-memory files into a gzipped tarball at path.
+func writeTarGz(path string, dirs []string, extra map[string][]byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, dir := range dirs {
+		base := filepath.Dir(dir)
+		err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if fi.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			src, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(tw, src)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for name, data := range extra {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// saveArchive writes the tarball to dest, a local path or an s3:// or
+// gs:// URL that the aws or gsutil CLI uploads it to.
+func saveArchive(dest string, dirs []string, extra map[string][]byte) error {
+	var upload []string
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		upload = []string{"aws", "s3", "cp"}
+	case strings.HasPrefix(dest, "gs://"):
+		upload = []string{"gsutil", "cp"}
+	default:
+		return writeTarGz(dest, dirs, extra)
+	}
+
+	tmp, err := os.CreateTemp("", "archive-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := writeTarGz(tmp.Name(), dirs, extra); err != nil {
+		return err
+	}
+	cmd := exec.Command(upload[0], append(upload[1:], tmp.Name(), dest)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", strings.Join(upload, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ArchiveMetadata describes how a suite was run, stored as metadata.json
+// in its --archive bundle.
+type ArchiveMetadata struct {
+	Suite       string            `json:"suite"`
+	Started     time.Time         `json:"started"`
+	Finished    time.Time         `json:"finished"`
+	Command     []string          `json:"command"`
+	Config      Config            `json:"config"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Environment Environment       `json:"environment"`
+	Versions    map[string]string `json:"versions,omitempty"`
+	Truncated   string            `json:"truncated,omitempty"`
+}
+
+// archiveSuite bundles the suite directory, the workbook if one was
+// written elsewhere, and the run's metadata into a single .tar.gz at dest.
+func archiveSuite(dest string, started time.Time, env Environment) error {
+	meta := ArchiveMetadata{
+		Suite: suiteID, Started: started, Finished: time.Now(), Command: redactArgs(os.Args),
+		Config: cfg, Labels: labels, Environment: env, Versions: versions, Truncated: truncated,
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // so redact sees URLs' & as written
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(meta); err != nil {
+		return err
+	}
+	raw := []byte(redact(buf.String())) // the config's URLs, webhooks and overrides
+	name := filepath.Base(suiteDir)
+	extra := map[string][]byte{name + "/metadata.json": raw}
+	if *xlsxPath != "" {
+		if data, err := os.ReadFile(*xlsxPath); err == nil {
+			extra[name+"/"+filepath.Base(*xlsxPath)] = data
+		}
+	}
+	if err := saveArchive(dest, []string{suiteDir}, extra); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Suite archived to %s\n", dest)
+	return nil
+}
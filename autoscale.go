@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// Autoscale replaces every run's constant load with a step: base_rps for
+// the baseline, step_rps for the hold, then base_rps again for after. It
+// measures how long each target's windowed p95 takes to recover to within
+// tolerance of its baseline once the step hits, its scale-up reaction,
+// and to settle once the step is removed, as it scales back down.
+type Autoscale struct {
+	BaseRPS   float64 `json:"base_rps"`
+	StepRPS   float64 `json:"step_rps"`
+	Baseline  string  `json:"baseline"`  // default 30s
+	Hold      string  `json:"hold"`      // default 2m
+	After     string  `json:"after"`     // default 1m
+	Window    string  `json:"window"`    // p95 is taken over windows this long; default 5s
+	Tolerance float64 `json:"tolerance"` // recovered is p95 ≤ tolerance × baseline p95; default 1.2
+
+	baseline, hold, after, window time.Duration
+}
+
+func validateAutoscale(a *Autoscale) error {
+	if a.BaseRPS <= 0 || a.StepRPS <= a.BaseRPS {
+		return fmt.Errorf("want 0 < base_rps < step_rps, got %v and %v", a.BaseRPS, a.StepRPS)
+	}
+	for _, d := range []struct {
+		name, spec, def string
+		into            *time.Duration
+	}{
+		{"baseline", a.Baseline, "30s", &a.baseline},
+		{"hold", a.Hold, "2m", &a.hold},
+		{"after", a.After, "1m", &a.after},
+		{"window", a.Window, "5s", &a.window},
+	} {
+		if d.spec == "" {
+			d.spec = d.def
+		}
+		v, err := time.ParseDuration(d.spec)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid %s %q", d.name, d.spec)
+		}
+		*d.into = v
+	}
+	if a.window > a.baseline || a.window > a.hold || a.window > a.after {
+		return fmt.Errorf("window %v is longer than a phase", a.window)
+	}
+	if a.Tolerance == 0 {
+		a.Tolerance = 1.2
+	}
+	if a.Tolerance < 1 {
+		return fmt.Errorf("tolerance %v is below 1", a.Tolerance)
+	}
+	return nil
+}
+
+// offsets schedules the profile's requests.
+func (a *Autoscale) offsets() []time.Duration {
+	var out []time.Duration
+	at := time.Duration(0)
+	for _, phase := range []struct {
+		rate float64
+		d    time.Duration
+	}{{a.BaseRPS, a.baseline}, {a.StepRPS, a.hold}, {a.BaseRPS, a.after}} {
+		interval := time.Duration(float64(time.Second) / phase.rate)
+		for end := at + phase.d; at < end; at += interval {
+			out = append(out, at)
+		}
+	}
+	return out
+}
+
+func (a *Autoscale) windows() int {
+	return int(math.Ceil(float64(a.baseline+a.hold+a.after) / float64(a.window)))
+}
+
+// autoscaleRun is one run's reaction to the step.
+type autoscaleRun struct {
+	windows   []float64 // p95 per window in milliseconds, NaN for an empty one
+	base      float64   // p95 over the baseline
+	up, down  time.Duration
+	upOK      bool // whether p95 recovered before the step ended
+	downOK    bool // and settled before the run did
+	holdPeak  float64
+	afterPeak float64
+}
+
+var (
+	autoscaleMu    sync.Mutex
+	autoscaleRuns  = map[string][]autoscaleRun{}
+	autoscaleOrder []string
+)
+
+// recordAutoscale measures a run of the profile against series key.
+func recordAutoscale(key string, samples []sample) {
+	a := cfg.Autoscale
+	buckets := make([][]float64, a.windows())
+	var base []float64
+	for _, s := range samples {
+		ms := millis(s.latency)
+		if w := int(s.offset / a.window); w < len(buckets) {
+			buckets[w] = append(buckets[w], ms)
+		}
+		if s.offset < a.baseline {
+			base = append(base, ms)
+		}
+	}
+	if len(base) == 0 {
+		return
+	}
+	r := autoscaleRun{base: quantile(base, 0.95)}
+	for _, b := range buckets {
+		if len(b) == 0 {
+			r.windows = append(r.windows, math.NaN())
+		} else {
+			r.windows = append(r.windows, quantile(b, 0.95))
+		}
+	}
+	step := int(a.baseline / a.window)
+	end := int((a.baseline + a.hold) / a.window)
+	threshold := a.Tolerance * r.base
+	r.up, r.upOK, r.holdPeak = recovery(r.windows[step:end], threshold, a.window)
+	r.down, r.downOK, r.afterPeak = recovery(r.windows[end:], threshold, a.window)
+
+	autoscaleMu.Lock()
+	defer autoscaleMu.Unlock()
+	if _, ok := autoscaleRuns[key]; !ok {
+		autoscaleOrder = append(autoscaleOrder, key)
+	}
+	autoscaleRuns[key] = append(autoscaleRuns[key], r)
+}
+
+// recovery returns how long into windows p95 came back within threshold
+// for good, whether it did before they ran out, and the highest p95.
+func recovery(windows []float64, threshold float64, width time.Duration) (time.Duration, bool, float64) {
+	last := -1 // the last window over threshold
+	peak := 0.0
+	for i, w := range windows {
+		if math.IsNaN(w) {
+			continue
+		}
+		peak = math.Max(peak, w)
+		if w > threshold {
+			last = i
+		}
+	}
+	if last == len(windows)-1 {
+		return 0, false, peak
+	}
+	return time.Duration(last+1) * width, true, peak
+}
+
+// reactionTime formats the mean recovery of the runs that recovered, and
+// how many didn't.
+func reactionTime(runs []autoscaleRun, up bool) string {
+	var ok []float64
+	for _, r := range runs {
+		switch {
+		case up && r.upOK:
+			ok = append(ok, r.up.Seconds())
+		case !up && r.downOK:
+			ok = append(ok, r.down.Seconds())
+		}
+	}
+	if len(ok) == 0 {
+		return "never"
+	}
+	s := fmt.Sprintf("%.0f s", mean(ok))
+	if missed := len(runs) - len(ok); missed > 0 {
+		s += fmt.Sprintf(" (%d of %d runs never)", missed, len(runs))
+	}
+	return s
+}
+
+// analyzeAutoscale compares the targets' reactions to the step in the
+// report and charts their windowed p95, averaged over runs, through it.
+func analyzeAutoscale(filename string) {
+	a := cfg.Autoscale
+	if len(autoscaleOrder) == 0 {
+		return
+	}
+	var table [][]string
+	for _, key := range autoscaleOrder {
+		runs := autoscaleRuns[key]
+		var base, holdPeak, afterPeak []float64
+		for _, r := range runs {
+			base = append(base, r.base)
+			holdPeak = append(holdPeak, r.holdPeak)
+			afterPeak = append(afterPeak, r.afterPeak)
+		}
+		table = append(table, []string{key, fmt.Sprintf("%.4g", inUnit(mean(base))), fmt.Sprintf("%.4g", inUnit(mean(holdPeak))),
+			reactionTime(runs, true), reactionTime(runs, false), fmt.Sprintf("%.4g", inUnit(mean(afterPeak)))})
+		fmt.Printf("→ %s: scale-up reaction %s, scale-down settle %s\n", key, reactionTime(runs, true), reactionTime(runs, false))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "A step from %v to %v rps after a %v baseline, held for %v, then %v back at %v rps. p95 is taken over %v windows. "+
+		"A target has recovered once its p95 stays within %.0f%% of its baseline p95 for the rest of the phase.\n\n",
+		a.BaseRPS, a.StepRPS, a.baseline, a.hold, a.after, a.BaseRPS, a.window, (a.Tolerance-1)*100)
+	b.WriteString(markdownTable([]string{"target", "baseline p95 (" + displayUnit + ")", "peak p95 in step", "scale-up reaction", "scale-down settle", "peak p95 after"}, table))
+	addReportSection("Autoscaling", b.String())
+
+	if !chartWanted(chartName(filename)) {
+		return
+	}
+	var xAxis []string
+	for w := 0; w < a.windows(); w++ {
+		xAxis = append(xAxis, strconv.FormatFloat((time.Duration(w)*a.window).Seconds(), 'f', -1, 64))
+	}
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Autoscaling Reaction", fmt.Sprintf("p95 per %v window through a %v → %v rps step", a.window, a.BaseRPS, a.StepRPS))),
+		charts.WithYAxisOpts(opts.YAxis{Name: withUnit("p95")}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Seconds"}),
+		charts.WithColorsOpts(seriesColors),
+	)
+	line.SetGlobalOptions(interactiveOpts(displayUnit)...)
+	line.SetXAxis(xAxis)
+	phases := charts.WithMarkLineNameXAxisItemOpts(
+		opts.MarkLineNameXAxisItem{Name: "step", XAxis: xAxis[int(a.baseline/a.window)]},
+		opts.MarkLineNameXAxisItem{Name: "step removed", XAxis: xAxis[int((a.baseline+a.hold)/a.window)]})
+	marked := false
+	for _, key := range autoscaleOrder {
+		if !seriesWanted(key) {
+			continue
+		}
+		var points []opts.LineData
+		for w := range xAxis {
+			var xs []float64
+			for _, r := range autoscaleRuns[key] {
+				if !math.IsNaN(r.windows[w]) {
+					xs = append(xs, r.windows[w])
+				}
+			}
+			if len(xs) == 0 {
+				points = append(points, opts.LineData{Value: "-"})
+				continue
+			}
+			points = append(points, opts.LineData{Value: round4(inUnit(mean(xs)))})
+		}
+		var seriesOpts []charts.SeriesOpts
+		if !marked {
+			seriesOpts, marked = append(seriesOpts, phases), true
+		}
+		line.AddSeries(key, points, seriesOpts...)
+	}
+	renderChart(line, filename)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// writeBenchFormat writes every run as a testing.B result line, e.g.
+//
+//	BenchmarkGreenCloud/GET_/persons 1000 12.3 ms/op 812 rps 45.1 p95-ms
+//
+// so benchstat can test whether two suites differ significantly:
+//
+//	benchstat old/hey_bench.txt new/hey_bench.txt
+func writeBenchFormat(filename string, rows []map[string]string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "goos: %s\ngoarch: %s\n", runtime.GOOS, runtime.GOARCH)
+	for _, k := range labelKeys() {
+		fmt.Fprintf(f, "%s: %s\n", k, labels[k])
+	}
+	for _, row := range rows {
+		avg, ok := rowFloat(row, "average")
+		if !ok {
+			continue
+		}
+		rps, _ := rowFloat(row, "requests_per_sec")
+		total, _ := rowFloat(row, "total")
+		fmt.Fprintf(f, "%s %d %.4f ms/op %.2f rps", benchName(row), int(math.Round(rps*total/1000)), avg, rps)
+		for _, p := range cfg.Percentiles {
+			if v, ok := rowFloat(row, percentileKey(p)); ok {
+				fmt.Fprintf(f, " %.4f %s-ms", v, percentileKey(p))
+			}
+		}
+		fmt.Fprintln(f)
+	}
+	fmt.Printf("✅ Benchmark output written to %s\n", filename)
+	return nil
+}
+
+// benchName turns a row's series into a benchmark name: the target in
+// CamelCase, with the route and sweep level as sub-benchmarks.
+func benchName(row map[string]string) string {
+	var b strings.Builder
+	b.WriteString("Benchmark")
+	upper := true
+	for _, r := range row["target"] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if row["route"] != "" {
+		b.WriteString("/" + strings.ReplaceAll(row["route"], " ", "_"))
+	}
+	if len(cfg.Sweep) > 1 {
+		b.WriteString("/c=" + row["concurrency"])
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// Baseline is a known-good suite's per-series means, saved by
+// `bisect --save-baseline` and compared against on every bisect step.
+type Baseline struct {
+	Schema int                           `json:"schema"`
+	Suite  string                        `json:"suite"`
+	Series map[string]map[string]float64 `json:"series"`
+}
+
+var bisectMetrics = []string{"p95", "requests_per_sec"}
+
+// Exit codes understood by `git bisect run`.
+const (
+	bisectGood = 0
+	bisectBad  = 1
+	bisectSkip = 125
+)
+
+// runBisect implements `bisect [flags] [-- suite flags]`: it runs a short
+// suite (the config with --runs repeats) and exits 0 when every series'
+// p95 and RPS are within --tolerance of the baseline, 1 when any regressed,
+// or 125 (skip) when the suite couldn't run, so that
+//
+//	git bisect run sh -c './deploy.sh && custom-per-tools bisect'
+//
+// finds the commit that made the server slower.
+func runBisect(args []string) {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	config := fs.String("config", "", "suite config file")
+	baselinePath := fs.String("baseline", "bisect_baseline.json", "baseline file")
+	save := fs.Bool("save-baseline", false, "run the suite and store it as the good baseline")
+	runs := fs.Int("runs", 3, "runs per target for each bisect step")
+	tolerance := fs.Float64("tolerance", 10, "allowed regression in percent")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(bisectSkip)
+	}
+	c.Repeat = *runs
+	cfg = c
+
+	suite, err := runShortSuite(c, fs.Args())
+	if err != nil {
+		fmt.Println("❌ Suite failed, skipping this commit:", err)
+		os.Exit(bisectSkip)
+	}
+	current := Baseline{Schema: schemaVersion, Suite: suite.Suite, Series: map[string]map[string]float64{}}
+	for _, m := range bisectMetrics {
+		for key, v := range seriesMeans(suite.Results, m) {
+			if current.Series[key] == nil {
+				current.Series[key] = map[string]float64{}
+			}
+			current.Series[key][m] = v
+		}
+	}
+
+	if *save {
+		raw, _ := json.MarshalIndent(current, "", "  ")
+		if err := os.WriteFile(*baselinePath, raw, 0644); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Baseline written to %s\n", *baselinePath)
+		return
+	}
+
+	raw, err := os.ReadFile(*baselinePath)
+	if err != nil {
+		fmt.Println("❌ No baseline, run bisect --save-baseline on a good commit first:", err)
+		os.Exit(bisectSkip)
+	}
+	var base Baseline
+	if err := json.Unmarshal(raw, &base); err != nil {
+		fmt.Println("❌ Invalid baseline:", err)
+		os.Exit(bisectSkip)
+	}
+	if base.Schema < 5 {
+		// saved while latencies were in seconds
+		for _, metrics := range base.Series {
+			for m, v := range metrics {
+				if timeColumn(m) {
+					metrics[m] = v * 1000
+				}
+			}
+		}
+	}
+	if compareBaseline(base, current, *tolerance) {
+		fmt.Println("✅ good")
+		os.Exit(bisectGood)
+	}
+	fmt.Println("❌ bad")
+	os.Exit(bisectBad)
+}
+
+// runShortSuite runs the suite as a child process with c written to a
+// temporary config and its results in a temporary directory, and returns
+// what it wrote to hey_results.json.
+func runShortSuite(c Config, extra []string) (SuiteJSON, error) {
+	var suite SuiteJSON
+	tmp, err := os.CreateTemp("", "bisect-*.json")
+	if err != nil {
+		return suite, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(c); err != nil {
+		return suite, err
+	}
+	tmp.Close()
+	dir, err := os.MkdirTemp("", "bisect-results-*")
+	if err != nil {
+		return suite, err
+	}
+	defer os.RemoveAll(dir)
+
+	self, err := os.Executable()
+	if err != nil {
+		return suite, err
+	}
+	cmd := exec.Command(self, append([]string{"--config", tmp.Name(), "--results-dir", dir}, extra...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Run() // a failed threshold still leaves results to compare
+
+	latest, err := latestSuiteDir(dir)
+	if err != nil {
+		return suite, err
+	}
+	raw, err := os.ReadFile(filepath.Join(latest, "hey_results.json"))
+	if err != nil {
+		return suite, err
+	}
+	if err := json.Unmarshal(raw, &suite); err != nil {
+		return suite, err
+	}
+	if len(suite.Results) == 0 {
+		return suite, fmt.Errorf("no results")
+	}
+	return suite, nil
+}
+
+// compareBaseline prints each series' change and reports whether none
+// regressed by more than tolerance percent: p95 up or RPS down.
+func compareBaseline(base, current Baseline, tolerance float64) bool {
+	var keys []string
+	for key := range base.Series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ok := true
+	for _, key := range keys {
+		got, found := current.Series[key]
+		if !found {
+			fmt.Printf("⚠️  %s isn't in this run\n", key)
+			continue
+		}
+		for _, m := range bisectMetrics {
+			want, have := base.Series[key][m], got[m]
+			if want == 0 {
+				continue
+			}
+			change := (have - want) / want * 100
+			regressed := change > tolerance
+			if m == "requests_per_sec" {
+				regressed = -change > tolerance
+			}
+			mark := "✅"
+			if regressed {
+				mark = "❌"
+				ok = false
+			}
+			fmt.Printf("%s %s %s: %.4f → %.4f (%+.1f%%)\n", mark, key, m, want, have, change)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// suiteCtx ends at the --max-duration deadline, killing an in-flight hey
+// run so the suite can stop on time.
+var suiteCtx = context.Background()
+
+// truncated says why the suite stopped before running everything, or is
+// empty when it completed. It's recorded in the JSON, the report and the
+// archive metadata.
+var truncated string
+
+// budget tracks the suite against --max-duration and --max-requests.
+type budget struct {
+	deadline    time.Time
+	maxRequests int
+	sent        int
+}
+
+// exceeded returns why the suite must stop, or "" while it's within budget.
+func (b *budget) exceeded() string {
+	if s, ok := terminatedBy.Load().(string); ok {
+		return s
+	}
+	if !b.deadline.IsZero() && !time.Now().Before(b.deadline) {
+		return fmt.Sprintf("--max-duration %s reached", *maxDuration)
+	}
+	if b.maxRequests > 0 && b.sent >= b.maxRequests {
+		return fmt.Sprintf("--max-requests %s reached after %d requests", *maxRequests, b.sent)
+	}
+	return ""
+}
+
+// requestsSent counts a run's requests from its rows' requests column. A
+// mix or scenario's aggregate row, which comes last, covers its members;
+// without one, as in an A/B run, each row is its own target's share.
+func requestsSent(rows []map[string]string) int {
+	if hasAggregate(rows) {
+		n, _ := strconv.Atoi(rows[len(rows)-1]["requests"])
+		return n
+	}
+	total := 0
+	for _, row := range rows {
+		n, _ := strconv.Atoi(row["requests"])
+		total += n
+	}
+	return total
+}
+
+// aggregateKey marks the aggregate row runJob writes after a mix or
+// scenario's members. Like slo_good, it's internal and never written out.
+const aggregateKey = "aggregate"
+
+// hasAggregate reports whether the last of a run's rows is its aggregate.
+func hasAggregate(rows []map[string]string) bool {
+	return len(rows) > 0 && rows[len(rows)-1][aggregateKey] != ""
+}
+
+// targetRows are the rows of a run standing for a whole target: a mix or
+// scenario's aggregate, or else every row, e.g. both sides of an A/B run.
+func targetRows(rows []map[string]string) []map[string]string {
+	if hasAggregate(rows) {
+		return rows[len(rows)-1:]
+	}
+	return rows
+}
+
+// parseCount reads a request count like 500000, 500k or 2m.
+func parseCount(s string) (int, error) {
+	mult, num := 1.0, s
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult, num = 1000, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		mult, num = 1000000, strings.TrimSuffix(s, "m")
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	n := v * mult
+	// a whole number of requests; the tolerance only absorbs the float
+	// error of e.g. 1.001 * 1000
+	whole := math.Round(n)
+	if err != nil || math.IsNaN(n) || n < 1 || n > 1<<53 || math.Abs(n-whole) > 1e-6 {
+		return 0, fmt.Errorf("invalid count %q, want a whole number like 500000 or 500k", s)
+	}
+	return int(whole), nil
+}
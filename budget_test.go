@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"1", 1},
+		{"500000", 500000},
+		{"500k", 500000},
+		{"1.5k", 1500},
+		{"2m", 2000000},
+		{"0.5m", 500000},
+		{"1.001k", 1001},
+	}
+	for _, tt := range tests {
+		got, err := parseCount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseCount(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "0", "-5", "0.5", "k", "m", "5x", "inf", "NaN", "1e20", "5K", "5 k", "1.5", "999.9", "1.0005k", "0.0000005m"} {
+		if n, err := parseCount(in); err == nil {
+			t.Errorf("parseCount(%q) = %d, want an error", in, n)
+		}
+	}
+}
+
+func TestRequestsSent(t *testing.T) {
+	row := func(target, route, requests string) map[string]string {
+		return map[string]string{"target": target, "route": route, "requests": requests}
+	}
+	aggregate := func(target, requests string) map[string]string {
+		r := row(target, "", requests)
+		r[aggregateKey] = "1"
+		return r
+	}
+	tests := []struct {
+		name string
+		rows []map[string]string
+		want int
+	}{
+		{"none", nil, 0},
+		{"single", []map[string]string{row("api", "", "500")}, 500},
+		{"mix with aggregate", []map[string]string{
+			row("api", "GET /a", "310"), row("api", "GET /b", "190"), aggregate("api", "500"),
+		}, 500},
+		{"scenario with aggregate", []map[string]string{
+			row("checkout", "1. login", "100"), row("checkout", "2. pay", "100"), aggregate("checkout", "200"),
+		}, 200},
+		{"interleaved A/B", []map[string]string{row("blue", "", "250"), row("green", "", "250")}, 500},
+		{"A/B whose second side has no route", []map[string]string{row("api", "GET /a", "250"), row("api", "", "250")}, 500},
+		{"A/B of one target's routes", []map[string]string{row("api", "GET /a", "250"), row("api", "GET /b", "250")}, 500},
+		{"A/B of route and whole target", []map[string]string{row("api", "GET /a", "250"), row("other", "", "250")}, 500},
+	}
+	for _, tt := range tests {
+		if got := requestsSent(tt.rows); got != tt.want {
+			t.Errorf("%s: requestsSent = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CacheBust makes a target's requests miss the caches in front of it, a
+// CDN's in particular: Query appends a unique _cb parameter to the URL,
+// Header sends Cache-Control: no-cache. With PerRequest every request gets
+// its own value, which needs the native engine; otherwise a run shares
+// Token, so its first request misses and the rest may hit.
+type CacheBust struct {
+	Query      bool   `json:"query"`
+	Header     bool   `json:"header"`
+	PerRequest bool   `json:"per_request"`
+	Token      string `json:"token,omitempty"`
+}
+
+// parseCacheBust reads --cache-bust and --cache-bust-scope; scope
+// defaults to request on the native engine and run on hey.
+func parseCacheBust(mode, scope, engine string) (*CacheBust, error) {
+	b := &CacheBust{}
+	switch mode {
+	case "query":
+		b.Query = true
+	case "header":
+		b.Header = true
+	case "both":
+		b.Query, b.Header = true, true
+	case "":
+		return nil, fmt.Errorf("--cache-bust-scope needs --cache-bust or --cache-compare")
+	default:
+		return nil, fmt.Errorf("unknown mode %q, want query, header or both", mode)
+	}
+	switch scope {
+	case "":
+		b.PerRequest = engine == "native"
+	case "request":
+		if engine != "native" {
+			return nil, fmt.Errorf("a new value per request needs --engine native; hey sends one URL per run")
+		}
+		b.PerRequest = true
+	case "run":
+	default:
+		return nil, fmt.Errorf("unknown scope %q, want request or run", scope)
+	}
+	return b, nil
+}
+
+// cacheBusting is the suite's --cache-bust, nil without.
+var cacheBusting *CacheBust
+
+var cacheTokens atomic.Uint64
+
+// cacheToken is unique to this process and, by its time prefix, across
+// suites.
+func cacheToken() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatUint(cacheTokens.Add(1), 36)
+}
+
+// apply returns t as it's sent: with b's token, or a fresh one without.
+func (b *CacheBust) apply(t Target) Target {
+	token := b.Token
+	if token == "" {
+		token = cacheToken()
+	}
+	if b.Query {
+		sep := "?"
+		if strings.Contains(t.URL, "?") {
+			sep = "&"
+		}
+		t.URL += sep + "_cb=" + token
+	}
+	if b.Header {
+		t.Headers = append(append([]Header(nil), t.Headers...), Header{Name: "Cache-Control", Value: "no-cache"})
+	}
+	return t
+}
+
+// withRunTokens gives the targets busting caches once per run this run's
+// token.
+func (j job) withRunTokens() job {
+	out := job{name: j.name}
+	for _, t := range j.targets {
+		if t.Bust != nil && !t.Bust.PerRequest {
+			b := *t.Bust
+			b.Token = cacheToken()
+			t.Bust = &b
+		}
+		out.targets = append(out.targets, t)
+	}
+	return out
+}
+
+// cacheVariants pairs every deployment with a cache-busting twin, run
+// straight after it.
+func cacheVariants(targets []Target, b *CacheBust) []Target {
+	return targetVariants(targets, []string{"cached", "uncached"}, func(t *Target, k int) {
+		if k == 1 {
+			t.Bust = b
+		}
+	})
+}
+
+// analyzeCache compares each deployment's cached and uncached runs from
+// --cache-compare: how much the cache saves, and whether it's caching at
+// all.
+func analyzeCache(rows []map[string]string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	type pair struct{ rps, lat [2][]float64 }
+	pairs := map[string]*pair{}
+	for _, row := range rows {
+		if row["cache"] == "" || row["agent"] != "" {
+			continue
+		}
+		side := map[string]int{"cached": 0, "uncached": 1}[row["cache"]]
+		r, ok1 := rowFloat(row, "requests_per_sec")
+		l, ok2 := rowFloat(row, latency)
+		if !ok1 || !ok2 {
+			continue
+		}
+		base := map[string]string{
+			"target":      strings.TrimSuffix(row["target"], variantName("", row["cache"])),
+			"route":       row["route"],
+			"concurrency": row["concurrency"],
+		}
+		key := rowSeriesKey(base)
+		if pairs[key] == nil {
+			pairs[key] = &pair{}
+		}
+		pairs[key].rps[side] = append(pairs[key].rps[side], r)
+		pairs[key].lat[side] = append(pairs[key].lat[side], l)
+	}
+	var keys []string
+	for k, p := range pairs {
+		if len(p.lat[0]) > 0 && len(p.lat[1]) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	var table [][]string
+	for _, k := range keys {
+		p := pairs[k]
+		cached, uncached := mean(p.lat[0]), mean(p.lat[1])
+		speedup := uncached / cached
+		verdict := fmt.Sprintf("✅ the cache saves %.4g %s", inUnit(uncached-cached), displayUnit)
+		switch {
+		case math.IsNaN(speedup) || math.IsInf(speedup, 0):
+			verdict = "–"
+		case speedup < 1.1:
+			verdict = "⚠️ no benefit: the cache may not be serving these requests"
+		}
+		table = append(table, []string{k,
+			fmt.Sprintf("%.1f", mean(p.rps[0])), fmt.Sprintf("%.1f", mean(p.rps[1])),
+			fmt.Sprintf("%.4g", inUnit(cached)), fmt.Sprintf("%.4g", inUnit(uncached)),
+			fmt.Sprintf("%.2f×", speedup), verdict})
+		fmt.Printf("→ %s: cached %.4g %s vs uncached %.4g %s (%s)\n", k, inUnit(cached), displayUnit, inUnit(uncached), displayUnit, latency)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Every target ran cached and, straight after, with caches busted (%s). ", cacheBustText(cacheBusting))
+	fmt.Fprintf(&b, "Speedup is the uncached %s latency over the cached one.\n\n", latency)
+	b.WriteString(markdownTable([]string{"target", "cached rps", "uncached rps", "cached " + withUnit(latency), "uncached " + withUnit(latency), "speedup", "verdict"}, table))
+	addReportSection("Cache comparison", b.String())
+}
+
+// cacheBustText describes b for the report.
+func cacheBustText(b *CacheBust) string {
+	var how []string
+	if b.Query {
+		how = append(how, "a unique _cb query parameter")
+	}
+	if b.Header {
+		how = append(how, "Cache-Control: no-cache")
+	}
+	scope := "per run"
+	if b.PerRequest {
+		scope = "per request"
+	}
+	return strings.Join(how, " and ") + ", " + scope
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Capacity asks the sweep how each target would fare at production load:
+// current_rps and multiples of it, by default 1×, 1.5× and 2×.
+type Capacity struct {
+	CurrentRPS float64   `json:"current_rps"`
+	Multiples  []float64 `json:"multiples"`
+}
+
+// Utilisation at which a predicted load is called at risk rather than
+// held: an M/M/c queue's latency climbs steeply beyond it, so the model's
+// error there is large.
+const capacityRiskUtilisation = 0.85
+
+func validateCapacity(c *Capacity) error {
+	if c.CurrentRPS <= 0 && cfg.Cutover != nil {
+		c.CurrentRPS = cfg.Cutover.PeakRPS
+	}
+	if c.CurrentRPS <= 0 {
+		return fmt.Errorf("current_rps must be a positive request rate")
+	}
+	if len(c.Multiples) == 0 {
+		c.Multiples = []float64{1, 1.5, 2}
+	}
+	for _, m := range c.Multiples {
+		if m <= 0 {
+			return fmt.Errorf("multiple %v isn't positive", m)
+		}
+	}
+	return nil
+}
+
+// queueModel is an M/M/c queue fitted to a target's sweep: servers
+// parallel workers, each serving rate requests per second.
+type queueModel struct {
+	servers int
+	rate    float64
+}
+
+// fitQueueModel takes the service time as the mean latency of the lowest
+// level, where requests barely queue, and the capacity as the highest
+// throughput measured; the servers are how many workers of that service
+// time the capacity takes.
+func fitQueueModel(points []sweepPoint) queueModel {
+	service := points[0].latency / 1000
+	peak := 0.0
+	for _, p := range points {
+		peak = math.Max(peak, p.rps)
+	}
+	c := max(1, int(math.Round(peak*service)))
+	return queueModel{servers: c, rate: peak / float64(c)}
+}
+
+func (m queueModel) capacity() float64 { return float64(m.servers) * m.rate }
+
+// utilisation is ρ = λ / cμ.
+func (m queueModel) utilisation(load float64) float64 { return load / m.capacity() }
+
+// latency is the mean response time at load, in milliseconds: the
+// service time plus the expected wait, Erlang C over the spare capacity.
+// It's infinite at or past capacity, where the queue grows without bound.
+func (m queueModel) latency(load float64) float64 {
+	if load >= m.capacity() {
+		return math.Inf(1)
+	}
+	a := load / m.rate // offered load in servers
+	b := 1.0           // Erlang B, built up one server at a time
+	for k := 1; k <= m.servers; k++ {
+		b = a * b / (float64(k) + a*b)
+	}
+	c := float64(m.servers) * b / (float64(m.servers) - a*(1-b))
+	return 1000 * (1/m.rate + c/(m.capacity()-load))
+}
+
+// fitError is the mean absolute relative error of the model's latency
+// against the measured one, at each level's measured throughput.
+func (m queueModel) fitError(points []sweepPoint) float64 {
+	var errs []float64
+	for _, p := range points {
+		if w := m.latency(p.rps); !math.IsInf(w, 1) && p.latency > 0 {
+			errs = append(errs, math.Abs(w-p.latency)/p.latency)
+		}
+	}
+	if len(errs) == 0 {
+		return math.NaN()
+	}
+	return mean(errs)
+}
+
+// analyzeCapacity fits a queueModel to every swept target and predicts
+// its mean latency and utilisation at each configured load, to answer
+// whether a deployment holds, say, twice today's traffic.
+func analyzeCapacity(rows []map[string]string, c *Capacity) {
+	points := sweepPoints(rows)
+	var keys []string
+	for k, p := range points {
+		if len(p) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		fmt.Println("⚠️ The capacity model needs a sweep of at least two concurrency levels")
+		return
+	}
+	sort.Strings(keys)
+
+	var models, predictions [][]string
+	var caveats []string
+	for _, k := range keys {
+		ps := points[k]
+		m := fitQueueModel(ps)
+		top := ps[len(ps)-1]
+		fit := m.fitError(ps)
+		fitText := "–"
+		if !math.IsNaN(fit) {
+			fitText = fmt.Sprintf("±%.0f%%", 100*fit)
+		}
+		models = append(models, []string{k, fmt.Sprint(m.servers), fmt.Sprintf("%.4g", inUnit(1000/m.rate)),
+			fmt.Sprintf("%.1f", m.capacity()), fitText})
+		if saturationPoint(ps) < 0 && findKnee(levelsOf(ps), latenciesOf(ps)).index < 0 {
+			caveats = append(caveats, fmt.Sprintf("- %s was still scaling at c=%d, so its capacity of %.1f rps is a lower bound and its predictions are pessimistic; sweep higher to find its limit.",
+				k, top.concurrency, m.capacity()))
+		}
+		if fit > 0.25 {
+			caveats = append(caveats, fmt.Sprintf("- The model fits %s's sweep poorly (%s), so treat its predictions as rough.", k, fitText))
+		}
+		for _, mult := range c.Multiples {
+			load := mult * c.CurrentRPS
+			rho := m.utilisation(load)
+			verdict := "✅ holds"
+			latency := fmt.Sprintf("%.4g", inUnit(m.latency(load)))
+			switch {
+			case rho >= 1:
+				verdict, latency = "❌ overloaded", "∞"
+			case rho >= capacityRiskUtilisation:
+				verdict = "⚠️ at risk"
+			}
+			if load > top.rps && rho < 1 {
+				verdict += " (extrapolated)"
+			}
+			predictions = append(predictions, []string{k, fmt.Sprintf("%g× (%.1f rps)", mult, load),
+				fmt.Sprintf("%.0f%%", 100*rho), latency, verdict})
+		}
+		worst := 0.0
+		for _, mult := range c.Multiples {
+			worst = math.Max(worst, mult)
+		}
+		fmt.Printf("→ %s at %g× current traffic: %.0f%% utilised\n", k, worst, 100*m.utilisation(worst*c.CurrentRPS))
+	}
+	caveats = append(caveats,
+		"- An M/M/c queue assumes Poisson arrivals, exponential service times and no failures under load; real services are burstier, so expect the wait to be worse than predicted near capacity.",
+		fmt.Sprintf("- Loads above %.0f%% utilisation are at risk: small errors in the fitted capacity change the predicted latency there a lot.", capacityRiskUtilisation*100),
+		"- Predictions above the highest throughput measured are extrapolated beyond the sweep.")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Each swept target fitted as an M/M/c queue: the service time is the mean latency at the lowest level, the capacity the highest throughput measured, and the servers how many workers of that service time the capacity takes. Fit is the model's mean error against the measured latencies.\n\n")
+	b.WriteString(markdownTable([]string{"target", "servers", "service time (" + displayUnit + ")", "capacity (rps)", "fit"}, models))
+	fmt.Fprintf(&b, "\nPredicted at production load, from current traffic of %v rps:\n\n", c.CurrentRPS)
+	b.WriteString(markdownTable([]string{"target", "load", "utilisation", "mean latency (" + displayUnit + ")", "verdict"}, predictions))
+	b.WriteString("\nCaveats:\n\n" + strings.Join(caveats, "\n") + "\n")
+	addReportSection("Capacity model", b.String())
+}
+
+func levelsOf(ps []sweepPoint) []float64 {
+	var xs []float64
+	for _, p := range ps {
+		xs = append(xs, float64(p.concurrency))
+	}
+	return xs
+}
+
+func latenciesOf(ps []sweepPoint) []float64 {
+	var ys []float64
+	for _, p := range ps {
+		ys = append(ys, p.latency)
+	}
+	return ys
+}
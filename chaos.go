@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// ChaosAction disrupts a deployment at run Run: it calls Webhook, e.g. to
+// toggle a feature flag, or runs Command in a shell, e.g. kubectl delete
+// pod. It fires just before the run, or At into it to hit requests in
+// flight; Target limits it to one deployment's runs, by name, and it
+// fires at every sweep level.
+type ChaosAction struct {
+	Name    string `json:"name"`
+	Run     int    `json:"run"`
+	Target  string `json:"target"`
+	At      string `json:"at"`
+	Webhook string `json:"webhook"`
+	Method  string `json:"method"` // default POST
+	Body    string `json:"body"`
+	Command string `json:"command"`
+
+	at time.Duration
+}
+
+func validateChaos(actions []ChaosAction) error {
+	for i := range actions {
+		a := &actions[i]
+		if a.Name == "" {
+			a.Name = fmt.Sprintf("chaos %d", i+1)
+		}
+		if a.Run < 1 || a.Run > cfg.Repeat {
+			return fmt.Errorf("%s: run %d is outside the suite's 1–%d", a.Name, a.Run, cfg.Repeat)
+		}
+		if (a.Webhook == "") == (a.Command == "") {
+			return fmt.Errorf("%s: wants exactly one of webhook and command", a.Name)
+		}
+		if a.At != "" {
+			var err error
+			if a.at, err = time.ParseDuration(a.At); err != nil || a.at < 0 {
+				return fmt.Errorf("%s: invalid at %q", a.Name, a.At)
+			}
+		}
+		if a.Method == "" {
+			a.Method = http.MethodPost
+		}
+	}
+	return nil
+}
+
+// chaosEvent is an action as it fired, for the report and charts.
+type chaosEvent struct {
+	action *ChaosAction
+	target string
+	run    int
+	at     time.Time
+	err    error
+}
+
+var (
+	chaosMu     sync.Mutex
+	chaosEvents []chaosEvent
+)
+
+// startChaos fires the actions due at run i of deployment name: those
+// without an at now, the rest in the background. The returned function
+// waits for them and names the ones that fired, to annotate the run's rows.
+func startChaos(name, label string, i int) func() string {
+	var wg sync.WaitGroup
+	var fired []string
+	for k := range cfg.Chaos {
+		a := &cfg.Chaos[k]
+		if a.Run != i || a.Target != "" && a.Target != name {
+			continue
+		}
+		fired = append(fired, a.Name)
+		if a.at == 0 {
+			fireChaos(a, label, i)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(a.at):
+				fireChaos(a, label, i)
+			case <-suiteCtx.Done():
+			}
+		}()
+	}
+	return func() string {
+		wg.Wait()
+		return strings.Join(fired, "; ")
+	}
+}
+
+func fireChaos(a *ChaosAction, label string, i int) {
+	fmt.Printf("→ Chaos: %s during run %d of %s\n", a.Name, i, label)
+	ev := chaosEvent{action: a, target: label, run: i, at: time.Now()}
+	if a.Webhook != "" {
+		ev.err = callWebhook(a)
+	} else {
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		if out, err := exec.CommandContext(suiteCtx, shell, flag, a.Command).CombinedOutput(); err != nil {
+			ev.err = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	if ev.err != nil {
+		fmt.Printf("⚠️  Chaos action %s failed: %s\n", a.Name, redact(ev.err.Error()))
+	}
+	chaosMu.Lock()
+	chaosEvents = append(chaosEvents, ev)
+	chaosMu.Unlock()
+}
+
+func callWebhook(a *ChaosAction) error {
+	req, err := http.NewRequestWithContext(suiteCtx, a.Method, a.Webhook, strings.NewReader(a.Body))
+	if err != nil {
+		return err
+	}
+	if a.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// chaosMarks marks the runs chaos fired in on a per-run chart, at their
+// run number or, on a time axis, when they fired.
+func chaosMarks(byTime bool) []charts.SeriesOpts {
+	var items []opts.MarkLineNameXAxisItem
+	seen := map[string]bool{}
+	for _, ev := range chaosEvents {
+		var x interface{} = strconv.Itoa(ev.run)
+		if byTime {
+			x = ev.at.UTC().Format(time.RFC3339Nano)
+		}
+		name := "⚡ " + ev.action.Name
+		if key := fmt.Sprint(name, x); !seen[key] {
+			seen[key] = true
+			items = append(items, opts.MarkLineNameXAxisItem{Name: name, XAxis: x})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []charts.SeriesOpts{
+		charts.WithMarkLineNameXAxisItemOpts(items...),
+		charts.WithMarkLineStyleOpts(opts.MarkLineStyle{Label: &opts.Label{Show: opts.Bool(true), Formatter: "{b}"}}),
+	}
+}
+
+// chaosRecoveryTolerance is how close to its pre-chaos mean a series'
+// latency must come back to count as recovered.
+const chaosRecoveryTolerance = 0.2
+
+// analyzeChaos reports the actions fired and how each disrupted series
+// recovered: how far latency and errors rose from the run chaos hit, and
+// after how many runs latency came back within tolerance of its mean
+// before.
+func analyzeChaos(rows []map[string]string) {
+	if len(chaosEvents) == 0 {
+		return
+	}
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	var actions [][]string
+	for _, ev := range chaosEvents {
+		what, outcome := ev.action.Webhook, "✅"
+		if ev.action.Command != "" {
+			what = "`" + ev.action.Command + "`"
+		} else {
+			what = ev.action.Method + " " + what
+		}
+		if ev.err != nil {
+			outcome = "❌ " + ev.err.Error()
+		}
+		when := "before the run"
+		if ev.action.at > 0 {
+			when = fmt.Sprintf("%v into the run", ev.action.at)
+		}
+		actions = append(actions, []string{ev.action.Name, ev.target, strconv.Itoa(ev.run), when, what, outcome})
+	}
+
+	bySeries := map[string][]map[string]string{}
+	var keys []string
+	for _, row := range rows {
+		if row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if _, ok := bySeries[key]; !ok {
+			keys = append(keys, key)
+		}
+		bySeries[key] = append(bySeries[key], row)
+	}
+	sort.Strings(keys)
+	var recovery [][]string
+	for _, key := range keys {
+		series := bySeries[key]
+		hit := -1
+		for k, row := range series {
+			if row["chaos"] != "" {
+				hit = k
+				break
+			}
+		}
+		if hit < 0 {
+			continue
+		}
+		var before []float64
+		for _, row := range series[:hit] {
+			if v, ok := rowFloat(row, latency); ok {
+				before = append(before, v)
+			}
+		}
+		peak, peakErrors := 0.0, 0.0
+		recovered := "–"
+		if len(before) > 0 {
+			recovered = "not within the suite"
+		}
+		base := mean(before)
+		for k, row := range series[hit:] {
+			v, _ := rowFloat(row, latency)
+			e, _ := rowFloat(row, "error_rate")
+			peak, peakErrors = max(peak, v), max(peakErrors, e)
+			if len(before) > 0 && recovered == "not within the suite" && v <= (1+chaosRecoveryTolerance)*base {
+				recovered = fmt.Sprintf("after %d run(s)", k)
+				if k == 0 {
+					recovered = "not disrupted"
+				}
+			}
+		}
+		baseText := "–"
+		if len(before) > 0 {
+			baseText = fmt.Sprintf("%.4g", inUnit(base))
+		}
+		recovery = append(recovery, []string{key, series[hit]["chaos"], baseText,
+			fmt.Sprintf("%.4g", inUnit(peak)), fmt.Sprintf("%.1f%%", peakErrors), recovered})
+		fmt.Printf("→ %s after chaos: %s\n", key, recovered)
+	}
+
+	var b strings.Builder
+	b.WriteString(markdownTable([]string{"action", "target", "run", "when", "what", "outcome"}, actions))
+	if len(recovery) > 0 {
+		fmt.Fprintf(&b, "\nRecovery of each disrupted series: its mean %s before the chaos run, the peak %s and error rate from that run on, "+
+			"and how many runs later %s was back within %.0f%% of its mean before.\n\n", latency, latency, latency, chaosRecoveryTolerance*100)
+		b.WriteString(markdownTable([]string{"series", "chaos", "before (" + displayUnit + ")", "peak (" + displayUnit + ")", "peak errors", "recovered"}, recovery))
+	}
+	addReportSection("Chaos", b.String())
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// ChartSpec declares a chart: the metric it plots (a result column, or
+// rps, rps_per_worker or bandwidth), how, and for which targets. The
+// suite's own charts are ChartSpecs too, and a spec in the config's
+// "charts" with the same file replaces the built-in one.
+//
+// Without an aggregation every run is a point on the x axis, placed by
+// its run number or, with x_axis "time" (the default with --x-axis time),
+// by when it started; with mean, median, min or max each target collapses
+// to one value and the targets become the x axis.
+type ChartSpec struct {
+	Metric      string   `json:"metric"`
+	Type        string   `json:"type"` // line (default), bar or scatter
+	Targets     []string `json:"targets"`
+	Title       string   `json:"title"`
+	File        string   `json:"file"`
+	Scale       string   `json:"scale"` // linear (default) or log
+	Aggregation string   `json:"aggregation"`
+	XAxis       string   `json:"x_axis"` // run or time; empty follows --x-axis
+
+	marks []opts.MarkLineNameYAxisItem
+}
+
+// derivedMetrics are the chartable metrics computed from others rather
+// than stored as columns.
+var derivedMetrics = []string{"rps", "rps_per_worker", "bandwidth"}
+
+// validateChart checks a configured chart and fills in its defaults.
+func validateChart(c *ChartSpec) error {
+	if c.Metric == "" {
+		return fmt.Errorf("a chart needs a metric")
+	}
+	known := false
+	for _, h := range append(resultHeaders(), derivedMetrics...) {
+		known = known || (h == c.Metric && numericColumn(h) && !strings.HasPrefix(h, "label_") && h != "schema")
+	}
+	if !known {
+		return fmt.Errorf("chart metric %q isn't a numeric result column", c.Metric)
+	}
+	switch c.Type {
+	case "":
+		c.Type = "line"
+	case "line", "bar", "scatter":
+	default:
+		return fmt.Errorf("chart %s: type %q isn't line, bar or scatter", c.Metric, c.Type)
+	}
+	switch c.Scale {
+	case "":
+		c.Scale = "linear"
+	case "linear", "log":
+	default:
+		return fmt.Errorf("chart %s: scale %q isn't linear or log", c.Metric, c.Scale)
+	}
+	switch c.Aggregation {
+	case "", "run":
+		c.Aggregation = "run"
+	case "mean", "median", "min", "max":
+	default:
+		return fmt.Errorf("chart %s: aggregation %q isn't run, mean, median, min or max", c.Metric, c.Aggregation)
+	}
+	if c.XAxis != "" && c.XAxis != "run" && c.XAxis != "time" {
+		return fmt.Errorf("chart %s: x_axis %q isn't run or time", c.Metric, c.XAxis)
+	}
+	if c.Title == "" {
+		c.Title = withUnit(c.Metric)
+		if c.Aggregation != "run" {
+			c.Title = c.Aggregation + " " + c.Title
+		}
+	}
+	if c.File == "" {
+		c.File = "chart_" + safeSlug(strings.ReplaceAll(c.Metric, ".", "_"))
+		if c.Aggregation != "run" {
+			c.File += "_" + c.Aggregation
+		}
+		c.File += ".html"
+	}
+	if filepath.Base(c.File) != c.File || !strings.HasSuffix(c.File, ".html") {
+		return fmt.Errorf("chart %s: file %q must be a plain .html file name", c.Metric, c.File)
+	}
+	return nil
+}
+
+func validateCharts(specs []ChartSpec) error {
+	for i := range specs {
+		if err := validateChart(&specs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineChart is the spec of a built-in per-run line chart.
+func lineChart(metric, title, file string, marks ...opts.MarkLineNameYAxisItem) ChartSpec {
+	return ChartSpec{Metric: metric, Type: "line", Title: title, File: file, Scale: "linear", Aggregation: "run", marks: marks}
+}
+
+// withConfigCharts returns the built-in charts with the configured ones
+// replacing those writing the same file, followed by the rest.
+func withConfigCharts(builtin, configured []ChartSpec) []ChartSpec {
+	byFile := map[string]int{}
+	for i, c := range builtin {
+		byFile[c.File] = i
+	}
+	out := append([]ChartSpec{}, builtin...)
+	for _, c := range configured {
+		if i, ok := byFile[c.File]; ok {
+			c.marks = out[i].marks
+			out[i] = c
+			continue
+		}
+		byFile[c.File] = len(out)
+		out = append(out, c)
+	}
+	return out
+}
+
+// chartXAxis is --x-axis, what per-run charts put on the x axis: the run
+// number or the time each run started.
+var chartXAxis = "run"
+
+// chartFilter and seriesFilter are --charts and --targets: the charts to
+// write, and the series to draw in them. Empty means all.
+var chartFilter, seriesFilter []string
+
+// chartName is how --charts refers to a chart file: chart_p95.html is p95.
+func chartName(file string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "chart_"), ".html")
+}
+
+// chartWanted reports whether --charts selects a chart known by any of
+// names.
+func chartWanted(names ...string) bool {
+	if len(chartFilter) == 0 {
+		return true
+	}
+	for _, n := range names {
+		for _, f := range chartFilter {
+			if n == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// seriesMatches reports whether the series key is selected by list: by
+// target name, "name route", or the full key, e.g. "green-cloud c=50".
+func seriesMatches(key string, list []string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, t := range list {
+		if key == t || strings.HasPrefix(key, t+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesWanted reports whether --targets keeps a series in the charts.
+func seriesWanted(key string) bool { return seriesMatches(key, seriesFilter) }
+
+// wantsSeries reports whether the chart should draw the run's series,
+// given its own targets and --targets.
+func (c ChartSpec) wantsSeries(d HeyResult) bool {
+	return seriesMatches(seriesKey(d), c.Targets) && seriesWanted(seriesKey(d))
+}
+
+func aggregate(xs []float64, how string) float64 {
+	switch how {
+	case "median":
+		return quantile(xs, 0.5)
+	case "min":
+		s := append([]float64{}, xs...)
+		sort.Float64s(s)
+		return s[0]
+	case "max":
+		s := append([]float64{}, xs...)
+		sort.Float64s(s)
+		return s[len(s)-1]
+	default:
+		return mean(xs)
+	}
+}
+
+// generateChart renders spec from the runs in data into filename.
+func generateChart(data []HeyResult, spec ChartSpec, filename string) {
+	byTime := spec.Aggregation == "run" && (spec.XAxis == "time" || spec.XAxis == "" && chartXAxis == "time")
+	// values[series] holds one cell per run, nil for a gap; on a time axis
+	// each cell is a [start, value] pair instead
+	values := map[string][]interface{}{}
+	runs := map[string][]string{} // the runDetail of each cell, for the tooltip
+	raw := map[string][]float64{} // each cell's value, NaN for a gap, to find outliers in
+	var order []string
+	for _, d := range data {
+		if !spec.wantsSeries(d) {
+			continue
+		}
+		key := seriesKey(d)
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		v, ok := extractMetric(d, spec.Metric)
+		var cell interface{}
+		if ok && d.Invalid == "" {
+			cell = displayValue(spec.Metric, v)
+		} // else a gap rather than a misleading zero
+		if byTime {
+			if d.Started.IsZero() {
+				continue // recorded before runs were timestamped
+			}
+			if cell == nil {
+				cell = "-"
+			}
+			cell = []interface{}{d.Started.Format(time.RFC3339Nano), cell}
+		}
+		values[key] = append(values[key], cell)
+		runs[key] = append(runs[key], runDetail(d))
+		if f, ok := cell.(float64); ok {
+			raw[key] = append(raw[key], f)
+		} else if pair, ok := cell.([]interface{}); ok && pair[1] != "-" {
+			raw[key] = append(raw[key], pair[1].(float64))
+		} else {
+			raw[key] = append(raw[key], math.NaN())
+		}
+	}
+
+	var xAxis []string
+	var xName string
+	series := map[string][]interface{}{}
+	names := order
+	switch {
+	case byTime:
+		xName = "Start Time"
+		series = values
+	case spec.Aggregation == "run":
+		xName = "Test Run"
+		for i := 1; i <= cfg.Repeat; i++ {
+			xAxis = append(xAxis, strconv.Itoa(i))
+		}
+		series = values
+	default:
+		xName = "Target"
+		xAxis = order
+		runs = nil
+		names = []string{spec.Aggregation + " " + spec.Metric}
+		for _, key := range order {
+			var xs []float64
+			for _, v := range values[key] {
+				if f, ok := v.(float64); ok {
+					xs = append(xs, f)
+				}
+			}
+			var cell interface{}
+			if len(xs) > 0 {
+				cell = round4(aggregate(xs, spec.Aggregation))
+			}
+			series[names[0]] = append(series[names[0]], cell)
+		}
+	}
+
+	global := []charts.GlobalOpts{
+		charts.WithTitleOpts(chartTitle(spec.Title, "")),
+		charts.WithYAxisOpts(opts.YAxis{Name: withUnit(spec.Metric), Type: map[string]string{"linear": "value", "log": "log"}[spec.Scale]}),
+		charts.WithXAxisOpts(opts.XAxis{Name: xName}),
+		charts.WithColorsOpts(seriesColors),
+	}
+	global = append(global, interactiveOpts(unitOf(spec.Metric))...)
+	if runs != nil {
+		global = append(global, pointTooltip(unitOf(spec.Metric)))
+	}
+	if byTime {
+		global = append(global, charts.WithXAxisOpts(opts.XAxis{Name: xName, Type: "time"}))
+	}
+	var seriesOpts []charts.SeriesOpts
+	if len(spec.marks) > 0 {
+		seriesOpts = append(seriesOpts, charts.WithMarkLineNameYAxisItemOpts(spec.marks...))
+	}
+	// the runs chaos disrupted are marked on the first series
+	var chaos []charts.SeriesOpts
+	if runs != nil {
+		chaos = chaosMarks(byTime)
+	}
+	optsFor := func(k int) []charts.SeriesOpts {
+		if k == 0 {
+			return append(append([]charts.SeriesOpts(nil), seriesOpts...), chaos...)
+		}
+		return seriesOpts
+	}
+	cell := func(v interface{}) interface{} {
+		if v == nil {
+			return "-"
+		}
+		return v
+	}
+	// anomalous points get their own symbol or colour, and say why in
+	// their tooltip
+	anomalies := map[string]map[int]string{}
+	if runs != nil {
+		for key, xs := range raw {
+			anomalies[key] = outliers(xs)
+		}
+	}
+	run := func(name string, i int) string {
+		if i >= len(runs[name]) {
+			return ""
+		}
+		if why, ok := anomalies[name][i]; ok {
+			return "⚠️ outlier: " + why + "<br/>" + runs[name][i]
+		}
+		return runs[name][i]
+	}
+	anomalous := func(name string, i int) bool {
+		_, ok := anomalies[name][i]
+		return ok
+	}
+
+	var chart interface{ Render(io.Writer) error }
+	switch spec.Type {
+	case "bar":
+		bar := charts.NewBar()
+		bar.SetGlobalOptions(global...)
+		if !byTime {
+			bar.SetXAxis(xAxis)
+		}
+		for k, name := range names {
+			var points []opts.BarData
+			for i, v := range series[name] {
+				p := opts.BarData{Name: run(name, i), Value: cell(v)}
+				if anomalous(name, i) {
+					p.ItemStyle = &opts.ItemStyle{Color: anomalyColor}
+				}
+				points = append(points, p)
+			}
+			bar.AddSeries(name, points, optsFor(k)...)
+		}
+		chart = bar
+	case "scatter":
+		scatter := charts.NewScatter()
+		scatter.SetGlobalOptions(global...)
+		if !byTime {
+			scatter.SetXAxis(xAxis)
+		}
+		for k, name := range names {
+			var points []opts.ScatterData
+			for i, v := range series[name] {
+				p := opts.ScatterData{Name: run(name, i), Value: cell(v)}
+				if anomalous(name, i) {
+					p.Symbol, p.SymbolSize = anomalySymbol, anomalySymbolSize
+				}
+				points = append(points, p)
+			}
+			scatter.AddSeries(name, points, optsFor(k)...)
+		}
+		chart = scatter
+	default:
+		line := charts.NewLine()
+		line.SetGlobalOptions(global...)
+		if !byTime {
+			line.SetXAxis(xAxis)
+		}
+		for k, name := range names {
+			var points []opts.LineData
+			for i, v := range series[name] {
+				p := opts.LineData{Name: run(name, i), Value: cell(v)}
+				if anomalous(name, i) {
+					p.Symbol, p.SymbolSize = anomalySymbol, anomalySymbolSize
+				}
+				points = append(points, p)
+			}
+			line.AddSeries(name, points, optsFor(k)...)
+		}
+		chart = line
+	}
+
+	writeChart(chart, filename)
+}
+
+// interactiveOpts are the options every chart shares so long series, a
+// soak test's especially, stay explorable: a zoom slider under the x axis
+// and mouse-wheel zoom over the plot, a toolbox to zoom to an area,
+// restore the view and save the chart as an image, and an axis tooltip
+// giving each value in unit along with the run it came from.
+func interactiveOpts(unit string) []charts.GlobalOpts {
+	return []charts.GlobalOpts{
+		charts.WithDataZoomOpts(opts.DataZoom{Type: "slider"}, opts.DataZoom{Type: "inside"}),
+		chartToolbox(),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis", Formatter: tooltipFormatter(unit)}),
+	}
+}
+
+func chartToolbox() charts.GlobalOpts {
+	return charts.WithToolboxOpts(opts.Toolbox{Show: opts.Bool(true), Feature: &opts.ToolBoxFeature{
+		SaveAsImage: &opts.ToolBoxFeatureSaveAsImage{Show: opts.Bool(true), Title: "save as image"},
+		DataZoom:    &opts.ToolBoxFeatureDataZoom{Show: opts.Bool(true), Title: map[string]string{"zoom": "zoom", "back": "undo zoom"}},
+		Restore:     &opts.ToolBoxFeatureRestore{Show: opts.Bool(true), Title: "restore"},
+	}})
+}
+
+// tooltipFormatter lists each series' value at the hovered point with its
+// unit, followed by the point's name, which per-run charts set to the
+// run's details. Gaps are left out. The function is inlined into the
+// chart's JSON, hence single quotes only.
+func tooltipFormatter(unit string) types.FuncStr {
+	if unit != "" {
+		unit = " " + unit
+	}
+	return opts.FuncOpts(`function (params) {
+		params = [].concat(params);
+		var out = params[0].axisValueLabel === undefined ? [] : [params[0].axisValueLabel];
+		params.forEach(function (p) {
+			var v = Array.isArray(p.value) ? p.value[1] : p.value;
+			if (v === undefined || v === null || v === '-') { return; }
+			var line = p.marker + p.seriesName + ': <b>' + v + '` + strings.ReplaceAll(unit, "'", "") + `</b>';
+			if (p.data && p.data.name) { line += '<br/><span style=color:#888>' + p.data.name + '</span>'; }
+			out.push(line);
+		});
+		return out.join('<br/>');
+	}`)
+}
+
+// pointTooltip replaces the axis tooltip of a per-run chart with one for
+// the hovered point alone, which the pointer can enter to follow the
+// links to the run's files.
+func pointTooltip(unit string) charts.GlobalOpts {
+	return charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "item", Enterable: opts.Bool(true), Formatter: tooltipFormatter(unit)})
+}
+
+// runDetail is a run's metadata as shown in its point's tooltip: its ID,
+// start time and concurrency, its errors and status codes, and links to
+// its summary and raw latencies, relative to the suite directory where
+// the charts are written.
+func runDetail(d HeyResult) string {
+	var parts []string
+	if d.RunID != "" {
+		parts = append(parts, html.EscapeString(d.RunID))
+	}
+	if !d.Started.IsZero() {
+		parts = append(parts, "started "+d.Started.Local().Format("2006-01-02 15:04:05"))
+	}
+	if d.Concurrency > 0 {
+		parts = append(parts, fmt.Sprintf("c=%d", d.Concurrency))
+	}
+	lines := []string{strings.Join(parts, " · ")}
+	if d.File == "" {
+		return lines[0]
+	}
+	if codes, errs, err := runStatuses(d.File); err == nil {
+		failed, total := errs, errs
+		var mix []string
+		var sorted []int
+		for code := range codes {
+			sorted = append(sorted, code)
+		}
+		sort.Ints(sorted)
+		for _, code := range sorted {
+			total += codes[code]
+			if code >= 400 {
+				failed += codes[code]
+			}
+			mix = append(mix, fmt.Sprintf("%d×%d", code, codes[code]))
+		}
+		if errs > 0 {
+			mix = append(mix, fmt.Sprintf("no response×%d", errs))
+		}
+		lines = append(lines, fmt.Sprintf("errors: %d of %d · %s", failed, total, strings.Join(mix, " ")))
+	}
+	links := []string{runLink(d.File, "summary")}
+	if d.RawFile != "" {
+		links = append(links, runLink(d.RawFile, "raw latencies"))
+	}
+	lines = append(lines, strings.Join(links, " · "))
+	return strings.Join(lines, "<br/>")
+}
+
+// runLink links a run's file from a chart in the suite directory.
+func runLink(file, text string) string {
+	return `<a href="` + html.EscapeString(runHref(file)) + `" target="_blank">` + text + "</a>"
+}
+
+// runHref is the path of a run's file in outDir relative to the suite
+// directory, where the charts and reports are written.
+func runHref(file string) string {
+	href := filepath.Join(outDir, file)
+	if rel, err := filepath.Rel(suiteDir, href); err == nil && suiteDir != "" {
+		href = rel
+	}
+	return filepath.ToSlash(href)
+}
+
+// How charts mark outliers: bars are coloured, points drawn as pins.
+const (
+	anomalyColor      = "#d94e5d"
+	anomalySymbol     = "pin"
+	anomalySymbolSize = 22
+)
+
+// renderChart writes chart to filename unless --charts leaves it out.
+func renderChart(chart interface{ Render(io.Writer) error }, filename string) {
+	if chartWanted(chartName(filename)) {
+		writeChart(chart, filename)
+	}
+}
+
+func writeChart(chart interface{ Render(io.Writer) error }, filename string) {
+	f, _ := os.Create(filename)
+	defer f.Close()
+	chart.Render(f)
+	fmt.Printf("✅ Chart written to %s\n", filename)
+}
+
+// splitList reads a comma-separated flag, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
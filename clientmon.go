@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The load generator counts as saturated during a run when its machine's
+// CPU averaged clientCPULimit percent, its memory peaked at clientMemLimit
+// percent used, or its open files or ephemeral ports reached
+// clientShareLimit percent of what's available. Beyond those, a run
+// measures the client as much as the target.
+const (
+	clientCPULimit   = 85.0
+	clientMemLimit   = 90.0
+	clientShareLimit = 80.0
+)
+
+const clientSampleEvery = 500 * time.Millisecond
+
+// clientUsage is the load generator's own resource use over one run.
+type clientUsage struct {
+	cpu, peakCPU float64 // percent of all cores, mean and peak
+	mem          float64 // peak percent of memory in use
+	fds, fdLimit int     // peak open files of this process and its children (hey)
+	ports        int     // peak sockets on an ephemeral local port
+	portRange    int
+}
+
+// clientMonitor samples the machine every clientSampleEvery while a run
+// is in flight. It reads /proc, so it's only started on Linux.
+type clientMonitor struct {
+	done  chan struct{}
+	wg    sync.WaitGroup
+	usage clientUsage
+	cpus  []float64
+}
+
+// clientMonitored reports whether runs sample the client: only on Linux,
+// and not when the load is generated elsewhere, by agents or over SSH.
+func clientMonitored() bool {
+	return runtime.GOOS == "linux" && len(agents) == 0 && len(sshHosts) == 0
+}
+
+// startClientMonitor starts sampling, or returns nil when the client isn't
+// monitored.
+func startClientMonitor() *clientMonitor {
+	if !clientMonitored() {
+		return nil
+	}
+	m := &clientMonitor{done: make(chan struct{})}
+	m.usage.fdLimit = openFileLimit()
+	m.usage.portRange = ephemeralPortRange()
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		busy, total := cpuTimes()
+		last := time.Now()
+		sample := func() {
+			// CPU time is counted in jiffies, too coarse for a sliver
+			b, t := cpuTimes()
+			if t > total && time.Since(last) >= clientSampleEvery/5 {
+				m.cpus = append(m.cpus, 100*float64(b-busy)/float64(t-total))
+				busy, total, last = b, t, time.Now()
+			}
+			m.usage.mem = max(m.usage.mem, memoryUsed())
+			m.usage.fds = max(m.usage.fds, openFiles())
+			m.usage.ports = max(m.usage.ports, ephemeralPortsInUse())
+		}
+		tick := time.NewTicker(clientSampleEvery)
+		defer tick.Stop()
+		for {
+			select {
+			case <-m.done:
+				sample() // a run shorter than a tick still gets one
+				return
+			case <-tick.C:
+				sample()
+			}
+		}
+	}()
+	return m
+}
+
+// stop ends sampling and returns the run's usage.
+func (m *clientMonitor) stop() clientUsage {
+	close(m.done)
+	m.wg.Wait()
+	u := m.usage
+	u.cpu = mean(m.cpus)
+	for _, c := range m.cpus {
+		u.peakCPU = max(u.peakCPU, c)
+	}
+	return u
+}
+
+// saturated lists the limits the client reached, if any.
+func (u clientUsage) saturated() []string {
+	var out []string
+	if u.cpu >= clientCPULimit {
+		out = append(out, fmt.Sprintf("CPU averaged %.0f%%", u.cpu))
+	}
+	if u.mem >= clientMemLimit {
+		out = append(out, fmt.Sprintf("memory peaked at %.0f%% used", u.mem))
+	}
+	if u.fdLimit > 0 && 100*float64(u.fds)/float64(u.fdLimit) >= clientShareLimit {
+		out = append(out, fmt.Sprintf("%d of %d open files", u.fds, u.fdLimit))
+	}
+	if u.portRange > 0 && 100*float64(u.ports)/float64(u.portRange) >= clientShareLimit {
+		out = append(out, fmt.Sprintf("%d of %d ephemeral ports", u.ports, u.portRange))
+	}
+	return out
+}
+
+// addTo stores the usage in a result row.
+func (u clientUsage) addTo(row map[string]string) {
+	if u.peakCPU > 0 {
+		row["client_cpu"] = fmt.Sprintf("%.1f", u.cpu)
+	}
+	row["client_mem"] = fmt.Sprintf("%.1f", u.mem)
+	row["client_fds"] = strconv.Itoa(u.fds)
+	row["client_ports"] = strconv.Itoa(u.ports)
+}
+
+// clientColumns are the result columns of clientUsage.
+var clientColumns = []string{"client_cpu", "client_mem", "client_fds", "client_ports"}
+
+// saturatedRuns lists the runs during which the client was saturated,
+// for the report.
+var saturatedRuns [][]string
+
+func recordClientSaturation(label string, i int, u clientUsage) {
+	limits := u.saturated()
+	if len(limits) == 0 {
+		return
+	}
+	fmt.Printf("⚠️  The load generator was saturated during run %d of %s: %s\n", i, label, strings.Join(limits, ", "))
+	saturatedRuns = append(saturatedRuns, []string{label, strconv.Itoa(i), strings.Join(limits, ", ")})
+}
+
+// reportClientSaturation warns that the saturated runs' results may
+// reflect the client's limits rather than the targets'.
+func reportClientSaturation() {
+	if len(saturatedRuns) == 0 {
+		return
+	}
+	addReportSection("Client saturation",
+		fmt.Sprintf("⚠️ The load generator itself ran out of headroom during these runs, so their throughput and latency may reflect its limits rather than the target's, "+
+			"and a \"winner\" may just be the target measured while the client had more to spare. "+
+			"Rerun with fewer workers, or spread the load over --agents. Saturated is a mean CPU of %.0f%%, a peak of %.0f%% of memory, or %.0f%% of open files or ephemeral ports.\n\n",
+			clientCPULimit, clientMemLimit, clientShareLimit)+
+			markdownTable([]string{"target", "run", "limits reached"}, saturatedRuns))
+}
+
+// cpuTimes reads the machine's busy and total CPU time, in jiffies, from
+// /proc/stat; iowait counts as idle.
+func cpuTimes() (busy, total uint64) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		return 0, 0
+	}
+	fields := strings.Fields(sc.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0
+	}
+	for k, field := range fields[1:] {
+		v, _ := strconv.ParseUint(field, 10, 64)
+		total += v
+		if k != 3 && k != 4 { // idle, iowait
+			busy += v
+		}
+	}
+	return busy, total
+}
+
+// memoryUsed is the percentage of memory not available, from
+// /proc/meminfo.
+func memoryUsed() float64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	var total, available float64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, _ := strconv.ParseFloat(fields[1], 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = v
+		case "MemAvailable:":
+			available = v
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 100 * (total - available) / total
+}
+
+// openFiles counts the open files of this process and its children,
+// which is where hey runs.
+func openFiles() int {
+	self := os.Getpid()
+	n := countFiles(self)
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, stat := range stats {
+		raw, err := os.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		// the parent pid is the second field after the parenthesised name
+		_, rest, ok := strings.Cut(string(raw), ") ")
+		if fields := strings.Fields(rest); ok && len(fields) > 1 && fields[1] == strconv.Itoa(self) {
+			pid, _ := strconv.Atoi(filepath.Base(filepath.Dir(stat)))
+			n += countFiles(pid)
+		}
+	}
+	return n
+}
+
+func countFiles(pid int) int {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// openFileLimit is this process's soft limit on open files, which hey
+// inherits.
+func openFileLimit() int {
+	f, err := os.Open("/proc/self/limits")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if rest, ok := strings.CutPrefix(sc.Text(), "Max open files"); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				n, _ := strconv.Atoi(fields[0])
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+var portLow, portHigh int
+
+// ephemeralPortRange is the size of the local port range outgoing
+// connections are given ports from.
+func ephemeralPortRange() int {
+	raw, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) != 2 {
+		return 0
+	}
+	portLow, _ = strconv.Atoi(fields[0])
+	portHigh, _ = strconv.Atoi(fields[1])
+	return portHigh - portLow + 1
+}
+
+// ephemeralPortsInUse counts the machine's TCP sockets on a local port in
+// the ephemeral range, TIME_WAIT ones included, as they hold their port.
+func ephemeralPortsInUse() int {
+	n := 0
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Scan() // header
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			port, err := strconv.ParseUint(hexPort, 16, 32)
+			if ok && err == nil && int(port) >= portLow && int(port) <= portHigh {
+				n++
+			}
+		}
+		f.Close()
+	}
+	return n
+}
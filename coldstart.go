@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// ColdStart makes every run a probe: the target is left idle for idle,
+// long enough for a serverless deployment to scale to zero, then sent one
+// request, the cold one, and warm_requests more straight after, the warm
+// ones. Each run is one probe, so repeat is how many are taken.
+type ColdStart struct {
+	Idle         string `json:"idle"` // default 5m
+	WarmRequests int    `json:"warm_requests"`
+
+	idle time.Duration
+}
+
+func validateColdStart(c *ColdStart) error {
+	if c.Idle == "" {
+		c.Idle = "5m"
+	}
+	var err error
+	if c.idle, err = time.ParseDuration(c.Idle); err != nil || c.idle <= 0 {
+		return fmt.Errorf("invalid idle %q", c.Idle)
+	}
+	if c.WarmRequests == 0 {
+		c.WarmRequests = 10
+	}
+	if c.WarmRequests < 1 {
+		return fmt.Errorf("warm_requests must be at least 1")
+	}
+	return nil
+}
+
+// runColdStart idles, then sends the cold request to the first target
+// over a fresh connection and the warm ones, one at a time, to the
+// targets by weight. The cold sample's offset is exactly zero.
+func runColdStart(targets []Target, c *ColdStart, opts nativeOptions) nativeRun {
+	fmt.Printf("→ Idling %v before the cold request\n", c.idle)
+	time.Sleep(c.idle)
+	client := newVUClient(newNativeTransport(1, opts), timeoutFor(targets[0]), opts)
+	pick := weightedPicker(targets, opts)
+
+	start := time.Now()
+	run := nativeRun{samples: []sample{doRequest(client, targets[0])}}
+	for k := 0; k < c.WarmRequests; k++ {
+		ti := pick(int64(k))
+		at := time.Since(start)
+		s := doRequest(client, targets[ti])
+		s.target = ti
+		s.offset = at
+		run.samples = append(run.samples, s)
+	}
+	run.total = time.Since(start)
+	return run
+}
+
+var (
+	coldStartMu     sync.Mutex
+	coldLatencies   = map[string][]float64{} // milliseconds, one per probe
+	warmLatencies   = map[string][]float64{}
+	coldStartSeries []string
+)
+
+// recordColdStart files a probe's samples under series key.
+func recordColdStart(key string, samples []sample) {
+	coldStartMu.Lock()
+	defer coldStartMu.Unlock()
+	if _, ok := warmLatencies[key]; !ok {
+		coldStartSeries = append(coldStartSeries, key)
+		warmLatencies[key] = nil
+	}
+	for _, s := range samples {
+		if s.offset == 0 {
+			coldLatencies[key] = append(coldLatencies[key], millis(s.latency))
+		} else {
+			warmLatencies[key] = append(warmLatencies[key], millis(s.latency))
+		}
+	}
+}
+
+// analyzeColdStart reports each target's cold and warm latency and the
+// cold-start penalty, and charts them side by side.
+func analyzeColdStart(filename string) {
+	if len(coldStartSeries) == 0 {
+		return
+	}
+	var table [][]string
+	var shown []string
+	var cold, warm []opts.BarData
+	for _, key := range coldStartSeries {
+		cs, ws := coldLatencies[key], warmLatencies[key]
+		row := []string{key, fmt.Sprint(len(cs)), "–", "–", "–", fmt.Sprintf("%.4g", inUnit(quantile(ws, 0.5))), fmt.Sprintf("%.4g", inUnit(quantile(ws, 0.95))), "–"}
+		if len(cs) > 0 {
+			row[2] = fmt.Sprintf("%.4g", inUnit(quantile(cs, 0.5)))
+			row[3] = fmt.Sprintf("%.4g", inUnit(mean(cs)))
+			row[4] = fmt.Sprintf("%.4g", inUnit(quantile(cs, 1)))
+			if w := quantile(ws, 0.5); w > 0 {
+				penalty := quantile(cs, 0.5) - w
+				row[7] = fmt.Sprintf("%+.4g %s (%.1f×)", inUnit(penalty), displayUnit, quantile(cs, 0.5)/w)
+				fmt.Printf("→ %s: cold start %+.4g %s over warm\n", key, inUnit(penalty), displayUnit)
+			}
+		}
+		table = append(table, row)
+		if !seriesWanted(key) {
+			continue
+		}
+		shown = append(shown, key)
+		c := opts.BarData{Name: fmt.Sprintf("%d probes", len(cs)), Value: "-"}
+		if len(cs) > 0 {
+			c.Value = round4(inUnit(quantile(cs, 0.5)))
+		}
+		cold = append(cold, c)
+		warm = append(warm, opts.BarData{Name: fmt.Sprintf("%d requests", len(ws)), Value: round4(inUnit(quantile(ws, 0.5)))})
+	}
+	addReportSection("Cold starts",
+		fmt.Sprintf("Each probe idled %v, then sent one cold request and %d warm ones. Latencies in %s.\n\n", cfg.ColdStart.idle, cfg.ColdStart.WarmRequests, displayUnit)+
+			markdownTable([]string{"target", "probes", "cold median", "cold mean", "cold max", "warm median", "warm p95", "cold-start penalty (median)"}, table))
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Cold vs Warm Latency", fmt.Sprintf("median, after %v idle", cfg.ColdStart.idle))),
+		charts.WithYAxisOpts(opts.YAxis{Name: displayUnit}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Target"}),
+	)
+	bar.SetGlobalOptions(interactiveOpts(displayUnit)...)
+	bar.SetXAxis(shown)
+	bar.AddSeries("cold", cold, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[3]}))
+	bar.AddSeries("warm", warm, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[0]}))
+	renderChart(bar, filename)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// seriesColors gives charts 20 distinguishable series colours, more than
+// echarts' default nine, so suites with many targets don't repeat colours.
+var seriesColors = opts.Colors{
+	"#5470c6", "#91cc75", "#fac858", "#ee6666", "#73c0de", "#3ba272", "#fc8452", "#9a60b4", "#ea7ccc", "#2f4554",
+	"#c23531", "#61a0a8", "#d48265", "#749f83", "#ca8622", "#bda29a", "#6e7074", "#546570", "#c4ccd3", "#1f77b4",
+}
+
+// analyzeComparison compares every target against every other one that
+// ran the same route (at the same concurrency in a sweep): a summary per
+// target, then for each metric a matrix of how much the row's mean differs
+// from the column's, starred where Welch's t-test finds the difference
+// significant at p < 0.05.
+func analyzeComparison(rows []map[string]string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	metrics := []string{"requests_per_sec", latency}
+
+	type group struct {
+		targets []string
+		values  map[string]map[string][]float64 // target -> metric -> runs
+	}
+	groups := map[string]*group{}
+	var order []string
+	for _, row := range rows {
+		if row["agent"] != "" {
+			continue
+		}
+		ctx := row["route"]
+		if len(cfg.Sweep) > 1 {
+			ctx = strings.TrimSpace(ctx + " c=" + row["concurrency"])
+		}
+		g, ok := groups[ctx]
+		if !ok {
+			g = &group{values: map[string]map[string][]float64{}}
+			groups[ctx] = g
+			order = append(order, ctx)
+		}
+		if _, ok := g.values[row["target"]]; !ok {
+			g.targets = append(g.targets, row["target"])
+			g.values[row["target"]] = map[string][]float64{}
+		}
+		for _, m := range metrics {
+			if v, ok := rowFloat(row, m); ok {
+				g.values[row["target"]][m] = append(g.values[row["target"]][m], v)
+			}
+		}
+	}
+
+	var b strings.Builder
+	var compared []string
+	for _, ctx := range order {
+		g := groups[ctx]
+		if len(g.targets) < 2 {
+			continue
+		}
+		if ctx != "" {
+			fmt.Fprintf(&b, "### %s\n\n", ctx)
+		}
+		var summary [][]string
+		for _, t := range g.targets {
+			line := []string{t, strconv.Itoa(len(g.values[t][metrics[0]]))}
+			for _, m := range metrics {
+				line = append(line, fmt.Sprintf("%.2f", displayValue(m, mean(g.values[t][m]))))
+			}
+			summary = append(summary, line)
+		}
+		b.WriteString(markdownTable([]string{"target", "runs", "rps", withUnit(latency)}, summary) + "\n")
+
+		for _, m := range metrics {
+			name := withUnit(m)
+			if m == "requests_per_sec" {
+				name = "rps"
+			}
+			fmt.Fprintf(&b, "Δ %s, row vs column:\n\n", name)
+			var table [][]string
+			for _, a := range g.targets {
+				line := []string{a}
+				for _, c := range g.targets {
+					if a == c {
+						line = append(line, "—")
+						continue
+					}
+					line = append(line, deltaCell(g.values[a][m], g.values[c][m]))
+				}
+				table = append(table, line)
+			}
+			b.WriteString(markdownTable(append([]string{""}, g.targets...), table) + "\n")
+		}
+		compared = append(compared, g.targets...)
+	}
+	if b.Len() == 0 {
+		return
+	}
+	b.WriteString(transientNarrative(compared))
+	addReportSection("Comparison matrix", "Differences between the targets' mean results; * marks a significant difference (Welch's t-test, p < 0.05).\n\n"+b.String())
+}
+
+// deltaCell formats how much mean(a) differs from mean(b), in percent.
+func deltaCell(a, b []float64) string {
+	if len(a) == 0 || len(b) == 0 || mean(b) == 0 {
+		return "n/a"
+	}
+	cell := fmt.Sprintf("%+.1f%%", 100*(mean(a)-mean(b))/mean(b))
+	if p := welchP(a, b); !math.IsNaN(p) && p < 0.05 {
+		cell += " *"
+	}
+	return cell
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// openOutput opens a hey output or raw file, decompressing it when it was
+// gzipped by --gzip.
+func openOutput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return gzipFile{gz, f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// compressOutput replaces path with path.gz when --gzip is set and returns
+// the name the file now has.
+func compressOutput(path string) (string, error) {
+	if !*gzipOutputs {
+		return path, nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return path, err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return path, err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return path, err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return path, err
+	}
+	if err := dst.Close(); err != nil {
+		return path, err
+	}
+	src.Close()
+	return path + ".gz", os.Remove(path)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config holds the suite settings. Defaults match the constants the tool
+// has always used; PERTOOLS_ environment variables override them, a JSON
+// file passed with --config overrides those and explicit flags override
+// the file.
+type Config struct {
+	URLs         []string                   `json:"urls"`
+	Repeat       int                        `json:"repeat"`
+	Requests     int                        `json:"requests"`
+	Concurrency  int                        `json:"concurrency"`
+	Percentiles  []float64                  `json:"percentiles"`
+	Sweep        []int                      `json:"sweep"`
+	SLO          string                     `json:"slo"`
+	Thresholds   []string                   `json:"thresholds"`
+	Rate         float64                    `json:"rate"`
+	Store        string                     `json:"store"`
+	Schedules    []Schedule                 `json:"schedules"`
+	Labels       map[string]string          `json:"labels"`
+	Fingerprint  string                     `json:"fingerprint"`
+	CSV          CSVDialect                 `json:"csv"`
+	Units        string                     `json:"units"`
+	Overrides    map[string]Override        `json:"overrides"`
+	CIWidth      float64                    `json:"ci_width"`
+	CIMetrics    []string                   `json:"ci_metrics"`
+	MinRepeat    int                        `json:"min_repeat"`
+	Cooldown     string                     `json:"cooldown"`
+	Cutover      *Cutover                   `json:"cutover"`
+	Charts       []ChartSpec                `json:"charts"`
+	Capacity     *Capacity                  `json:"capacity"`
+	Autoscale    *Autoscale                 `json:"autoscale"`
+	ColdStart    *ColdStart                 `json:"cold_start"`
+	Chaos        []ChaosAction              `json:"chaos"`
+	Stages       []Stage                    `json:"stages"`
+	Warmup       int                        `json:"warmup"`
+	Profiles     map[string]Profile         `json:"profiles"`
+	Redact       []string                   `json:"redact"`
+	Environments map[string]json.RawMessage `json:"environments"`
+	ErrorBudget  *ErrorBudget               `json:"error_budget"`
+	Exporters    []Exporter                 `json:"exporters"`
+	ExporterDirs []string                   `json:"exporter_dirs"`
+	Webhooks     []Webhook                  `json:"webhooks"`
+	MQTT         *MQTT                      `json:"mqtt"`
+}
+
+// Override replaces the suite's load parameters for the targets whose
+// name or URL it's keyed by, e.g. so a smaller deployment gets lower
+// concurrency than the rest instead of simply being overloaded.
+type Override struct {
+	Requests    int    `json:"requests"`
+	Concurrency int    `json:"concurrency"`
+	Timeout     string `json:"timeout"`
+	Method      string `json:"method"`
+	Cooldown    string `json:"cooldown"`
+}
+
+var cfg = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		URLs:        append([]string(nil), urls...), // a copy, or decoding a config would overwrite urls
+		Repeat:      repeat,
+		Requests:    requestCounter,
+		Concurrency: worker,
+		Percentiles: []float64{50, 75, 90, 95, 99},
+		CIMetrics:   []string{"rps", "p95"},
+		MinRepeat:   3,
+		Cooldown:    "1s",
+	}
+}
+
+func loadConfig(path string) (Config, error) {
+	c := defaultConfig()
+	if err := applyEnvConfig(&c); err != nil {
+		return c, err
+	}
+	if path == "" {
+		return c, c.sortPercentiles()
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, positionError(path, raw, err)
+	}
+	var problems []string
+	for _, issue := range checkConfig(raw, c) {
+		if issue.warning {
+			fmt.Println("⚠️ ", issue.format(path))
+		} else {
+			problems = append(problems, issue.format(path))
+		}
+	}
+	if len(problems) > 0 {
+		return c, fmt.Errorf("%d problems in %s:\n  %s", len(problems), path, strings.Join(problems, "\n  "))
+	}
+	return c, c.sortPercentiles()
+}
+
+// sortPercentiles sorts and dedups c's percentiles, as
+// parsePercentiles does the flag's, so every column and table lists them
+// once and in order.
+func (c *Config) sortPercentiles() error {
+	var err error
+	if c.Percentiles, err = normalizePercentiles(c.Percentiles); err != nil {
+		return fmt.Errorf("percentiles: %w", err)
+	}
+	return nil
+}
+
+// parsePercentiles reads "50,95,99.9" (a leading p is allowed) into a
+// sorted, de-duplicated list.
+func parsePercentiles(s string) ([]float64, error) {
+	var ps []float64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "p")
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q", part)
+		}
+		ps = append(ps, v)
+	}
+	return normalizePercentiles(ps)
+}
+
+// normalizePercentiles checks every p is within (0, 100) and returns them
+// sorted, without duplicates.
+func normalizePercentiles(ps []float64) ([]float64, error) {
+	var out []float64
+	seen := map[float64]bool{}
+	for _, p := range ps {
+		if !(p > 0 && p < 100) {
+			return nil, fmt.Errorf("invalid percentile %v, want 0 < p < 100", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Float64s(out)
+	return out, nil
+}
+
+func parseIntList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid value %q, want a positive integer", part)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// percentileKey names a percentile column, e.g. 99.9 -> "p99.9".
+func percentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// heyPercentiles are the ones hey's text summary reports; any other
+// percentile needs --raw or the native engine.
+var heyPercentiles = []float64{10, 25, 50, 75, 90, 95, 99}
+
+func isHeyPercentile(p float64) bool {
+	for _, h := range heyPercentiles {
+		if h == p {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryPercentiles is what native summaries print: hey's set, so the
+// files stay hey-compatible, plus every configured percentile.
+func summaryPercentiles() []float64 {
+	return mergePercentiles(heyPercentiles, cfg.Percentiles)
+}
+
+func mergePercentiles(a, b []float64) []float64 {
+	seen := map[float64]bool{}
+	var out []float64
+	for _, p := range append(append([]float64{}, a...), b...) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Float64s(out)
+	return out
+}
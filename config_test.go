@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []float64
+	}{
+		{"50,95,99", []float64{50, 95, 99}},
+		{"p99, p50 ,99.9", []float64{50, 99, 99.9}},
+		{"99,50,50", []float64{50, 99}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := parsePercentiles(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePercentiles(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"0", "100", "150", "-1", "nan", "x", "99,150"} {
+		if got, err := parsePercentiles(in); err == nil {
+			t.Errorf("parsePercentiles(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestLoadConfigPercentiles(t *testing.T) {
+	load := func(body string) (Config, error) {
+		path := filepath.Join(t.TempDir(), "perf.json")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return loadConfig(path)
+	}
+	c, err := load(`{"percentiles": [99, 50, 50, 95]}`)
+	if want := []float64{50, 95, 99}; err != nil || !reflect.DeepEqual(c.Percentiles, want) {
+		t.Errorf("percentiles [99, 50, 50, 95] loaded as %v, %v, want %v", c.Percentiles, err, want)
+	}
+	if c, err := load(`{"percentiles": [99, 50, 50, 150]}`); err == nil {
+		t.Errorf("percentiles [99, 50, 50, 150] loaded as %v, want an error", c.Percentiles)
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configIssue is a problem in a config file, at its line and column when
+// the offending key is in the file rather than a default.
+type configIssue struct {
+	path      string // e.g. urls[1] or overrides.staging.concurrency
+	line, col int
+	msg       string
+	warning   bool // reported, but the config still loads
+}
+
+func (i configIssue) format(file string) string {
+	if i.line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", file, i.line, i.col, i.path, i.msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", file, i.path, i.msg)
+}
+
+// lineCol turns a byte offset in raw into a 1-based line and column.
+func lineCol(raw []byte, off int64) (int, int) {
+	if off > int64(len(raw)) {
+		off = int64(len(raw))
+	}
+	before := raw[:off]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, int(off) - bytes.LastIndexByte(before, '\n')
+}
+
+// positionError adds the line and column to a decoding error.
+func positionError(file string, raw []byte, err error) error {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		line, col := lineCol(raw, syntax.Offset)
+		return fmt.Errorf("%s:%d:%d: %v", file, line, col, err)
+	case errors.As(err, &typ):
+		line, col := lineCol(raw, typ.Offset)
+		return fmt.Errorf("%s:%d:%d: %s: want %s, got a JSON %s", file, line, col, typ.Field, typ.Type, typ.Value)
+	}
+	return fmt.Errorf("parse %s: %w", file, err)
+}
+
+// configWalker reads a config's tokens alongside the type they decode
+// into, noting where each key and list element starts and which keys
+// match no field. The walk can start base bytes into raw, at an
+// environment's overlay; offsets are from the start of raw all the same.
+type configWalker struct {
+	raw    []byte
+	base   int64
+	dec    *json.Decoder
+	pos    map[string]int64
+	issues []configIssue
+}
+
+// next skips the whitespace and separators before the next token and
+// returns its offset.
+func (w *configWalker) next() int64 {
+	off := w.base + w.dec.InputOffset()
+	for off < int64(len(w.raw)) && strings.IndexByte(" \t\r\n,:", w.raw[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+func (w *configWalker) walk(t reflect.Type, path string) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() {
+			off := w.next()
+			keyTok, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			var elem reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				elem = t.Elem()
+			case t.Kind() == reflect.Struct:
+				elem, key = w.field(t, key, path, off)
+			}
+			sub := key
+			if path != "" {
+				sub = path + "." + key
+			}
+			w.pos[sub] = off
+			if err := w.walk(elem, sub); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for k := 0; w.dec.More(); k++ {
+			sub := fmt.Sprintf("%s[%d]", path, k)
+			w.pos[sub] = w.next()
+			if err := w.walk(elem, sub); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	_, err = w.dec.Token() // the closing delimiter
+	return err
+}
+
+// field finds key among struct t's JSON fields, as the decoder does, and
+// returns its type and name. It reports a key matching none, which the
+// decoder would silently drop.
+func (w *configWalker) field(t reflect.Type, key, path string, off int64) (reflect.Type, string) {
+	if path != "" {
+		path += "."
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f.Type, name
+		}
+		if strings.EqualFold(name, key) {
+			line, col := lineCol(w.raw, off)
+			w.issues = append(w.issues, configIssue{path: path + key, line: line, col: col, warning: true,
+				msg: fmt.Sprintf("matched %q regardless of case; write it as %q", name, name)})
+			return f.Type, name
+		}
+		names = append(names, name)
+	}
+	msg := "unknown key, ignored"
+	if guess := closestName(key, names); guess != "" {
+		msg += fmt.Sprintf("; did you mean %q?", guess)
+	}
+	line, col := lineCol(w.raw, off)
+	w.issues = append(w.issues, configIssue{path: path + key, line: line, col: col, msg: msg})
+	return nil, key
+}
+
+// closestName is the name within a couple of edits of s, if any.
+func closestName(s string, names []string) string {
+	best, bestDist := "", 3
+	for _, n := range names {
+		if d := editDistance(s, n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// checkConfig validates c as decoded from raw: unknown keys, values that
+// can't work and settings that contradict each other, positioned at the
+// keys in raw. It runs before any load is generated, so a typo doesn't
+// cost a suite. Every environment is checked too, as the config it makes
+// of c, reporting what its overlay adds to c's problems.
+func checkConfig(raw []byte, c Config) []configIssue {
+	issues, pos := checkConfigAt(raw, 0, c)
+	for _, name := range environmentNames(c) {
+		path := "environments." + name
+		var line, col int
+		if off, ok := pos[path]; ok {
+			line, col = lineCol(raw, off)
+		}
+		env, err := c.environment(name)
+		if err != nil {
+			issues = append(issues, configIssue{path: path, line: line, col: col, msg: err.Error()})
+			continue
+		}
+		known := map[string]bool{}
+		for _, i := range issues {
+			known[i.path+": "+i.msg] = true
+		}
+		envIssues, _ := checkConfigAt(raw, valueOffset(raw, pos[path]), env)
+		for _, i := range envIssues {
+			if known[i.path+": "+i.msg] {
+				continue
+			}
+			if i.line == 0 {
+				i.line, i.col = line, col
+			}
+			i.path = path + "." + i.path
+			issues = append(issues, i)
+		}
+	}
+	// in file order, those without a position last
+	sort.SliceStable(issues, func(a, b int) bool {
+		la, lb := issues[a].line, issues[b].line
+		return la > 0 && (lb == 0 || la < lb || la == lb && issues[a].col < issues[b].col)
+	})
+	return issues
+}
+
+// valueOffset is where the value of the key at off starts.
+func valueOffset(raw []byte, off int64) int64 {
+	dec := json.NewDecoder(bytes.NewReader(raw[off:]))
+	if _, err := dec.Token(); err != nil {
+		return off
+	}
+	off += dec.InputOffset()
+	for off < int64(len(raw)) && strings.IndexByte(" \t\r\n:", raw[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// checkConfigAt checks c as decoded from the JSON object base bytes into
+// raw, returning its issues and where its keys are.
+func checkConfigAt(raw []byte, base int64, c Config) ([]configIssue, map[string]int64) {
+	w := &configWalker{raw: raw, base: base, dec: json.NewDecoder(bytes.NewReader(raw[base:])), pos: map[string]int64{}}
+	if err := w.walk(reflect.TypeOf(c), ""); err != nil && err != io.EOF {
+		return []configIssue{{path: "config", msg: err.Error()}}, w.pos
+	}
+	issues := w.issues
+	add := func(path string, warning bool, format string, args ...interface{}) {
+		i := configIssue{path: path, msg: fmt.Sprintf(format, args...), warning: warning}
+		if off, ok := w.pos[path]; ok {
+			i.line, i.col = lineCol(raw, off)
+		}
+		issues = append(issues, i)
+	}
+	inFile := func(key string) bool {
+		_, ok := w.pos[key]
+		return ok
+	}
+
+	for k, u := range c.URLs {
+		if err := checkTargetURL(u); err != nil {
+			add(fmt.Sprintf("urls[%d]", k), false, "%q: %v", u, err)
+		}
+	}
+	for _, n := range []struct {
+		key string
+		v   int
+	}{{"repeat", c.Repeat}, {"requests", c.Requests}, {"concurrency", c.Concurrency}} {
+		if n.v <= 0 {
+			add(n.key, false, "must be positive, got %d", n.v)
+		}
+	}
+	if c.Requests > 0 && c.Concurrency > c.Requests && len(c.Sweep) == 0 {
+		add("requests", false, "%d requests can't keep %d workers busy; raise requests or lower concurrency", c.Requests, c.Concurrency)
+	}
+	for k, level := range c.Sweep {
+		if level <= 0 {
+			add(fmt.Sprintf("sweep[%d]", k), false, "must be positive, got %d", level)
+		} else if c.Requests > 0 && level > c.Requests {
+			add(fmt.Sprintf("sweep[%d]", k), false, "%d workers would outnumber the %d requests", level, c.Requests)
+		}
+	}
+	if len(c.Sweep) > 0 && inFile("concurrency") {
+		add("concurrency", true, "ignored: sweep sets the concurrency")
+	}
+	seenPercentile := map[float64]bool{}
+	for k, p := range c.Percentiles {
+		switch {
+		case !(p > 0 && p < 100):
+			add(fmt.Sprintf("percentiles[%d]", k), false, "%v isn't a percentile, want 0 < p < 100", p)
+		case seenPercentile[p]:
+			add(fmt.Sprintf("percentiles[%d]", k), true, "%v is listed twice", p)
+		}
+		seenPercentile[p] = true
+	}
+	if _, err := parseSLO(c.SLO); err != nil {
+		add("slo", false, "%v", err)
+	}
+	for k, t := range c.Thresholds {
+		if _, err := parseThreshold(t); err != nil {
+			add(fmt.Sprintf("thresholds[%d]", k), false, "%v", err)
+		}
+	}
+	if c.Units != "" {
+		if err := validateUnits(c.Units); err != nil {
+			add("units", false, "%v", err)
+		}
+	}
+	if err := c.CSV.validate(); err != nil {
+		add("csv", false, "%v", err)
+	}
+	if _, err := parseCooldown(c.Cooldown); err != nil {
+		add("cooldown", false, "%v", err)
+	}
+	if c.Rate < 0 {
+		add("rate", false, "must be positive, got %v", c.Rate)
+	}
+	if c.CIWidth < 0 || c.CIWidth >= 1 {
+		add("ci_width", false, "want a fraction of the mean, e.g. 0.05, got %v", c.CIWidth)
+	}
+	if c.CIWidth > 0 && c.MinRepeat > c.Repeat {
+		add("min_repeat", false, "%d is more than repeat, %d", c.MinRepeat, c.Repeat)
+	}
+	if c.CIWidth == 0 && inFile("min_repeat") && c.MinRepeat != defaultConfig().MinRepeat {
+		add("min_repeat", true, "ignored unless ci_width or --ci-width is set")
+	}
+	switch {
+	case c.Autoscale != nil && c.ColdStart != nil:
+		add("cold_start", false, "can't run with autoscale; both set their own load")
+	case c.Rate > 0 && (c.Autoscale != nil || c.ColdStart != nil):
+		add("rate", false, "can't run with autoscale or cold_start, which set their own load")
+	}
+	if c.Warmup > 0 && (c.Autoscale != nil || c.ColdStart != nil) {
+		add("warmup", false, "would spoil autoscale and cold_start, which set their own load")
+	}
+	if c.ErrorBudget != nil {
+		if c.SLO == "" {
+			add("error_budget", false, "needs an slo, which the budget is taken from")
+		}
+		if _, _, err := budgetWindows(c.ErrorBudget); err != nil {
+			add("error_budget", false, "%v", err)
+		}
+	}
+	for k, s := range c.Schedules {
+		if _, err := parseCron(s.Cron); err != nil {
+			add(fmt.Sprintf("schedules[%d].cron", k), false, "%v", err)
+		}
+	}
+	for k, s := range c.Stages {
+		if s.Concurrency > 0 && len(s.Sweep) > 0 {
+			add(fmt.Sprintf("stages[%d].sweep", k), false, "a stage sets concurrency or sweep, not both")
+		}
+		for j, t := range s.Thresholds {
+			if _, err := parseThreshold(t); err != nil {
+				add(fmt.Sprintf("stages[%d].thresholds[%d]", k, j), false, "%v", err)
+			}
+		}
+	}
+	for k, e := range c.Exporters {
+		if e.Name == "" {
+			add(fmt.Sprintf("exporters[%d].name", k), false, "an exporter needs a name")
+		}
+		for j, ev := range e.Events {
+			if !contains(exportEvents, ev) {
+				add(fmt.Sprintf("exporters[%d].events[%d]", k, j), false, "unknown event %q, want one of %s", ev, strings.Join(exportEvents, ", "))
+			}
+		}
+		if d, err := time.ParseDuration(e.Timeout); e.Timeout != "" && (err != nil || d <= 0) {
+			add(fmt.Sprintf("exporters[%d].timeout", k), false, "invalid duration %q", e.Timeout)
+		}
+		if e.Command == "" && e.Name != "" {
+			if _, err := findExporter(e.Name, exporterDirs(c)); err != nil {
+				add(fmt.Sprintf("exporters[%d].name", k), true, "%v", err)
+			}
+		}
+	}
+	for k, h := range c.Webhooks {
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(fmt.Sprintf("webhooks[%d].url", k), false, "want an http or https URL")
+		}
+		for j, ev := range h.Events {
+			if !contains(webhookEvents, ev) {
+				add(fmt.Sprintf("webhooks[%d].events[%d]", k, j), false, "unknown event %q, want one of %s", ev, strings.Join(webhookEvents, ", "))
+			}
+		}
+		if h.Secret != "" && h.SecretEnv != "" {
+			add(fmt.Sprintf("webhooks[%d].secret", k), true, "ignored; secret_env is set")
+		}
+	}
+	if c.MQTT != nil {
+		if u, err := url.Parse(c.MQTT.Broker); err != nil || (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Hostname() == "" {
+			add("mqtt.broker", false, "want mqtt://host:port or mqtts://host:port")
+		}
+		if strings.ContainsAny(c.MQTT.Topic, "+#") {
+			add("mqtt.topic", false, "wildcards can't be published to")
+		}
+	}
+	return issues, w.pos
+}
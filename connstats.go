@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// connTrace follows how a native request got its connection. The
+// transport may dial from its own goroutine, hence the atomics.
+type connTrace struct {
+	newConn, reused, tls atomic.Bool
+}
+
+func (c *connTrace) attach(req *http.Request) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.reused.Store(info.Reused)
+			c.newConn.Store(!info.Reused)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { c.tls.Store(true) },
+	}))
+}
+
+// record copies the trace into s.
+func (c *connTrace) record(s sample) sample {
+	s.newConn, s.reused, s.tlsHandshake = c.newConn.Load(), c.reused.Load(), c.tls.Load()
+	return s
+}
+
+// connColumns are the native engine's per-run connection counts.
+var connColumns = []string{"conn_opened", "conn_reused", "tls_handshakes"}
+
+// addConnStats stores how many of a run's requests opened a connection,
+// reused one and performed a TLS handshake in its row.
+func addConnStats(row map[string]string, samples []sample) {
+	var opened, reused, handshakes int
+	for _, s := range samples {
+		if s.newConn {
+			opened++
+		}
+		if s.reused {
+			reused++
+		}
+		if s.tlsHandshake {
+			handshakes++
+		}
+	}
+	row["conn_opened"] = strconv.Itoa(opened)
+	row["conn_reused"] = strconv.Itoa(reused)
+	row["tls_handshakes"] = strconv.Itoa(handshakes)
+}
+
+// analyzeConnections compares the targets' connection churn in the report
+// and charts their mean connections and handshakes per run: a stack that
+// closes connections pays a dial, and over TLS a handshake, on many more
+// requests, which shows in its latency.
+func analyzeConnections(rows []map[string]string, filename string) {
+	var own []map[string]string
+	for _, row := range rows {
+		if row["agent"] == "" && row["conn_opened"] != "" {
+			own = append(own, row)
+		}
+	}
+	opened, order := seriesValues(own, "conn_opened")
+	if len(order) == 0 {
+		return
+	}
+	reused, _ := seriesValues(own, "conn_reused")
+	handshakes, _ := seriesValues(own, "tls_handshakes")
+
+	var table [][]string
+	var shown []string
+	var openBars, reuseBars, tlsBars []opts.BarData
+	for _, key := range order {
+		o, r, h := mean(opened[key]), mean(reused[key]), mean(handshakes[key])
+		share := "–"
+		if o+r > 0 {
+			share = fmt.Sprintf("%.1f%%", 100*r/(o+r))
+		}
+		table = append(table, []string{key, strconv.Itoa(len(opened[key])),
+			fmt.Sprintf("%.1f", o), fmt.Sprintf("%.1f", r), share, fmt.Sprintf("%.1f", h)})
+		if !seriesWanted(key) {
+			continue
+		}
+		shown = append(shown, key)
+		detail := fmt.Sprintf("<b>%s</b> · %s of requests reused a connection", key, share)
+		openBars = append(openBars, opts.BarData{Name: detail, Value: round4(o)})
+		reuseBars = append(reuseBars, opts.BarData{Name: detail, Value: round4(r)})
+		tlsBars = append(tlsBars, opts.BarData{Name: detail, Value: round4(h)})
+	}
+	addReportSection("Connection reuse",
+		"How each target's requests got their connection on the native engine, per run on average: a new one, dialed for it, or one reused from an earlier request; and how many TLS handshakes that took. "+
+			"Low reuse means the target, or something in front of it, closes connections, so requests pay for a dial and a handshake.\n\n"+
+			markdownTable([]string{"target", "runs", "connections opened", "connections reused", "reuse", "TLS handshakes"}, table))
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Connection Reuse", "mean per run, native engine")),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Requests"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Target"}),
+		chartToolbox(),
+		pointTooltip(""),
+	)
+	bar.SetXAxis(shown)
+	bar.AddSeries("connections opened", openBars, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[3]}))
+	bar.AddSeries("connections reused", reuseBars, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[0]}))
+	bar.AddSeries("TLS handshakes", tlsBars, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[1]}))
+	renderChart(bar, filename)
+}
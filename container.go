@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// Where the image expects its config and results volumes.
+const (
+	containerConfig  = "/config/perf.json"
+	containerResults = "/results"
+	containerUID     = 10001 // the Dockerfile's perf user
+)
+
+// containerArgs turns `container [flags]`, the image's entrypoint, into the
+// suite's flags: the config from the /config volume and results in the
+// /results volume, unless the flags or the PERTOOLS_ environment say
+// otherwise. Everything else is configured like any suite, e.g.
+// PERTOOLS_ARCHIVE=s3://bucket/perf/ to upload the results.
+func containerArgs(args []string) []string {
+	given := func(name string) bool {
+		if _, ok := os.LookupEnv(envName(name)); ok {
+			return true
+		}
+		for _, a := range args {
+			a = strings.TrimLeft(a, "-")
+			if a == name || strings.HasPrefix(a, name+"=") {
+				return true
+			}
+		}
+		return false
+	}
+	var out []string
+	if !given("config") {
+		if _, err := os.Stat(containerConfig); err == nil {
+			out = append(out, "--config", containerConfig)
+		}
+	}
+	if !given("results-dir") {
+		out = append(out, "--results-dir", containerResults)
+	}
+	return append(out, args...)
+}
+
+// terminatedBy is the signal that stopped the suite, if one did.
+var terminatedBy atomic.Value
+
+// stopOnSignal cancels the suite on SIGTERM, as a Kubernetes Job's pod
+// gets when it's deleted or preempted, so the suite stops like at its
+// --max-duration deadline and still writes the results so far.
+func stopOnSignal() {
+	var cancel context.CancelFunc
+	suiteCtx, cancel = context.WithCancel(suiteCtx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("⚠️  Got SIGTERM, stopping the suite")
+		terminatedBy.Store("stopped by SIGTERM")
+		cancel()
+	}()
+}
+
+// heyCommand is the command running hey with args: hey itself, or with
+// --hey-exec, hey inside another container, e.g. by `kubectl exec`.
+func heyCommand(args []string) (string, []string, error) {
+	if *heyExec == "" {
+		return heyBin, args, nil
+	}
+	prefix, err := shellSplit(*heyExec)
+	if err != nil || len(prefix) == 0 {
+		return "", nil, fmt.Errorf("invalid --hey-exec %q", *heyExec)
+	}
+	return prefix[0], append(append(prefix[1:], heyBin), args...), nil
+}
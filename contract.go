@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Contract is an SLA kept in its own versioned file, given with
+// --contract: latency, error and availability targets per endpoint.
+//
+//	{
+//	  "name": "checkout-api",
+//	  "version": "2.3.0",
+//	  "endpoints": [
+//	    {"match": "/login", "latency": {"p95": "300ms", "p99": "800ms"}, "error_rate": 0.5, "availability": 99.9},
+//	    {"match": "/api/*", "latency": {"p95": "500ms"}, "availability": 99.5}
+//	  ]
+//	}
+//
+// A target is held to the first endpoint matching its URL path, route or
+// name, as a path.Match pattern; "*" matches any.
+type Contract struct {
+	Name      string             `json:"name"`
+	Version   string             `json:"version"`
+	Endpoints []ContractEndpoint `json:"endpoints"`
+}
+
+// ContractEndpoint is one endpoint's terms. Latency bounds are maxima of
+// the mean across runs, like thresholds; error_rate is the maximum mean
+// percentage of failed requests, and availability the minimum percentage
+// of all the suite's requests answered without a 5xx or a transport
+// error.
+type ContractEndpoint struct {
+	Match        string            `json:"match"`
+	Latency      map[string]string `json:"latency"`
+	ErrorRate    *float64          `json:"error_rate"`
+	Availability *float64          `json:"availability"`
+}
+
+// contractTerm is a single clause of an endpoint's terms.
+type contractTerm struct {
+	metric string // a CSV column, or availability
+	limit  Threshold
+}
+
+func (e ContractEndpoint) terms() ([]contractTerm, error) {
+	var out []contractTerm
+	var metrics []string
+	for m := range e.Latency {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics)
+	for _, m := range metrics {
+		if !timeColumn(m) {
+			return nil, fmt.Errorf("latency: %s isn't a latency metric, e.g. p95 or average", m)
+		}
+		t, err := parseThreshold(m + "<=" + strings.TrimSpace(e.Latency[m]))
+		if err != nil {
+			return nil, fmt.Errorf("latency: invalid bound %q for %s, want e.g. 300ms", e.Latency[m], m)
+		}
+		out = append(out, contractTerm{metric: m, limit: t})
+	}
+	if e.ErrorRate != nil {
+		if *e.ErrorRate < 0 || *e.ErrorRate > 100 {
+			return nil, fmt.Errorf("error_rate %v isn't a percentage", *e.ErrorRate)
+		}
+		out = append(out, contractTerm{metric: "error_rate", limit: Threshold{Metric: "error_rate", Op: "<=", Value: *e.ErrorRate}})
+	}
+	if e.Availability != nil {
+		if *e.Availability <= 0 || *e.Availability > 100 {
+			return nil, fmt.Errorf("availability %v isn't a percentage", *e.Availability)
+		}
+		out = append(out, contractTerm{metric: "availability", limit: Threshold{Metric: "availability", Op: ">=", Value: *e.Availability}})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no latency, error_rate or availability terms")
+	}
+	return out, nil
+}
+
+func loadContract(file string) (*Contract, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var c Contract
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, positionError(file, raw, err)
+	}
+	if len(c.Endpoints) == 0 {
+		return nil, fmt.Errorf("%s defines no endpoints", file)
+	}
+	for i, e := range c.Endpoints {
+		if _, err := path.Match(e.Match, ""); err != nil || e.Match == "" {
+			return nil, fmt.Errorf("%s: endpoints[%d]: invalid match %q", file, i, e.Match)
+		}
+		if _, err := e.terms(); err != nil {
+			return nil, fmt.Errorf("%s: endpoints[%d] (%s): %v", file, i, e.Match, err)
+		}
+	}
+	return &c, nil
+}
+
+func (c *Contract) String() string {
+	name := c.Name
+	if name == "" {
+		name = "SLA contract"
+	}
+	if c.Version != "" {
+		name += " v" + strings.TrimPrefix(c.Version, "v")
+	}
+	return name
+}
+
+// endpoint finds the terms t is held to, or nil if the contract doesn't
+// cover it.
+func (c *Contract) endpoint(t Target) *ContractEndpoint {
+	candidates := []string{t.Name, t.Route}
+	if u, err := url.Parse(t.URL); err == nil {
+		p := u.Path
+		if p == "" {
+			p = "/"
+		}
+		candidates = append(candidates, p)
+	}
+	for i := range c.Endpoints {
+		e := &c.Endpoints[i]
+		if e.Match == "*" {
+			return e
+		}
+		for _, s := range candidates {
+			if ok, _ := path.Match(e.Match, s); ok && s != "" {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// availability pools the share of the rows' requests that got an answer
+// other than a 5xx.
+func availability(rows []map[string]string) (float64, bool) {
+	up, total := 0, 0
+	for _, row := range rows {
+		codes, errs, err := runStatuses(row["file"])
+		if err != nil {
+			continue
+		}
+		total += errs
+		for code, n := range codes {
+			total += n
+			if code < 500 {
+				up += n
+			}
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return 100 * float64(up) / float64(total), true
+}
+
+// evaluateContract checks every target's results against the contract,
+// printing a verdict per term and adding the compliance table to the
+// report. It reports whether the candidate, or with none given every
+// target, kept to the contract; other targets are only reported.
+func evaluateContract(c *Contract, targets []Target, rows []map[string]string, candidate string) bool {
+	byKey := map[string]Target{}
+	for _, t := range targets {
+		byKey[t.Name+"\x00"+t.Route] = t
+	}
+	groups := map[string][]map[string]string{}
+	var keys []string
+	held := map[string]Target{}
+	for _, row := range rows {
+		if row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			t, ok := byKey[row["target"]+"\x00"+row["route"]]
+			if !ok {
+				// a mix's aggregate, under its deployment's name only
+				t = Target{Name: row["target"], Route: row["route"]}
+			}
+			held[key] = t
+		}
+		groups[key] = append(groups[key], row)
+	}
+	sort.Strings(keys)
+
+	kept := true
+	var table [][]string
+	var uncovered []string
+	for _, key := range keys {
+		t := held[key]
+		e := c.endpoint(t)
+		if e == nil {
+			uncovered = append(uncovered, key)
+			continue
+		}
+		binding := candidate == "" || t.Name == candidate
+		terms, _ := e.terms() // validated by loadContract
+		for _, term := range terms {
+			label := withUnit(term.metric)
+			if term.metric == "availability" {
+				label += " (%)"
+			}
+			var v float64
+			var ok bool
+			if term.metric == "availability" {
+				v, ok = availability(groups[key])
+			} else {
+				v, ok = seriesMeans(groups[key], term.metric)[key]
+			}
+			verdict := "✅ met"
+			switch {
+			case !ok:
+				verdict = "❌ not measured"
+				fmt.Printf("❌ %s: %s: no %s values to check %s against\n", c, key, term.metric, term.limit)
+			case term.limit.holds(v):
+				fmt.Printf("✅ %s: %s: %s = %.4f (%s)\n", c, key, label, displayValue(term.metric, v), term.limit)
+			default:
+				verdict = "❌ breached"
+				fmt.Printf("❌ %s: %s: %s = %.4f breaches %s\n", c, key, label, displayValue(term.metric, v), term.limit)
+			}
+			if verdict != "✅ met" {
+				if binding {
+					kept = false
+				} else {
+					verdict += " (not the candidate)"
+				}
+			}
+			measured := "-"
+			if ok {
+				measured = fmt.Sprintf("%.4g", displayValue(term.metric, v))
+			}
+			table = append(table, []string{key, e.Match, label, strings.TrimPrefix(term.limit.String(), term.metric+" "), measured, verdict})
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Results against the SLA contract %s", c)
+	if candidate != "" {
+		fmt.Fprintf(&b, "; only the candidate, %s, is held to it", candidate)
+	}
+	b.WriteString(".\n\n")
+	b.WriteString(markdownTable([]string{"target", "endpoint", "metric", "contract", "measured", "result"}, table))
+	if len(uncovered) > 0 {
+		fmt.Fprintf(&b, "\nNo endpoint of the contract covers %s.\n", strings.Join(uncovered, ", "))
+	}
+	if kept {
+		b.WriteString("\n✅ The contract is kept.\n")
+	} else {
+		b.WriteString("\n❌ The contract is broken.\n")
+	}
+	addReportSection("SLA contract", b.String())
+	return kept
+}
+
+// onlyNamed keeps the targets with one of names, every route of a mix
+// named so.
+func onlyNamed(targets []Target, names []string) ([]Target, error) {
+	var out []Target
+	for _, name := range names {
+		if !hasTargetNamed(targets, name) {
+			return nil, fmt.Errorf("%q names no target", name)
+		}
+	}
+	for _, t := range targets {
+		if contains(names, t.Name) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func hasTargetNamed(targets []Target, name string) bool {
+	for _, t := range targets {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cooldown is the pause after each run, letting connection pools drain
+// and autoscalers settle before the next one. It's written as:
+//
+//	2s            always 2s
+//	exp 1s 30s    1s after the first run, doubling after each, up to 30s
+//	jitter 1s 3s  uniformly random between 1s and 3s
+type Cooldown struct {
+	Spec     string
+	Mode     string
+	Min, Max time.Duration
+}
+
+func parseCooldown(s string) (Cooldown, error) {
+	fields := strings.Fields(s)
+	c := Cooldown{Spec: s}
+	var err error
+	switch {
+	case len(fields) == 1:
+		c.Mode = "fixed"
+		c.Min, err = time.ParseDuration(fields[0])
+		c.Max = c.Min
+	case len(fields) == 3 && (fields[0] == "exp" || fields[0] == "jitter"):
+		c.Mode = fields[0]
+		if c.Min, err = time.ParseDuration(fields[1]); err == nil {
+			c.Max, err = time.ParseDuration(fields[2])
+		}
+	default:
+		return c, fmt.Errorf("invalid cooldown %q, want e.g. 2s, \"exp 1s 30s\" or \"jitter 1s 3s\"", s)
+	}
+	if err != nil || c.Min < 0 || c.Max < c.Min {
+		return c, fmt.Errorf("invalid cooldown %q, want e.g. 2s, \"exp 1s 30s\" or \"jitter 1s 3s\"", s)
+	}
+	return c, nil
+}
+
+// after returns the pause after run i (1-based).
+func (c Cooldown) after(i int) time.Duration {
+	switch c.Mode {
+	case "exp":
+		d := c.Min
+		for k := 1; k < i && d < c.Max; k++ {
+			d *= 2
+		}
+		if d > c.Max {
+			d = c.Max
+		}
+		return d
+	case "jitter":
+		return c.Min + time.Duration(nextRand().Int63n(int64(c.Max-c.Min)+1))
+	default:
+		return c.Min
+	}
+}
+
+// suiteCooldown is cfg.Cooldown parsed; targets with an override carry
+// their own.
+var suiteCooldown = Cooldown{Spec: "1s", Mode: "fixed", Min: time.Second, Max: time.Second}
+
+// targetCooldowns records the targets whose override changes the cooldown,
+// by label, for the suite's JSON.
+var targetCooldowns = map[string]string{}
+
+func cooldownFor(t Target) Cooldown {
+	if t.Cooldown != nil {
+		return *t.Cooldown
+	}
+	return suiteCooldown
+}
+
+// coolDown sleeps for d, waking early at the --max-duration deadline.
+func coolDown(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-suiteCtx.Done():
+	}
+}
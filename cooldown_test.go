@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCooldown(t *testing.T) {
+	tests := []struct {
+		in       string
+		mode     string
+		min, max time.Duration
+	}{
+		{"2s", "fixed", 2 * time.Second, 2 * time.Second},
+		{"0s", "fixed", 0, 0},
+		{"exp 1s 30s", "exp", time.Second, 30 * time.Second},
+		{"jitter 500ms 3s", "jitter", 500 * time.Millisecond, 3 * time.Second},
+		{"  exp   1s  1s ", "exp", time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		c, err := parseCooldown(tt.in)
+		if err != nil {
+			t.Errorf("parseCooldown(%q): %v", tt.in, err)
+			continue
+		}
+		if c.Mode != tt.mode || c.Min != tt.min || c.Max != tt.max {
+			t.Errorf("parseCooldown(%q) = %s %v %v, want %s %v %v", tt.in, c.Mode, c.Min, c.Max, tt.mode, tt.min, tt.max)
+		}
+	}
+	for _, in := range []string{"", "2", "-1s", "exp 1s", "exp 30s 1s", "jitter 1s x", "linear 1s 2s", "1s 2s"} {
+		if _, err := parseCooldown(in); err == nil {
+			t.Errorf("parseCooldown(%q) accepted it", in)
+		}
+	}
+}
+
+func TestCooldownAfter(t *testing.T) {
+	exp, _ := parseCooldown("exp 1s 10s")
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := exp.after(i + 1); got != want {
+			t.Errorf("exp 1s 10s after run %d = %v, want %v", i+1, got, want)
+		}
+	}
+	jitter, _ := parseCooldown("jitter 1s 2s")
+	for i := 1; i <= 100; i++ {
+		if got := jitter.after(i); got < time.Second || got > 2*time.Second {
+			t.Fatalf("jitter 1s 2s after run %d = %v, outside 1s-2s", i, got)
+		}
+	}
+	fixed, _ := parseCooldown("3s")
+	if got := fixed.after(7); got != 3*time.Second {
+		t.Errorf("3s after run 7 = %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) accepted it", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2026, 10, 14, 8, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 14, 8, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 14, 8, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2026, 10, 14, 8, 25, 0, 0, time.UTC)},
+		{"0,30 9-17/2 * * *", time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)},
+		{"@nightly", time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 6", time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)},
+		// with both day fields restricted, either one matching is enough
+		{"0 0 1 * 1", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"7 8 * * *", time.Date(2026, 10, 15, 8, 7, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v = %v, want %v", tt.expr, from, got, tt.want)
+		}
+	}
+}
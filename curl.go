@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// curl options that consume a value but don't affect the request we
+// replay.
+var curlIgnoredWithArg = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true,
+	"--connect-timeout": true, "-w": true, "--write-out": true,
+	"--retry": true, "--cacert": true, "-E": true, "--cert": true,
+	"--resolve": true, "-x": true, "--proxy": true, "--key": true,
+	"--retry-delay": true, "--retry-max-time": true, "-D": true, "--dump-header": true,
+}
+
+// curl options without a value that don't affect the request we replay.
+var curlIgnored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-L": true, "--location": true,
+	"-k": true, "--insecure": true, "--compressed": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-f": true, "--fail": true, "--fail-with-body": true,
+	"-N": true, "--no-buffer": true, "-#": true, "--progress-bar": true, "--no-progress-meter": true,
+	"-g": true, "--globoff": true, "-4": true, "--ipv4": true, "-6": true, "--ipv6": true,
+	"-0": true, "--http1.0": true, "--http1.1": true, "--http2": true, "--http2-prior-knowledge": true, "--http3": true,
+	"--location-trusted": true, "--path-as-is": true, "--raw": true, "--tr-encoding": true,
+	"--tcp-nodelay": true, "--no-keepalive": true, "--tlsv1.2": true, "--tlsv1.3": true,
+}
+
+// curlWithArg are the options parseCurl reads that take a value.
+var curlWithArg = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-raw": true, "--data-binary": true, "--data-ascii": true, "--data-urlencode": true,
+	"--json": true, "-u": true, "--user": true, "-A": true, "--user-agent": true,
+	"-b": true, "--cookie": true, "-e": true, "--referer": true, "--url": true,
+}
+
+// curlOpt is one option of a curl command line with its value, or a bare
+// URL when name is empty.
+type curlOpt struct {
+	name, value string
+}
+
+// curlOpts splits curl's arguments into options, accepting values
+// attached (-XPOST, -d@body.json), after = (--request=POST) or in the next
+// argument, and short flags grouped (-sSL). Unknown options are errors,
+// since silently dropping one could replay a different request.
+func curlOpts(args []string) ([]curlOpt, error) {
+	var opts []curlOpt
+	takesArg := func(name string) bool { return curlWithArg[name] || curlIgnoredWithArg[name] }
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		value := func(name string) (curlOpt, error) {
+			i++
+			if i >= len(args) {
+				return curlOpt{}, fmt.Errorf("curl option %s needs a value", name)
+			}
+			return curlOpt{name, args[i]}, nil
+		}
+		switch {
+		case !strings.HasPrefix(a, "-") || a == "-":
+			opts = append(opts, curlOpt{value: a})
+		case strings.HasPrefix(a, "--"):
+			name, v, attached := strings.Cut(a, "=")
+			switch {
+			case takesArg(name) && attached:
+				opts = append(opts, curlOpt{name, v})
+			case takesArg(name):
+				o, err := value(name)
+				if err != nil {
+					return nil, err
+				}
+				opts = append(opts, o)
+			case attached:
+				return nil, fmt.Errorf("curl option %s takes no value", name)
+			case curlIgnored[name], name == "--head", name == "--get":
+				opts = append(opts, curlOpt{name: name})
+			default:
+				return nil, fmt.Errorf("unsupported curl option %s", name)
+			}
+		default:
+		flags:
+			for k := 1; k < len(a); k++ {
+				name := "-" + a[k:k+1]
+				switch {
+				case takesArg(name) && k+1 < len(a):
+					opts = append(opts, curlOpt{name, a[k+1:]})
+					break flags
+				case takesArg(name):
+					o, err := value(name)
+					if err != nil {
+						return nil, err
+					}
+					opts = append(opts, o)
+				case curlIgnored[name], name == "-I", name == "-G":
+					opts = append(opts, curlOpt{name: name})
+				default:
+					return nil, fmt.Errorf("unsupported curl option %s in %s", name, a)
+				}
+			}
+		}
+	}
+	return opts, nil
+}
+
+// parseCurl converts a pasted curl command line into a Target.
+func parseCurl(command string) (Target, error) {
+	args, err := shellSplit(command)
+	if err != nil {
+		return Target{}, err
+	}
+	if len(args) > 0 && (args[0] == "curl" || strings.HasSuffix(args[0], "/curl")) {
+		args = args[1:]
+	}
+	opts, err := curlOpts(args)
+	if err != nil {
+		return Target{}, err
+	}
+
+	var t Target
+	var rawURL string
+	var data []string
+	get := false
+	for _, o := range opts {
+		v := o.value
+		switch o.name {
+		case "":
+			rawURL = v
+		case "-X", "--request":
+			t.Method = strings.ToUpper(v)
+		case "-H", "--header":
+			name, value, _ := strings.Cut(v, ":")
+			t.Headers = append(t.Headers, Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode":
+			if strings.HasPrefix(v, "@") && o.name != "--data-raw" {
+				raw, err := os.ReadFile(v[1:])
+				if err != nil {
+					return Target{}, err
+				}
+				v = string(raw)
+			}
+			if o.name == "--data-urlencode" {
+				if name, value, ok := strings.Cut(v, "="); ok {
+					v = name + "=" + url.QueryEscape(value)
+				} else {
+					v = url.QueryEscape(v)
+				}
+			}
+			data = append(data, v)
+		case "--json":
+			data = append(data, v)
+			t.Headers = append(t.Headers,
+				Header{Name: "Content-Type", Value: "application/json"},
+				Header{Name: "Accept", Value: "application/json"})
+		case "-u", "--user":
+			t.Headers = append(t.Headers, Header{Name: "Authorization", Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(v))})
+		case "-A", "--user-agent":
+			t.Headers = append(t.Headers, Header{Name: "User-Agent", Value: v})
+		case "-b", "--cookie":
+			t.Headers = append(t.Headers, Header{Name: "Cookie", Value: v})
+		case "-e", "--referer":
+			t.Headers = append(t.Headers, Header{Name: "Referer", Value: v})
+		case "--url":
+			rawURL = v
+		case "-I", "--head":
+			t.Method = "HEAD"
+		case "-G", "--get":
+			get = true
+		}
+	}
+
+	if rawURL == "" {
+		return Target{}, fmt.Errorf("curl command has no url")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		rawURL += sep + body
+		body = ""
+	}
+	if t.Method == "" {
+		t.Method = "GET"
+		if body != "" {
+			t.Method = "POST"
+			if !hasHeader(t.Headers, "Content-Type") {
+				t.Headers = append(t.Headers, Header{Name: "Content-Type", Value: "application/x-www-form-urlencoded"})
+			}
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Target{}, err
+	}
+	t.Name = u.Host
+	t.URL = rawURL
+	t.Body = body
+	t.Route = routeOf(t.Method, rawURL)
+	return t, nil
+}
+
+func hasHeader(headers []Header, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellSplit tokenizes a command line the way a POSIX shell would for the
+// quoting styles copy-as-curl produces: single quotes, double quotes,
+// backslash escapes and line continuations.
+func shellSplit(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' {
+					cur.WriteByte(s[i])
+					inArg = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
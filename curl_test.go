@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestShellSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"curl https://a.example", []string{"curl", "https://a.example"}},
+		{"  curl \t -s  ", []string{"curl", "-s"}},
+		{`-H 'X-Name: a b'`, []string{"-H", "X-Name: a b"}},
+		{`-d "{\"k\":\"v\"}"`, []string{"-d", `{"k":"v"}`}},
+		{`-d "a\nb"`, []string{"-d", `a\nb`}},
+		{`it''s`, []string{"its"}},
+		{`-H'X: '"y"`, []string{"-HX: y"}},
+		{`a\ b c`, []string{"a b", "c"}},
+		{"curl \\\n  -s \\\n  https://a.example", []string{"curl", "-s", "https://a.example"}},
+		{`''`, []string{""}},
+		{`-d 'héllo wörld'`, []string{"-d", "héllo wörld"}},
+	}
+	for _, tt := range tests {
+		got, err := shellSplit(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellSplit(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{`-H 'unterminated`, `-d "unterminated`} {
+		if got, err := shellSplit(in); err == nil {
+			t.Errorf("shellSplit(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	body := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(body, []byte(`{"name":"a"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in      string
+		method  string
+		url     string
+		headers []Header
+		body    string
+	}{
+		{"curl https://a.example/x", "GET", "https://a.example/x", nil, ""},
+		{"curl a.example/x", "GET", "http://a.example/x", nil, ""},
+		{"curl -X POST https://a.example/x", "POST", "https://a.example/x", nil, ""},
+		{"curl -XPOST https://a.example/x", "POST", "https://a.example/x", nil, ""},
+		{"curl --request=put https://a.example/x", "PUT", "https://a.example/x", nil, ""},
+		{"curl --url=https://a.example/x", "GET", "https://a.example/x", nil, ""},
+		{"curl -H'X-Id: 7' https://a.example/x", "GET", "https://a.example/x", []Header{{"X-Id", "7"}}, ""},
+		{"curl --header='X-Id: 7' https://a.example/x", "GET", "https://a.example/x", []Header{{"X-Id", "7"}}, ""},
+		{"curl -sSL -k --compressed https://a.example/x", "GET", "https://a.example/x", nil, ""},
+		{"curl -sXPOST https://a.example/x", "POST", "https://a.example/x", nil, ""},
+		{"curl -sX POST https://a.example/x", "POST", "https://a.example/x", nil, ""},
+		{"curl -d@" + body + " -H 'Content-Type: application/json' https://a.example/x", "POST", "https://a.example/x",
+			[]Header{{"Content-Type", "application/json"}}, `{"name":"a"}`},
+		{"curl --data-raw @literal https://a.example/x", "POST", "https://a.example/x",
+			[]Header{{"Content-Type", "application/x-www-form-urlencoded"}}, "@literal"},
+		{"curl -d a=1 -d b=2 https://a.example/x", "POST", "https://a.example/x",
+			[]Header{{"Content-Type", "application/x-www-form-urlencoded"}}, "a=1&b=2"},
+		{"curl -G -d q=1 https://a.example/x?p=2", "GET", "https://a.example/x?p=2&q=1", nil, ""},
+		{"curl --data-urlencode 'q=a b' -G https://a.example/x", "GET", "https://a.example/x?q=a+b", nil, ""},
+		{"curl -I https://a.example/x", "HEAD", "https://a.example/x", nil, ""},
+		{"curl -uuser:pass https://a.example/x", "GET", "https://a.example/x", []Header{{"Authorization", "Basic dXNlcjpwYXNz"}}, ""},
+		{"curl -o /dev/null -w '%{http_code}' -m5 https://a.example/x", "GET", "https://a.example/x", nil, ""},
+		{`curl --json '{"a":1}' https://a.example/x`, "POST", "https://a.example/x",
+			[]Header{{"Content-Type", "application/json"}, {"Accept", "application/json"}}, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		got, err := parseCurl(tt.in)
+		if err != nil {
+			t.Errorf("parseCurl(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Method != tt.method || got.URL != tt.url || got.Body != tt.body || !reflect.DeepEqual(got.Headers, tt.headers) {
+			t.Errorf("parseCurl(%q) = %s %s %q %v, want %s %s %q %v", tt.in,
+				got.Method, got.URL, got.Body, got.Headers, tt.method, tt.url, tt.body, tt.headers)
+		}
+	}
+
+	for _, in := range []string{
+		"curl -s",                               // no url
+		"curl -X",                               // missing value
+		"curl https://a.example/x -H",           // missing value at the end
+		"curl --frobnicate https://a.example/x", // unknown long option
+		"curl -Z https://a.example/x",           // unknown short option
+		"curl -sZ https://a.example/x",          // unknown within a group
+		"curl --silent=yes https://a.example/x", // value for a flag
+		"curl 'https://a.example/x",             // bad quoting
+	} {
+		if got, err := parseCurl(in); err == nil {
+			t.Errorf("parseCurl(%q) = %+v, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Cutover configures the blue/green cutover readiness report: Blue is the
+// deployment serving traffic now, Green the one that would take over, and
+// Criteria the thresholds Green must meet for a go. Besides CSV columns,
+// criteria can use the report's derived metrics (see cutoverMetrics) and
+// <metric>_vs_blue, Green's percentage change from Blue.
+type Cutover struct {
+	Blue          string             `json:"blue"`
+	Green         string             `json:"green"`
+	PeakRPS       float64            `json:"peak_rps"`
+	Availability  float64            `json:"availability"`
+	CostPerHour   map[string]float64 `json:"cost_per_hour"`
+	Watts         map[string]float64 `json:"watts"`
+	GridIntensity float64            `json:"grid_gco2_per_kwh"`
+	Criteria      []string           `json:"criteria"`
+}
+
+// defaultAvailability is the success rate objective the error budget is
+// taken from when the config doesn't set one.
+const defaultAvailability = 99.9
+
+// defaultGridIntensity is roughly the world average carbon intensity of
+// electricity, in gCO2e per kWh.
+const defaultGridIntensity = 475
+
+// cutoverMetrics are the derived metrics the report computes per side, in
+// the order its table lists them.
+var cutoverMetrics = []string{"capacity_rps", "headroom", "error_rate", "error_budget_used", "slo_compliance", "cost_per_million", "gco2_per_million"}
+
+// resolveCutover fills in Blue and Green, defaulting to the first two
+// deployments, and checks both ran.
+func resolveCutover(c *Cutover, targets []Target) error {
+	var names []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if !seen[t.Name] {
+			seen[t.Name] = true
+			names = append(names, t.Name)
+		}
+	}
+	if c.Blue == "" && len(names) > 0 {
+		c.Blue = names[0]
+	}
+	if c.Green == "" {
+		for _, n := range names {
+			if n != c.Blue {
+				c.Green = n
+				break
+			}
+		}
+	}
+	for _, n := range []string{c.Blue, c.Green} {
+		if !seen[n] {
+			return fmt.Errorf("no target is named %q; targets are %s", n, strings.Join(names, ", "))
+		}
+	}
+	for k := range c.CostPerHour {
+		if !seen[k] {
+			return fmt.Errorf("cost_per_hour names %q, which isn't a target; targets are %s", k, strings.Join(names, ", "))
+		}
+	}
+	for k := range c.Watts {
+		if !seen[k] {
+			return fmt.Errorf("watts names %q, which isn't a target; targets are %s", k, strings.Join(names, ", "))
+		}
+	}
+	if c.Blue == c.Green {
+		return fmt.Errorf("blue and green are both %q", c.Blue)
+	}
+	if c.Availability == 0 {
+		c.Availability = defaultAvailability
+	}
+	if c.Availability <= 0 || c.Availability >= 100 {
+		return fmt.Errorf("availability %v isn't a percentage below 100", c.Availability)
+	}
+	if c.GridIntensity == 0 {
+		c.GridIntensity = defaultGridIntensity
+	}
+	return nil
+}
+
+// cutoverCriteria parses the configured criteria, or builds the default
+// set: Green stays within its error budget, isn't more than 10% slower or
+// 10% less throughput than Blue, meets the SLO, and keeps 20% headroom
+// over the expected peak.
+func cutoverCriteria(c *Cutover, slo *SLO) ([]Threshold, error) {
+	specs := c.Criteria
+	if len(specs) == 0 {
+		latency := "average"
+		if hasPercentile(95) {
+			latency = "p95"
+		}
+		specs = []string{"error_budget_used<100", latency + "_vs_blue<=10", "requests_per_sec_vs_blue>=-10"}
+		if slo != nil {
+			specs = append(specs, fmt.Sprintf("slo_compliance>=%v", slo.Percent))
+		}
+		if c.PeakRPS > 0 {
+			specs = append(specs, "headroom>=20")
+		}
+	}
+	return parseThresholds(specs)
+}
+
+// cutoverSide holds one deployment's figures for the report.
+type cutoverSide struct {
+	name    string
+	rows    []map[string]string
+	metrics map[string]float64
+	notes   []string
+}
+
+// value looks a metric up among the derived ones and then the mean of its
+// CSV column.
+func (s *cutoverSide) value(metric string) (float64, bool) {
+	if v, ok := s.metrics[metric]; ok {
+		return v, true
+	}
+	var xs []float64
+	for _, row := range s.rows {
+		if v, ok := rowFloat(row, metric); ok {
+			xs = append(xs, v)
+		}
+	}
+	if len(xs) == 0 {
+		return 0, false
+	}
+	return mean(xs), true
+}
+
+// cutoverRows picks a deployment's own rows: its mix aggregate when it
+// ran a mix, otherwise every run of it, and never agents' shares.
+func cutoverRows(rows []map[string]string, name string) []map[string]string {
+	var all, whole []map[string]string
+	for _, row := range rows {
+		if row["target"] != name || row["agent"] != "" {
+			continue
+		}
+		all = append(all, row)
+		if row["route"] == "" {
+			whole = append(whole, row)
+		}
+	}
+	if len(whole) > 0 {
+		return whole
+	}
+	return all
+}
+
+func newCutoverSide(c *Cutover, rows []map[string]string, name string) *cutoverSide {
+	s := &cutoverSide{name: name, rows: cutoverRows(rows, name), metrics: map[string]float64{}}
+
+	// capacity is the best throughput before saturation in a sweep, or
+	// simply the measured throughput, a lower bound, at one level
+	if ps := sweepPoints(s.rows)[rowTargetKey(s.rows[0])]; len(ps) > 1 {
+		limit := len(ps)
+		if sat := saturationPoint(ps); sat > 0 {
+			limit = sat
+			s.notes = append(s.notes, fmt.Sprintf("saturates at c=%d", ps[sat].concurrency))
+		}
+		for _, p := range ps[:limit] {
+			s.metrics["capacity_rps"] = math.Max(s.metrics["capacity_rps"], p.rps)
+		}
+	} else if v, ok := s.value("requests_per_sec"); ok {
+		s.metrics["capacity_rps"] = v
+		s.notes = append(s.notes, "capacity measured at one concurrency level only")
+	}
+	capacity := s.metrics["capacity_rps"]
+	if c.PeakRPS > 0 && capacity > 0 {
+		s.metrics["headroom"] = 100 * (capacity - c.PeakRPS) / capacity
+	}
+
+	failed, total := 0.0, 0
+	good, sloTotal := 0, 0
+	for _, row := range s.rows {
+		if rate, n, err := errorRate(row); err == nil && n > 0 {
+			failed += rate * float64(n) / 100
+			total += n
+		}
+		g, _ := strconv.Atoi(row["slo_good"])
+		t, _ := strconv.Atoi(row["slo_total"])
+		good += g
+		sloTotal += t
+	}
+	if total > 0 {
+		s.metrics["error_rate"] = 100 * failed / float64(total)
+		s.metrics["error_budget_used"] = 100 * s.metrics["error_rate"] / (100 - c.Availability)
+	}
+	if sloTotal > 0 {
+		s.metrics["slo_compliance"] = 100 * float64(good) / float64(sloTotal)
+	}
+
+	// per million requests served at capacity
+	if capacity > 0 {
+		hours := 1e6 / (capacity * 3600)
+		if cost, ok := c.CostPerHour[name]; ok {
+			s.metrics["cost_per_million"] = cost * hours
+		}
+		if w, ok := c.Watts[name]; ok {
+			s.metrics["gco2_per_million"] = w / 1000 * hours * c.GridIntensity
+		}
+	}
+	return s
+}
+
+// writeCutoverReport writes the readiness report for c to filename and
+// returns whether the verdict is go.
+func writeCutoverReport(filename string, c *Cutover, criteria []Threshold, slo *SLO, rows []map[string]string) (bool, error) {
+	blueRows, greenRows := cutoverRows(rows, c.Blue), cutoverRows(rows, c.Green)
+	if len(blueRows) == 0 || len(greenRows) == 0 {
+		fmt.Println("❌ Cutover: NO-GO, blue or green has no results")
+		return false, nil
+	}
+	blue, green := newCutoverSide(c, rows, c.Blue), newCutoverSide(c, rows, c.Green)
+
+	var b strings.Builder
+	b.WriteString("# Blue/green cutover readiness\n\n")
+	if l := labelString(); l != "" {
+		fmt.Fprintf(&b, "Labels: %s\n\n", l)
+	}
+	fmt.Fprintf(&b, "Blue (serving now): **%s**, %d runs. Green (candidate): **%s**, %d runs.\n", c.Blue, len(blue.rows), c.Green, len(green.rows))
+
+	// the verdict
+	var checks [][]string
+	ok := true
+	for _, t := range criteria {
+		shown, pass := "no data", false
+		if v, found := cutoverValue(blue, green, t.Metric); found {
+			shown = fmt.Sprintf("%.2f", displayValue(t.Metric, v))
+			pass = t.holds(v)
+		}
+		mark := "✅"
+		if !pass {
+			mark = "❌"
+			ok = false
+		}
+		checks = append(checks, []string{mark, t.String(), shown})
+	}
+	verdict := "✅ **GO**: green meets every criterion."
+	if !ok {
+		verdict = "❌ **NO-GO**: green misses at least one criterion."
+	}
+	fmt.Fprintf(&b, "\n## Verdict\n\n%s\n\n%s", verdict, markdownTable([]string{"", "criterion", "green"}, checks))
+
+	// side by side
+	latency := []string{"average"}
+	for _, p := range cfg.Percentiles {
+		if p >= 95 {
+			latency = append(latency, percentileKey(p))
+		}
+	}
+	var table [][]string
+	for _, m := range append(append([]string{"requests_per_sec"}, latency...), cutoverMetrics...) {
+		vb, okB := blue.value(m)
+		vg, okG := green.value(m)
+		if !okB && !okG {
+			continue
+		}
+		line := []string{cutoverLabel(m, slo), "—", "—", "—"}
+		if okB {
+			line[1] = fmt.Sprintf("%.2f", displayValue(m, vb))
+		}
+		if okG {
+			line[2] = fmt.Sprintf("%.2f", displayValue(m, vg))
+		}
+		if okB && okG && vb != 0 {
+			line[3] = fmt.Sprintf("%+.1f%%", 100*(vg-vb)/vb)
+		}
+		table = append(table, line)
+	}
+	fmt.Fprintf(&b, "\n## Side by side\n\n%s", markdownTable([]string{"metric", "blue", "green", "change"}, table))
+
+	var notes []string
+	for _, s := range []*cutoverSide{blue, green} {
+		for _, n := range s.notes {
+			notes = append(notes, fmt.Sprintf("- %s %s.", s.name, n))
+		}
+	}
+	if c.PeakRPS > 0 {
+		notes = append(notes, fmt.Sprintf("- Headroom is the share of capacity left at the expected peak of %v rps.", c.PeakRPS))
+	} else {
+		notes = append(notes, "- Set `peak_rps` to see capacity headroom at the expected peak.")
+	}
+	notes = append(notes, fmt.Sprintf("- The error budget is the %.3g%% of requests an availability objective of %v%% allows to fail.", 100-c.Availability, c.Availability))
+	if len(c.CostPerHour) > 0 || len(c.Watts) > 0 {
+		notes = append(notes, fmt.Sprintf("- Cost and carbon are estimates for a million requests served at capacity, assuming %v gCO2e/kWh.", c.GridIntensity))
+	} else {
+		notes = append(notes, "- Set `cost_per_hour` and `watts` per deployment for cost and carbon estimates.")
+	}
+	fmt.Fprintf(&b, "\n%s\n", strings.Join(notes, "\n"))
+
+	if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+		return ok, err
+	}
+	if ok {
+		fmt.Printf("✅ Cutover: GO for %s to replace %s\n", c.Green, c.Blue)
+	} else {
+		fmt.Printf("❌ Cutover: NO-GO for %s to replace %s\n", c.Green, c.Blue)
+	}
+	fmt.Printf("✅ Cutover report written to %s\n", filename)
+	return ok, nil
+}
+
+// cutoverValue is green's value of metric, or for <metric>_vs_blue its
+// percentage change from blue's.
+func cutoverValue(blue, green *cutoverSide, metric string) (float64, bool) {
+	base, relative := strings.CutSuffix(metric, "_vs_blue")
+	if !relative {
+		return green.value(metric)
+	}
+	vb, okB := blue.value(metricColumn(base))
+	vg, okG := green.value(metricColumn(base))
+	if !okB || !okG || vb == 0 {
+		return 0, false
+	}
+	return 100 * (vg - vb) / vb, true
+}
+
+var cutoverLabels = map[string]string{
+	"requests_per_sec":  "rps",
+	"capacity_rps":      "capacity (rps)",
+	"headroom":          "headroom at peak (%)",
+	"error_rate":        "error rate (%)",
+	"error_budget_used": "error budget used (%)",
+	"cost_per_million":  "cost per 1M requests",
+	"gco2_per_million":  "gCO2e per 1M requests",
+}
+
+func cutoverLabel(metric string, slo *SLO) string {
+	if metric == "slo_compliance" && slo != nil {
+		return "SLO compliance (" + slo.String() + ", %)"
+	}
+	if l, ok := cutoverLabels[metric]; ok {
+		return l
+	}
+	return withUnit(metric)
+}
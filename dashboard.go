@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// kpiCard is one target's at-a-glance figures in the combined report.
+// Deltas compare against the first series, the suite's baseline as in the
+// workbook.
+type kpiCard struct {
+	Series     string
+	Baseline   bool
+	RPS        string
+	Latency    string
+	ErrorRate  string
+	DeltaRPS   kpiDelta
+	DeltaLat   kpiDelta
+	DeltaError kpiDelta
+}
+
+type kpiDelta struct {
+	Text  string
+	Class string // good, bad or same
+}
+
+// delta formats v's change from base; lowerIsBetter decides its colour.
+func delta(base, v float64, lowerIsBetter bool) kpiDelta {
+	if base == 0 {
+		return kpiDelta{Text: "n/a", Class: "same"}
+	}
+	pct := pctChange(base, v)
+	d := kpiDelta{Text: fmt.Sprintf("%+.1f%%", pct), Class: "same"}
+	switch {
+	case pct == 0:
+	case (pct < 0) == lowerIsBetter:
+		d.Class = "good"
+	default:
+		d.Class = "bad"
+	}
+	return d
+}
+
+var dashboardTemplate = template.Must(template.New("report.html").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+.kpis { display: flex; flex-wrap: wrap; gap: 1em; margin-bottom: 2em; }
+.card { border: 1px solid #ddd; border-radius: 8px; padding: 1em 1.5em; min-width: 16em; }
+.card h2 { font-size: 1em; margin: 0 0 .75em; word-break: break-all; }
+.card .stat { display: flex; justify-content: space-between; gap: 1em; margin: .3em 0; }
+.card .value { font-size: 1.4em; font-weight: bold; }
+.good { color: #3ba272; } .bad { color: #c23531; } .same { color: #888; }
+iframe { width: 100%; height: 520px; border: none; margin-bottom: 1em; }
+details { margin-bottom: 2em; }
+table { border-collapse: collapse; margin-top: .5em; }
+th, td { text-align: left; padding: .2em .8em; border-bottom: 1px solid #eee; white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Labels}}<p>Labels: {{.Labels}}</p>{{end}}
+<div class="kpis">
+{{range .Cards}}<div class="card">
+<h2>{{.Series}}{{if .Baseline}} (baseline){{end}}</h2>
+<div class="stat"><span>rps</span><span><span class="value">{{.RPS}}</span>{{if not .Baseline}} <span class="{{.DeltaRPS.Class}}">{{.DeltaRPS.Text}}</span>{{end}}</span></div>
+<div class="stat"><span>{{$.LatencyName}}</span><span><span class="value">{{.Latency}}</span>{{if not .Baseline}} <span class="{{.DeltaLat.Class}}">{{.DeltaLat.Text}}</span>{{end}}</span></div>
+<div class="stat"><span>errors</span><span><span class="value">{{.ErrorRate}}</span>{{if not .Baseline}} <span class="{{.DeltaError.Class}}">{{.DeltaError.Text}}</span>{{end}}</span></div>
+</div>
+{{end}}</div>
+{{if .Sections}}<p>Findings: <a href="report.md">report.md</a></p>{{end}}
+{{if .Runs}}<details>
+<summary>Runs ({{len .Runs}})</summary>
+<table>
+<tr><th>series</th><th>run</th><th>started</th><th>rps</th><th>{{.LatencyName}}</th><th>errors</th><th>files</th></tr>
+{{range .Runs}}<tr><td>{{.Series}}</td><td title="{{.RunID}}">{{.Run}}</td><td>{{.Started}}</td><td>{{.RPS}}</td><td>{{.Latency}}</td><td>{{.ErrorRate}}</td><td>{{if .Summary}}<a href="{{.Summary}}">summary</a>{{end}}{{if .Raw}} · <a href="{{.Raw}}">raw latencies</a>{{end}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}{{range .Charts}}<iframe src="{{.}}" title="{{.}}"></iframe>
+{{end}}</body>
+</html>
+`))
+
+// kpiCards sums up each series for the KPI cards: its mean rps, latency
+// and error rate, and their change from the baseline.
+func kpiCards(rows []map[string]string, latency string) []kpiCard {
+	var own []map[string]string
+	for _, row := range rows {
+		if row["agent"] == "" {
+			own = append(own, row)
+		}
+	}
+	series, order := seriesRows(own)
+	var cards []kpiCard
+	var baseRPS, baseLat, baseErr float64
+	for i, key := range order {
+		rps := seriesMean(series[key], "requests_per_sec")
+		lat := seriesMean(series[key], latency)
+		errs := seriesMean(series[key], "error_rate")
+		if i == 0 {
+			baseRPS, baseLat, baseErr = rps, lat, errs
+		}
+		cards = append(cards, kpiCard{
+			Series:     key,
+			Baseline:   i == 0,
+			RPS:        fmt.Sprintf("%.1f", rps),
+			Latency:    fmt.Sprintf("%.2f %s", inUnit(lat), displayUnit),
+			ErrorRate:  fmt.Sprintf("%.2f%%", errs),
+			DeltaRPS:   delta(baseRPS, rps, false),
+			DeltaLat:   delta(baseLat, lat, true),
+			DeltaError: delta(baseErr, errs, true),
+		})
+	}
+	return cards
+}
+
+// reportRun is one run in the combined report's table of runs, linking
+// to its archived hey output and raw latencies.
+type reportRun struct {
+	Series    string
+	Run       int // its number within the series
+	RunID     string
+	Started   string
+	RPS       string
+	Latency   string
+	ErrorRate string
+	Summary   string // paths relative to the suite directory, "" if absent
+	Raw       string
+}
+
+func reportRuns(rows []map[string]string, latency string) []reportRun {
+	var runs []reportRun
+	n := map[string]int{}
+	cell := func(row map[string]string, metric, format string) string {
+		if v, ok := rowFloat(row, metric); ok {
+			return fmt.Sprintf(format, displayValue(metric, v))
+		}
+		return "–"
+	}
+	for _, row := range rows {
+		key := rowSeriesKey(row)
+		n[key]++
+		r := reportRun{
+			Series:    key,
+			Run:       n[key],
+			RunID:     row["run_id"],
+			RPS:       cell(row, "requests_per_sec", "%.1f"),
+			Latency:   cell(row, latency, "%.2f "+unitOf(latency)),
+			ErrorRate: cell(row, "error_rate", "%.2f%%"),
+		}
+		if t, err := time.Parse(time.RFC3339Nano, row["started"]); err == nil {
+			r.Started = t.Local().Format("2006-01-02 15:04:05")
+		}
+		if row["file"] != "" {
+			r.Summary = runHref(row["file"])
+		}
+		if row["raw_file"] != "" {
+			r.Raw = runHref(row["raw_file"])
+		}
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+// writeDashboard writes the combined HTML report: the KPI cards, the
+// runs with links to their files, then every chart of the suite.
+func writeDashboard(filename string, data ReportData) error {
+	if err := writeTemplate(filename, "report.html", dashboardTemplate, data); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Combined report written to %s\n", filename)
+	return nil
+}
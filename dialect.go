@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVDialect controls how result CSVs are written and read back, e.g.
+// {"delimiter": ";", "decimal": ","} for European spreadsheet locales that
+// otherwise split or mangle the numbers, or {"delimiter": "tab"} for TSV.
+type CSVDialect struct {
+	Delimiter string `json:"delimiter"` // "," by default; "tab" or "\t" for TSV
+	Decimal   string `json:"decimal"`   // "." by default, or ","
+	Quote     string `json:"quote"`     // "minimal" (default) or "all"
+}
+
+func (d CSVDialect) validate() error {
+	if r := d.comma(); r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return fmt.Errorf("invalid CSV delimiter %q", d.Delimiter)
+	}
+	if d.Decimal != "" && d.Decimal != "." && d.Decimal != "," {
+		return fmt.Errorf("invalid decimal separator %q, want . or ,", d.Decimal)
+	}
+	if d.Decimal == "," && d.comma() == ',' {
+		return fmt.Errorf("decimal comma needs a delimiter other than a comma, e.g. ;")
+	}
+	if d.Quote != "" && d.Quote != "minimal" && d.Quote != "all" {
+		return fmt.Errorf("invalid CSV quoting %q, want minimal or all", d.Quote)
+	}
+	return nil
+}
+
+func (d CSVDialect) comma() rune {
+	switch d.Delimiter {
+	case "":
+		return ','
+	case "tab", `\t`:
+		return '\t'
+	}
+	r, size := utf8.DecodeRuneInString(d.Delimiter)
+	if size != len(d.Delimiter) {
+		return utf8.RuneError
+	}
+	return r
+}
+
+// csvWriter writes records in cfg.CSV's dialect. Unlike encoding/csv it
+// can quote every field and localise the decimal separator of numbers.
+type csvWriter struct {
+	w *bufio.Writer
+	d CSVDialect
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: bufio.NewWriter(w), d: cfg.CSV}
+}
+
+func (c *csvWriter) Write(record []string) error {
+	comma := string(c.d.comma())
+	for i, field := range record {
+		if i > 0 {
+			c.w.WriteString(comma)
+		}
+		if c.d.Decimal == "," && isNumber(field) {
+			field = strings.Replace(field, ".", ",", 1)
+		}
+		if c.d.Quote == "all" || (field != "" && (strings.ContainsAny(field, comma+"\"\r\n") || field[0] == ' ')) {
+			field = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+		c.w.WriteString(field)
+	}
+	_, err := c.w.WriteString("\n")
+	return err
+}
+
+func (c *csvWriter) Flush() error {
+	return c.w.Flush()
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = cfg.CSV.comma()
+	return reader
+}
+
+// csvNumber undoes the dialect's decimal comma so a cell read back from a
+// result CSV parses as a float.
+func csvNumber(s string) string {
+	if cfg.CSV.Decimal != "," || strings.Count(s, ",") != 1 {
+		return s
+	}
+	if v := strings.Replace(s, ",", ".", 1); isNumber(v) {
+		return v
+	}
+	return s
+}
+
+// isNumber reports whether s is a finite decimal number, as metric cells
+// are. ParseFloat alone would also take inf, nan and hex floats like
+// 0x1p-2.
+func isNumber(s string) bool {
+	v, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsInf(v, 0) && !math.IsNaN(v) && !strings.ContainsAny(s, "xX_")
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestIsNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"0", true},
+		{"12.5000", true},
+		{"-3", true},
+		{"+3", true},
+		{".5", true},
+		{"1e3", true},
+		{"2.5E-4", true},
+		{"", false},
+		{"abc", false},
+		{"1,5", false},
+		{"1.2.3", false},
+		{"inf", false},
+		{"+Inf", false},
+		{"-infinity", false},
+		{"NaN", false},
+		{"nan", false},
+		{"1e999", false},
+		{"0x1p-2", false},
+		{"0X1P4", false},
+		{"0x_1p0", false},
+		{"1_000", false},
+	}
+	for _, tt := range tests {
+		if got := isNumber(tt.in); got != tt.want {
+			t.Errorf("isNumber(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
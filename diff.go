@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// runDiff implements `diff BEFORE AFTER`: it charts, run by run, how a
+// metric changed between two suites for every target they share, or with
+// --targets between two targets of one suite.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	config := fs.String("config", "", "config whose results store, csv dialect and units to use")
+	store := fs.String("store", "", "results store to look suite IDs up in (default: the config's, or "+defaultStore+")")
+	metric := fs.String("metric", "p95", "metric to compare")
+	mode := fs.String("mode", "percent", "plot the change as percent, ratio (after / before) or diff (after − before)")
+	target := fs.String("target", "", "between two suites, only compare this target")
+	targetPair := fs.String("targets", "", "compare two targets of one suite instead, e.g. t2no3,green-cloud (before,after)")
+	out := fs.String("out", "", "chart file to write (default diff_<metric>.html)")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	cfg = c
+	if cfg.Units != "" {
+		if err := validateUnits(cfg.Units); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		displayUnit = cfg.Units
+	}
+	if *store == "" {
+		*store = cfg.Store
+	}
+	if *store == "" {
+		*store = defaultStore
+	}
+	if *mode != "percent" && *mode != "ratio" && *mode != "diff" {
+		fmt.Printf("❌ Unknown --mode %q, want percent, ratio or diff\n", *mode)
+		os.Exit(1)
+	}
+	col := metricColumn(*metric)
+	if !numericColumn(col) {
+		fmt.Printf("❌ %s isn't a numeric metric\n", *metric)
+		os.Exit(1)
+	}
+	if *out == "" {
+		*out = "diff_" + strings.ReplaceAll(*metric, ".", "_") + ".html"
+	}
+
+	var pairs []diffPair
+	switch {
+	case *targetPair != "" && fs.NArg() == 1:
+		names := strings.Split(*targetPair, ",")
+		if len(names) != 2 {
+			fmt.Println("❌ --targets takes two targets, before,after")
+			os.Exit(1)
+		}
+		rows, err := loadSuiteResults(fs.Arg(0), *store)
+		if err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		series, _ := seriesOf(rows, col)
+		pairs = append(pairs, diffPair{name: names[1] + " vs " + names[0], before: series[names[0]], after: series[names[1]]})
+	case *targetPair == "" && fs.NArg() == 2:
+		a, err := loadSuiteResults(fs.Arg(0), *store)
+		if err == nil {
+			var b []HeyResult
+			if b, err = loadSuiteResults(fs.Arg(1), *store); err == nil {
+				pairs = suitePairs(a, b, col, *target)
+			}
+		}
+		if err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println("❌ Usage: diff [flags] BEFORE AFTER, or diff --targets BEFORE,AFTER SUITE")
+		os.Exit(1)
+	}
+
+	var kept []diffPair
+	for _, p := range pairs {
+		if len(p.before) == 0 || len(p.after) == 0 {
+			fmt.Printf("⚠️  %s: no %s values on one side, skipping\n", p.name, *metric)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if len(kept) == 0 {
+		fmt.Println("❌ Nothing to compare")
+		os.Exit(1)
+	}
+	if err := writeDiffChart(*out, kept, *metric, *mode); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Chart written to %s\n", *out)
+	for _, p := range kept {
+		n := min(len(p.before), len(p.after))
+		lower := 0
+		for i := 0; i < n; i++ {
+			if p.after[i] < p.before[i] {
+				lower++
+			}
+		}
+		change := "n/a"
+		if m := mean(p.before[:n]); m != 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*(mean(p.after[:n])-m)/m)
+		}
+		fmt.Printf("→ %s: %s %s on average over %d runs, lower in %d of them\n", p.name, *metric, change, n, lower)
+	}
+}
+
+// diffPair is one compared series: its values per run index before and
+// after.
+type diffPair struct {
+	name          string
+	before, after []float64
+}
+
+// suitePairs pairs up the series two suites share, keyed by target and
+// route.
+func suitePairs(a, b []HeyResult, col, only string) []diffPair {
+	before, order := seriesOf(a, col)
+	after, _ := seriesOf(b, col)
+	var pairs []diffPair
+	for _, key := range order {
+		if only != "" && key != only && !strings.HasPrefix(key, only+" ") {
+			continue
+		}
+		if _, ok := after[key]; ok {
+			pairs = append(pairs, diffPair{name: key, before: before[key], after: after[key]})
+		}
+	}
+	return pairs
+}
+
+// seriesOf collects col per series in run order, skipping invalid rows and
+// missing values.
+func seriesOf(rows []HeyResult, col string) (map[string][]float64, []string) {
+	out := map[string][]float64{}
+	var order []string
+	for _, r := range rows {
+		key := seriesKey(r)
+		if _, ok := out[key]; !ok {
+			out[key] = nil
+			order = append(order, key)
+		}
+		if v, ok := extractMetric(r, col); ok && r.Invalid == "" {
+			out[key] = append(out[key], v)
+		}
+	}
+	return out, order
+}
+
+// loadSuiteResults reads a suite from a suite directory, a results CSV, or
+// the results store by suite ID or its first characters.
+func loadSuiteResults(src, store string) ([]HeyResult, error) {
+	if fi, err := os.Stat(src); err == nil {
+		if fi.IsDir() {
+			src = filepath.Join(src, "hey_results.csv")
+		}
+		return readCSV(src)
+	}
+	f, err := os.Open(store)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a file nor a suite in the results store: %w", src, err)
+	}
+	defer f.Close()
+	var rows []HeyResult
+	suites := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec StoreRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || !strings.HasPrefix(rec.Suite, src) || rec.Row["agent"] != "" {
+			continue
+		}
+		if _, err := migrateRow(nil, rec.Row); err != nil {
+			return nil, err
+		}
+		suites[rec.Suite] = true
+		rows = append(rows, resultFromRow(rec.Row))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	switch len(suites) {
+	case 0:
+		return nil, fmt.Errorf("no suite %s in %s", src, store)
+	case 1:
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("%s matches %d suites in %s, give more of the ID", src, len(suites), store)
+	}
+}
+
+func writeDiffChart(file string, pairs []diffPair, metric, mode string) error {
+	col := metricColumn(metric)
+	yName := map[string]string{"percent": "Δ " + metric + " %", "ratio": metric + " after / before", "diff": "Δ " + withUnit(col)}[mode]
+	baseline := 0.0
+	if mode == "ratio" {
+		baseline = 1
+	}
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Change in " + metric, Subtitle: "after vs before, per run"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: yName}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Test Run"}),
+		charts.WithColorsOpts(seriesColors),
+	)
+	line.SetGlobalOptions(interactiveOpts(map[string]string{"percent": "%", "diff": unitOf(col)}[mode])...)
+	runs := 0
+	for _, p := range pairs {
+		runs = max(runs, min(len(p.before), len(p.after)))
+	}
+	var xAxis []string
+	for i := 1; i <= runs; i++ {
+		xAxis = append(xAxis, strconv.Itoa(i))
+	}
+	line.SetXAxis(xAxis)
+	for _, p := range pairs {
+		var points []opts.LineData
+		for i := 0; i < min(len(p.before), len(p.after)); i++ {
+			b, a := p.before[i], p.after[i]
+			switch {
+			case mode == "diff":
+				points = append(points, opts.LineData{Value: round4(displayValue(col, a-b))})
+			case b == 0:
+				points = append(points, opts.LineData{Value: "-"})
+			case mode == "ratio":
+				points = append(points, opts.LineData{Value: round4(a / b)})
+			default:
+				points = append(points, opts.LineData{Value: round4(100 * (a - b) / b)})
+			}
+		}
+		line.AddSeries(p.name, points, charts.WithMarkLineNameYAxisItemOpts(opts.MarkLineNameYAxisItem{Name: "no change", YAxis: baseline}))
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return line.Render(f)
+}
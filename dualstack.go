@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// Address families of --dual-stack, by the name the report uses and the
+// network a request is dialed over.
+var families = []struct{ name, network string }{{"IPv4", "tcp4"}, {"IPv6", "tcp6"}}
+
+type familyKey struct{}
+
+var familyDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// dialFamily returns a dialer over the address family a request's context
+// forces, tcp4 or tcp6, and otherwise over whichever the resolver offers,
+// shaping every connection to shape, if any.
+func dialFamily(shape *networkShape) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if f, ok := ctx.Value(familyKey{}).(string); ok {
+			network = f
+		}
+		conn, err := familyDialer.DialContext(ctx, network, addr)
+		if err == nil && shape != nil {
+			conn = shape.wrap(conn)
+		}
+		return conn, err
+	}
+}
+
+// withFamily forces req onto t's address family, if it has one.
+func withFamily(req *http.Request, t Target) *http.Request {
+	if t.Family == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), familyKey{}, t.Family))
+}
+
+// dualStackVariants runs every deployment over forced IPv4 and then over
+// forced IPv6.
+func dualStackVariants(targets []Target) []Target {
+	var names []string
+	for _, f := range families {
+		names = append(names, f.name)
+	}
+	return targetVariants(targets, names, func(t *Target, k int) {
+		t.Family = families[k].network
+	})
+}
+
+// familyName is the report's name for a network, "" for none.
+func familyName(network string) string {
+	for _, f := range families {
+		if f.network == network {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// analyzeDualStack compares each deployment's IPv4 and IPv6 runs from
+// --dual-stack in the report and a chart, to check its v6 path is as
+// healthy as its v4 one.
+func analyzeDualStack(rows []map[string]string, filename string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	type side struct{ lat, rps, errs []float64 }
+	pairs := map[string]*[2]side{}
+	for _, row := range rows {
+		k := -1
+		for i, f := range families {
+			if row["family"] == f.name {
+				k = i
+			}
+		}
+		if k < 0 || row["agent"] != "" {
+			continue
+		}
+		base := map[string]string{
+			"target":      strings.TrimSuffix(row["target"], variantName("", row["family"])),
+			"route":       row["route"],
+			"concurrency": row["concurrency"],
+		}
+		key := rowSeriesKey(base)
+		if pairs[key] == nil {
+			pairs[key] = &[2]side{}
+		}
+		s := &pairs[key][k]
+		if v, ok := rowFloat(row, latency); ok {
+			s.lat = append(s.lat, v)
+		}
+		if v, ok := rowFloat(row, "requests_per_sec"); ok {
+			s.rps = append(s.rps, v)
+		}
+		if v, ok := rowFloat(row, "error_rate"); ok {
+			s.errs = append(s.errs, v)
+		}
+	}
+	if len(pairs) == 0 {
+		return
+	}
+	var keys []string
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table [][]string
+	var shown []string
+	var bars [2][]opts.BarData
+	meanOf := func(xs []float64, format string) string {
+		if len(xs) == 0 {
+			return "–"
+		}
+		return fmt.Sprintf(format, mean(xs))
+	}
+	latencyOf := func(xs []float64) string {
+		if len(xs) == 0 {
+			return "–"
+		}
+		return fmt.Sprintf("%.4g", inUnit(mean(xs)))
+	}
+	for _, key := range keys {
+		p := pairs[key]
+		v4, v6 := mean(p[0].lat), mean(p[1].lat)
+		e4, e6 := mean(p[0].errs), mean(p[1].errs)
+		diff := "–"
+		if v4 > 0 && len(p[1].lat) > 0 {
+			diff = fmt.Sprintf("%+.1f%%", 100*(v6-v4)/v4)
+		}
+		verdict := "✅ on par"
+		switch {
+		case len(p[1].errs) == 0 || e6 >= 50:
+			verdict = "❌ IPv6 path failing"
+		case len(p[0].errs) == 0 || e4 >= 50:
+			verdict = "❌ IPv4 path failing"
+		case e6 > e4+1:
+			verdict = "⚠️ more IPv6 errors"
+		case v6 > 1.2*v4:
+			verdict = "⚠️ IPv6 slower"
+		}
+		table = append(table, []string{key,
+			latencyOf(p[0].lat), latencyOf(p[1].lat), diff,
+			meanOf(p[0].rps, "%.1f"), meanOf(p[1].rps, "%.1f"),
+			meanOf(p[0].errs, "%.1f%%"), meanOf(p[1].errs, "%.1f%%"), verdict})
+		fmt.Printf("→ %s: IPv4 vs IPv6 %s, %s\n", key, diff, verdict)
+		if !seriesWanted(key) {
+			continue
+		}
+		shown = append(shown, key)
+		for k := range families {
+			bar := opts.BarData{Name: fmt.Sprintf("%.1f rps · %.1f%% errors", mean(p[k].rps), mean(p[k].errs)), Value: "-"}
+			if len(p[k].lat) > 0 {
+				bar.Value = round4(inUnit(mean(p[k].lat)))
+			}
+			bars[k] = append(bars[k], bar)
+		}
+	}
+	addReportSection("Dual stack",
+		fmt.Sprintf("Every target ran over forced IPv4 and then forced IPv6. Latencies are mean %s in %s; "+
+			"IPv6 is called failing from a 50%% error rate, or when none of its runs completed.\n\n", latency, displayUnit)+
+			markdownTable([]string{"target", "IPv4 " + latency, "IPv6 " + latency, "IPv6 vs IPv4", "IPv4 rps", "IPv6 rps", "IPv4 errors", "IPv6 errors", "verdict"}, table))
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("IPv4 vs IPv6", "mean "+latency)),
+		charts.WithYAxisOpts(opts.YAxis{Name: withUnit(latency)}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Target"}),
+		chartToolbox(),
+		pointTooltip(unitOf(latency)),
+	)
+	bar.SetXAxis(shown)
+	for k, f := range families {
+		bar.AddSeries(f.name, bars[k], charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[k]}))
+	}
+	renderChart(bar, filename)
+}
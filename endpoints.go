@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// endpointOf is the deployment and path a target's results belong to: a
+// mix's name and route, or otherwise the URL's host and path, so several
+// URLs on one host are paths of the same deployment.
+func endpointOf(t Target) (deployment, path string) {
+	if t.Route != "" {
+		return t.Name, t.Route
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || u.Host == "" {
+		return t.Name, ""
+	}
+	return u.Host, u.RequestURI()
+}
+
+// endpointCell is one path of one deployment.
+type endpointCell struct {
+	deployment, path string
+	rows             []map[string]string
+}
+
+func (c *endpointCell) mean(metric string) (float64, bool) {
+	var xs []float64
+	for _, row := range c.rows {
+		if v, ok := rowFloat(row, metric); ok {
+			xs = append(xs, v)
+		}
+	}
+	if len(xs) == 0 {
+		return 0, false
+	}
+	return mean(xs), true
+}
+
+// requests totals the cell's requests; a mix's routes don't have their
+// own count, so theirs are counted in the run files.
+func (c *endpointCell) requests() float64 {
+	n := 0.0
+	for _, row := range c.rows {
+		if v, ok := rowFloat(row, "requests"); ok {
+			n += v
+			continue
+		}
+		codes, errs, err := runStatuses(row["file"])
+		if err != nil {
+			continue
+		}
+		n += float64(errs)
+		for _, k := range codes {
+			n += float64(k)
+		}
+	}
+	return n
+}
+
+// slowRouteFactor is how much slower than its deployment's average a path
+// is flagged at.
+const slowRouteFactor = 1.5
+
+// analyzeEndpoints breaks the results of deployments with several paths
+// down by path: a row per path and deployment in hey_endpoints.csv and
+// the report, beside the deployment's request-weighted average, and a
+// grouped bar chart of path × deployment. A slow route can hide behind a
+// good average; those slowRouteFactor times slower than theirs are
+// flagged.
+func analyzeEndpoints(rows []map[string]string, targets []Target, csvFile, chartFile string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	byKey := map[string]Target{}
+	for _, t := range targets {
+		byKey[t.Name+"\x00"+t.Route] = t
+	}
+	cells := map[[2]string]*endpointCell{}
+	paths := map[string]map[string]bool{}
+	var deployments, pathOrder []string
+	seenPath := map[string]bool{}
+	for _, row := range rows {
+		t, ok := byKey[row["target"]+"\x00"+row["route"]]
+		if row["agent"] != "" || !ok {
+			continue // agents' shares, and a mix's aggregate
+		}
+		dep, path := endpointOf(t)
+		if len(cfg.Sweep) > 1 {
+			path += " c=" + row["concurrency"]
+		}
+		key := [2]string{dep, path}
+		if cells[key] == nil {
+			cells[key] = &endpointCell{deployment: dep, path: path}
+			if paths[dep] == nil {
+				paths[dep] = map[string]bool{}
+				deployments = append(deployments, dep)
+			}
+			paths[dep][path] = true
+			if !seenPath[path] {
+				seenPath[path] = true
+				pathOrder = append(pathOrder, path)
+			}
+		}
+		cells[key].rows = append(cells[key].rows, row)
+	}
+	multi := false
+	for _, dep := range deployments {
+		multi = multi || len(paths[dep]) > 1
+	}
+	if !multi {
+		return
+	}
+	sort.Strings(deployments)
+
+	type depAverage struct{ latency, errorRate float64 }
+	averages := map[string]depAverage{}
+	for _, dep := range deployments {
+		var lat, errs, weight float64
+		for _, path := range pathOrder {
+			c := cells[[2]string{dep, path}]
+			if c == nil {
+				continue
+			}
+			w := c.requests()
+			l, okL := c.mean(latency)
+			e, okE := c.mean("error_rate")
+			if w == 0 || !okL || !okE {
+				continue
+			}
+			lat += l * w
+			errs += e * w
+			weight += w
+		}
+		if weight > 0 {
+			averages[dep] = depAverage{lat / weight, errs / weight}
+		}
+	}
+
+	f, err := os.Create(csvFile)
+	if err != nil {
+		fmt.Println("❌ Error writing endpoint breakdown:", err)
+		return
+	}
+	defer f.Close()
+	w := newCSVWriter(f)
+	defer w.Flush()
+	w.Write([]string{"deployment", "path", "runs", "requests", "requests_per_sec", latency, "error_rate", latency + "_vs_deployment"})
+
+	var table [][]string
+	var flagged []string
+	format := func(v float64, ok bool) string {
+		if !ok {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+	for _, dep := range deployments {
+		avg, hasAvg := averages[dep]
+		for _, path := range pathOrder {
+			c := cells[[2]string{dep, path}]
+			if c == nil {
+				continue
+			}
+			rps, okR := c.mean("requests_per_sec")
+			lat, okL := c.mean(latency)
+			errRate, okE := c.mean("error_rate")
+			vs, okVs := 0.0, okL && hasAvg && avg.latency > 0
+			if okVs {
+				vs = 100 * (lat - avg.latency) / avg.latency
+			}
+			w.Write([]string{dep, path, strconv.Itoa(len(c.rows)), fmt.Sprintf("%.0f", c.requests()), format(rps, okR), format(lat, okL), format(errRate, okE), format(vs, okVs)})
+
+			note := ""
+			if okVs && len(paths[dep]) > 1 && lat >= slowRouteFactor*avg.latency {
+				note = fmt.Sprintf("⚠️ %.1f× the average", lat/avg.latency)
+				flagged = append(flagged, fmt.Sprintf("%s %s: %s %.1f× the deployment's average", dep, path, latency, lat/avg.latency))
+			}
+			table = append(table, []string{dep, path, strconv.Itoa(len(c.rows)), fmt.Sprintf("%.2f", rps),
+				fmt.Sprintf("%.4g", displayValue(latency, lat)), fmt.Sprintf("%.2f%%", errRate), note})
+		}
+		if hasAvg && len(paths[dep]) > 1 {
+			table = append(table, []string{dep, "**all paths**", "", "", fmt.Sprintf("**%.4g**", displayValue(latency, avg.latency)), fmt.Sprintf("**%.2f%%**", avg.errorRate), ""})
+		}
+	}
+	fmt.Println("✅ Endpoint breakdown written to", csvFile)
+	body := fmt.Sprintf("Every path of each deployment, with the deployment's average over all of them weighted by requests. Paths at %v× their deployment's average %s or slower are flagged.\n\n", slowRouteFactor, latency)
+	for _, p := range flagged {
+		fmt.Println("⚠️ ", p)
+	}
+	addReportSection("Endpoint breakdown", body+markdownTable([]string{"deployment", "path", "runs", "rps", withUnit(latency), "errors", ""}, table))
+
+	page := components.NewPage()
+	page.SetPageTitle("Endpoints")
+	page.SetLayout(components.PageFlexLayout)
+	for _, metric := range []string{latency, "requests_per_sec"} {
+		name, unit := withUnit(metric), unitOf(metric)
+		if metric == "requests_per_sec" {
+			name, unit = "rps", "rps"
+		}
+		bar := charts.NewBar()
+		bar.SetGlobalOptions(
+			charts.WithTitleOpts(chartTitle(name+" per path", "")),
+			charts.WithYAxisOpts(opts.YAxis{Name: name}),
+			charts.WithXAxisOpts(opts.XAxis{Name: "Path", AxisLabel: &opts.AxisLabel{Rotate: 30}}),
+			charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+			charts.WithColorsOpts(seriesColors),
+			chartToolbox(),
+			charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis", Formatter: tooltipFormatter(unit)}),
+		)
+		bar.SetXAxis(pathOrder)
+		for _, dep := range deployments {
+			var data []opts.BarData
+			for _, path := range pathOrder {
+				c := cells[[2]string{dep, path}]
+				v, ok := 0.0, false
+				if c != nil {
+					v, ok = c.mean(metric)
+				}
+				if !ok {
+					data = append(data, opts.BarData{Value: nil})
+					continue
+				}
+				data = append(data, opts.BarData{Value: round4(displayValue(metric, v))})
+			}
+			bar.AddSeries(dep, data)
+		}
+		page.AddCharts(bar)
+	}
+	renderChart(page, chartFile)
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const nativeTimeout = 20 * time.Second
+
+type sample struct {
+	target  int
+	offset  time.Duration // since the start of the run
+	latency time.Duration
+	status  int
+	size    int64
+	err     string
+
+	retryAfter time.Duration // from a 429's Retry-After
+
+	newConn, reused, tlsHandshake bool // how the request got its connection
+}
+
+type nativeRun struct {
+	samples []sample
+	total   time.Duration
+}
+
+// nativeOptions are the settings a native run takes from the suite's
+// flags, or from an agent's job, passed down rather than read from the
+// flags so an agent's jobs can't leak into each other.
+type nativeOptions struct {
+	noCookies  bool
+	interleave bool // alternate requests between the targets
+	retryAfter bool
+	shape      *networkShape
+	seed       int64 // the stream of the mix picks, 0 for the suite's next
+}
+
+// suiteOptions are the native options this process's flags set.
+func suiteOptions() nativeOptions {
+	return nativeOptions{noCookies: *noCookies, interleave: *abMode, retryAfter: *retryAfter, shape: shape}
+}
+
+// runNative sends n requests from c concurrent workers, each request picked
+// from targets according to their weights, the same closed-loop model hey
+// uses.
+func runNative(targets []Target, n, c int, opts nativeOptions) nativeRun {
+	if c > n {
+		c = n
+	}
+	transport := newNativeTransport(c, opts)
+
+	pick := weightedPicker(targets, opts)
+
+	var sent int64
+	results := make([][]sample, c)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			client := newVUClient(transport, timeoutFor(targets[0]), opts)
+			for k := atomic.AddInt64(&sent, 1) - 1; k < int64(n); k = atomic.AddInt64(&sent, 1) - 1 {
+				ti := pick(k)
+				at := time.Since(start)
+				s := doRequest(client, targets[ti])
+				s.target = ti
+				s.offset = at
+				results[w] = append(results[w], s)
+				waitRetryAfter(s, opts)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	run := nativeRun{total: time.Since(start)}
+	for _, r := range results {
+		run.samples = append(run.samples, r...)
+	}
+	return run
+}
+
+// runNativeRate sends n requests at a constant arrival rate (requests/sec)
+// with up to c in flight, an open workload model. Latency is measured from
+// each request's scheduled start rather than from when a worker got to it,
+// so time spent queued behind a saturated server counts against the tail
+// instead of being silently omitted as in a closed loop.
+func runNativeRate(targets []Target, n, c int, rate float64, opts nativeOptions) nativeRun {
+	interval := time.Duration(float64(time.Second) / rate)
+	offsets := make([]time.Duration, n)
+	for k := range offsets {
+		offsets[k] = time.Duration(k) * interval
+	}
+	return runNativeSchedule(targets, c, offsets, opts)
+}
+
+// runNativeSchedule sends a request at each of offsets from the start,
+// with up to c in flight, measuring latency from the scheduled start as
+// runNativeRate describes.
+func runNativeSchedule(targets []Target, c int, offsets []time.Duration, opts nativeOptions) nativeRun {
+	transport := newNativeTransport(c, opts)
+	pick := weightedPicker(targets, opts)
+
+	schedule := make(chan int, len(offsets))
+	results := make([][]sample, c)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			client := newVUClient(transport, timeoutFor(targets[0]), opts)
+			for k := range schedule {
+				at := offsets[k]
+				ti := pick(int64(k))
+				queued := time.Since(start) - at
+				s := doRequest(client, targets[ti])
+				s.target = ti
+				s.offset = at
+				s.latency += queued
+				results[w] = append(results[w], s)
+			}
+		}(w)
+	}
+	for k, at := range offsets {
+		time.Sleep(time.Until(start.Add(at)))
+		schedule <- k
+	}
+	close(schedule)
+	wg.Wait()
+
+	run := nativeRun{total: time.Since(start)}
+	for _, r := range results {
+		run.samples = append(run.samples, r...)
+	}
+	return run
+}
+
+// weightedPicker returns a function choosing the target of a run's k-th
+// request according to the targets' weights; unweighted targets count as
+// 1. The choice depends only on k and the suite's seed, not on which
+// virtual user sends the request or when, so a rerun with the same --seed
+// sends the same sequence.
+func weightedPicker(targets []Target, opts nativeOptions) func(k int64) int {
+	if opts.interleave {
+		// an A/B experiment alternates requests strictly
+		return func(k int64) int {
+			return int(k % int64(len(targets)))
+		}
+	}
+	cumulative := make([]float64, len(targets))
+	sum := 0.0
+	for i, t := range targets {
+		w := t.Weight
+		if w <= 0 {
+			w = 1
+		}
+		sum += w
+		cumulative[i] = sum
+	}
+	u := nextUniform()
+	if opts.seed != 0 {
+		u = uniform(opts.seed)
+	}
+	return func(k int64) int {
+		i := sort.SearchFloat64s(cumulative, u(k)*sum)
+		if i >= len(targets) {
+			i = len(targets) - 1
+		}
+		return i
+	}
+}
+
+func newNativeTransport(c int, opts nativeOptions) *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialFamily(opts.shape),
+		ForceAttemptHTTP2:   true, // a custom dialer turns HTTP/2 off otherwise
+		MaxIdleConnsPerHost: c,
+	}
+}
+
+// newVUClient returns the client of one virtual user. Each gets its own
+// cookie jar, so session-affinity load balancers see distinct, sticky
+// sessions, unless --no-cookies asks for stateless requests.
+func newVUClient(transport http.RoundTripper, timeout time.Duration, opts nativeOptions) *http.Client {
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	if !opts.noCookies {
+		client.Jar, _ = cookiejar.New(nil)
+	}
+	return client
+}
+
+func doRequest(client *http.Client, t Target) sample {
+	if t.Bust != nil {
+		t = t.Bust.apply(t)
+	}
+	var body io.Reader
+	if t.Body != "" {
+		body = strings.NewReader(t.Body)
+	}
+	req, err := http.NewRequest(t.Method, t.URL, body)
+	if err != nil {
+		return sample{err: err.Error()}
+	}
+	for _, h := range t.Headers {
+		req.Header.Set(h.Name, h.Value)
+	}
+	var conn connTrace
+	req = conn.attach(withFamily(req, t))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return conn.record(sample{latency: time.Since(start), err: err.Error()})
+	}
+	size, _ := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	s := conn.record(sample{latency: time.Since(start), status: resp.StatusCode, size: size})
+	if s.status == http.StatusTooManyRequests {
+		s.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return s
+}
+
+// filter returns the samples that hit the target at index i.
+func (r nativeRun) filter(i int) []sample {
+	var out []sample
+	for _, s := range r.samples {
+		if s.target == i {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// writeHeySummary renders samples in hey's text report format, so native
+// runs leave the same files as hey runs, and returns their summary.
+func writeHeySummary(file string, samples []sample, total time.Duration) (runSummary, error) {
+	sum := runSummary{total: total, statuses: map[int]int{}, errs: map[string]int{}}
+	var lats []float64
+	for _, s := range samples {
+		if sum.add(s) {
+			lats = append(lats, s.latency.Seconds())
+		}
+	}
+	sort.Float64s(lats)
+	sum.quantile = func(p float64) float64 { return percentile(lats, p) }
+	if len(lats) > 0 {
+		sum.marks = histogramMarks(lats[0], lats[len(lats)-1])
+		sum.counts = make([]int, len(sum.marks))
+		for _, l := range lats {
+			sum.counts[bucketOf(sum.marks, l)]++
+		}
+	}
+	return sum, sum.write(file)
+}
+
+// runSummary holds what a hey-format report needs, so it can be filled
+// from samples in memory or streamed from a raw file.
+type runSummary struct {
+	total            time.Duration
+	count            int
+	sum              float64
+	fastest, slowest float64
+	bytes            int64
+	statuses         map[int]int
+	errs             map[string]int
+	marks            []float64
+	counts           []int
+	quantile         func(p float64) float64
+}
+
+// requests is how many requests the summary counts, failed ones included.
+func (r *runSummary) requests() int {
+	n := r.count
+	for _, c := range r.errs {
+		n += c
+	}
+	return n
+}
+
+// rps is the run's successful requests per second, 0 for a run that took
+// no measurable time rather than NaN or +Inf.
+func (r *runSummary) rps() float64 {
+	if r.total <= 0 {
+		return 0
+	}
+	return float64(r.count) / r.total.Seconds()
+}
+
+// add counts s and reports whether it was a successful request.
+func (r *runSummary) add(s sample) bool {
+	if s.err != "" {
+		r.errs[s.err]++
+		return false
+	}
+	l := s.latency.Seconds()
+	if r.count == 0 || l < r.fastest {
+		r.fastest = l
+	}
+	if l > r.slowest {
+		r.slowest = l
+	}
+	r.count++
+	r.sum += l
+	r.bytes += s.size
+	r.statuses[s.status]++
+	return true
+}
+
+func (r runSummary) write(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "\nSummary:\n")
+	fmt.Fprintf(f, "  Total:\t%4.4f secs\n", r.total.Seconds())
+	if r.count > 0 {
+		fmt.Fprintf(f, "  Slowest:\t%4.4f secs\n", r.slowest)
+		fmt.Fprintf(f, "  Fastest:\t%4.4f secs\n", r.fastest)
+		fmt.Fprintf(f, "  Average:\t%4.4f secs\n", r.sum/float64(r.count))
+	}
+	fmt.Fprintf(f, "  Requests/sec:\t%4.4f\n", r.rps())
+	// like hey, sizes are left out when no response had a body, which
+	// is also all hey's CSV output can tell
+	if r.count > 0 && r.bytes > 0 {
+		fmt.Fprintf(f, "  \n  Total data:\t%d bytes\n", r.bytes)
+		fmt.Fprintf(f, "  Size/request:\t%d bytes\n", r.bytes/int64(r.count))
+	}
+	if r.count > 0 {
+		fmt.Fprintf(f, "\nResponse time histogram:\n")
+		writeHistogram(f, r.marks, r.counts)
+
+		fmt.Fprintf(f, "\nLatency distribution:\n")
+		for _, p := range summaryPercentiles() {
+			fmt.Fprintf(f, "  %v%% in %4.4f secs\n", p, r.quantile(p))
+		}
+	}
+
+	if len(r.statuses) > 0 {
+		fmt.Fprintf(f, "\nStatus code distribution:\n")
+		var codes []int
+		for code := range r.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(f, "  [%d]\t%d responses\n", code, r.statuses[code])
+		}
+	}
+	if len(r.errs) > 0 {
+		fmt.Fprintf(f, "\nError distribution:\n")
+		for e, count := range r.errs {
+			fmt.Fprintf(f, "  [%d]\t%s\n", count, e)
+		}
+	}
+	return nil
+}
+
+// metrics returns the run's result columns, the same ones parseHeyFile
+// scrapes from a hey summary, with latencies in milliseconds.
+func (r runSummary) metrics(file string) map[string]string {
+	ms := func(secs float64) string { return fmt.Sprintf("%.4f", secs*1000) }
+	row := map[string]string{
+		"file":             filepath.Base(file),
+		"total":            ms(r.total.Seconds()),
+		"requests_per_sec": fmt.Sprintf("%.4f", r.rps()),
+	}
+	if r.count > 0 {
+		row["fastest"] = ms(r.fastest)
+		row["slowest"] = ms(r.slowest)
+		row["average"] = ms(r.sum / float64(r.count))
+		if r.bytes > 0 {
+			row["size_request"] = fmt.Sprintf("%.4f", float64(r.bytes/int64(r.count)))
+		}
+		for _, p := range cfg.Percentiles {
+			row[percentileKey(p)] = ms(r.quantile(p))
+		}
+	}
+	return row
+}
+
+// histogramMarks returns hey's 11 bucket upper bounds from fastest to
+// slowest.
+func histogramMarks(fastest, slowest float64) []float64 {
+	const buckets = 10
+	size := (slowest - fastest) / buckets
+	marks := make([]float64, buckets+1)
+	for i := range marks {
+		marks[i] = fastest + size*float64(i)
+	}
+	return marks
+}
+
+func bucketOf(marks []float64, l float64) int {
+	i := sort.SearchFloat64s(marks, l)
+	if i >= len(marks) {
+		i = len(marks) - 1
+	}
+	return i
+}
+
+func writeHistogram(w io.Writer, marks []float64, counts []int) {
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	for i, c := range counts {
+		bar := 0
+		if max > 0 {
+			bar = c * 40 / max
+		}
+		fmt.Fprintf(w, "  %4.3f [%d]\t|%s\n", marks[i], c, strings.Repeat("■", bar))
+	}
+}
+
+// percentile expects sorted input and uses the nearest-rank method: the
+// smallest value with at least p% of the input at or below it.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	hundred := make([]float64, 100)
+	for i := range hundred {
+		hundred[i] = float64(i + 1)
+	}
+	tests := []struct {
+		in   []float64
+		p    float64
+		want float64
+	}{
+		{nil, 50, 0},
+		{[]float64{7}, 99, 7},
+		{ten, 50, 5},
+		{ten, 51, 6},
+		{ten, 90, 9},
+		{ten, 95, 10},
+		{ten, 99.9, 10},
+		{ten, 100, 10},
+		{ten, 0, 1},
+		{[]float64{1, 2, 3, 4}, 25, 1},
+		{[]float64{1, 2, 3, 4}, 26, 2},
+		{hundred, 7, 7}, // 7/100*100 isn't exactly 7 in floating point
+		{hundred, 99, 99},
+		{hundred, 99.9, 100},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.in, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.in, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestWeightedPicker(t *testing.T) {
+	targets := []Target{{Weight: 3}, {Weight: 1}, {}}
+	counts := make([]int, len(targets))
+	pick := weightedPicker(targets, nativeOptions{seed: 42})
+	const n = 50000
+	for k := int64(0); k < n; k++ {
+		counts[pick(k)]++
+	}
+	// shares of 3:1:1, an unweighted target counting as 1
+	for i, want := range []float64{0.6, 0.2, 0.2} {
+		if got := float64(counts[i]) / n; math.Abs(got-want) > 0.01 {
+			t.Errorf("target %d got %.3f of the requests, want %.1f", i, got, want)
+		}
+	}
+
+	again := weightedPicker(targets, nativeOptions{seed: 42})
+	other := weightedPicker(targets, nativeOptions{seed: 43})
+	same, differ := true, false
+	for k := int64(0); k < 1000; k++ {
+		same = same && again(k) == pick(k)
+		differ = differ || other(k) != pick(k)
+	}
+	if !same {
+		t.Error("the same seed picked a different sequence")
+	}
+	if !differ {
+		t.Error("another seed picked the same sequence")
+	}
+
+	ab := weightedPicker(targets[:2], nativeOptions{interleave: true, seed: 42})
+	for k := int64(0); k < 6; k++ {
+		if got := ab(k); got != int(k%2) {
+			t.Errorf("interleaved pick(%d) = %d, want %d", k, got, k%2)
+		}
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	saved := cfg.Percentiles
+	defer func() { cfg.Percentiles = saved }()
+	cfg.Percentiles = []float64{50, 99}
+
+	var samples []sample
+	for i := 1; i <= 4; i++ {
+		samples = append(samples, sample{latency: time.Duration(i) * 100 * time.Millisecond, status: 200, size: 10})
+	}
+	samples = append(samples, sample{err: "connection refused"}, sample{latency: time.Second, status: 503})
+
+	file := filepath.Join(t.TempDir(), "run.txt")
+	sum, err := writeHeySummary(file, samples, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.requests() != 6 {
+		t.Errorf("requests() = %d, want 6", sum.requests())
+	}
+	got := sum.metrics(file)
+	want := map[string]string{
+		"total":            "2000.0000",
+		"requests_per_sec": "2.5000",
+		"fastest":          "100.0000",
+		"slowest":          "1000.0000",
+		"average":          "400.0000",
+		"size_request":     "8.0000",
+		"p50":              "300.0000",
+		"p99":              "1000.0000",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("metrics: %s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"  Requests/sec:\t2.5000", "  [200]\t4 responses", "  [503]\t1 responses", "  [1]\tconnection refused"} {
+		if !strings.Contains(string(b), line+"\n") {
+			t.Errorf("summary lacks %q:\n%s", line, b)
+		}
+	}
+}
+
+func TestRunSummaryNoTime(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "run.txt")
+	sum, err := writeHeySummary(file, []sample{{err: "dial tcp: refused"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := sum.metrics(file)
+	if row["requests_per_sec"] != "0.0000" {
+		t.Errorf("requests_per_sec = %q, want 0.0000", row["requests_per_sec"])
+	}
+	if b, _ := os.ReadFile(file); strings.Contains(string(b), "NaN") || strings.Contains(string(b), "Inf") {
+		t.Errorf("summary of a zero-length run isn't numeric:\n%s", b)
+	}
+}
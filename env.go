@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the environment variables that configure the suite, one
+// per config key or flag, e.g. PERTOOLS_URLS, PERTOOLS_CONCURRENCY or
+// PERTOOLS_ENGINE, so a container or CI job needs no config file. They sit
+// beneath both: a config file overrides them, and flags override that.
+const envPrefix = "PERTOOLS_"
+
+// envName is the variable for a config key or flag: ci_width and
+// ci-width are both PERTOOLS_CI_WIDTH.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// configKeys are Config's JSON keys, in field order.
+func configKeys() []string {
+	t := reflect.TypeOf(Config{})
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// applyEnvConfig sets c's fields from their variables. Lists are
+// comma-separated, string maps are k=v,k=v, and anything else, or a value
+// starting with [ or {, is JSON as in a config file.
+func applyEnvConfig(c *Config) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		raw, ok := os.LookupEnv(envName(key))
+		if key == "" || !ok {
+			continue
+		}
+		if err := setFromEnv(v.Field(i), raw); err != nil {
+			return fmt.Errorf("%s: %w", envName(key), err)
+		}
+	}
+	return nil
+}
+
+func setFromEnv(f reflect.Value, raw string) error {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return json.Unmarshal([]byte(s), f.Addr().Interface())
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		f.SetInt(int64(n))
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		f.SetFloat(x)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		list := reflect.MakeSlice(f.Type(), 0, 0)
+		for _, part := range splitList(s) {
+			elem := reflect.New(f.Type().Elem()).Elem()
+			if k := elem.Kind(); k == reflect.Struct || k == reflect.Slice || k == reflect.Map {
+				return fmt.Errorf("want a JSON list")
+			}
+			if err := setFromEnv(elem, part); err != nil {
+				return err
+			}
+			list = reflect.Append(list, elem)
+		}
+		f.Set(list)
+	case reflect.Map:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("want a JSON object")
+		}
+		m := reflect.MakeMap(f.Type())
+		for _, pair := range splitList(s) {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid %q, want key=value", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		f.Set(m)
+	default:
+		return fmt.Errorf("want JSON")
+	}
+	return nil
+}
+
+// applyEnvFlags sets the suite's flags that have no config key from their
+// variables, before the command line is parsed so it overrides them.
+// Flags that do have one, like --percentiles, are left to the config
+// layer, beneath the config file. A repeatable flag takes a
+// comma-separated list, and the command line adds to it.
+func applyEnvFlags(fs *flag.FlagSet) error {
+	keys := map[string]bool{}
+	for _, key := range configKeys() {
+		keys[key] = true
+	}
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		raw, ok := os.LookupEnv(envName(fl.Name))
+		if !ok || err != nil || keys[strings.ReplaceAll(fl.Name, "-", "_")] {
+			return
+		}
+		values := []string{raw}
+		if _, repeatable := fl.Value.(*stringList); repeatable {
+			values = splitList(raw)
+		}
+		for _, v := range values {
+			if serr := fl.Value.Set(v); serr != nil {
+				err = fmt.Errorf("%s: %w", envName(fl.Name), serr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// envSettings lists the PERTOOLS_ variables set, and those among them
+// that match no config key or flag, likely typos.
+func envSettings(fs *flag.FlagSet) (used, unknown []string) {
+	known := map[string]bool{}
+	for _, key := range configKeys() {
+		known[envName(key)] = true
+	}
+	fs.VisitAll(func(fl *flag.Flag) { known[envName(fl.Name)] = true })
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) || name == defaultAgentTokenEnv {
+			continue // the agents' token is read where it's used
+		}
+		if known[name] {
+			used = append(used, name)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(used)
+	sort.Strings(unknown)
+	return used, unknown
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// generateEnvelopeChart draws each target's average latency per run inside
+// a shaded band from that run's fastest to its slowest request, showing
+// the spread the average hides. The band is two stacked series: an
+// invisible one up to fastest, then one as tall as slowest − fastest with
+// its area filled.
+func generateEnvelopeChart(data []HeyResult, filename string) {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Latency Envelope", "average within fastest–slowest")),
+		charts.WithYAxisOpts(opts.YAxis{Name: withUnit("average")}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Test Run"}),
+	)
+	line.SetGlobalOptions(interactiveOpts(displayUnit)...)
+	line.SetGlobalOptions(pointTooltip(displayUnit))
+	var xAxis []string
+	for i := 1; i <= cfg.Repeat; i++ {
+		xAxis = append(xAxis, fmt.Sprintf("%d", i))
+	}
+	line.SetXAxis(xAxis)
+
+	type band struct{ low, span, avg []opts.LineData }
+	bands := map[string]*band{}
+	var order []string
+	gap := opts.LineData{Value: "-"}
+	for _, d := range data {
+		key := seriesKey(d)
+		if !seriesWanted(key) {
+			continue
+		}
+		b, ok := bands[key]
+		if !ok {
+			b = &band{}
+			bands[key] = b
+			order = append(order, key)
+		}
+		lo, okLo := d.Values["fastest"]
+		hi, okHi := d.Values["slowest"]
+		avg, okAvg := d.Values["average"]
+		if d.Invalid != "" || !okLo || !okHi || !okAvg {
+			b.low, b.span, b.avg = append(b.low, gap), append(b.span, gap), append(b.avg, gap)
+			continue
+		}
+		lo, hi = inUnit(lo), inUnit(hi)
+		b.low = append(b.low, opts.LineData{Value: lo})
+		b.span = append(b.span, opts.LineData{Value: math.Round((hi-lo)*1e4) / 1e4})
+		b.avg = append(b.avg, opts.LineData{Name: runDetail(d), Value: inUnit(avg)})
+	}
+
+	hidden := opts.LineStyle{Color: "transparent"}
+	for i, key := range order {
+		color := seriesColors[i%len(seriesColors)]
+		b := bands[key]
+		line.AddSeries(key+" fastest", b.low,
+			charts.WithLineChartOpts(opts.LineChart{Stack: key, ShowSymbol: opts.Bool(false)}),
+			charts.WithLineStyleOpts(hidden))
+		line.AddSeries(key+" slowest − fastest", b.span,
+			charts.WithLineChartOpts(opts.LineChart{Stack: key, ShowSymbol: opts.Bool(false)}),
+			charts.WithLineStyleOpts(hidden),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color}),
+			charts.WithAreaStyleOpts(opts.AreaStyle{Color: color, Opacity: 0.2}))
+		line.AddSeries(key, b.avg,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: color, Width: 2}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color}))
+	}
+
+	renderChart(line, filename)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"debug/buildinfo"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Environment describes the load generator the suite ran on. Client-side
+// conditions (a busy laptop, Wi-Fi instead of wired) skew results as much as
+// the server does, so they're recorded with every suite.
+type Environment struct {
+	Hostname   string `json:"hostname"`
+	OS         string `json:"os"`
+	CPU        string `json:"cpu,omitempty"`
+	Cores      int    `json:"cores"`
+	GoVersion  string `json:"go_version"`
+	HeyVersion string `json:"hey_version,omitempty"`
+	Interface  string `json:"interface,omitempty"`
+}
+
+// captureEnvironment inspects this machine; probe is a target URL used to
+// find the network interface the load leaves through.
+func captureEnvironment(probe string) Environment {
+	env := Environment{
+		OS:        runtime.GOOS + "/" + runtime.GOARCH,
+		Cores:     runtime.NumCPU(),
+		GoVersion: runtime.Version(),
+		CPU:       cpuModel(),
+		Interface: outboundInterface(probe),
+	}
+	env.Hostname, _ = os.Hostname()
+	if *engine == "hey" {
+		env.HeyVersion = heyVersion()
+	}
+	return env
+}
+
+func cpuModel() string {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/cpuinfo")
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if k, v, ok := strings.Cut(sc.Text(), ":"); ok && strings.TrimSpace(k) == "model name" {
+				return strings.TrimSpace(v)
+			}
+		}
+	case "darwin":
+		out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return ""
+}
+
+// heyVersion reads the module version from the hey binary's build info,
+// since hey has no --version flag.
+func heyVersion() string {
+	if *heyExec != "" {
+		return heyBin + " via " + *heyExec
+	}
+	path, err := exec.LookPath(heyBin)
+	if err != nil {
+		return ""
+	}
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	return info.Main.Path + " " + info.Main.Version
+}
+
+// outboundInterface names the interface, address and MTU the OS routes
+// probe's host through. A UDP "dial" sends nothing but picks the route.
+func outboundInterface(probe string) string {
+	u, err := url.Parse(probe)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return ""
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(local) {
+				return iface.Name + " " + local.String() + " mtu " + strconv.Itoa(iface.MTU)
+			}
+		}
+	}
+	return local.String()
+}
+
+func (e Environment) markdown() string {
+	rows := [][]string{
+		{"hostname", e.Hostname},
+		{"os", e.OS},
+		{"cpu", e.CPU},
+		{"cores", strconv.Itoa(e.Cores)},
+		{"go", e.GoVersion},
+	}
+	if e.HeyVersion != "" {
+		rows = append(rows, []string{"hey", e.HeyVersion})
+	}
+	rows = append(rows, []string{"interface", e.Interface})
+	return markdownTable([]string{"", "load generator"}, rows)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrorBudget tracks how much of the SLO's error budget the monitored
+// targets have used, from the results store the scheduled suites append
+// to. The budget is the share of requests the slo lets miss its latency,
+// e.g. 1% for "99% < 300ms", over Window; failed requests count as misses.
+// The burn rate is how fast it's spent: 1 uses it up exactly by the end
+// of the window. An alert fires when the burn rate over its own window
+// exceeds its burn_rate, and the webhook is POSTed when an alert fires or
+// resolves.
+type ErrorBudget struct {
+	Window  string      `json:"window"`
+	Alerts  []BurnAlert `json:"alerts"`
+	Webhook string      `json:"webhook"`
+}
+
+type BurnAlert struct {
+	Window   string  `json:"window"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// defaultBurnAlerts page when a 30-day budget would be gone in about two
+// days, warn when in five, and note a budget that won't last the window.
+var defaultBurnAlerts = []BurnAlert{{"1h", 14.4}, {"6h", 6}, {"3d", 1}}
+
+// burnAlert is a parsed BurnAlert.
+type burnAlert struct {
+	name   string
+	window time.Duration
+	rate   float64
+}
+
+// budgetWindows parses b's window, 30d by default, and its alerts, the
+// defaults without any.
+func budgetWindows(b *ErrorBudget) (time.Duration, []burnAlert, error) {
+	spec := b.Window
+	if spec == "" {
+		spec = "30d"
+	}
+	window, err := parseAge(spec)
+	if err != nil {
+		return 0, nil, fmt.Errorf("window: %v", err)
+	}
+	alerts := b.Alerts
+	if len(alerts) == 0 {
+		alerts = defaultBurnAlerts
+	}
+	var out []burnAlert
+	for _, a := range alerts {
+		w, err := parseAge(a.Window)
+		if err != nil {
+			return 0, nil, fmt.Errorf("alert: %v", err)
+		}
+		if w > window {
+			return 0, nil, fmt.Errorf("alert window %s is longer than the budget's, %s", a.Window, spec)
+		}
+		if a.BurnRate <= 0 {
+			return 0, nil, fmt.Errorf("alert over %s: burn_rate must be positive", a.Window)
+		}
+		out = append(out, burnAlert{name: fmt.Sprintf("burn rate over %s > %v", a.Window, a.BurnRate), window: w, rate: a.BurnRate})
+	}
+	return window, out, nil
+}
+
+// sloEvent is one run's SLO counts from the results store.
+type sloEvent struct {
+	at        time.Time
+	good, all int
+}
+
+// loadSLOEvents reads every target's SLO counts from the store, since
+// since.
+func loadSLOEvents(path string, since time.Time) (map[string][]sloEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := map[string][]sloEvent{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec StoreRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.Row == nil || rec.Row["agent"] != "" || rec.Time.Before(since) {
+			continue
+		}
+		good, okGood := rowFloat(rec.Row, "slo_good")
+		all, okAll := rowFloat(rec.Row, "slo_total")
+		if !okGood || !okAll || all == 0 {
+			continue
+		}
+		key := rowTargetKey(rec.Row)
+		out[key] = append(out[key], sloEvent{at: rec.Time, good: int(good), all: int(all)})
+	}
+	return out, scanner.Err()
+}
+
+// budgetStatus is one target's state of its error budget.
+type budgetStatus struct {
+	target     string
+	remaining  float64            // percent of the budget left
+	burn       map[string]float64 // burn rate per alert, by name
+	firing     []string           // the alerts firing, by name
+	exhaustion time.Time          // at the current burn rate; zero if not burning
+}
+
+// missRate is the share of the events' requests since since that
+// missed the objective, and whether there were any.
+func missRate(events []sloEvent, since time.Time) (float64, bool) {
+	good, all := 0, 0
+	for _, e := range events {
+		if !e.at.Before(since) {
+			good += e.good
+			all += e.all
+		}
+	}
+	if all == 0 {
+		return 0, false
+	}
+	return float64(all-good) / float64(all), true
+}
+
+// budgetStatuses works out every target's budget at now. The exhaustion
+// projection uses the burn rate over the longest alert window.
+func budgetStatuses(events map[string][]sloEvent, slo *SLO, window time.Duration, alerts []burnAlert, now time.Time) []budgetStatus {
+	budget := 1 - slo.Percent/100
+	longest := 0
+	for i, a := range alerts {
+		if a.window > alerts[longest].window {
+			longest = i
+		}
+	}
+	var out []budgetStatus
+	for target, evs := range events {
+		s := budgetStatus{target: target, burn: map[string]float64{}}
+		// the budget spent so far: the burn rate over the part of the
+		// window with results, for that part of it
+		first := now
+		for _, e := range evs {
+			if e.at.Before(first) {
+				first = e.at
+			}
+		}
+		miss, _ := missRate(evs, now.Add(-window))
+		s.remaining = 100 * (1 - miss/budget*float64(now.Sub(first))/float64(window))
+		for i, a := range alerts {
+			miss, ok := missRate(evs, now.Add(-a.window))
+			if !ok {
+				continue
+			}
+			s.burn[a.name] = miss / budget
+			if s.burn[a.name] > a.rate {
+				s.firing = append(s.firing, a.name)
+			}
+			if i == longest && s.burn[a.name] > 0 && s.remaining > 0 {
+				left := time.Duration(s.remaining / 100 / s.burn[a.name] * float64(window))
+				s.exhaustion = now.Add(left)
+			}
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].target < out[j].target })
+	return out
+}
+
+// printBudget prints a target's budget, burn rates and projection.
+func printBudget(s budgetStatus, alerts []burnAlert, now time.Time) {
+	mark := "✅"
+	switch {
+	case s.remaining <= 0 || len(s.firing) > 0:
+		mark = "❌"
+	case s.remaining < 25:
+		mark = "⚠️ "
+	}
+	var burns []string
+	for _, a := range alerts {
+		if v, ok := s.burn[a.name]; ok {
+			burns = append(burns, fmt.Sprintf("%.2f×/%v", v, shortAge(a.window)))
+		}
+	}
+	projection := "not burning"
+	switch {
+	case s.remaining <= 0:
+		projection = "exhausted"
+	case !s.exhaustion.IsZero():
+		projection = fmt.Sprintf("exhausted in %s, %s", shortAge(s.exhaustion.Sub(now)), s.exhaustion.Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("%s %s: %.1f%% of the error budget left, burn %s, %s\n", mark, s.target, math.Max(s.remaining, 0), strings.Join(burns, " "), projection)
+	for _, name := range s.firing {
+		fmt.Printf("   ❌ Alert: %s (%.2f)\n", name, s.burn[name])
+	}
+}
+
+// shortAge prints d in the largest whole unit of days, hours or minutes.
+func shortAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dm", int(math.Ceil(d.Minutes())))
+	}
+}
+
+// budgetAlert is the webhook's body. Text makes it readable as a Slack or
+// Teams incoming webhook message.
+type budgetAlert struct {
+	State      string  `json:"state"` // firing or resolved
+	Target     string  `json:"target"`
+	Alert      string  `json:"alert"`
+	BurnRate   float64 `json:"burn_rate"`
+	Remaining  float64 `json:"budget_remaining"`
+	Exhaustion string  `json:"exhaustion,omitempty"`
+	SLO        string  `json:"slo"`
+	Text       string  `json:"text"`
+}
+
+func postBudgetAlert(webhook string, a budgetAlert) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false) // keep "> 14.4" readable
+	if err := enc.Encode(a); err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Post(webhook, "application/json", &body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// budgetMonitor checks the error budget after every scheduled suite,
+// remembering which alerts fire so the webhook hears of each change once.
+type budgetMonitor struct {
+	budget  *ErrorBudget
+	slo     *SLO
+	store   string
+	window  time.Duration
+	alerts  []burnAlert
+	firing  map[string]bool // target + "\x00" + alert
+	anyFire bool
+}
+
+func newBudgetMonitor(c Config, store string) (*budgetMonitor, error) {
+	slo, err := parseSLO(c.SLO)
+	if err != nil {
+		return nil, err
+	}
+	if slo == nil {
+		return nil, fmt.Errorf("error_budget needs an slo, which the budget is taken from")
+	}
+	window, alerts, err := budgetWindows(c.ErrorBudget)
+	if err != nil {
+		return nil, err
+	}
+	return &budgetMonitor{budget: c.ErrorBudget, slo: slo, store: store, window: window, alerts: alerts, firing: map[string]bool{}}, nil
+}
+
+// check reads the store and reports every target's budget.
+func (m *budgetMonitor) check(now time.Time) error {
+	events, err := loadSLOEvents(m.store, now.Add(-m.window))
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		fmt.Printf("⚠️  No SLO results in %s from the last %s to take the error budget from\n", m.store, shortAge(m.window))
+		return nil
+	}
+	fmt.Printf("→ Error budget of %s over %s\n", m.slo, shortAge(m.window))
+	m.anyFire = false
+	for _, s := range budgetStatuses(events, m.slo, m.window, m.alerts, now) {
+		printBudget(s, m.alerts, now)
+		firing := map[string]bool{}
+		for _, name := range s.firing {
+			firing[name] = true
+			m.anyFire = true
+		}
+		for _, a := range m.alerts {
+			key := s.target + "\x00" + a.name
+			was, is := m.firing[key], firing[a.name]
+			m.firing[key] = is
+			if m.budget.Webhook == "" || is == was {
+				continue
+			}
+			alert := budgetAlert{State: "firing", Target: s.target, Alert: a.name, SLO: m.slo.String(),
+				BurnRate: math.Round(s.burn[a.name]*100) / 100, Remaining: math.Round(math.Max(s.remaining, 0)*10) / 10}
+			if !s.exhaustion.IsZero() {
+				alert.Exhaustion = s.exhaustion.UTC().Format(time.RFC3339)
+			}
+			alert.Text = fmt.Sprintf("🔥 %s: error budget %s at %.2f, %.1f%% left", s.target, a.name, alert.BurnRate, alert.Remaining)
+			if !is {
+				alert.State = "resolved"
+				alert.Text = fmt.Sprintf("✅ %s: error budget %s resolved, now %.2f", s.target, a.name, alert.BurnRate)
+			}
+			if err := postBudgetAlert(m.budget.Webhook, alert); err != nil {
+				fmt.Printf("⚠️  Error budget webhook failed: %s\n", redact(err.Error()))
+			}
+		}
+	}
+	return nil
+}
+
+// runErrorBudget implements `error-budget --config monitor.json`: it
+// reports every target's error budget from the results store once, posts
+// the firing alerts to the webhook, and exits 1 if any fire, for checks
+// outside `schedule`, which keeps no state between them.
+func runErrorBudget(args []string) {
+	fs := flag.NewFlagSet("error-budget", flag.ExitOnError)
+	config := fs.String("config", "", "config with the slo and error_budget")
+	store := fs.String("store", "", "results store to read (default: the config's, or "+defaultStore+")")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	cfg = c
+	if c.ErrorBudget == nil {
+		c.ErrorBudget = &ErrorBudget{}
+	}
+	if *store == "" {
+		*store = c.Store
+	}
+	if *store == "" {
+		*store = defaultStore
+	}
+	m, err := newBudgetMonitor(c, *store)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if err := m.check(time.Now()); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if m.anyFire {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter ships a suite's results somewhere the tool doesn't know about:
+// an external process started with the suite that reads its events as
+// JSON lines on stdin. Command runs in a shell; without one the exporter
+// is discovered by Name, as an executable custom-per-tools-exporter-<name>
+// in exporter_dirs or on the PATH. Events limits what it's sent, all by
+// default. A Required exporter failing fails the suite; otherwise it's
+// warned about.
+type Exporter struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	Events   []string `json:"events"`
+	Timeout  string   `json:"timeout"` // to finish after suite_end, default 1m
+	Required bool     `json:"required"`
+
+	path    string
+	timeout time.Duration
+}
+
+const (
+	exporterProtocol = 1
+	exporterPrefix   = "custom-per-tools-exporter-"
+	// an exporter that falls this far behind, or doesn't take an event for
+	// this long, is failed rather than allowed to hold up the suite
+	exporterQueue        = 1024
+	exporterWriteTimeout = 10 * time.Second
+)
+
+// exportEvents are the events an exporter can be sent, in the order a
+// suite sends them: suite_start once, run for every result row as its run
+// finishes and suite_end once all the files are written.
+var exportEvents = []string{"suite_start", "run", "suite_end"}
+
+// ExportEvent is one line of the exporter protocol. Every event has
+// Event, Protocol, Suite and Time; the rest belong to one event each.
+type ExportEvent struct {
+	Event    string    `json:"event"`
+	Protocol int       `json:"protocol"`
+	Suite    string    `json:"suite"`
+	Time     time.Time `json:"time"`
+
+	// suite_start
+	Dir         string            `json:"dir,omitempty"`
+	Engine      string            `json:"engine,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Environment *Environment      `json:"environment,omitempty"`
+	Targets     []ManifestTarget  `json:"targets,omitempty"`
+
+	// run
+	Row map[string]string `json:"row,omitempty"`
+
+	// suite_end
+	Rows      int               `json:"rows,omitempty"`
+	Files     map[string]string `json:"files,omitempty"`
+	Truncated string            `json:"truncated,omitempty"`
+	Aborted   map[string]string `json:"aborted,omitempty"`
+	Passed    *bool             `json:"passed,omitempty"`
+}
+
+// validateExporters checks the exporters' fields and finds the
+// executables of those without a command.
+func validateExporters(exporters []Exporter, dirs []string) error {
+	seen := map[string]bool{}
+	for i := range exporters {
+		e := &exporters[i]
+		if e.Name == "" {
+			return fmt.Errorf("exporter %d has no name", i+1)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("%s: another exporter has the same name", e.Name)
+		}
+		seen[e.Name] = true
+		for _, ev := range e.Events {
+			if !contains(exportEvents, ev) {
+				return fmt.Errorf("%s: unknown event %q, want one of %s", e.Name, ev, strings.Join(exportEvents, ", "))
+			}
+		}
+		e.timeout = time.Minute
+		if e.Timeout != "" {
+			d, err := time.ParseDuration(e.Timeout)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("%s: invalid timeout %q", e.Name, e.Timeout)
+			}
+			e.timeout = d
+		}
+		if e.Command == "" {
+			path, err := findExporter(e.Name, dirs)
+			if err != nil {
+				return fmt.Errorf("%s: %v", e.Name, err)
+			}
+			e.path = path
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// findExporter looks for name's executable in dirs, then on the PATH.
+func findExporter(name string, dirs []string) (string, error) {
+	file := exporterPrefix + name
+	if runtime.GOOS == "windows" {
+		file += ".exe"
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return filepath.Abs(path)
+		}
+	}
+	path, err := exec.LookPath(file)
+	if err != nil {
+		where := "the PATH"
+		if len(dirs) > 0 {
+			where = strings.Join(dirs, ", ") + " or the PATH"
+		}
+		return "", fmt.Errorf("no %s in %s", file, where)
+	}
+	return path, nil
+}
+
+// exporterDirs are where exporters are discovered, ./exporters unless the
+// config says otherwise.
+func exporterDirs(c Config) []string {
+	if c.ExporterDirs != nil {
+		return c.ExporterDirs
+	}
+	return []string{"exporters"}
+}
+
+// exporterProc is a running exporter. Its events are written to its
+// stdin by a goroutine of its own, so one that stops reading can't block
+// the suite.
+type exporterProc struct {
+	e     *Exporter
+	cmd   *exec.Cmd
+	stdin *os.File
+	queue chan ExportEvent
+	sent  chan struct{} // closed once the queue is written, or given up on
+	out   *exporterOutput
+
+	mu  sync.Mutex
+	err error // the first failure; nothing more is sent after it
+}
+
+var exporterProcs []*exporterProc
+
+// startExporters starts the config's exporters and sends them
+// suite_start. One that can't start is given up on like one that fails.
+func startExporters(started time.Time, env Environment, targets []Target) {
+	dir, _ := filepath.Abs(suiteDir)
+	start := ExportEvent{Event: "suite_start", Time: started.UTC(), Dir: dir, Engine: *engine, Labels: labels, Environment: &env}
+	for _, t := range targets {
+		start.Targets = append(start.Targets, ManifestTarget{Name: t.Name, Route: t.Route, URL: redact(t.URL), Version: versions[t.Name]})
+	}
+	for i := range cfg.Exporters {
+		e := &cfg.Exporters[i]
+		var cmd *exec.Cmd
+		switch {
+		case e.Command == "":
+			cmd = exec.Command(e.path)
+		case runtime.GOOS == "windows":
+			cmd = exec.Command("cmd", "/C", e.Command)
+		default:
+			cmd = exec.Command("sh", "-c", e.Command)
+		}
+		out := &exporterOutput{name: e.Name}
+		cmd.Stdout, cmd.Stderr = out, out
+		// a killed shell's children may keep its output open
+		cmd.WaitDelay = time.Second
+		p := &exporterProc{e: e, cmd: cmd, out: out}
+		exporterProcs = append(exporterProcs, p)
+		// a pipe of our own, unlike StdinPipe's, takes write deadlines
+		r, w, err := os.Pipe()
+		if err == nil {
+			cmd.Stdin = r
+			err = cmd.Start()
+			r.Close()
+			if err != nil {
+				w.Close()
+			}
+		}
+		if err != nil {
+			p.fail(err)
+			continue
+		}
+		p.stdin, p.queue, p.sent = w, make(chan ExportEvent, exporterQueue), make(chan struct{})
+		go p.write()
+		fmt.Printf("→ Exporting to %s\n", e.Name)
+		p.send(start)
+	}
+}
+
+// exportRows sends a run event for each of the rows.
+func exportRows(rows []map[string]string) {
+	for _, row := range rows {
+		ev := ExportEvent{Event: "run", Time: time.Now().UTC(), Row: publicRow(row)}
+		for _, p := range exporterProcs {
+			p.send(ev)
+		}
+	}
+}
+
+// finishExporters sends suite_end, then waits for every exporter to exit
+// within its timeout. It's false when a required exporter failed.
+func finishExporters(rows int, files map[string]string, passed bool) bool {
+	end := ExportEvent{Event: "suite_end", Time: time.Now().UTC(), Rows: rows, Files: map[string]string{},
+		Truncated: truncated, Aborted: abortedTargets, Passed: &passed}
+	for name, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			end.Files[name], _ = filepath.Abs(f)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, p := range exporterProcs {
+		if p.stdin == nil {
+			continue
+		}
+		p.send(end)
+		close(p.queue)
+		wg.Add(1)
+		go func(p *exporterProc) {
+			defer wg.Done()
+			<-p.sent
+			done := make(chan error, 1)
+			go func() { done <- p.cmd.Wait() }()
+			select {
+			case err := <-done:
+				if err != nil {
+					p.fail(err)
+				}
+			case <-time.After(p.e.timeout):
+				p.cmd.Process.Kill()
+				<-done
+				p.fail(fmt.Errorf("still running after %v, killed", p.e.timeout))
+			}
+			p.out.flush()
+		}(p)
+	}
+	wg.Wait()
+	ok := true
+	for _, p := range exporterProcs {
+		switch {
+		case p.failure() == nil:
+			fmt.Printf("✅ Exported to %s\n", p.e.Name)
+		case p.e.Required:
+			ok = false
+		}
+	}
+	return ok
+}
+
+// send queues ev for the exporter, failing it rather than waiting when
+// it's exporterQueue events behind.
+func (p *exporterProc) send(ev ExportEvent) {
+	if p.queue == nil || p.failure() != nil || len(p.e.Events) > 0 && !contains(p.e.Events, ev.Event) {
+		return
+	}
+	ev.Protocol, ev.Suite = exporterProtocol, suiteID
+	select {
+	case p.queue <- ev:
+	default:
+		p.fail(fmt.Errorf("%d events behind, dropping %s and the rest", exporterQueue, ev.Event))
+	}
+}
+
+// write writes the queued events to the exporter's stdin until the queue
+// is closed, then closes stdin. After a failure it discards the rest.
+func (p *exporterProc) write() {
+	defer close(p.sent)
+	defer p.stdin.Close()
+	enc := json.NewEncoder(p.stdin)
+	for ev := range p.queue {
+		if p.failure() != nil {
+			continue
+		}
+		p.stdin.SetWriteDeadline(time.Now().Add(exporterWriteTimeout))
+		if err := enc.Encode(ev); err != nil {
+			if os.IsTimeout(err) {
+				err = fmt.Errorf("not reading its events, none taken for %v", exporterWriteTimeout)
+			}
+			p.fail(fmt.Errorf("sending %s: %v", ev.Event, err))
+		}
+	}
+}
+
+func (p *exporterProc) failure() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *exporterProc) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	p.err = err
+	if p.e.Required {
+		fmt.Printf("❌ Required exporter %s failed: %s\n", p.e.Name, redact(err.Error()))
+	} else {
+		fmt.Printf("⚠️  Exporter %s failed: %s\n", p.e.Name, redact(err.Error()))
+	}
+}
+
+// exporterOutput prints what an exporter writes, a line at a time under
+// its name and redacted like everything else.
+type exporterOutput struct {
+	name string
+	mu   sync.Mutex
+	buf  []byte
+}
+
+func (o *exporterOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, b...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		o.print(o.buf[:i])
+		o.buf = o.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush prints a last line left without a newline.
+func (o *exporterOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.buf) > 0 {
+		o.print(o.buf)
+		o.buf = nil
+	}
+}
+
+func (o *exporterOutput) print(line []byte) {
+	fmt.Printf("   %s: %s\n", o.name, redact(strings.TrimRight(string(line), "\r")))
+}
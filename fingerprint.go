@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// versions maps each deployment (target name) to the build it reported
+// before the suite, when --fingerprint is set.
+var versions = map[string]string{}
+
+// parseFingerprint reads "PATH [RULE]", e.g. "/version json:build.commit",
+// "/health header:X-Build" or just "header:X-Build" to read the header from
+// the target URL itself. RULE uses the scenario extraction syntax; without
+// one the whole response body is the version.
+func parseFingerprint(spec string) (string, extractor, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", nil, fmt.Errorf("invalid fingerprint %q, want \"PATH [RULE]\"", spec)
+	}
+	path, rule := fields[0], ""
+	if len(fields) == 2 {
+		rule = fields[1]
+	} else if !strings.HasPrefix(path, "/") {
+		path, rule = "", fields[0]
+	}
+	if rule == "" {
+		return path, nil, nil
+	}
+	ex, err := compileExtract(rule)
+	return path, ex, err
+}
+
+// fingerprintTargets asks every deployment which build it runs and
+// records the answers for the report, JSON output and result rows.
+func fingerprintTargets(targets []Target, spec string) error {
+	path, ex, err := parseFingerprint(spec)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	var table [][]string
+	for _, t := range targets {
+		if _, done := versions[t.Name]; done {
+			continue
+		}
+		probe := t.URL
+		if path != "" {
+			u, err := url.Parse(t.URL)
+			if err != nil {
+				return err
+			}
+			probe = u.Scheme + "://" + u.Host + path
+		}
+		version := fetchVersion(client, probe, ex)
+		versions[t.Name] = version
+		fmt.Printf("→ %s runs %s\n", t.Name, version)
+		table = append(table, []string{t.Name, probe, version})
+	}
+	addReportSection("Versions compared", markdownTable([]string{"target", "probe", "version"}, table))
+	return nil
+}
+
+func fetchVersion(client *http.Client, probe string, ex extractor) string {
+	resp, err := client.Get(probe)
+	if err != nil {
+		return "unreachable"
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if ex == nil {
+		v := strings.TrimSpace(string(body))
+		if len(v) > 80 {
+			v = v[:80] + "…"
+		}
+		return v
+	}
+	if v, ok := ex(resp, body); ok {
+		return v
+	}
+	return "unknown"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// networkFloors maps each target host, as host:port, to its network
+// round trip in milliseconds, measured before the suite with
+// --network-floor.
+var networkFloors = map[string]float64{}
+
+// hostPort is the address a URL's requests connect to.
+func hostPort(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// probeNetworkFloors times n TCP connects to every target host and keeps
+// the fastest as its floor: a connect costs one round trip, like a request
+// on a reused connection, and nothing for the server to process. TCP
+// rather than ICMP needs no privileges, and follows the requests' path
+// through firewalls that drop pings.
+func probeNetworkFloors(targets []Target, n int) error {
+	var table [][]string
+	for _, t := range targets {
+		addr, err := hostPort(t.URL)
+		if err != nil {
+			return err
+		}
+		if _, done := networkFloors[addr]; done {
+			continue
+		}
+		var rtts []float64
+		failed := 0
+		for k := 0; k < n; k++ {
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+			if err != nil {
+				failed++
+				continue
+			}
+			rtts = append(rtts, millis(time.Since(start)))
+			conn.Close()
+		}
+		if len(rtts) == 0 {
+			fmt.Printf("⚠️  Couldn't connect to %s to measure its network floor\n", addr)
+			table = append(table, []string{addr, "–", "–", fmt.Sprintf("0 of %d", n)})
+			continue
+		}
+		floor := quantile(rtts, 0)
+		networkFloors[addr] = floor
+		fmt.Printf("→ %s: network floor %.4g %s (fastest of %d TCP connects)\n", addr, inUnit(floor), displayUnit, len(rtts))
+		table = append(table, []string{addr, fmt.Sprintf("%.4g", inUnit(floor)), fmt.Sprintf("%.4g", inUnit(quantile(rtts, 0.5))),
+			fmt.Sprintf("%d of %d", len(rtts), n)})
+	}
+	addReportSection("Network floor",
+		fmt.Sprintf("Each target host's round trip from this machine before the suite, timed as TCP connects, in %s. "+
+			"The floor is the fastest: the least any request can take.\n\n", displayUnit)+
+			markdownTable([]string{"host", "floor", "median", "connects"}, table))
+	return nil
+}
+
+// addNetworkFloor stores the floor of the row's host in it.
+func addNetworkFloor(row map[string]string) {
+	addr, err := hostPort(row["url"])
+	if err != nil {
+		return
+	}
+	if floor, ok := networkFloors[addr]; ok {
+		row["network_floor"] = fmt.Sprintf("%.4f", floor)
+	}
+}
+
+// analyzeServerTime reports each target's latency less its host's network
+// floor, the time spent in the server and beyond the network, so targets
+// in different regions compare fairly.
+func analyzeServerTime(rows []map[string]string) {
+	metrics := []string{"average"}
+	if hasPercentile(95) {
+		metrics = append(metrics, "p95")
+	}
+	type acc struct {
+		floor  float64
+		values map[string][]float64
+	}
+	byKey := map[string]*acc{}
+	for _, row := range rows {
+		floor, ok := rowFloat(row, "network_floor")
+		if !ok || row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if byKey[key] == nil {
+			byKey[key] = &acc{floor: floor, values: map[string][]float64{}}
+		}
+		for _, m := range metrics {
+			if v, ok := rowFloat(row, m); ok {
+				byKey[key].values[m] = append(byKey[key].values[m], v)
+			}
+		}
+	}
+	if len(byKey) == 0 {
+		return
+	}
+	var keys []string
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	header := []string{"target", "network floor"}
+	for _, m := range metrics {
+		header = append(header, m+" measured", m+" server")
+	}
+	var table [][]string
+	for _, k := range keys {
+		a := byKey[k]
+		row := []string{k, fmt.Sprintf("%.4g", inUnit(a.floor))}
+		for _, m := range metrics {
+			if len(a.values[m]) == 0 {
+				row = append(row, "–", "–")
+				continue
+			}
+			v := mean(a.values[m])
+			row = append(row, fmt.Sprintf("%.4g", inUnit(v)), fmt.Sprintf("%.4g", inUnit(max(v-a.floor, 0))))
+		}
+		table = append(table, row)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Mean latency in %s, measured and less the host's network floor: the server's processing time, for comparing deployments at different network distances. ", displayUnit)
+	b.WriteString("A request on a reused connection costs one round trip; new connections and TLS handshakes cost more, which stays in the server figure.\n\n")
+	b.WriteString(markdownTable(header, table))
+	addReportSection("Server time", b.String())
+}
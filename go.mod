@@ -2,4 +2,19 @@ module example.com/m/v2
 
 go 1.22.2
 
-require github.com/go-echarts/go-echarts/v2 v2.5.4
+require (
+	github.com/go-echarts/go-echarts/v2 v2.5.4
+	github.com/xuri/excelize/v2 v2.8.1
+)
+
+require (
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/richardlehane/mscfb v1.0.4 // indirect
+	github.com/richardlehane/msoleps v1.0.4 // indirect
+	github.com/stretchr/testify v1.9.0 // indirect
+	github.com/xuri/efp v0.0.1 // indirect
+	github.com/xuri/nfp v0.0.1 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
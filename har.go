@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Headers the browser or transport manages itself; replaying them verbatim
+// would produce invalid or misleading requests.
+var harSkipHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+}
+
+// loadHAR turns the requests recorded in a HAR file into targets. Identical
+// requests are collapsed into one target whose weight is the number of
+// times the browser issued it.
+func loadHAR(path string) ([]Target, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(raw, &har); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var targets []Target
+	index := map[string]int{}
+	for _, e := range har.Log.Entries {
+		req := e.Request
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		t := Target{
+			Name:   u.Host,
+			Method: strings.ToUpper(req.Method),
+			URL:    req.URL,
+			Weight: 1,
+		}
+		t.Route = routeOf(t.Method, t.URL)
+		for _, h := range req.Headers {
+			if strings.HasPrefix(h.Name, ":") || harSkipHeaders[strings.ToLower(h.Name)] {
+				continue
+			}
+			t.Headers = append(t.Headers, Header{Name: h.Name, Value: h.Value})
+		}
+		if req.PostData != nil {
+			t.Body = req.PostData.Text
+		}
+
+		key := t.Method + " " + t.URL + "\n" + t.Body
+		if i, ok := index[key]; ok {
+			targets[i].Weight++
+			continue
+		}
+		index[key] = len(targets)
+		targets = append(targets, t)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s contains no replayable http(s) requests", path)
+	}
+	return targets, nil
+}
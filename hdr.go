@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Histogram is a log-linear HDR histogram of latencies in microseconds with
+// three significant digits of precision. Histograms of separate runs can be
+// merged, which makes suite-level percentiles exact to that precision
+// instead of averaging per-run percentiles.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    float64
+	max    int64
+}
+
+const (
+	hdrSubBuckets     = 2048 // 2 * 10^3 rounded up to a power of two
+	hdrHalfSubBuckets = hdrSubBuckets / 2
+	hdrSubBucketBits  = 11
+	hdrMaxValue       = int64(time.Hour / time.Microsecond)
+)
+
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]int64, hdrIndex(hdrMaxValue)+1)}
+}
+
+func hdrIndex(v int64) int {
+	if v < hdrSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - hdrSubBucketBits
+	sub := int(v >> uint(shift))
+	return hdrSubBuckets + (shift-1)*hdrHalfSubBuckets + (sub - hdrHalfSubBuckets)
+}
+
+// hdrValue returns the highest value that maps to index i.
+func hdrValue(i int) int64 {
+	if i < hdrSubBuckets {
+		return int64(i)
+	}
+	shift := (i-hdrSubBuckets)/hdrHalfSubBuckets + 1
+	sub := int64((i-hdrSubBuckets)%hdrHalfSubBuckets + hdrHalfSubBuckets)
+	return (sub+1)<<uint(shift) - 1
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d / time.Microsecond)
+	if v < 0 {
+		v = 0
+	}
+	if v > hdrMaxValue {
+		v = hdrMaxValue
+	}
+	h.counts[hdrIndex(v)]++
+	h.total++
+	h.sum += float64(v)
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *Histogram) Merge(o *Histogram) {
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.total += o.total
+	h.sum += o.sum
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+func (h *Histogram) Count() int64 { return h.total }
+
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/float64(h.total)) * time.Microsecond
+}
+
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max) * time.Microsecond }
+
+// Quantile returns the latency at percentile p (0-100).
+func (h *Histogram) Quantile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := hdrValue(i)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
+
+// suitePercentiles are the configured percentiles plus p99.9, which merged
+// histograms have enough samples to report reliably.
+func suitePercentiles() []float64 {
+	return mergePercentiles(cfg.Percentiles, []float64{99.9})
+}
+
+// writeSuiteSummary merges the raw latencies of every run into one
+// histogram per target and route and writes suite-level percentiles.
+// Rows without a raw file (runs made without --raw) are skipped.
+func writeSuiteSummary(rows []map[string]string, filename string) error {
+	hists := map[string]*Histogram{}
+	runs := map[string]int{}
+	keys := map[string][2]string{}
+	var order []string
+
+	for _, row := range rows {
+		if row["raw_file"] == "" {
+			continue
+		}
+		key := row["target"] + "\x00" + row["route"]
+		if _, ok := hists[key]; !ok {
+			hists[key] = NewHistogram()
+			keys[key] = [2]string{row["target"], row["route"]}
+			order = append(order, key)
+		}
+	}
+
+	// read and bucket every run's raw file in parallel, merging each into
+	// its series as soon as it's read so only the runs in flight are held
+	var mu sync.Mutex
+	errs := make([]error, len(rows))
+	parallel(len(rows), func(i int) {
+		if rows[i]["raw_file"] == "" {
+			return
+		}
+		run := NewHistogram()
+		_, err := streamRawLatencies(filepath.Join(outDir, rows[i]["raw_file"]), func(s sample) {
+			if s.err == "" {
+				run.Record(s.latency)
+			}
+		})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		key := rows[i]["target"] + "\x00" + rows[i]["route"]
+		mu.Lock()
+		hists[key].Merge(run)
+		runs[key]++
+		mu.Unlock()
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := newCSVWriter(file)
+	defer writer.Flush()
+
+	headers := []string{"target", "route", "runs", "requests", "mean"}
+	for _, p := range suitePercentiles() {
+		headers = append(headers, percentileKey(p))
+	}
+	headers = append(headers, "max")
+	writer.Write(headers)
+
+	for _, key := range order {
+		h := hists[key]
+		record := []string{keys[key][0], keys[key][1], fmt.Sprint(runs[key]), fmt.Sprint(h.Count()), fmt.Sprintf("%.4f", millis(h.Mean()))}
+		for _, p := range suitePercentiles() {
+			record = append(record, fmt.Sprintf("%.4f", millis(h.Quantile(p))))
+		}
+		record = append(record, fmt.Sprintf("%.4f", millis(h.Max())))
+		writer.Write(record)
+	}
+	fmt.Printf("✅ Suite summary written to %s\n", filename)
+	return nil
+}
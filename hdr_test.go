@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSuiteSummary(t *testing.T) {
+	saved := outDir
+	defer func() { outDir = saved }()
+	outDir = t.TempDir()
+
+	raw := func(name string, ms ...int) string {
+		var samples []sample
+		for _, l := range ms {
+			samples = append(samples, sample{latency: time.Duration(l) * time.Millisecond, status: 200})
+		}
+		samples = append(samples, sample{err: "timeout"}) // failed requests aren't latencies
+		if err := writeRawLatencies(filepath.Join(outDir, name), samples); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	var rows []map[string]string
+	for i := 0; i < 6; i++ {
+		rows = append(rows, map[string]string{"target": "api", "route": "GET /a", "raw_file": raw(fmt.Sprintf("api-%d.csv", i), 10*(i+1), 20*(i+1))})
+	}
+	rows = append(rows,
+		map[string]string{"target": "web", "raw_file": raw("web.csv", 5, 7, 9)},
+		map[string]string{"target": "web"}, // a run without --raw
+	)
+
+	file := filepath.Join(outDir, "suite.csv")
+	if err := writeSuiteSummary(rows, file); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("suite summary has %d lines, want a header and 2 series:\n%s", len(lines), b)
+	}
+	for _, want := range []string{"api,GET /a,6,12,", "web,,1,3,"} {
+		if !strings.Contains(string(b), "\n"+want) {
+			t.Errorf("suite summary lacks a row starting %q:\n%s", want, b)
+		}
+	}
+	if !strings.HasSuffix(lines[1], ",120.0000") {
+		t.Errorf("api's max in %q, want 120ms", lines[1])
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// generateConcurrencyHeatmaps draws, for each target of a sweep, its
+// runs as cells of concurrency level × run index coloured by latency,
+// and beside it the same by rps. A level where one run in several goes
+// bad stands out as an odd cell in its row, where the overlapping lines
+// of the other charts would hide it.
+func generateConcurrencyHeatmaps(rows []map[string]string, filename string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	type cell struct {
+		c, run int
+		row    map[string]string
+	}
+	cells := map[string][]cell{}
+	var targets []string
+	seen := map[string]map[int]int{} // runs so far per target and level
+	for _, row := range rows {
+		key := rowTargetKey(row)
+		c, err := strconv.Atoi(row["concurrency"])
+		if err != nil || !seriesWanted(key) || row["agent"] != "" {
+			continue
+		}
+		if seen[key] == nil {
+			seen[key] = map[int]int{}
+			targets = append(targets, key)
+		}
+		seen[key][c]++
+		cells[key] = append(cells[key], cell{c: c, run: seen[key][c], row: row})
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	page := components.NewPage()
+	page.SetPageTitle("Concurrency Heatmaps")
+	page.SetLayout(components.PageFlexLayout)
+	for _, target := range targets {
+		var levels []int
+		runs := 0
+		for c, n := range seen[target] {
+			levels = append(levels, c)
+			runs = max(runs, n)
+		}
+		sort.Ints(levels)
+		y := map[int]int{}
+		var yAxis, xAxis []string
+		for i, c := range levels {
+			y[c] = i
+			yAxis = append(yAxis, strconv.Itoa(c))
+		}
+		for i := 1; i <= runs; i++ {
+			xAxis = append(xAxis, strconv.Itoa(i))
+		}
+		for _, metric := range []string{latency, "requests_per_sec"} {
+			var data []opts.HeatMapData
+			lo, hi := 0.0, 0.0
+			for _, c := range cells[target] {
+				v, ok := rowFloat(c.row, metric)
+				if !ok {
+					continue
+				}
+				v = round4(displayValue(metric, v))
+				if len(data) == 0 || v < lo {
+					lo = v
+				}
+				if len(data) == 0 || v > hi {
+					hi = v
+				}
+				data = append(data, opts.HeatMapData{
+					Name:  fmt.Sprintf("c=%d · run %d", c.c, c.run),
+					Value: [3]interface{}{c.run - 1, y[c.c], v},
+				})
+			}
+			if len(data) == 0 {
+				continue
+			}
+			name, unit := withUnit(metric), unitOf(metric)
+			colors := []string{"#50a3ba", "#eac736", "#d94e5d"}
+			if metric == "requests_per_sec" {
+				name, unit = "rps", "rps"
+				colors = []string{"#d94e5d", "#eac736", "#50a3ba"} // low throughput is the bad end
+			}
+			hm := charts.NewHeatMap()
+			hm.SetGlobalOptions(
+				charts.WithTitleOpts(chartTitle(target, name+" by concurrency and run")),
+				charts.WithXAxisOpts(opts.XAxis{Name: "Test Run", Type: "category", Data: xAxis}),
+				charts.WithYAxisOpts(opts.YAxis{Name: "Concurrency", Type: "category", Data: yAxis}),
+				charts.WithVisualMapOpts(opts.VisualMap{
+					Calculable: opts.Bool(true),
+					Min:        float32(lo),
+					Max:        float32(hi),
+					InRange:    &opts.VisualMapInRange{Color: colors},
+				}),
+				chartToolbox(),
+				charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Formatter: opts.FuncOpts(
+					"function (p) { return p.data.name + ': <b>' + p.value[2] + ' " + unit + "</b>'; }")}),
+			)
+			hm.AddSeries(name, data)
+			page.AddCharts(hm)
+		}
+	}
+
+	renderChart(page, filename)
+}
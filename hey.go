@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// heyBin is the hey binary every run executes, resolved once by findHey.
+var heyBin = "hey"
+
+// minHeyVersion is the oldest hey release whose -o csv output is known to
+// have the columns readRawLatencies expects.
+const minHeyVersion = "v0.1.2"
+
+const heyInstallHint = "install it with `go install github.com/rakyll/hey@latest`, point --hey-path at it, " +
+	"let the tool download it with --hey-sha256, or use --engine native"
+
+// findHey looks for hey in --hey-path, on the PATH, in ./bin, and in the
+// user cache, downloading it there when --hey-sha256 is given. Cached and
+// downloaded binaries must match that checksum.
+func findHey() (string, error) {
+	if *heyPath != "" {
+		path, err := exec.LookPath(*heyPath)
+		if err != nil {
+			return "", fmt.Errorf("--hey-path %s: %w", *heyPath, err)
+		}
+		return path, nil
+	}
+	if path, err := exec.LookPath("hey"); err == nil {
+		return path, nil
+	}
+	name := "hey"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if path, err := exec.LookPath(filepath.Join("bin", name)); err == nil {
+		return path, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("hey isn't on the PATH; %s", heyInstallHint)
+	}
+	cached := filepath.Join(cacheDir, "custom-per-tools", name)
+	if _, err := os.Stat(cached); err == nil {
+		if *heySHA256 != "" {
+			if err := verifySHA256(cached, *heySHA256); err != nil {
+				return "", err
+			}
+		}
+		return cached, nil
+	}
+	if *heySHA256 == "" {
+		return "", fmt.Errorf("hey isn't on the PATH; %s", heyInstallHint)
+	}
+	url := *heyURL
+	if url == "" {
+		url = fmt.Sprintf("https://hey-release.s3.us-east-2.amazonaws.com/hey_%s_%s", runtime.GOOS, runtime.GOARCH)
+	}
+	fmt.Printf("→ Downloading hey from %s\n", url)
+	if err := downloadHey(url, cached, *heySHA256); err != nil {
+		return "", fmt.Errorf("download hey: %w", err)
+	}
+	return cached, nil
+}
+
+// downloadHey fetches url to dest, keeping it only if its SHA-256 matches.
+func downloadHey(url, dest, sum string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".download"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	f.Close()
+	if err == nil {
+		err = verifySHA256(tmp, sum)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func verifySHA256(path, want string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s: checksum %s doesn't match --hey-sha256 %s", path, got, want)
+	}
+	return nil
+}
+
+// checkHeyVersion warns when path isn't a hey build this tool knows, or
+// fails when it's older than minHeyVersion. hey has no --version flag, so
+// the version comes from the binary's Go build info.
+func checkHeyVersion(path string) error {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		fmt.Printf("⚠️  Can't tell which hey %s is (%v); assuming it's compatible\n", path, err)
+		return nil
+	}
+	if info.Main.Path != "github.com/rakyll/hey" {
+		fmt.Printf("⚠️  %s is %s, not github.com/rakyll/hey; assuming it's compatible\n", path, info.Main.Path)
+		return nil
+	}
+	v := info.Main.Version
+	if v == "" || v == "(devel)" {
+		return nil
+	}
+	if compareVersions(v, minHeyVersion) < 0 {
+		return fmt.Errorf("hey %s at %s is older than %s; update it with `go install github.com/rakyll/hey@latest`", v, path, minHeyVersion)
+	}
+	return nil
+}
+
+// compareVersions compares the numeric parts of two vX.Y.Z versions.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(strings.SplitN(pa[i], "-", 2)[0])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(strings.SplitN(pb[i], "-", 2)[0])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// infraRegion is one region of the agent fleet; ID is its name as a
+// Terraform identifier.
+type infraRegion struct {
+	Name, ID string
+	Count    int
+}
+
+// agentInfra is what the infrastructure templates are executed with.
+type agentInfra struct {
+	Name         string
+	InstanceType string
+	Image        string
+	AllowCIDR    string
+	Port         int
+	TTLMinutes   int
+	Regions      []infraRegion
+}
+
+var terraformTemplate = template.Must(template.New("main.tf").Parse(`# Load agents for custom-per-tools, generated by agents-infra. Run the suite
+# across them with ./run.sh, which applies this, runs it and destroys it.
+
+terraform {
+  required_providers {
+    aws = { source = "hashicorp/aws", version = ">= 5.0" }
+  }
+}
+
+# the token the agents take jobs with; run.sh generates one per fleet
+variable "agent_token" {
+  type      = string
+  sensitive = true
+}
+{{range .Regions}}
+provider "aws" {
+  alias  = "{{.ID}}"
+  region = "{{.Name}}"
+}
+
+data "aws_ami" "{{.ID}}" {
+  provider    = aws.{{.ID}}
+  most_recent = true
+  owners      = ["099720109477"] # Canonical
+  filter {
+    name   = "name"
+    values = ["ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*"]
+  }
+}
+
+resource "aws_security_group" "{{.ID}}" {
+  provider    = aws.{{.ID}}
+  name_prefix = "{{$.Name}}-"
+  description = "custom-per-tools agents, reachable from the coordinator only"
+  ingress {
+    from_port   = {{$.Port}}
+    to_port     = {{$.Port}}
+    protocol    = "tcp"
+    cidr_blocks = ["{{$.AllowCIDR}}"]
+  }
+  egress {
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}
+
+resource "aws_instance" "{{.ID}}" {
+  provider                             = aws.{{.ID}}
+  count                                = {{.Count}}
+  ami                                  = data.aws_ami.{{.ID}}.id
+  instance_type                        = "{{$.InstanceType}}"
+  vpc_security_group_ids               = [aws_security_group.{{.ID}}.id]
+  user_data                            = templatefile("${path.module}/cloud-init.yaml", { agent_token = var.agent_token })
+  instance_initiated_shutdown_behavior = "terminate"
+  tags = {
+    Name = "{{$.Name}}-{{.Name}}-${count.index + 1}"
+  }
+}
+{{end}}
+# region=host:port for every agent, as --agents takes them
+output "agents" {
+  value = join(",", concat({{range $i, $r := .Regions}}{{if $i}},{{end}}
+    [for ip in aws_instance.{{.ID}}[*].public_ip : "{{.Name}}=${ip}:{{$.Port}}"]{{end}}
+  ))
+}
+`))
+
+var cloudInitTemplate = template.Must(template.New("cloud-init.yaml").Parse(`#cloud-config
+# Runs a custom-per-tools agent from the container image on boot. Works on
+# any cloud whose VMs run cloud-init and can install Docker.
+package_update: true
+packages:
+  - docker.io
+runcmd:
+  # ephemeral: powered off, and terminated, after {{.TTLMinutes}} minutes even if
+  # nobody tears the fleet down
+  - [shutdown, -P, "+{{.TTLMinutes}}"]
+  - [systemctl, enable, --now, docker]
+  - [docker, run, -d, --restart, unless-stopped, --network, host, --ulimit, "nofile=65535:65535", -e, "PERTOOLS_AGENT_TOKEN=${agent_token}", --entrypoint, custom-per-tools, "{{.Image}}", agent, --listen, ":{{.Port}}"]
+`))
+
+var runScriptTemplate = template.Must(template.New("run.sh").Parse(`#!/bin/sh
+# Spins up the load agents, runs the suite command given as arguments
+# across them and tears them down, even when the suite fails:
+#
+#   {{.Script}} go run . --config perf.json
+#
+# The results are written by the coordinator, this machine, as usual.
+set -eu
+dir=$(dirname "$0")
+tf() { terraform -chdir="$dir" "$@"; }
+
+[ $# -gt 0 ] || { echo "usage: $0 <suite command>, e.g. $0 go run . --config perf.json" >&2; exit 2; }
+# the fleet's token, new for every fleet unless it's set; the suite sends
+# it with every job
+PERTOOLS_AGENT_TOKEN=${PERTOOLS_AGENT_TOKEN:-$(od -An -N32 -tx1 /dev/urandom | tr -d ' \n')}
+export PERTOOLS_AGENT_TOKEN TF_VAR_agent_token="$PERTOOLS_AGENT_TOKEN"
+tf init -input=false
+trap 'tf destroy -auto-approve -input=false' EXIT
+tf apply -auto-approve -input=false
+agents=$(tf output -raw agents)
+
+# an agent is up once its /run endpoint refuses a GET that has the token
+for agent in $(echo "$agents" | tr ',' ' '); do
+  addr=${agent#*=}
+  echo "→ Waiting for the agent at $addr"
+  tries=0
+  until [ "$(curl -s -o /dev/null -m 5 -w '%{http_code}' -H "Authorization: Bearer $PERTOOLS_AGENT_TOKEN" "http://$addr/run")" = 405 ]; do
+    tries=$((tries + 1))
+    [ $tries -lt 120 ] || { echo "❌ The agent at $addr didn't start" >&2; exit 1; }
+    sleep 5
+  done
+done
+
+"$@" --agents "$agents"
+`))
+
+var awsRegionRe = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// parseInfraRegions reads "eu-west-1,us-east-1=2": the regions to run
+// agents in, each with its own count or perRegion.
+func parseInfraRegions(s string, perRegion int) ([]infraRegion, error) {
+	var out []infraRegion
+	seen := map[string]bool{}
+	for _, part := range splitList(s) {
+		r := infraRegion{Name: part, Count: perRegion}
+		if name, count, ok := strings.Cut(part, "="); ok {
+			r.Name = strings.TrimSpace(name)
+			if _, err := fmt.Sscan(count, &r.Count); err != nil || r.Count <= 0 {
+				return nil, fmt.Errorf("invalid agent count in %q", part)
+			}
+		}
+		if !awsRegionRe.MatchString(r.Name) {
+			return nil, fmt.Errorf("%q isn't an AWS region, e.g. eu-west-1", r.Name)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("region %s given twice", r.Name)
+		}
+		seen[r.Name] = true
+		r.ID = strings.ReplaceAll(r.Name, "-", "_")
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no regions given")
+	}
+	return out, nil
+}
+
+// runAgentsInfra implements `agents-infra --regions eu-west-1,us-east-1`:
+// it writes the Terraform and cloud-init for a fleet of ephemeral agent
+// VMs, and run.sh, which brings the fleet up, runs the suite across it
+// with every agent labelled by its region, and destroys it, making a
+// multi-region test one command.
+func runAgentsInfra(args []string) {
+	fs := flag.NewFlagSet("agents-infra", flag.ExitOnError)
+	regions := fs.String("regions", "", "comma-separated AWS regions to run agents in, each as region or region=count")
+	perRegion := fs.Int("per-region", 1, "agents in each region without its own count")
+	instanceType := fs.String("instance-type", "c6i.large", "EC2 instance type of the agents")
+	image := fs.String("image", "custom-per-tools:latest", "image built from the Dockerfile, pullable by the agents from a registry")
+	allow := fs.String("allow-cidr", "", "CIDR the agents accept jobs from, the coordinator's, e.g. 203.0.113.7/32")
+	port := fs.Int("port", 9090, "port the agents listen on")
+	ttl := fs.Duration("ttl", 2*time.Hour, "the agents power off and terminate after this long, even if never torn down")
+	name := fs.String("name", "perf-agents", "prefix of the cloud resources' names")
+	out := fs.String("out", "infra", "directory to write main.tf, cloud-init.yaml and run.sh to")
+	force := fs.Bool("force", false, "overwrite the files if they exist")
+	fs.Parse(args)
+
+	list, err := parseInfraRegions(*regions, *perRegion)
+	if err != nil {
+		fmt.Println("❌ --regions:", err)
+		os.Exit(1)
+	}
+	// agents run any job they're sent, so they must not be open to all
+	if _, ipnet, err := net.ParseCIDR(*allow); err != nil || ipnet.IP.IsUnspecified() {
+		fmt.Println("❌ --allow-cidr must be the coordinator's address range, e.g. 203.0.113.7/32: agents run any job they're sent")
+		os.Exit(1)
+	}
+	if *ttl < time.Minute {
+		fmt.Println("❌ --ttl must be a minute or more")
+		os.Exit(1)
+	}
+	data := struct {
+		agentInfra
+		Script string // how to invoke run.sh
+	}{agentInfra{
+		Name:         k8sName(*name),
+		InstanceType: *instanceType,
+		Image:        *image,
+		AllowCIDR:    *allow,
+		Port:         *port,
+		TTLMinutes:   int((*ttl + time.Minute - 1) / time.Minute),
+		Regions:      list,
+	}, filepath.Join(*out, "run.sh")}
+	if !filepath.IsAbs(data.Script) {
+		data.Script = "." + string(filepath.Separator) + data.Script
+	}
+
+	files := []struct {
+		name string
+		t    *template.Template
+		mode os.FileMode
+	}{{"main.tf", terraformTemplate, 0644}, {"cloud-init.yaml", cloudInitTemplate, 0644}, {"run.sh", runScriptTemplate, 0755}}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(*out, f.name)); err == nil && !*force {
+			fmt.Printf("❌ %s already exists; pass --force to overwrite it\n", filepath.Join(*out, f.name))
+			os.Exit(1)
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	for _, f := range files {
+		var b strings.Builder
+		if err := f.t.Execute(&b, data); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*out, f.name)
+		if err := os.WriteFile(path, []byte(b.String()), f.mode); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Written", path)
+	}
+	total := 0
+	for _, r := range list {
+		total += r.Count
+	}
+	fmt.Printf("→ %d agents in %d regions, terminated after %v at the latest\n", total, len(list), *ttl)
+	fmt.Printf("→ Run the suite across them with: %s go run . --config perf.json\n", data.Script)
+}
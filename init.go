@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// starterConfig is the config `init` writes: the settings it asks about,
+// leaving out those answered with nothing so the file stays short.
+type starterConfig struct {
+	URLs        []string    `json:"urls"`
+	Repeat      int         `json:"repeat"`
+	Requests    int         `json:"requests"`
+	Concurrency int         `json:"concurrency"`
+	Sweep       []int       `json:"sweep,omitempty"`
+	SLO         string      `json:"slo,omitempty"`
+	Thresholds  []string    `json:"thresholds,omitempty"`
+	Percentiles []float64   `json:"percentiles"`
+	Units       string      `json:"units,omitempty"`
+	Store       string      `json:"store,omitempty"`
+	CSV         *CSVDialect `json:"csv,omitempty"`
+}
+
+// wizard asks questions on out and reads the answers from in. Once in
+// runs dry every remaining question takes its default, so `init <
+// /dev/null` writes the defaults.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool
+}
+
+// ask prompts for one answer, offering def when it's set, until check
+// accepts it.
+func (w *wizard) ask(question, def string, check func(string) error) string {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", question)
+		}
+		answer := def
+		if !w.eof {
+			line, err := w.in.ReadString('\n')
+			w.eof = err != nil
+			if line = strings.TrimSpace(line); line != "" {
+				answer = line
+			}
+		}
+		if w.eof {
+			fmt.Fprintln(w.out)
+		}
+		if check == nil {
+			return answer
+		}
+		err := check(answer)
+		if err == nil {
+			return answer
+		}
+		fmt.Fprintf(w.out, "❌ %v\n", err)
+		if w.eof {
+			return def
+		}
+	}
+}
+
+func (w *wizard) askInt(question string, def int) int {
+	answer := w.ask(question, strconv.Itoa(def), func(s string) error {
+		if v, err := strconv.Atoi(s); err != nil || v <= 0 {
+			return fmt.Errorf("want a positive integer")
+		}
+		return nil
+	})
+	v, _ := strconv.Atoi(answer)
+	return v
+}
+
+func checkTargetURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want an http:// or https:// URL")
+	}
+	return nil
+}
+
+// runInit implements `init [--config FILE]`: it asks for the targets,
+// the load and the outputs wanted, and writes them as a starter config,
+// so nobody has to edit the constants in main.go to point the tool at
+// their own deployments.
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	path := fs.String("config", "perf.json", "config file to write")
+	force := fs.Bool("force", false, "overwrite the config file if it exists")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*path); err == nil && !*force {
+		fmt.Printf("❌ %s already exists; pass --force to overwrite it\n", *path)
+		os.Exit(1)
+	}
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	fmt.Printf("→ Writing a starter config to %s; press Enter to take the [default]\n", *path)
+	def := defaultConfig()
+	var c starterConfig
+
+	fmt.Println("\nTargets: the deployments to compare, one URL each. Finish with an empty line.")
+	for {
+		question := fmt.Sprintf("Target %d URL", len(c.URLs)+1)
+		if len(c.URLs) == 0 {
+			question += " (empty for the built-in targets)"
+		}
+		u := w.ask(question, "", func(s string) error {
+			if s == "" {
+				return nil
+			}
+			return checkTargetURL(s)
+		})
+		if u == "" {
+			break
+		}
+		c.URLs = append(c.URLs, u)
+		if w.eof {
+			break
+		}
+	}
+	if len(c.URLs) == 0 {
+		c.URLs = def.URLs
+	}
+
+	fmt.Println("\nLoad: every target runs repeat times, each run sending requests at the concurrency.")
+	c.Repeat = w.askInt("Runs per target (repeat)", def.Repeat)
+	c.Requests = w.askInt("Requests per run", def.Requests)
+	c.Concurrency = w.askInt("Concurrency", def.Concurrency)
+	sweep := w.ask("Concurrency levels to sweep instead, e.g. 10,50,100 (empty for none)", "", func(s string) error {
+		_, err := parseIntList(s)
+		return err
+	})
+	c.Sweep, _ = parseIntList(sweep)
+
+	fmt.Println("\nPass/fail: the suite exits 1 when these don't hold.")
+	c.SLO = w.ask(`Latency objective, e.g. "99% < 300ms" (empty for none)`, "", func(s string) error {
+		_, err := parseSLO(s)
+		return err
+	})
+	thresholds := w.ask("Thresholds, e.g. p95<500ms,error_rate<1 (empty for none)", "", func(s string) error {
+		_, err := parseThresholds(splitList(s))
+		return err
+	})
+	c.Thresholds = splitList(thresholds)
+
+	fmt.Println("\nOutput:")
+	percentiles := w.ask("Percentiles to report", "50,75,90,95,99", func(s string) error {
+		_, err := parsePercentiles(s)
+		return err
+	})
+	c.Percentiles, _ = parsePercentiles(percentiles)
+	if units := w.ask("Latency units, ms or s", "ms", validateUnits); units != "ms" {
+		c.Units = units
+	}
+	c.Store = w.ask("Results store to append every suite to, for trends (empty for none)", "", nil)
+	delimiter := w.ask("CSV delimiter, e.g. ; or tab", ",", func(s string) error {
+		return CSVDialect{Delimiter: s}.validate()
+	})
+	if delimiter != "," {
+		c.CSV = &CSVDialect{Delimiter: delimiter}
+	}
+
+	var raw strings.Builder
+	enc := json.NewEncoder(&raw)
+	enc.SetEscapeHTML(false) // keep "p95<500ms" readable
+	enc.SetIndent("", "  ")
+	enc.Encode(c)
+	if err := os.WriteFile(*path, []byte(raw.String()), 0644); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if _, err := loadConfig(*path); err != nil {
+		fmt.Println("❌ The written config doesn't load:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Config written to %s\n", *path)
+	fmt.Printf("→ Run the suite with: go run . --config %s\n", *path)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// addSpread stores the within-run tail spread p99 − p50, when both
+// percentiles were measured.
+func addSpread(row map[string]string) {
+	p99, ok1 := rowFloat(row, "p99")
+	p50, ok2 := rowFloat(row, "p50")
+	if ok1 && ok2 {
+		row["spread"] = strconv.FormatFloat(p99-p50, 'f', 4, 64)
+	}
+}
+
+// analyzeJitter reports how stable each target is across runs: the
+// standard deviation, IQR and coefficient of variation of the mean
+// latency, and the average within-run p99 − p50 spread.
+func analyzeJitter(rows []map[string]string, filename string) {
+	averages, order := seriesValues(rows, "average")
+	spreads, _ := seriesValues(rows, "spread")
+	if len(order) == 0 {
+		return
+	}
+
+	var table [][]string
+	var sd, iq, sp []opts.BarData
+	var shown []string
+	for _, key := range order {
+		avgs := averages[key]
+		cv := 0.0
+		if m := mean(avgs); m > 0 {
+			cv = stddev(avgs) / m
+		}
+		table = append(table, []string{
+			key, strconv.Itoa(len(avgs)),
+			fmt.Sprintf("%.4f", inUnit(mean(avgs))), fmt.Sprintf("%.4f", inUnit(stddev(avgs))), fmt.Sprintf("%.4f", inUnit(iqr(avgs))),
+			fmt.Sprintf("%.1f%%", cv*100), fmt.Sprintf("%.4f", inUnit(mean(spreads[key]))),
+		})
+		if !seriesWanted(key) {
+			continue
+		}
+		shown = append(shown, key)
+		sd = append(sd, opts.BarData{Value: round4(inUnit(stddev(avgs)))})
+		iq = append(iq, opts.BarData{Value: round4(inUnit(iqr(avgs)))})
+		sp = append(sp, opts.BarData{Value: round4(inUnit(mean(spreads[key])))})
+	}
+	addReportSection("Latency jitter across runs",
+		"Spread of each run's mean latency across the suite, and the average within-run p99 − p50 gap ("+displayUnit+").\n\n"+
+			markdownTable([]string{"target", "runs", "mean", "stddev", "IQR", "CV", "p99 − p50"}, table))
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Latency Jitter", "lower is more stable")),
+		charts.WithYAxisOpts(opts.YAxis{Name: displayUnit}),
+	)
+	bar.SetGlobalOptions(interactiveOpts(displayUnit)...)
+	bar.SetXAxis(shown)
+	bar.AddSeries("stddev of mean", sd)
+	bar.AddSeries("IQR of mean", iq)
+	bar.AddSeries("p99 − p50", sp)
+
+	renderChart(bar, filename)
+}
+
+func round4(v float64) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', 4, 64), 64)
+	return r
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SuiteJSON is the machine-readable form of a suite written next to the
+// CSV: its identity, labels, load-generator environment and every
+// result row.
+type SuiteJSON struct {
+	Schema      int                 `json:"schema"`
+	Suite       string              `json:"suite"`
+	Started     time.Time           `json:"started"`
+	Labels      map[string]string   `json:"labels,omitempty"`
+	Environment Environment         `json:"environment"`
+	Versions    map[string]string   `json:"versions,omitempty"`
+	Cooldown    string              `json:"cooldown"`
+	Cooldowns   map[string]string   `json:"cooldowns,omitempty"`
+	Truncated   string              `json:"truncated,omitempty"`
+	Aborted     map[string]string   `json:"aborted,omitempty"`
+	Results     []map[string]string `json:"results"`
+}
+
+func writeJSON(filename, suite string, started time.Time, env Environment, rows []map[string]string) error {
+	out := SuiteJSON{Schema: schemaVersion, Suite: suite, Started: started, Labels: labels, Environment: env, Versions: versions,
+		Cooldown: cfg.Cooldown, Cooldowns: targetCooldowns, Truncated: truncated, Aborted: abortedTargets}
+	for _, row := range rows {
+		out.Results = append(out.Results, publicRow(row))
+	}
+	raw, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, raw, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ JSON written to %s\n", filename)
+	return nil
+}
+
+// publicRow drops the internal slo_good/slo_total counters and aggregate
+// mark from a row.
+func publicRow(row map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range row {
+		if k != "slo_good" && k != "slo_total" && k != aggregateKey {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// manifest writes Kubernetes YAML. Scalars are written as JSON strings,
+// which YAML reads as double-quoted ones.
+type manifest struct {
+	strings.Builder
+}
+
+func (m *manifest) line(indent int, format string, args ...interface{}) {
+	m.WriteString(strings.Repeat("  ", indent))
+	fmt.Fprintf(m, format, args...)
+	m.WriteString("\n")
+}
+
+func yamlString(s string) string {
+	raw, _ := json.Marshal(s)
+	return string(raw)
+}
+
+var k8sUnsafe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// k8sName turns s into a DNS-1123 label, as object names must be. CronJob
+// names are limited to 52 characters, to leave room for the Jobs'
+// suffix.
+func k8sName(s string) string {
+	name := strings.Trim(k8sUnsafe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(name) > 52 {
+		name = strings.TrimRight(name[:52], "-")
+	}
+	if name == "" {
+		name = "perf"
+	}
+	return name
+}
+
+// k8sJob is one Job or CronJob of the manifest.
+type k8sJob struct {
+	name, cron string
+	args       []string
+}
+
+// k8sSink is where the suites inside the cluster leave their results.
+type k8sSink struct {
+	claim   string // the PersistentVolumeClaim mounted at /results, if any
+	create  bool   // whether the manifest creates claim
+	archive string // an s3:// or gs:// URL to upload every suite to
+}
+
+func parseSink(s string) (k8sSink, error) {
+	switch {
+	case s == "pvc":
+		return k8sSink{create: true}, nil
+	case strings.HasPrefix(s, "pvc:") && len(s) > len("pvc:"):
+		return k8sSink{claim: strings.TrimPrefix(s, "pvc:")}, nil
+	case strings.HasPrefix(s, "s3://"), strings.HasPrefix(s, "gs://"):
+		return k8sSink{archive: s}, nil
+	}
+	return k8sSink{}, fmt.Errorf("invalid --results %q, want pvc, pvc:CLAIM or an s3:// or gs:// URL", s)
+}
+
+// runK8sManifest implements `k8s-manifest [--config FILE] [suite flags]`:
+// it writes the manifest running the suite inside the cluster, next to
+// the targets, from the image the Dockerfile builds. The config goes in a
+// Secret mounted at /config, since it can hold webhook secrets, auth
+// headers and credentialed URLs. With --schedule, or schedules in the
+// config, the suite runs as CronJobs, one per schedule with its args;
+// otherwise once, as a Job. Results go to a PersistentVolumeClaim, which
+// also holds the results store for trends, or are archived to S3 or GCS.
+func runK8sManifest(args []string) {
+	fs := flag.NewFlagSet("k8s-manifest", flag.ExitOnError)
+	config := fs.String("config", "", "suite config to put in the config Secret")
+	name := fs.String("name", "perf", "name of the Job or CronJob, and prefix of the other objects")
+	namespace := fs.String("namespace", "", "namespace of the objects, e.g. the targets'")
+	image := fs.String("image", "custom-per-tools:latest", "image built from the Dockerfile")
+	schedule := fs.String("schedule", "", "cron expression to run the suite on, as a CronJob")
+	results := fs.String("results", "pvc", "where results go: pvc (a new claim), pvc:CLAIM (an existing one), or an s3:// or gs:// URL to archive to")
+	pvcSize := fs.String("pvc-size", "1Gi", "storage requested by the new results claim")
+	secret := fs.String("secret", "", "Secret whose keys become the suite's environment, e.g. AWS credentials or PERTOOLS_ settings")
+	out := fs.String("out", "k8s.yaml", "manifest file to write")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: k8s-manifest [flags] [-- suite flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	sink, err := parseSink(*results)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	base := k8sName(*name)
+	if sink.create {
+		sink.claim = base + "-results"
+	}
+	var raw []byte
+	var c Config
+	if *config != "" {
+		if c, err = loadConfig(*config); err != nil {
+			fmt.Println("❌ Error loading config:", err)
+			os.Exit(1)
+		}
+		raw, _ = os.ReadFile(*config)
+	}
+
+	var jobs []k8sJob
+	switch {
+	case *schedule != "":
+		if _, err := parseCron(*schedule); err != nil {
+			fmt.Println("❌ --schedule:", err)
+			os.Exit(1)
+		}
+		jobs = []k8sJob{{name: base, cron: *schedule}}
+	case len(c.Schedules) > 0:
+		for _, s := range c.Schedules {
+			jobs = append(jobs, k8sJob{name: k8sName(base + "-" + s.Name), cron: s.Cron, args: append([]string{"--tag", s.Name}, s.Args...)})
+		}
+	default:
+		jobs = []k8sJob{{name: base}}
+	}
+	for i := range jobs {
+		jobs[i].args = append(jobs[i].args, fs.Args()...)
+		// Kubernetes has no @nightly; spell the shorthands out
+		if expr, ok := cronAliases[strings.TrimSpace(jobs[i].cron)]; ok {
+			jobs[i].cron = expr
+		}
+	}
+
+	m := &manifest{}
+	meta := func(kind, objName string) {
+		if m.Len() > 0 {
+			m.line(0, "---")
+		}
+		apiVersion := "v1"
+		switch kind {
+		case "Job", "CronJob":
+			apiVersion = "batch/v1"
+		}
+		m.line(0, "apiVersion: %s", apiVersion)
+		m.line(0, "kind: %s", kind)
+		m.line(0, "metadata:")
+		m.line(1, "name: %s", objName)
+		if *namespace != "" {
+			m.line(1, "namespace: %s", yamlString(*namespace))
+		}
+		m.line(1, "labels:")
+		m.line(2, "app.kubernetes.io/name: custom-per-tools")
+		m.line(2, "app.kubernetes.io/instance: %s", base)
+	}
+
+	if raw != nil {
+		meta("Secret", base+"-config")
+		m.line(0, "type: Opaque")
+		m.line(0, "stringData:")
+		m.line(1, "perf.json: |")
+		for _, l := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+			m.line(2, "%s", strings.TrimRight(l, " \t\r"))
+		}
+	}
+	if sink.create {
+		meta("PersistentVolumeClaim", sink.claim)
+		m.line(0, "spec:")
+		m.line(1, "accessModes: [ReadWriteOnce]")
+		m.line(1, "resources:")
+		m.line(2, "requests:")
+		m.line(3, "storage: %s", yamlString(*pvcSize))
+	}
+
+	// pod writes the pod template at indent.
+	pod := func(indent int, j k8sJob) {
+		m.line(indent, "spec:")
+		m.line(indent+1, "restartPolicy: Never")
+		// the image's user; fsGroup lets it write to the results claim
+		m.line(indent+1, "securityContext:")
+		m.line(indent+2, "runAsNonRoot: true")
+		m.line(indent+2, "runAsUser: %d", containerUID)
+		m.line(indent+2, "runAsGroup: %d", containerUID)
+		m.line(indent+2, "fsGroup: %d", containerUID)
+		m.line(indent+1, "containers:")
+		m.line(indent+2, "- name: perf")
+		m.line(indent+3, "image: %s", yamlString(*image))
+		if len(j.args) > 0 {
+			quoted := make([]string, len(j.args))
+			for k, a := range j.args {
+				quoted[k] = yamlString(a)
+			}
+			m.line(indent+3, "args: [%s]", strings.Join(quoted, ", "))
+		}
+		var env [][2]string
+		if sink.archive != "" {
+			env = append(env, [2]string{envName("archive"), sink.archive})
+		}
+		if sink.claim != "" && c.Store == "" {
+			// beneath the config file, so its own store still wins
+			env = append(env, [2]string{envName("store"), containerResults + "/" + defaultStore})
+		}
+		if len(env) > 0 {
+			m.line(indent+3, "env:")
+			for _, e := range env {
+				m.line(indent+4, "- name: %s", e[0])
+				m.line(indent+5, "value: %s", yamlString(e[1]))
+			}
+		}
+		if *secret != "" {
+			m.line(indent+3, "envFrom:")
+			m.line(indent+4, "- secretRef:")
+			m.line(indent+6, "name: %s", yamlString(*secret))
+		}
+		m.line(indent+3, "volumeMounts:")
+		if raw != nil {
+			m.line(indent+4, "- name: config")
+			m.line(indent+5, "mountPath: /config")
+			m.line(indent+5, "readOnly: true")
+		}
+		m.line(indent+4, "- name: results")
+		m.line(indent+5, "mountPath: %s", containerResults)
+		m.line(indent+1, "volumes:")
+		if raw != nil {
+			m.line(indent+2, "- name: config")
+			m.line(indent+3, "secret:")
+			m.line(indent+4, "secretName: %s", base+"-config")
+		}
+		m.line(indent+2, "- name: results")
+		if sink.claim != "" {
+			m.line(indent+3, "persistentVolumeClaim:")
+			m.line(indent+4, "claimName: %s", yamlString(sink.claim))
+		} else {
+			m.line(indent+3, "emptyDir: {}")
+		}
+	}
+	for _, j := range jobs {
+		if j.cron == "" {
+			meta("Job", j.name)
+			m.line(0, "spec:")
+			// a failed threshold fails the Job; retrying won't pass it
+			m.line(1, "backoffLimit: 0")
+			m.line(1, "template:")
+			pod(2, j)
+			continue
+		}
+		meta("CronJob", j.name)
+		m.line(0, "spec:")
+		m.line(1, "schedule: %s", yamlString(j.cron))
+		// like `schedule`, suites never overlap, skewing each other
+		m.line(1, "concurrencyPolicy: Forbid")
+		m.line(1, "jobTemplate:")
+		m.line(2, "spec:")
+		m.line(3, "backoffLimit: 0")
+		m.line(3, "template:")
+		pod(4, j)
+	}
+
+	// only for the owner, as it holds the config Secret
+	if err := os.WriteFile(*out, []byte(m.String()), 0600); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	for _, j := range jobs {
+		if j.cron != "" {
+			fmt.Printf("→ CronJob %s on %q\n", j.name, j.cron)
+		} else {
+			fmt.Printf("→ Job %s\n", j.name)
+		}
+	}
+	switch {
+	case sink.archive != "":
+		fmt.Printf("→ Results archived to %s\n", sink.archive)
+	case sink.create:
+		fmt.Printf("→ Results kept in the new claim %s\n", sink.claim)
+	default:
+		fmt.Printf("→ Results kept in the claim %s\n", sink.claim)
+	}
+	fmt.Printf("✅ Manifest written to %s; apply it with: kubectl apply -f %s\n", *out, *out)
+}
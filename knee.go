@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// A knee is called where latency grows at least kneeSlopeRatio times as
+// fast per added worker after it as before, and by at least kneeGrowth
+// in all between it and the highest level, so noise on a flat curve
+// isn't mistaken for one.
+const (
+	kneeSlopeRatio = 2.0
+	kneeGrowth     = 0.2
+)
+
+type knee struct {
+	index         int // of the knee level, -1 for none
+	before, after float64
+}
+
+// findKnee fits two lines to latency ys over concurrency xs, meeting at
+// each inner level in turn, and keeps the split with the least squared
+// error: the changepoint where the curve bends. It's a knee if the curve
+// bends upwards sharply enough.
+func findKnee(xs, ys []float64) knee {
+	best := knee{index: -1}
+	if len(xs) < 3 {
+		return best
+	}
+	bestSSE := math.Inf(1)
+	for k := 1; k < len(xs)-1; k++ {
+		s1, e1 := lineFit(xs[:k+1], ys[:k+1])
+		s2, e2 := lineFit(xs[k:], ys[k:])
+		if e1+e2 < bestSSE {
+			bestSSE = e1 + e2
+			best = knee{index: k, before: s1, after: s2}
+		}
+	}
+	k := best.index
+	if best.after <= 0 || best.after < kneeSlopeRatio*math.Max(best.before, 0) || ys[len(ys)-1] < (1+kneeGrowth)*ys[k] {
+		best.index = -1
+	}
+	return best
+}
+
+// lineFit is the least-squares slope of ys over xs and its squared error.
+func lineFit(xs, ys []float64) (slope, sse float64) {
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx float64
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope = sxy / sxx
+	for i := range xs {
+		r := ys[i] - (my + slope*(xs[i]-mx))
+		sse += r * r
+	}
+	return slope, sse
+}
+
+// analyzeKnees estimates each swept target's saturation knee, the level
+// past which its latency grows super-linearly with concurrency, and
+// reports the concurrency and throughput there: the most load it takes
+// before queueing sets in.
+func analyzeKnees(rows []map[string]string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	lat := map[string]map[int][]float64{}
+	rps := map[string]map[int][]float64{}
+	for _, row := range rows {
+		c, err := strconv.Atoi(row["concurrency"])
+		l, ok1 := rowFloat(row, latency)
+		r, ok2 := rowFloat(row, "requests_per_sec")
+		if err != nil || !ok1 || !ok2 || row["agent"] != "" {
+			continue
+		}
+		key := rowTargetKey(row)
+		if lat[key] == nil {
+			lat[key], rps[key] = map[int][]float64{}, map[int][]float64{}
+		}
+		lat[key][c] = append(lat[key][c], l)
+		rps[key][c] = append(rps[key][c], r)
+	}
+	var keys []string
+	for k := range lat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var table [][]string
+	for _, k := range keys {
+		var levels []int
+		for c := range lat[k] {
+			levels = append(levels, c)
+		}
+		if len(levels) < 3 {
+			continue
+		}
+		sort.Ints(levels)
+		var xs, ys []float64
+		for _, c := range levels {
+			xs = append(xs, float64(c))
+			ys = append(ys, mean(lat[k][c]))
+		}
+		kn := findKnee(xs, ys)
+		if kn.index < 0 {
+			table = append(table, []string{k, "none", "–", "–",
+				fmt.Sprintf("no knee up to c=%d", levels[len(levels)-1])})
+			continue
+		}
+		c := levels[kn.index]
+		slopes := fmt.Sprintf("%.3g → %.3g %s/worker", inUnit(kn.before), inUnit(kn.after), displayUnit)
+		table = append(table, []string{k, strconv.Itoa(c), fmt.Sprintf("%.1f", mean(rps[k][c])),
+			fmt.Sprintf("%.4g", inUnit(ys[kn.index])), slopes})
+		fmt.Printf("⚠️ %s: latency knee at c=%d (%.1f rps)\n", k, c, mean(rps[k][c]))
+	}
+	if len(table) == 0 {
+		return
+	}
+	addReportSection("Saturation knee",
+		fmt.Sprintf("Where %s latency starts growing super-linearly with concurrency: the best split of the sweep into two linear segments, "+
+			"called a knee when the slope after it is at least %.0f× the slope before and latency rises %.0f%% more by the highest level. "+
+			"Sweeps of fewer than three levels aren't analysed.\n\n", latency, kneeSlopeRatio, kneeGrowth*100)+
+			markdownTable([]string{"target", "knee c", "rps at knee", withUnit(latency) + " at knee", "latency slope"}, table))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// labels are the suite's key=value annotations (git sha, env, branch,
+// ticket...) from "labels" in the config and --label flags. They're
+// copied into every result row as label_<key> columns, the JSON output,
+// the store, chart subtitles and the report, so any artifact can be
+// traced back to what was tested.
+var labels = map[string]string{}
+
+func parseLabels(list []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, l := range list {
+		k, v, ok := strings.Cut(l, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q, want key=value", l)
+		}
+		out[k] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+func labelKeys() []string {
+	var keys []string
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func labelString() string {
+	var parts []string
+	for _, k := range labelKeys() {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func applyLabels(row map[string]string) {
+	for k, v := range labels {
+		row["label_"+k] = v
+	}
+}
+
+// chartTitle adds the suite labels to a chart's subtitle.
+func chartTitle(title, subtitle string) opts.Title {
+	if l := labelString(); l != "" {
+		if subtitle != "" {
+			subtitle += " · "
+		}
+		subtitle += l
+	}
+	return opts.Title{Title: title, Subtitle: subtitle}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// suiteDir holds everything one suite writes: the raw hey outputs in
+// hey_results/, the CSVs, JSON, charts and report. Each suite gets its own
+// directory under --results-dir, so earlier suites are kept.
+var suiteDir = "."
+
+var outDir = "hey_results"
+
+func suiteFile(name string) string {
+	return filepath.Join(suiteDir, name)
+}
+
+// createSuiteDir makes root/<short suite ID>-<timestamp> and points
+// root/latest at it. Where symlinks need privileges, as on Windows, the
+// suite's name is written to root/latest.txt instead.
+func createSuiteDir(root string, started time.Time) error {
+	name := suiteID[:8] + "-" + started.Format("20060102T150405")
+	suiteDir = filepath.Join(root, name)
+	outDir = filepath.Join(suiteDir, "hey_results")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	latest := filepath.Join(root, "latest")
+	if fi, err := os.Lstat(latest); err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		fmt.Printf("⚠️  %s isn't a symlink, leaving it alone\n", latest)
+		return nil
+	}
+	os.Remove(latest)
+	if err := os.Symlink(name, latest); err != nil {
+		if werr := os.WriteFile(latest+".txt", []byte(name+"\n"), 0644); werr != nil {
+			fmt.Printf("⚠️  Couldn't link %s: %v\n", latest, err)
+		}
+		return nil
+	}
+	os.Remove(latest + ".txt")
+	return nil
+}
+
+// latestSuiteDir is the directory root/latest (or root/latest.txt) points at.
+func latestSuiteDir(root string) (string, error) {
+	latest := filepath.Join(root, "latest")
+	if _, err := os.Stat(latest); err == nil {
+		return latest, nil
+	}
+	name, err := os.ReadFile(latest + ".txt")
+	if err != nil {
+		return "", fmt.Errorf("no latest suite in %s", root)
+	}
+	dir := filepath.Join(root, strings.TrimSpace(string(name)))
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
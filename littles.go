@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// saturationEfficiency is the share of ideal linear scaling below which a
+// sweep level counts as saturated.
+const saturationEfficiency = 0.8
+
+type sweepPoint struct {
+	concurrency int
+	rps         float64
+	latency     float64 // mean, milliseconds
+}
+
+// effective is the concurrency Little's Law infers from the measurement:
+// L = λW, requests in flight = throughput × mean latency.
+func (p sweepPoint) effective() float64 { return p.rps * p.latency / 1000 }
+
+// sweepPoints averages every run per target and concurrency level.
+func sweepPoints(rows []map[string]string) map[string][]sweepPoint {
+	type acc struct {
+		rps, lat float64
+		n        int
+	}
+	sums := map[string]map[int]*acc{}
+	for _, row := range rows {
+		c, err := strconv.Atoi(row["concurrency"])
+		rps, ok1 := rowFloat(row, "requests_per_sec")
+		lat, ok2 := rowFloat(row, "average")
+		if err != nil || !ok1 || !ok2 {
+			continue
+		}
+		key := rowTargetKey(row)
+		if sums[key] == nil {
+			sums[key] = map[int]*acc{}
+		}
+		a := sums[key][c]
+		if a == nil {
+			a = &acc{}
+			sums[key][c] = a
+		}
+		a.rps += rps
+		a.lat += lat
+		a.n++
+	}
+
+	out := map[string][]sweepPoint{}
+	for key, levels := range sums {
+		for c, a := range levels {
+			out[key] = append(out[key], sweepPoint{concurrency: c, rps: a.rps / float64(a.n), latency: a.lat / float64(a.n)})
+		}
+		sort.Slice(out[key], func(i, j int) bool { return out[key][i].concurrency < out[key][j].concurrency })
+	}
+	return out
+}
+
+// saturationPoint returns the index of the first level whose throughput
+// falls below saturationEfficiency of linear scaling from the lowest
+// level, or -1 if the target kept scaling.
+func saturationPoint(points []sweepPoint) int {
+	if len(points) < 2 || points[0].concurrency == 0 {
+		return -1
+	}
+	base := points[0]
+	for i, p := range points[1:] {
+		ideal := base.rps * float64(p.concurrency) / float64(base.concurrency)
+		if ideal > 0 && p.rps/ideal < saturationEfficiency {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// analyzeLittlesLaw adds the Little's Law table and saturation verdicts to
+// the report and charts measured against ideal throughput per target.
+func analyzeLittlesLaw(rows []map[string]string, filename string) {
+	points := sweepPoints(rows)
+	var keys []string
+	for k, p := range points {
+		if len(p) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	var table [][]string
+	var verdicts string
+	levels := map[int]bool{}
+	for _, k := range keys {
+		ps := points[k]
+		sat := saturationPoint(ps)
+		for i, p := range ps {
+			levels[p.concurrency] = true
+			ideal := ps[0].rps * float64(p.concurrency) / float64(ps[0].concurrency)
+			mark := ""
+			if i == sat {
+				mark = "⚠️ saturated"
+			}
+			table = append(table, []string{
+				k, strconv.Itoa(p.concurrency),
+				fmt.Sprintf("%.1f", p.rps), fmt.Sprintf("%.1f", ideal),
+				fmt.Sprintf("%.4f", inUnit(p.latency)), fmt.Sprintf("%.1f", p.effective()),
+				fmt.Sprintf("%.0f%%", 100*p.effective()/float64(p.concurrency)), mark,
+			})
+		}
+		if sat < 0 {
+			verdicts += fmt.Sprintf("- **%s** kept scaling up to c=%d (%.1f rps).\n", k, ps[len(ps)-1].concurrency, ps[len(ps)-1].rps)
+		} else {
+			verdicts += fmt.Sprintf("- **%s** saturates at c=%d: %.1f rps vs %.0f%% of linear scaling; last healthy level c=%d (%.1f rps).\n",
+				k, ps[sat].concurrency, ps[sat].rps, saturationEfficiency*100, ps[sat-1].concurrency, ps[sat-1].rps)
+		}
+	}
+	addReportSection("Concurrency and throughput (Little's Law)",
+		"Effective concurrency is RPS × mean latency; ideal RPS scales the lowest level linearly.\n\n"+
+			markdownTable([]string{"target", "c", "rps", "ideal rps", "mean latency (" + displayUnit + ")", "effective c", "utilisation", ""}, table)+
+			"\n"+verdicts)
+
+	var xs []int
+	for c := range levels {
+		xs = append(xs, c)
+	}
+	sort.Ints(xs)
+	var xAxis []string
+	for _, c := range xs {
+		xAxis = append(xAxis, strconv.Itoa(c))
+	}
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Measured vs Ideal Throughput", "")),
+		charts.WithYAxisOpts(opts.YAxis{Name: "rps"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Concurrency"}),
+	)
+	line.SetGlobalOptions(interactiveOpts("rps")...)
+	line.SetXAxis(xAxis)
+	for _, k := range keys {
+		if !seriesWanted(k) {
+			continue
+		}
+		ps := points[k]
+		byC := map[int]sweepPoint{}
+		for _, p := range ps {
+			byC[p.concurrency] = p
+		}
+		var measured, ideal []opts.LineData
+		for _, c := range xs {
+			p, ok := byC[c]
+			if !ok {
+				measured = append(measured, opts.LineData{Value: nil})
+			} else {
+				measured = append(measured, opts.LineData{Value: p.rps})
+			}
+			ideal = append(ideal, opts.LineData{Value: ps[0].rps * float64(c) / float64(ps[0].concurrency)})
+		}
+		line.AddSeries(k+" measured", measured)
+		line.AddSeries(k+" ideal", ideal, charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed"}))
+	}
+
+	renderChart(line, filename)
+}
@@ -2,14 +2,17 @@ package main
 
 import (
 	"bufio"
-	"encoding/csv"
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
-	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -21,17 +24,113 @@ var urls = []string{
 }
 
 const repeat = 30
-const outDir = "hey_results"
 const requestCounter = 1000
 const worker = 100
 
+var (
+	configPath      = flag.String("config", "", "JSON config file (urls, repeat, requests, concurrency, percentiles)")
+	percentileList  = flag.String("percentiles", "", "comma-separated percentiles to report, e.g. 50,95,99,99.9")
+	harPath         = flag.String("har", "", "replay the requests recorded in a HAR file as the load mix")
+	postmanPath     = flag.String("postman", "", "use the requests of a Postman v2.1 collection as targets")
+	postmanEnv      = flag.String("postman-env", "", "Postman environment file used for {{variable}} substitution")
+	openAPIPath     = flag.String("openapi", "", "generate GET targets from an OpenAPI (JSON) document")
+	baseURLs        = flag.String("base-url", "", "deployment base URLs for --openapi and --mix as name=url,name=url")
+	mixSpec         = flag.String("mix", "", "weighted request mix run against every base url, e.g. \"80% GET /persons, 20% GET /persons/1\"")
+	engine          = flag.String("engine", "hey", "load generator: hey or native")
+	rawCapture      = flag.Bool("raw", false, "capture every request latency into hey_raw_*.csv files next to the summaries")
+	noCookies       = flag.Bool("no-cookies", false, "native engine: don't keep a cookie jar per virtual user")
+	scenarioPath    = flag.String("scenario", "", "scenario file of chained steps run by each virtual user (native engine)")
+	sweepList       = flag.String("sweep", "", "run every target at each of these concurrency levels, e.g. 10,50,100,200")
+	sloSpec         = flag.String("slo", "", "latency objective, e.g. \"99% < 300ms\"; also gates the suite")
+	agentList       = flag.String("agents", "", "comma-separated agent addresses ([region=]host:port) to distribute every run across")
+	agentTokenEnv   = flag.String("agent-token-env", defaultAgentTokenEnv, "environment variable holding the token the agents take jobs with")
+	sshList         = flag.String("ssh", "", "comma-separated [region=][user@]hosts to run hey on over SSH instead of locally")
+	rate            = flag.Float64("rate", 0, "native engine: send requests at this constant rate (req/s) instead of a closed loop")
+	fingerprint     = flag.String("fingerprint", "", "before the suite, ask each target its version: \"PATH [RULE]\", e.g. \"/version json:commit\"")
+	csvDelimiter    = flag.String("csv-delimiter", "", "result CSV delimiter, e.g. \";\" or tab (default \",\")")
+	csvDecimal      = flag.String("csv-decimal", "", "decimal separator in result CSVs: . or ,")
+	csvQuote        = flag.String("csv-quote", "", "result CSV quoting: minimal or all")
+	parseMode       = flag.String("parse", "lenient", "malformed values in result CSVs: strict fails with file:line:column, lenient marks the row invalid")
+	workers         = flag.Int("workers", runtime.NumCPU(), "goroutines used to parse run files and render charts")
+	heyPath         = flag.String("hey-path", "", "hey binary to run (default: hey on the PATH, ./bin/hey, or the cached download)")
+	heyURL          = flag.String("hey-url", "", "where to download hey from when it isn't found (default: the hey release for this OS and arch)")
+	heySHA256       = flag.String("hey-sha256", "", "SHA-256 the downloaded or cached hey must match; required to download it")
+	showHeyOutput   = flag.Bool("show-hey-output", false, "stream hey's output live, each line prefixed by its target and run")
+	heyOutput       = flag.String("hey-output", "csv", "how results are read from hey: csv (its per-request rows) or text (scraping its summary)")
+	streamRaw       = flag.Bool("stream", false, "with --raw, summarise hey's raw latencies in constant memory (t-digest percentiles) for long soak tests")
+	gzipOutputs     = flag.Bool("gzip", false, "gzip each run's hey output and raw file to save space in large suites")
+	xlsxPath        = flag.String("xlsx", "", "also export the suite as an Excel workbook to this file")
+	storePath       = flag.String("store", "", "append every result row to this results store (JSON Lines)")
+	archivePath     = flag.String("archive", "", "bundle the suite's outputs and metadata into this .tar.gz (or upload it to an s3:// or gs:// URL)")
+	resultsDir      = flag.String("results-dir", "results", "write each suite to its own <suite>-<time> directory here, linked as latest")
+	ciWidth         = flag.Float64("ci-width", 0, "repeat each target until the 95% CI of its mean is within this fraction of it, e.g. 0.05, up to the configured repeat")
+	ciMetrics       = flag.String("ci-metrics", "", "with --ci-width, the metrics that must converge (default rps,p95)")
+	minRepeat       = flag.Int("min-repeat", 0, "with --ci-width, run each target at least this many times (default 3)")
+	cooldown        = flag.String("cooldown", "", "pause after each run: 2s, \"exp 1s 30s\" (doubling) or \"jitter 1s 3s\" (random) (default 1s)")
+	abortAfter      = flag.String("abort-after", "", "give up on a target after this many consecutive failed runs, e.g. 3-failures")
+	abortErrorRate  = flag.String("abort-on-error-rate", "", "give up on a target once a run's error rate reaches this, e.g. 50%")
+	abMode          = flag.Bool("ab", false, "native engine: A/B experiment alternating individual requests between the two targets in every run")
+	maxDuration     = flag.Duration("max-duration", 0, "stop the suite cleanly after this long, e.g. 45m, keeping the results so far")
+	maxRequests     = flag.String("max-requests", "", "stop the suite cleanly once this many requests were sent, e.g. 500k")
+	chartList       = flag.String("charts", "", "only write these charts, by name or metric, e.g. rps,p95,envelope (default all)")
+	targetList      = flag.String("targets", "", "only draw these series in charts, by target name, \"name route\" or series, e.g. green-cloud")
+	xAxis           = flag.String("x-axis", "run", "what per-run charts plot along x: run (the run number) or time (when each run started)")
+	units           = flag.String("units", "", "show latencies in ms or s in charts, the report and the console (default ms)")
+	cacheBust       = flag.String("cache-bust", "", "defeat caches in front of the targets: query (a unique _cb parameter), header (Cache-Control: no-cache) or both")
+	cacheBustScope  = flag.String("cache-bust-scope", "", "a new cache-busting value per request (native engine; its default) or per run (hey's)")
+	retryAfter      = flag.Bool("retry-after", false, "back off as a 429's Retry-After says: each native virtual user before its next request, and every engine before the next run of a throttled target")
+	noPreflight     = flag.Bool("no-preflight", false, "skip checking open-file limits, ephemeral ports and GOMAXPROCS against the concurrency before the suite")
+	floorProbes     = flag.Int("network-floor", 0, "before the suite, time this many TCP connects to each target host as its network floor, and report latency less it")
+	networkSpec     = flag.String("network", "", "native engine: emulate a constrained client link, 3g, 4g, slow-3g or e.g. \"down=1.6mbit up=768kbit rtt=150ms\"")
+	dualStack       = flag.Bool("dual-stack", false, "native engine: run every target over forced IPv4 and then forced IPv6, as paired series")
+	heyExec         = flag.String("hey-exec", "", "run hey through this command, in another container, e.g. \"kubectl exec perf-job -c hey --\" for a sidecar")
+	cacheCompare    = flag.Bool("cache-compare", false, "run every target cached and then cache-busted (--cache-bust, default both), as paired series")
+	contractPath    = flag.String("contract", "", "SLA contract file with latency, error and availability targets per endpoint; the suite fails when they're broken")
+	onlyTargets     = flag.String("only", "", "run only the targets with these names, comma-separated, e.g. green")
+	candidate       = flag.String("candidate", "", "the deployment held to the --contract; the others are only reported (default: all)")
+	targetEnv       = flag.String("env", "", "select one of the config's environments, e.g. staging, overlaying the config with its urls and settings")
+	profileName     = flag.String("profile", "", "preset repeat, load, warm-up and thresholds: quick, standard, thorough, ci or one from the config's profiles")
+	seed            = flag.Int64("seed", 0, "seed the suite's random choices, e.g. the seed of a manifest to make the same ones (default: random)")
+	warmup          = flag.Int("warmup", 0, "discarded warm-up runs of each target before the measured ones")
+	curlCmds        stringList
+	thresholdList   stringList
+	tagList         stringList
+	labelList       stringList
+	reportTemplates stringList
+)
+
+func init() {
+	flag.Var(&curlCmds, "curl", "a pasted `curl ...` command to use as a target (repeatable)")
+	flag.Var(&thresholdList, "threshold", "fail the suite unless a metric holds, e.g. p95<500ms (repeatable)")
+	flag.Var(&labelList, "label", "annotate the suite with key=value, e.g. sha=abc123 (repeatable)")
+	flag.Var(&tagList, "tag", "tag the suite's rows in the results store (repeatable)")
+	flag.Var(&reportTemplates, "report-template", "Go template replacing report.md, or report.html when its name has .html (repeatable)")
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, "\n") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 type HeyResult struct {
-	URL     string
-	File    string
-	RPS     float64
-	P95     float64
-	Average float64
-	Total   float64
+	URL         string
+	Route       string
+	File        string
+	RPS         float64
+	P95         float64
+	Average     float64
+	Total       float64
+	Concurrency int
+	RunID       string
+	RawFile     string
+	Started     time.Time          // when the run started; zero in results from before it was recorded
+	Values      map[string]float64 // percentiles and derived metrics by column name
+	Invalid     string             // why the row can't be trusted; only set in lenient parsing
 }
 
 func readCSV(path string) ([]HeyResult, error) {
@@ -41,30 +140,112 @@ func readCSV(path string) ([]HeyResult, error) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	headers, _ := reader.Read()
+	reader := newCSVReader(file)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
 	index := make(map[string]int)
 	for i, h := range headers {
 		index[h] = i
 	}
 
-	records, _ := reader.ReadAll()
 	var results []HeyResult
+	var malformed []string
+	seen := map[string]bool{}
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if *parseMode == "strict" {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Printf("⚠️  %s: skipping unreadable row: %v\n", path, err)
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		var problems []string
+		if len(rec) != len(headers) {
+			problems = append(problems, fmt.Sprintf("%s:%d: %d fields, want %d", path, line, len(rec), len(headers)))
+		}
+		row := map[string]string{}
+		for h, i := range index {
+			if i >= len(rec) {
+				continue
+			}
+			row[h] = csvNumber(rec[i])
+			if row[h] != "" && numericColumn(h) && !isNumber(row[h]) {
+				_, col := reader.FieldPos(i)
+				problems = append(problems, fmt.Sprintf("%s:%d:%d: %s %q is not a number", path, line, col, h, rec[i]))
+			}
+		}
+		if _, err := migrateRow(headers, row); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if len(problems) > 0 && *parseMode == "strict" {
+			malformed = append(malformed, problems...)
+			continue
+		}
+		if kept, _ := dedupeRuns([]map[string]string{row}, seen); len(kept) == 0 {
+			fmt.Printf("⚠️  %s:%d: skipping duplicate run %s\n", path, line, row["run_id"])
+			continue
+		}
 
-	for _, row := range records {
-		r := HeyResult{
-			File:    row[index["file"]],
-			URL:     inferURLFromFile(row[index["file"]]),
-			RPS:     parseFloat(row[index["requests_per_sec"]]),
-			P95:     parseFloat(row[index["p95"]]),
-			Average: parseFloat(row[index["average"]]),
-			Total:   parseFloat(row[index["total"]]),
+		r := resultFromRow(row)
+		if len(problems) > 0 {
+			r.Invalid = strings.Join(problems, "; ")
+			fmt.Printf("⚠️  Row marked invalid: %s\n", r.Invalid)
 		}
 		results = append(results, r)
 	}
+	if len(malformed) > 0 {
+		return nil, fmt.Errorf("%d malformed values:\n  %s", len(malformed), strings.Join(malformed, "\n  "))
+	}
 	return results, nil
 }
 
+// resultFromRow converts a (migrated) result row for charting.
+func resultFromRow(row map[string]string) HeyResult {
+	r := HeyResult{
+		File:    row["file"],
+		URL:     inferURLFromFile(row["file"]),
+		RPS:     parseFloat(row["requests_per_sec"]),
+		Average: parseFloat(row["average"]),
+		Total:   parseFloat(row["total"]),
+		Route:   row["route"],
+		RunID:   row["run_id"],
+		RawFile: row["raw_file"],
+	}
+	if row["target"] != "" {
+		r.URL = row["target"]
+	}
+	r.Concurrency, _ = strconv.Atoi(row["concurrency"])
+	r.Started, _ = time.Parse(time.RFC3339Nano, row["started"])
+	r.Values = map[string]float64{}
+	for h, v := range row {
+		// empty cells are missing data, not zeros
+		if f, err := strconv.ParseFloat(v, 64); err == nil && numericColumn(h) {
+			r.Values[h] = f
+		}
+	}
+	r.P95 = r.Values["p95"]
+	return r
+}
+
+// numericColumn reports whether a result column holds a number; every
+// column is numeric except the identifying text ones.
+func numericColumn(h string) bool {
+	switch h {
+	case "run_id", "file", "target", "route", "method", "started", "raw_file", "version", "agent", "cache", "family", "chaos":
+		return false
+	}
+	return !strings.HasPrefix(h, "label_")
+}
+
 func parseFloat(s string) float64 {
 	v, _ := strconv.ParseFloat(s, 64)
 	return v
@@ -77,93 +258,220 @@ func inferURLFromFile(filename string) string {
 	return "t2no3"
 }
 
-func generateLineChart(data []HeyResult, metric string, title string, filename string) {
-	line := charts.NewLine()
-	line.SetGlobalOptions(
-		charts.WithTitleOpts(opts.Title{Title: title}),
-		charts.WithYAxisOpts(opts.YAxis{Name: metric}),
-		charts.WithXAxisOpts(opts.XAxis{Name: "Test Run"}),
-	)
-
-	urlGroups := map[string][]opts.LineData{}
-	xAxis := []string{}
-	for i := 1; i <= repeat; i++ {
-		xAxis = append(xAxis, fmt.Sprintf("%d", i))
-	}
-
+// generateRouteCharts renders one comparison chart per route and metric so
+// each endpoint's deployments can be compared side by side.
+func generateRouteCharts(data []HeyResult) {
+	byRoute := map[string][]HeyResult{}
+	var routes []string
 	for _, d := range data {
-		urlGroups[d.URL] = append(urlGroups[d.URL], opts.LineData{Value: extractMetric(d, metric)})
+		if d.Route == "" {
+			continue
+		}
+		if _, ok := byRoute[d.Route]; !ok {
+			routes = append(routes, d.Route)
+		}
+		route := d
+		route.Route = ""
+		byRoute[d.Route] = append(byRoute[d.Route], route)
+	}
+	if len(routes) < 2 {
+		return
 	}
 
-	line.SetXAxis(xAxis)
-	for url, series := range urlGroups {
-		line.AddSeries(url, series)
+	os.MkdirAll(suiteFile("charts"), 0755)
+	parallel(len(routes), func(i int) {
+		route := routes[i]
+		slug := slugifyURL(route)
+		if chartWanted("routes", "rps") {
+			generateChart(byRoute[route], lineChart("rps", "Requests Per Second — "+route, ""), suiteFile(filepath.Join("charts", "chart_"+slug+"_rps.html")))
+		}
+		if chartWanted("routes", "p95") {
+			generateChart(byRoute[route], lineChart("p95", "95th Percentile Latency — "+route, ""), suiteFile(filepath.Join("charts", "chart_"+slug+"_p95.html")))
+		}
+	})
+}
+
+func seriesKey(r HeyResult) string {
+	key := r.URL
+	if r.Route != "" {
+		key += " " + r.Route
+	}
+	if len(cfg.Sweep) > 1 && r.Concurrency > 0 {
+		key += fmt.Sprintf(" c=%d", r.Concurrency)
 	}
+	return key
+}
 
-	f, _ := os.Create(filename)
-	defer f.Close()
-	line.Render(f)
-	fmt.Printf("✅ Chart written to %s\n", filename)
+// mixedConcurrency reports whether targets ran at different concurrency,
+// e.g. because of per-target overrides, making raw throughput unfair to
+// compare.
+func mixedConcurrency(results []HeyResult) bool {
+	seen := map[float64]bool{}
+	for _, r := range results {
+		if c, ok := r.Values["concurrency"]; ok {
+			seen[c] = true
+		}
+	}
+	return len(seen) > 1
 }
 
-func extractMetric(r HeyResult, metric string) float64 {
+// extractMetric returns the metric's value and whether the run recorded
+// it at all, so a missing value isn't mistaken for a measured zero.
+func extractMetric(r HeyResult, metric string) (float64, bool) {
 	switch metric {
 	case "rps":
-		return r.RPS
-	case "p95":
-		return r.P95
-	case "average":
-		return r.Average
-	case "total":
-		return r.Total
-	default:
-		return 0
+		metric = "requests_per_sec"
+	case "rps_per_worker":
+		// normalises throughput across targets run at different concurrency
+		rps, ok := r.Values["requests_per_sec"]
+		c := r.Values["concurrency"]
+		if !ok || c <= 0 {
+			return 0, false
+		}
+		return rps / c, true
+	case "bandwidth":
+		return bandwidth(r.Values)
 	}
+	v, ok := r.Values[metric]
+	return v, ok
 }
 
 func slugifyURL(url string) string {
 	// Replace https:// and all non-alphanum with _
-	slug := strings.ReplaceAll(url, "https://", "")
-	return regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(slug, "_")
+	return safeSlug(strings.ReplaceAll(url, "https://", ""))
 }
 
-func runHey(url string, i int) (string, error) {
-	slug := slugifyURL(url)
-	outFile := filepath.Join(outDir, fmt.Sprintf("hey_result_%s_%d.txt", slug, i))
+// runHey runs hey for run i of t and returns its summary file and metrics.
+// In the default csv mode hey reports every request and the metrics are
+// computed from those rows; --hey-output text scrapes hey's own summary.
+func runHey(t Target, n int, i int) (string, map[string]string, error) {
+	outFile := filepath.Join(outDir, "hey_result_"+runStem(t.Slug, i)+".txt")
 
-	cmd := exec.Command("hey", "-n", strconv.Itoa(requestCounter), "-c", strconv.Itoa(worker), "-m", "GET", url)
-	outBytes, err := cmd.Output()
+	args := heyArgs(t, n, concurrencyFor(t))
+	csvMode := *rawCapture || *heyOutput == "csv"
+	if csvMode {
+		args = append([]string{"-o", "csv"}, args...)
+	}
+	name, args, err := heyCommand(args)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	cmd := exec.CommandContext(suiteCtx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if *showHeyOutput {
+		prefix := fmt.Sprintf("[%s #%d] ", t.label(), i)
+		liveOut := &linePrefixer{w: os.Stdout, prefix: prefix}
+		liveErr := &linePrefixer{w: os.Stderr, prefix: prefix}
+		cmd.Stdout = io.MultiWriter(&stdout, liveOut)
+		cmd.Stderr = io.MultiWriter(&stderr, liveErr)
+		defer liveOut.Flush()
+		defer liveErr.Flush()
+	}
+	began := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(began)
+	if stderr.Len() > 0 {
+		errFile := filepath.Join(outDir, "hey_stderr_"+runStem(t.Slug, i)+".txt")
+		if werr := os.WriteFile(errFile, []byte(redact(stderr.String())), 0644); werr == nil {
+			compressOutput(errFile)
+		}
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return "", nil, fmt.Errorf("%w: %s", err, redact(lastLine(stderr.Bytes())))
+		}
+		return "", nil, err
+	}
+	outBytes := stdout.Bytes()
+
+	if !csvMode {
+		os.WriteFile(outFile, outBytes, 0644)
+		data := parseHeyFile(outFile)
+		if data["requests_per_sec"] == "" {
+			fmt.Printf("⚠️  %s: no Requests/sec in hey's summary; if its format changed, use --hey-output csv\n", outFile)
+		}
+		return outFile, data, nil
+	}
+
+	// hey prints either the summary or the raw rows, so the summary is
+	// rebuilt from the raw rows with exact percentiles.
+	rawFile := filepath.Join(outDir, rawFileName(t.Slug, i))
+	if err := os.WriteFile(rawFile, outBytes, 0644); err != nil {
+		return "", nil, err
+	}
+	if !*rawCapture {
+		defer os.Remove(rawFile)
+	}
+	var sum runSummary
+	if *streamRaw {
+		sum, err = writeStreamedSummary(outFile, rawFile)
+	} else {
+		var samples []sample
+		var span time.Duration
+		if samples, span, err = readRawLatencies(rawFile); err == nil {
+			sum, err = writeHeySummary(outFile, samples, span)
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	// hey's rows are only the requests that got a response; the rest of
+	// the ones it sent failed in transport or timed out
+	if missing := heySent(n, concurrencyFor(t)) - sum.requests(); missing > 0 {
+		sum.errs[heyLostError] += missing
+		if sum.total == 0 {
+			sum.total = elapsed
+		}
+		if err := sum.write(outFile); err != nil {
+			return "", nil, err
+		}
+	}
+	if sum.count == 0 && len(sum.errs) == 0 {
+		return "", nil, fmt.Errorf("hey -o csv reported no requests")
 	}
+	return outFile, sum.metrics(outFile), nil
+}
+
+// heyLostError is the error the requests hey -o csv leaves out are
+// counted under.
+const heyLostError = "no response (transport error or timeout)"
 
-	os.WriteFile(outFile, outBytes, 0644)
-	return outFile, nil
+// heySent is how many requests hey -n n -c c sends: each of its c workers
+// sends n/c.
+func heySent(n, c int) int {
+	if n < c {
+		c = n
+	}
+	if c <= 0 {
+		return 0
+	}
+	return n / c * c
 }
 
-func extractFloat(re *regexp.Regexp, line string) float64 {
+func extractFloat(re *regexp.Regexp, line string) (float64, bool) {
 	match := re.FindStringSubmatch(line)
 	if len(match) >= 2 {
-		val, _ := strconv.ParseFloat(match[1], 64)
-		return val
+		val, err := strconv.ParseFloat(match[1], 64)
+		return val, err == nil
 	}
-	return 0
+	return 0, false
 }
 
 func parseHeyFile(file string) map[string]string {
 	result := make(map[string]string)
 	result["file"] = filepath.Base(file)
 
-	f, _ := os.Open(file)
+	f, err := openOutput(file)
+	if err != nil {
+		return result
+	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	percentiles := map[string]*regexp.Regexp{
-		"p50": regexp.MustCompile(`50% in ([\d.]+)`),
-		"p75": regexp.MustCompile(`75% in ([\d.]+)`),
-		"p90": regexp.MustCompile(`90% in ([\d.]+)`),
-		"p95": regexp.MustCompile(`95% in ([\d.]+)`),
-		"p99": regexp.MustCompile(`99% in ([\d.]+)`),
+	percentiles := map[string]*regexp.Regexp{}
+	for _, p := range cfg.Percentiles {
+		percentiles[percentileKey(p)] = regexp.MustCompile(`(?:^|\s)` + regexp.QuoteMeta(strconv.FormatFloat(p, 'f', -1, 64)) + `% in ([\d.]+)`)
 	}
 	fields := map[string]*regexp.Regexp{
 		"total":            regexp.MustCompile(`Total:\s+([\d.]+)`),
@@ -177,14 +485,19 @@ func parseHeyFile(file string) map[string]string {
 	for scanner.Scan() {
 		line := scanner.Text()
 
+		// a matched 0 is a real measurement; an unmatched field stays
+		// absent and is written as an empty cell
 		for k, re := range percentiles {
-			if val := extractFloat(re, line); val != 0 {
-				result[k] = fmt.Sprintf("%.4f", val)
+			if val, ok := extractFloat(re, line); ok {
+				result[k] = fmt.Sprintf("%.4f", val*1000)
 			}
 		}
 
 		for k, re := range fields {
-			if val := extractFloat(re, line); val != 0 {
+			if val, ok := extractFloat(re, line); ok {
+				if timeColumn(k) {
+					val *= 1000 // hey reports seconds
+				}
 				result[k] = fmt.Sprintf("%.4f", val)
 			}
 		}
@@ -193,6 +506,44 @@ func parseHeyFile(file string) map[string]string {
 	return result
 }
 
+// resultHeaders lists the result columns in output order.
+func resultHeaders() []string {
+	headers := []string{"schema", "run_id", "started", "file", "target", "route", "concurrency", "requests", "method", "timeout", "total", "average", "fastest", "slowest", "requests_per_sec", "size_request"}
+	for _, p := range cfg.Percentiles {
+		headers = append(headers, percentileKey(p))
+	}
+	headers = append(headers, "spread", "error_rate", "throttled")
+	if cfg.SLO != "" {
+		headers = append(headers, "slo_compliance")
+	}
+	headers = append(headers, "raw_file")
+	if cfg.Fingerprint != "" {
+		headers = append(headers, "version")
+	}
+	if cacheBusting != nil {
+		headers = append(headers, "cache")
+	}
+	if *engine == "native" {
+		headers = append(headers, connColumns...)
+	}
+	if *dualStack {
+		headers = append(headers, "family")
+	}
+	if *floorProbes > 0 {
+		headers = append(headers, "network_floor")
+	}
+	if clientMonitored() {
+		headers = append(headers, clientColumns...)
+	}
+	if len(cfg.Chaos) > 0 {
+		headers = append(headers, "chaos")
+	}
+	for _, k := range labelKeys() {
+		headers = append(headers, "label_"+k)
+	}
+	return headers
+}
+
 func writeCSV(data []map[string]string, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
@@ -200,10 +551,10 @@ func writeCSV(data []map[string]string, filename string) error {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
+	writer := newCSVWriter(file)
 	defer writer.Flush()
 
-	headers := []string{"file", "total", "average", "fastest", "slowest", "requests_per_sec", "size_request", "p50", "p75", "p90", "p95", "p99"}
+	headers := resultHeaders()
 	writer.Write(headers)
 
 	for _, row := range data {
@@ -217,43 +568,905 @@ func writeCSV(data []map[string]string, filename string) error {
 	return nil
 }
 
+func loadTargets() ([]Target, error) {
+	var targets []Target
+	var source string
+	var err error
+	switch {
+	case *harPath != "":
+		source = *harPath
+		targets, err = loadHAR(*harPath)
+	case *postmanPath != "":
+		source = *postmanPath
+		targets, err = loadPostman(*postmanPath, *postmanEnv)
+	case *openAPIPath != "":
+		source = *openAPIPath
+		bases, berr := parseBaseURLs(*baseURLs)
+		if berr != nil {
+			return nil, berr
+		}
+		targets, err = loadOpenAPI(*openAPIPath, bases)
+	case *scenarioPath != "":
+		source = *scenarioPath
+		bases, berr := parseBaseURLs(*baseURLs)
+		if berr != nil {
+			return nil, berr
+		}
+		targets, err = loadScenario(*scenarioPath, bases)
+	case *mixSpec != "":
+		source = "--mix"
+		bases, berr := parseBaseURLs(*baseURLs)
+		if berr != nil {
+			return nil, berr
+		}
+		targets, err = parseMix(*mixSpec, bases)
+	case len(curlCmds) > 0:
+		source = "--curl"
+		for _, c := range curlCmds {
+			t, cerr := parseCurl(c)
+			if cerr != nil {
+				return nil, cerr
+			}
+			targets = append(targets, t)
+		}
+	default:
+		return defaultTargets(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("✅ Loaded %d requests from %s\n", len(targets), source)
+	return targets, nil
+}
+
 func main() {
-	os.RemoveAll(outDir)
-	os.MkdirAll(outDir, 0755)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "agent":
+			runAgent(os.Args[2:])
+			return
+		case "container":
+			os.Args = append([]string{os.Args[0]}, containerArgs(os.Args[2:])...)
+			fmt.Printf("→ Container mode: %s\n", strings.Join(redactArgs(os.Args[1:]), " "))
+		case "init":
+			runInit(os.Args[2:])
+			return
+		case "schedule":
+			runScheduler(os.Args[2:])
+			return
+		case "stages":
+			runStages(os.Args[2:])
+			return
+		case "bisect":
+			runBisect(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "prune":
+			runPrune(os.Args[2:])
+			return
+		case "trend":
+			runTrend(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "agents-infra":
+			runAgentsInfra(os.Args[2:])
+			return
+		case "error-budget":
+			runErrorBudget(os.Args[2:])
+			return
+		case "k8s-manifest":
+			runK8sManifest(os.Args[2:])
+			return
+		case "rerun":
+			runRerun(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
+	if err := applyEnvFlags(flag.CommandLine); err != nil {
+		fmt.Println("❌ Invalid environment:", err)
+		os.Exit(1)
+	}
+	flag.Parse()
+	if suiteSeed = *seed; suiteSeed == 0 {
+		suiteSeed = time.Now().UnixNano()
+	}
+	if *engine != "hey" && *engine != "native" {
+		fmt.Printf("❌ Unknown engine %q, want hey or native\n", *engine)
+		os.Exit(1)
+	}
+
+	var err error
+	cfg, err = loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	used, unknown := envSettings(flag.CommandLine)
+	if len(used) > 0 {
+		fmt.Printf("→ Settings from the environment: %s\n", strings.Join(used, ", "))
+	}
+	for _, name := range unknown {
+		fmt.Printf("⚠️  Ignoring %s, which matches no config key or flag\n", name)
+	}
+	if *targetEnv != "" {
+		if err := applyEnvironment(&cfg, *targetEnv); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Environment %s: %s\n", *targetEnv, redact(strings.Join(cfg.URLs, ", ")))
+	}
+	if *profileName != "" {
+		if err := applyProfile(&cfg, *profileName); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Profile %s: %d runs of %d requests at c=%d after %d warm-up\n", *profileName, cfg.Repeat, cfg.Requests, cfg.Concurrency, cfg.Warmup)
+	}
+	if *warmup > 0 {
+		cfg.Warmup = *warmup
+	}
+	if *percentileList != "" {
+		if cfg.Percentiles, err = parsePercentiles(*percentileList); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	if *engine == "hey" && !*rawCapture && *heyOutput == "text" {
+		for _, p := range cfg.Percentiles {
+			if !isHeyPercentile(p) {
+				fmt.Printf("⚠️  %s isn't in hey's summary; use --hey-output csv or --engine native to compute it\n", percentileKey(p))
+			}
+		}
+	}
+	if *sweepList != "" {
+		if cfg.Sweep, err = parseIntList(*sweepList); err != nil {
+			fmt.Println("❌ Invalid --sweep:", err)
+			os.Exit(1)
+		}
+	}
+	if *sloSpec != "" {
+		cfg.SLO = *sloSpec
+	}
+	if *cooldown != "" {
+		cfg.Cooldown = *cooldown
+	}
+	if suiteCooldown, err = parseCooldown(cfg.Cooldown); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if *ciWidth > 0 {
+		cfg.CIWidth = *ciWidth
+	}
+	if *ciMetrics != "" {
+		cfg.CIMetrics = splitList(*ciMetrics)
+	}
+	if *minRepeat > 0 {
+		cfg.MinRepeat = *minRepeat
+	}
+	if cfg.CIWidth > 0 && cfg.MinRepeat < 2 {
+		cfg.MinRepeat = 2
+	}
+	if *storePath != "" {
+		cfg.Store = *storePath
+	}
+	if *csvDelimiter != "" {
+		cfg.CSV.Delimiter = *csvDelimiter
+	}
+	if *csvDecimal != "" {
+		cfg.CSV.Decimal = *csvDecimal
+	}
+	if *csvQuote != "" {
+		cfg.CSV.Quote = *csvQuote
+	}
+	if *heyOutput != "csv" && *heyOutput != "text" {
+		fmt.Printf("❌ Unknown --hey-output %q, want csv or text\n", *heyOutput)
+		os.Exit(1)
+	}
+	if *parseMode != "strict" && *parseMode != "lenient" {
+		fmt.Printf("❌ Unknown --parse mode %q, want strict or lenient\n", *parseMode)
+		os.Exit(1)
+	}
+	if err := cfg.CSV.validate(); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if *units != "" {
+		cfg.Units = *units
+	}
+	if cfg.Units != "" {
+		if err := validateUnits(cfg.Units); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		displayUnit = cfg.Units
+	}
+	if *fingerprint != "" {
+		cfg.Fingerprint = *fingerprint
+	}
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	flagLabels, err := parseLabels(labelList)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	for k, v := range flagLabels {
+		labels[k] = v
+	}
+	if *rate > 0 {
+		cfg.Rate = *rate
+	}
+	if *agentList != "" {
+		if *engine == "hey" && flagGiven("engine") {
+			fmt.Println("❌ --agents runs the native engine on every agent; it can't be combined with --engine hey")
+			os.Exit(1)
+		}
+		if agentToken = os.Getenv(*agentTokenEnv); agentToken == "" {
+			fmt.Printf("❌ %s isn't set; agents only take jobs carrying their token\n", *agentTokenEnv)
+			os.Exit(1)
+		}
+		secretValues = append(secretValues, agentToken)
+		agents = parseAgents(*agentList)
+		if err := checkAgents(); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		*engine = "native"
+		fmt.Printf("→ Distributing every run across %d agents (native engine)\n", len(agents))
+	}
+	if *heyExec != "" {
+		switch {
+		case *engine != "hey" || *sshList != "":
+			fmt.Println("❌ --hey-exec runs hey in another container; it needs --engine hey and can't run with --ssh")
+			os.Exit(1)
+		case *heyPath != "":
+			heyBin = *heyPath // a path inside that container
+		}
+		if _, _, err := heyCommand(nil); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Running hey through %s\n", *heyExec)
+	} else if *engine == "hey" {
+		// Over SSH a local hey is only needed for hosts that lack one.
+		path, err := findHey()
+		if err != nil && *sshList == "" {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		if err == nil {
+			if err := checkHeyVersion(path); err != nil {
+				fmt.Println("❌", err)
+				os.Exit(1)
+			}
+			heyBin = path
+		}
+	}
+	if *sshList != "" {
+		if len(agents) > 0 || *engine == "native" {
+			fmt.Println("❌ --ssh runs hey remotely; it can't be combined with --agents or --engine native")
+			os.Exit(1)
+		}
+		sshHosts = parseOrigins(*sshList)
+		if err := prepareSSHHosts(); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Running hey on %d hosts over SSH\n", len(sshHosts))
+	}
+	if cfg.Rate > 0 && *engine != "native" {
+		fmt.Println("❌ --rate needs --engine native; hey can only run a closed loop")
+		os.Exit(1)
+	}
+	if cfg.CIWidth > 0 {
+		// after --agents settles the engine, which decides the columns
+		if err := validateCIMetrics(cfg.CIMetrics); err != nil {
+			fmt.Println("❌ Invalid --ci-metrics:", err)
+			os.Exit(1)
+		}
+	}
+	slo, err := parseSLO(cfg.SLO)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	thresholds, err := parseThresholds(append(append([]string{}, cfg.Thresholds...), thresholdList...))
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if slo != nil {
+		thresholds = append(thresholds, Threshold{Metric: "slo_compliance", Op: ">=", Value: slo.Percent})
+	}
+	if err := loadReportTemplates(reportTemplates); err != nil {
+		fmt.Println("❌ Invalid --report-template:", err)
+		os.Exit(1)
+	}
+	switch *xAxis {
+	case "run", "time":
+		chartXAxis = *xAxis
+	default:
+		fmt.Printf("❌ Invalid --x-axis %q, want run or time\n", *xAxis)
+		os.Exit(1)
+	}
+	chartFilter = splitList(*chartList)
+	seriesFilter = splitList(*targetList)
+	if err := validateCharts(cfg.Charts); err != nil {
+		fmt.Println("❌ Invalid charts:", err)
+		os.Exit(1)
+	}
+
+	targets, err := loadTargets()
+	if err != nil {
+		fmt.Println("❌ Error loading targets:", err)
+		os.Exit(1)
+	}
+	if *onlyTargets != "" {
+		if targets, err = onlyNamed(targets, splitList(*onlyTargets)); err != nil {
+			fmt.Println("❌ Invalid --only:", err)
+			os.Exit(1)
+		}
+	}
+	registerSecrets(targets)
+	if *abMode {
+		switch {
+		case *engine != "native":
+			fmt.Println("❌ --ab interleaves requests, which needs --engine native")
+			os.Exit(1)
+		case len(targets) != 2:
+			fmt.Printf("❌ --ab compares exactly two targets, got %d\n", len(targets))
+			os.Exit(1)
+		case targets[0].Step > 0:
+			fmt.Println("❌ --ab can't run scenarios")
+			os.Exit(1)
+		}
+	}
+	if err := applyOverrides(targets); err != nil {
+		fmt.Println("❌ Invalid overrides:", err)
+		os.Exit(1)
+	}
+	var contract *Contract
+	if *contractPath != "" {
+		if contract, err = loadContract(*contractPath); err != nil {
+			fmt.Println("❌ Invalid contract:", err)
+			os.Exit(1)
+		}
+		if *candidate != "" && !hasTargetNamed(targets, *candidate) {
+			fmt.Printf("❌ --candidate %q names no target\n", *candidate)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Checking the results against %s\n", contract)
+	}
+	var cutoverChecks []Threshold
+	if cfg.Cutover != nil {
+		if err := resolveCutover(cfg.Cutover, targets); err != nil {
+			fmt.Println("❌ Invalid cutover:", err)
+			os.Exit(1)
+		}
+		if cutoverChecks, err = cutoverCriteria(cfg.Cutover, slo); err != nil {
+			fmt.Println("❌ Invalid cutover criteria:", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Autoscale != nil {
+		switch err := validateAutoscale(cfg.Autoscale); {
+		case err != nil:
+			fmt.Println("❌ Invalid autoscale:", err)
+			os.Exit(1)
+		case *engine != "native" || len(agents) > 0:
+			fmt.Println("❌ autoscale needs --engine native, on this machine")
+			os.Exit(1)
+		case targets[0].Step > 0 || cfg.Rate > 0:
+			fmt.Println("❌ autoscale sets its own load; it can't run scenarios or --rate")
+			os.Exit(1)
+		}
+	}
+	if cfg.ColdStart != nil {
+		switch err := validateColdStart(cfg.ColdStart); {
+		case err != nil:
+			fmt.Println("❌ Invalid cold_start:", err)
+			os.Exit(1)
+		case *engine != "native" || len(agents) > 0:
+			fmt.Println("❌ cold_start needs --engine native, on this machine")
+			os.Exit(1)
+		case targets[0].Step > 0 || cfg.Rate > 0 || cfg.Autoscale != nil:
+			fmt.Println("❌ cold_start sets its own load; it can't run scenarios, --rate or autoscale")
+			os.Exit(1)
+		}
+	}
+	if cfg.Warmup > 0 && (cfg.Autoscale != nil || cfg.ColdStart != nil) {
+		fmt.Println("❌ warm-up runs would spoil autoscale and cold_start, which set their own load")
+		os.Exit(1)
+	}
+	if err := validateChaos(cfg.Chaos); err != nil {
+		fmt.Println("❌ Invalid chaos:", err)
+		os.Exit(1)
+	}
+	if err := validateExporters(cfg.Exporters, exporterDirs(cfg)); err != nil {
+		fmt.Println("❌ Invalid exporters:", err)
+		os.Exit(1)
+	}
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		fmt.Println("❌ Invalid webhooks:", err)
+		os.Exit(1)
+	}
+	if cfg.MQTT != nil {
+		if err := validateMQTT(cfg.MQTT); err != nil {
+			fmt.Println("❌ Invalid mqtt:", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Capacity != nil {
+		if err := validateCapacity(cfg.Capacity); err != nil {
+			fmt.Println("❌ Invalid capacity:", err)
+			os.Exit(1)
+		}
+	}
+	if *cacheBust != "" || *cacheCompare || *cacheBustScope != "" {
+		mode := *cacheBust
+		if mode == "" && *cacheCompare {
+			mode = "both"
+		}
+		switch cacheBusting, err = parseCacheBust(mode, *cacheBustScope, *engine); {
+		case err != nil:
+			fmt.Println("❌ Invalid --cache-bust:", err)
+			os.Exit(1)
+		case *cacheCompare && *abMode:
+			fmt.Println("❌ --cache-compare can't run with --ab, which compares exactly two targets")
+			os.Exit(1)
+		case *cacheCompare:
+			targets = cacheVariants(targets, cacheBusting)
+		default:
+			for i := range targets {
+				targets[i].Bust = cacheBusting
+			}
+		}
+	}
+	if *networkSpec != "" {
+		if *engine != "native" {
+			fmt.Println("❌ --network needs --engine native; hey can't shape its connections")
+			os.Exit(1)
+		}
+		if shape, err = parseNetworkShape(*networkSpec); err != nil {
+			fmt.Println("❌ Invalid --network:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("→ Emulating a client network of %s\n", shape)
+		addReportSection("Emulated client network", fmt.Sprintf("Every connection was shaped to %s (`--network %s`), emulating a constrained client; the latencies include it.", shape, *networkSpec))
+	}
+	if *dualStack {
+		switch {
+		case *engine != "native":
+			fmt.Println("❌ --dual-stack needs --engine native; hey can't force an address family")
+			os.Exit(1)
+		case *abMode || *cacheCompare:
+			fmt.Println("❌ --dual-stack can't run with --ab or --cache-compare")
+			os.Exit(1)
+		}
+		targets = dualStackVariants(targets)
+	}
+	for _, t := range targets {
+		if t.Cooldown != nil {
+			targetCooldowns[t.label()] = t.Cooldown.Spec
+		}
+	}
+	if len(cfg.Sweep) > 0 {
+		for i := range targets {
+			if targets[i].Concurrency > 0 {
+				fmt.Printf("⚠️  --sweep sets the concurrency; ignoring the override for %s\n", targets[i].label())
+				targets[i].Concurrency = 0
+			}
+		}
+	}
+	assignSlugs(targets)
+	if !*noPreflight && len(agents) == 0 && len(sshHosts) == 0 {
+		levels := cfg.Sweep
+		if len(levels) == 0 {
+			levels = []int{cfg.Concurrency}
+		}
+		if err := preflight(peakConcurrency(targets, levels)); err != nil {
+			fmt.Println("❌ Preflight failed:", err)
+			os.Exit(1)
+		}
+	}
+	env := captureEnvironment(targets[0].URL)
+	addReportSection("Environment", env.markdown())
+	if cfg.Fingerprint != "" {
+		if err := fingerprintTargets(targets, cfg.Fingerprint); err != nil {
+			fmt.Println("❌ Invalid --fingerprint:", err)
+			os.Exit(1)
+		}
+	}
+	if *floorProbes != 0 {
+		switch {
+		case *floorProbes < 0:
+			fmt.Println("❌ --network-floor wants a positive number of probes")
+			os.Exit(1)
+		case len(agents) > 0 || len(sshHosts) > 0:
+			fmt.Println("❌ --network-floor probes from this machine, so it can't run with --agents or --ssh")
+			os.Exit(1)
+		}
+		if err := probeNetworkFloors(targets, *floorProbes); err != nil {
+			fmt.Println("❌ Invalid target URL:", err)
+			os.Exit(1)
+		}
+	}
 
 	var results []map[string]string
+	seenRuns := map[string]bool{}
+	started := time.Now()
+	if err := createSuiteDir(*resultsDir, started); err != nil {
+		fmt.Println("❌ Error creating the suite directory:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("→ Writing results to %s\n", suiteDir)
+	fmt.Printf("→ Seed %d; --seed %d makes the same random choices again\n", suiteSeed, suiteSeed)
+	if err := writeManifest(suiteFile("manifest.json"), env, targets); err != nil {
+		fmt.Println("⚠️  Couldn't write the suite manifest:", err)
+	}
+	var limits budget
+	if *maxDuration > 0 {
+		limits.deadline = started.Add(*maxDuration)
+		var cancel context.CancelFunc
+		suiteCtx, cancel = context.WithDeadline(context.Background(), limits.deadline)
+		defer cancel()
+	}
+	stopOnSignal()
+	if *maxRequests != "" {
+		if limits.maxRequests, err = parseCount(*maxRequests); err != nil {
+			fmt.Println("❌ Invalid --max-requests:", err)
+			os.Exit(1)
+		}
+	}
 
-	for _, url := range urls {
-		for i := 1; i <= repeat; i++ {
-			fmt.Printf("→ Running test %d for %s\n", i, url)
-			file, err := runHey(url, i)
-			if err != nil {
-				fmt.Printf("Error running hey: %v\n", err)
-				continue
+	var abort abortPolicy
+	if *abortAfter != "" {
+		if abort.maxFailures, err = parseFailures(*abortAfter); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	if *abortErrorRate != "" {
+		if abort.maxErrorRate, err = parseRate(*abortErrorRate); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	// from here on the suite ends through finishSuite, so exporters,
+	// webhooks and the broker always hear how it ended
+	startExporters(started, env, targets)
+	startWebhooks(targets)
+	startMQTT(started, targets)
+
+	levels := cfg.Sweep
+	if len(levels) == 0 {
+		levels = []int{cfg.Concurrency}
+	}
+suite:
+	for _, base := range planJobs(targets, *engine) {
+		health := jobHealth{}
+	sweep:
+		for _, c := range levels {
+			j := base.withConcurrency(c)
+			if len(levels) > 1 {
+				j = base.atConcurrency(c)
+			}
+			for w := 1; w <= cfg.Warmup; w++ {
+				if limits.exceeded() != "" {
+					break
+				}
+				fmt.Printf("→ Warming up %s (%d of %d)\n", j.label(), w, cfg.Warmup)
+				// run 0's files are overwritten by each warm-up and its rows dropped
+				rows, err := runJob(j, targets, *engine, 0)
+				if err != nil && suiteCtx.Err() == nil {
+					fmt.Printf("⚠️  Warm-up of %s failed: %v\n", j.label(), err)
+				}
+				limits.sent += requestsSent(rows)
+				coolDown(cooldownFor(j.targets[0]).after(0))
+			}
+			conv := newConvergence()
+			for i := 1; i <= cfg.Repeat; i++ {
+				if truncated = limits.exceeded(); truncated != "" {
+					break suite
+				}
+				if len(levels) > 1 {
+					fmt.Printf("→ Running test %d for %s at c=%d\n", i, j.label(), c)
+				} else {
+					fmt.Printf("→ Running test %d for %s\n", i, j.label())
+				}
+				runStarted := time.Now()
+				monitor := startClientMonitor()
+				chaos := startChaos(base.name, j.label(), i)
+				rows, err := runJob(j, targets, *engine, i)
+				chaosFired := chaos()
+				var usage *clientUsage
+				if monitor != nil {
+					u := monitor.stop()
+					usage = &u
+				}
+				if err != nil && suiteCtx.Err() != nil {
+					truncated = limits.exceeded()
+					fmt.Printf("⚠️  Run %d of %s cut short and discarded\n", i, j.label())
+					break suite
+				}
+				if err != nil {
+					fmt.Printf("Error running %s: %s\n", *engine, redact(err.Error()))
+				}
+				aborted := ""
+				if abort != (abortPolicy{}) {
+					if aborted = health.check(abort, rows, err); aborted != "" {
+						fmt.Printf("❌ Aborting %s: %s\n", base.label(), aborted)
+						abortedTargets[base.label()] = aborted
+					}
+				}
+				if err != nil {
+					if aborted != "" {
+						break sweep
+					}
+					continue
+				}
+				limits.sent += requestsSent(rows)
+				if usage != nil {
+					recordClientSaturation(j.label(), i, *usage)
+				}
+				if aborted == "" {
+					coolDown(cooldownFor(j.targets[0]).after(i))
+				}
+				if slo != nil {
+					for _, row := range rows {
+						addSLOCompliance(row, slo)
+					}
+				}
+				for _, row := range rows {
+					row["started"] = runStarted.UTC().Format(time.RFC3339Nano)
+					addErrorRate(row)
+					addThrottled(row)
+					row["schema"] = strconv.Itoa(schemaVersion)
+					applyLabels(row)
+					if v, ok := versions[row["target"]]; ok {
+						row["version"] = v
+					}
+					addNetworkFloor(row)
+					if chaosFired != "" {
+						row["chaos"] = chaosFired
+					}
+					if usage != nil {
+						usage.addTo(row)
+					}
+					redactRow(row)
+				}
+				rows, dropped := dedupeRuns(rows, seenRuns)
+				if dropped > 0 {
+					fmt.Printf("⚠️  Skipped %d duplicate runs\n", dropped)
+				}
+				results = append(results, rows...)
+				exportRows(rows)
+				notifyRun(j.label(), i, c, rows)
+				publishRun(j.label(), i, c, rows, results)
+				if n := len(rows); n > 0 && throttledRun(rows[n-1]) {
+					share, _ := rowFloat(rows[n-1], "throttled")
+					fmt.Printf("⚠️  Run %d of %s was rate limited: %.0f%% of responses were 429s\n", i, j.label(), share)
+					if *retryAfter && aborted == "" {
+						backOff(j.targets[0])
+					}
+				}
+				if aborted != "" {
+					break sweep // the run that tripped the policy is kept
+				}
+				if cfg.CIWidth > 0 && len(rows) > 0 {
+					conv.add(targetRows(rows))
+					if conv.converged() {
+						break
+					}
+				}
+			}
+			if cfg.CIWidth > 0 {
+				res := adaptiveResult{label: j.label(), runs: conv.runs, converged: conv.converged(), summary: conv.summary()}
+				if len(levels) > 1 {
+					res.label += fmt.Sprintf(" at c=%d", c)
+				}
+				if res.converged {
+					fmt.Printf("✅ %s converged after %d runs (%s)\n", res.label, res.runs, res.summary)
+				} else {
+					fmt.Printf("⚠️  %s didn't converge within %d runs (%s)\n", res.label, res.runs, res.summary)
+				}
+				adaptiveResults = append(adaptiveResults, res)
 			}
-			time.Sleep(1 * time.Second) // optional sleep between runs
-			data := parseHeyFile(file)
-			data["url"] = url
-			results = append(results, data)
 		}
 	}
 
-	err := writeCSV(results, "hey_results.csv")
+	if truncated != "" {
+		fmt.Printf("⚠️  Suite truncated: %s; writing the %d result rows so far\n", truncated, len(results))
+		addReportSection("Suite truncated", fmt.Sprintf("⚠️ The suite stopped early (%s); only %d result rows were collected.", truncated, len(results)))
+	}
+
+	err = writeCSV(results, suiteFile("hey_results.csv"))
 	if err != nil {
 		fmt.Println("❌ Error writing CSV:", err)
 	} else {
-		fmt.Println("✅ CSV written to hey_results.csv")
+		fmt.Println("✅ CSV written to", suiteFile("hey_results.csv"))
+	}
+	if err := writeSuiteSummary(results, suiteFile("hey_summary.csv")); err != nil {
+		fmt.Println("❌ Error writing suite summary:", err)
 	}
 
-	csvResults, err := readCSV("hey_results.csv")
+	csvResults, err := readCSV(suiteFile("hey_results.csv"))
 	if err != nil {
-		fmt.Println("Failed to read CSV:", err)
-		return
+		fmt.Println("❌ Failed to read CSV:", err)
+		finishSuite(results, true)
 	}
 
-	generateLineChart(csvResults, "rps", "Requests Per Second", "chart_rps.html")
-	generateLineChart(csvResults, "p95", "95th Percentile Latency", "chart_p95.html")
-	generateLineChart(csvResults, "average", "Average Latency", "chart_avg.html")
-	generateLineChart(csvResults, "total", "Total Time", "chart_total.html")
+	chartJobs := []func(){
+		func() { generateRouteCharts(csvResults) },
+		func() { generateEnvelopeChart(csvResults, suiteFile("chart_envelope.html")) },
+	}
+	builtin := []ChartSpec{
+		lineChart("rps", "Requests Per Second", "chart_rps.html"),
+		lineChart("average", "Average Latency", "chart_avg.html"),
+		lineChart("total", "Total Time", "chart_total.html"),
+	}
+	if mixedConcurrency(csvResults) {
+		builtin = append(builtin, lineChart("rps_per_worker", "Requests Per Second per Worker", "chart_rps_per_worker.html"))
+	}
+	errorMarks := []opts.MarkLineNameYAxisItem{}
+	for _, t := range thresholds {
+		if t.Metric == "error_rate" {
+			errorMarks = append(errorMarks, opts.MarkLineNameYAxisItem{Name: t.String(), YAxis: t.Value})
+		}
+	}
+	if abort.maxErrorRate > 0 {
+		errorMarks = append(errorMarks, opts.MarkLineNameYAxisItem{Name: "abort", YAxis: abort.maxErrorRate})
+	}
+	builtin = append(builtin, lineChart("error_rate", "Error Rate", "chart_errors.html", errorMarks...))
+	if hasSizes(results) {
+		builtin = append(builtin,
+			lineChart("size_request", "Response Size", "chart_size.html"),
+			lineChart("bandwidth", "Bandwidth", "chart_bandwidth.html"))
+	}
+	for _, p := range cfg.Percentiles {
+		if p >= 95 {
+			key := percentileKey(p)
+			builtin = append(builtin, lineChart(key, fmt.Sprintf("%vth Percentile Latency", p), "chart_"+strings.ReplaceAll(key, ".", "_")+".html"))
+		}
+	}
+	if slo != nil {
+		builtin = append(builtin, lineChart("slo_compliance", "SLO Compliance ("+slo.String()+")", "chart_slo.html",
+			opts.MarkLineNameYAxisItem{Name: "objective", YAxis: slo.Percent}))
+	}
+	for _, spec := range withConfigCharts(builtin, cfg.Charts) {
+		if !chartWanted(spec.Metric, chartName(spec.File)) {
+			continue
+		}
+		chartJobs = append(chartJobs, func() { generateChart(csvResults, spec, suiteFile(spec.File)) })
+	}
+	parallel(len(chartJobs), func(i int) { chartJobs[i]() })
+
+	analyzeJitter(results, suiteFile("chart_jitter.html"))
+	analyzeWindows(results, suiteFile("chart_windows.html"))
+	analyzeComparison(results)
+	analyzeTimeOfDay(results, suiteFile("chart_timeofday.html"))
+	analyzeServerTime(results)
+	if *cacheCompare {
+		analyzeCache(results)
+	}
+	analyzeThrottling(results)
+	if *dualStack {
+		analyzeDualStack(results, suiteFile("chart_dualstack.html"))
+	}
+	analyzeConnections(results, suiteFile("chart_connections.html"))
+	generateMeansChart(results, suiteFile("chart_means.html"))
+	analyzeEndpoints(results, targets, suiteFile("hey_endpoints.csv"), suiteFile("chart_endpoints.html"))
+	analyzePayload(results)
+	analyzeAnomalies(results)
+	if *abMode {
+		analyzeAB(results, targets[0], targets[1])
+	}
+	reportAgents()
+	reportAdaptive()
+	reportAborts()
+	reportClientSaturation()
+	analyzeChaos(results)
+	analyzeRegions(suiteFile("chart_regions.html"))
+	if len(levels) > 1 {
+		analyzeLittlesLaw(results, suiteFile("chart_throughput.html"))
+		analyzeKnees(results)
+		generateConcurrencyHeatmaps(results, suiteFile("chart_heatmap.html"))
+	}
+	if cfg.Capacity != nil {
+		analyzeCapacity(results, cfg.Capacity)
+	}
+	if cfg.Autoscale != nil {
+		analyzeAutoscale(suiteFile("chart_autoscale.html"))
+	}
+	if cfg.ColdStart != nil {
+		analyzeColdStart(suiteFile("chart_coldstart.html"))
+	}
+	contractKept := contract == nil || evaluateContract(contract, targets, results, *candidate)
+	reportData := newReportData(started, results)
+	if err := writeReport(suiteFile("report.md"), reportData); err != nil {
+		fmt.Println("❌ Error writing report:", err)
+	}
+	if err := writeDashboard(suiteFile("report.html"), reportData); err != nil {
+		fmt.Println("❌ Error writing combined report:", err)
+	}
+	cutoverGo := true
+	if cfg.Cutover != nil {
+		if cutoverGo, err = writeCutoverReport(suiteFile("cutover.md"), cfg.Cutover, cutoverChecks, slo, results); err != nil {
+			fmt.Println("❌ Error writing cutover report:", err)
+		}
+	}
+	if err := writeJSON(suiteFile("hey_results.json"), suiteID, started, env, results); err != nil {
+		fmt.Println("❌ Error writing JSON:", err)
+	}
+	if err := writeBenchFormat(suiteFile("hey_bench.txt"), results); err != nil {
+		fmt.Println("❌ Error writing benchmark output:", err)
+	}
+	if *xlsxPath != "" {
+		if err := writeXLSX(*xlsxPath, results); err != nil {
+			fmt.Println("❌ Error writing workbook:", err)
+		}
+	}
+	if cfg.Store != "" {
+		if err := appendStore(cfg.Store, suiteID, started, tagList, results); err != nil {
+			fmt.Println("❌ Error writing results store:", err)
+		}
+	}
+	if *archivePath != "" {
+		if err := archiveSuite(*archivePath, started, env); err != nil {
+			fmt.Println("❌ Error archiving the suite:", err)
+		}
+	}
+
+	failed := len(thresholds) > 0 && !evaluateThresholds(results, thresholds)
+	if len(abortedTargets) > 0 {
+		fmt.Printf("❌ %d targets aborted\n", len(abortedTargets))
+		failed = true
+	}
+	if !cutoverGo {
+		failed = true
+	}
+	if !contractKept {
+		fmt.Printf("❌ %s is broken\n", contract)
+		failed = true
+	}
+	finishSuite(results, failed)
+}
+
+// finishSuite tells the exporters, webhooks and broker the suite is done
+// and whether it passed, then exits 1 if it didn't.
+func finishSuite(results []map[string]string, failed bool) {
+	exported := finishExporters(len(results), map[string]string{
+		"csv": suiteFile("hey_results.csv"), "summary": suiteFile("hey_summary.csv"), "json": suiteFile("hey_results.json"),
+		"report": suiteFile("report.md"), "dashboard": suiteFile("report.html"), "manifest": suiteFile("manifest.json"),
+	}, !failed)
+	if !exported {
+		failed = true
+	}
+	finishWebhooks(results, !failed)
+	finishMQTT(results, !failed)
+	if failed {
+		os.Exit(1)
+	}
+}
 
+// flagGiven reports whether the suite's flag name was set, on the command
+// line or through its PERTOOLS_ variable, rather than left at its default.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	_, env := os.LookupEnv(envName(name))
+	return given || env
 }
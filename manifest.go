@@ -0,0 +1,429 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// suiteSeed seeds the suite's random choices, the native engine's picks
+// from a mix and jittered cool-downs, so a rerun with the same --seed
+// makes the same ones. Each call to nextStream takes the next stream of
+// its numbers; the suite draws them in the same order every time.
+var (
+	suiteSeed   int64
+	seedStreams int64
+)
+
+func nextStream() int64 {
+	return suiteSeed + atomic.AddInt64(&seedStreams, 1)
+}
+
+func nextRand() *rand.Rand {
+	return rand.New(rand.NewSource(nextStream()))
+}
+
+// nextUniform takes the next stream as a function from an index to a
+// number in [0, 1), so concurrent workers can draw the k-th number of a
+// sequence regardless of the order they get to it.
+func nextUniform() func(k int64) float64 {
+	return uniform(nextStream())
+}
+
+// uniform is nextUniform over the given stream.
+func uniform(stream int64) func(k int64) float64 {
+	mixed := splitmix64(uint64(stream))
+	return func(k int64) float64 {
+		return float64(splitmix64(mixed+uint64(k))>>11) / (1 << 53)
+	}
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Manifest pins everything a suite ran with, like a lockfile: the build
+// of the tool and of hey, every flag and the config after environment
+// variables, --env and --profile were applied, the seed, digests of the
+// files it read and the versions the targets reported. It's written to
+// manifest.json in the suite's directory; `rerun --manifest` replays it.
+// Secrets are redacted as everywhere else.
+type Manifest struct {
+	Manifest    int              `json:"manifest"` // the format's version
+	Suite       string           `json:"suite"`
+	Created     time.Time        `json:"created"`
+	Tool        ToolBuild        `json:"tool"`
+	Engine      string           `json:"engine"`
+	Seed        int64            `json:"seed"`
+	Flags       []string         `json:"flags"` // --name=value for every flag not at its default
+	Config      json.RawMessage  `json:"config"`
+	Inputs      []ManifestInput  `json:"inputs,omitempty"`
+	Targets     []ManifestTarget `json:"targets"`
+	Environment Environment      `json:"environment"`
+}
+
+const manifestVersion = 1
+
+// ToolBuild identifies the build of custom-per-tools that ran a suite.
+type ToolBuild struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Revision string `json:"revision,omitempty"`
+	Modified bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+	Go       string `json:"go"`
+}
+
+// ManifestInput is a file a flag pointed the suite at.
+type ManifestInput struct {
+	Flag   string `json:"flag"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+type ManifestTarget struct {
+	Name    string `json:"name"`
+	Route   string `json:"route,omitempty"`
+	URL     string `json:"url"`
+	Version string `json:"version,omitempty"`
+}
+
+// inputFlags are the flags naming files the suite reads.
+var inputFlags = []string{"har", "postman", "postman-env", "openapi", "scenario", "contract", "report-template"}
+
+// replayedElsewhere are the flags a replay doesn't pass on: the config
+// they selected is in the manifest already layered, and the seed is
+// given explicitly.
+var replayedElsewhere = map[string]bool{"config": true, "env": true, "profile": true, "seed": true}
+
+func toolBuild() ToolBuild {
+	b := ToolBuild{Version: "(unknown)", Go: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	b.Module, b.Version = info.Main.Path, info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+func (b ToolBuild) String() string {
+	s := b.Version
+	if b.Revision != "" {
+		s = b.Revision
+		if len(s) > 12 {
+			s = s[:12]
+		}
+	}
+	if b.Modified {
+		s += " (modified)"
+	}
+	return s + " built with " + b.Go
+}
+
+// setFlags lists every flag of fs not at its default, whether given on
+// the command line or by a PERTOOLS_ variable, as --name=value, each
+// value of a repeatable flag on its own.
+func setFlags(fs *flag.FlagSet) []string {
+	var out []string
+	fs.VisitAll(func(fl *flag.Flag) {
+		if list, ok := fl.Value.(*stringList); ok {
+			for _, v := range *list {
+				out = append(out, "--"+fl.Name+"="+v)
+			}
+			return
+		}
+		if v := fl.Value.String(); v != fl.DefValue {
+			out = append(out, "--"+fl.Name+"="+v)
+		}
+	})
+	return out
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// manifestInputs digests the files the input flags of fs name.
+func manifestInputs(fs *flag.FlagSet) ([]ManifestInput, error) {
+	var out []ManifestInput
+	for _, name := range inputFlags {
+		fl := fs.Lookup(name)
+		var paths []string
+		if list, ok := fl.Value.(*stringList); ok {
+			paths = *list
+		} else if v := fl.Value.String(); v != "" {
+			paths = []string{v}
+		}
+		for _, p := range paths {
+			sum, err := fileSHA256(p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, ManifestInput{Flag: name, Path: p, SHA256: sum})
+		}
+	}
+	return out, nil
+}
+
+// writeManifest pins the suite about to run.
+func writeManifest(filename string, env Environment, targets []Target) error {
+	c, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	inputs, err := manifestInputs(flag.CommandLine)
+	if err != nil {
+		return err
+	}
+	m := Manifest{Manifest: manifestVersion, Suite: suiteID, Created: time.Now().UTC(), Tool: toolBuild(), Engine: *engine,
+		Seed: suiteSeed, Config: json.RawMessage(redact(string(c))), Inputs: inputs, Environment: env}
+	for _, f := range setFlags(flag.CommandLine) {
+		m.Flags = append(m.Flags, redact(f))
+	}
+	for _, t := range targets {
+		m.Targets = append(m.Targets, ManifestTarget{Name: t.Name, Route: t.Route, URL: redact(t.URL), Version: versions[t.Name]})
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(raw, '\n'), 0644)
+}
+
+func loadManifest(file string) (*Manifest, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, positionError(file, raw, err)
+	}
+	if m.Manifest == 0 || len(m.Config) == 0 {
+		return nil, fmt.Errorf("%s isn't a suite manifest", file)
+	}
+	if m.Manifest > manifestVersion {
+		return nil, fmt.Errorf("%s is a version %d manifest; this build reads up to version %d", file, m.Manifest, manifestVersion)
+	}
+	return &m, nil
+}
+
+// runRerun implements `rerun --manifest results/latest/manifest.json`: it
+// checks this build of the tool and of hey and the input files against
+// the manifest, refusing to go on with any different unless --force, and
+// runs the suite again with the manifest's flags, config and seed. Flags
+// after -- are added, e.g. to give a secret the manifest redacted; the
+// config's can be given with --overlay, a file of config keys like an
+// environment's. Once it's run, the targets' versions are compared.
+func runRerun(args []string) {
+	fs := flag.NewFlagSet("rerun", flag.ExitOnError)
+	file := fs.String("manifest", "", "manifest.json of the suite to replay")
+	overlay := fs.String("overlay", "", "JSON file of config keys laid over the manifest's, e.g. secrets it redacted")
+	force := fs.Bool("force", false, "replay even if the tool, hey or input files differ from the manifest's")
+	fs.Parse(args)
+	if *file == "" {
+		fmt.Println("❌ rerun needs --manifest, e.g. results/latest/manifest.json")
+		os.Exit(1)
+	}
+	m, err := loadManifest(*file)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	fmt.Printf("→ Replaying suite %s of %s\n", m.Suite, m.Created.Format(time.RFC3339))
+
+	var mismatches []string
+	switch now := toolBuild(); {
+	case now.Revision != m.Tool.Revision || now.Version != m.Tool.Version:
+		mismatches = append(mismatches, fmt.Sprintf("the suite ran on custom-per-tools %s, this is %s", m.Tool, now))
+	case now.Modified || m.Tool.Modified:
+		mismatches = append(mismatches, fmt.Sprintf("custom-per-tools was built with uncommitted changes (%s), so the two builds can't be told apart", m.Tool))
+	}
+	if m.Engine == "hey" {
+		if now := heyVersion(); now != m.Environment.HeyVersion {
+			mismatches = append(mismatches, fmt.Sprintf("the suite ran hey %q, this would run %q", m.Environment.HeyVersion, now))
+		}
+	}
+	for _, in := range m.Inputs {
+		sum, err := fileSHA256(in.Path)
+		switch {
+		case err != nil:
+			mismatches = append(mismatches, fmt.Sprintf("--%s %s: %v", in.Flag, in.Path, err))
+		case sum != in.SHA256:
+			mismatches = append(mismatches, fmt.Sprintf("--%s %s has changed since the suite ran", in.Flag, in.Path))
+		}
+	}
+	for _, why := range mismatches {
+		if *force {
+			fmt.Println("⚠️ ", why)
+		} else {
+			fmt.Println("❌", why)
+		}
+	}
+	if len(mismatches) > 0 && !*force {
+		fmt.Println("❌ Not an identical suite; pass --force to replay it anyway")
+		os.Exit(1)
+	}
+
+	var c Config
+	if err := json.Unmarshal(m.Config, &c); err != nil {
+		fmt.Println("❌ The manifest's config:", err)
+		os.Exit(1)
+	}
+	if *overlay != "" {
+		raw, err := os.ReadFile(*overlay)
+		if err == nil {
+			err = json.Unmarshal(raw, &c)
+		}
+		if err != nil {
+			fmt.Println("❌ --overlay:", err)
+			os.Exit(1)
+		}
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if strings.Contains(string(raw), redacted) {
+		fmt.Println("❌ The manifest's config has redacted secrets; give them with --overlay, e.g. {\"urls\": [...]}")
+		os.Exit(1)
+	}
+	tmp, err := os.CreateTemp("", "rerun-*.json")
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(raw)
+	tmp.Close()
+
+	childArgs, resultsRoot := rerunArgs(m, tmp.Name(), fs.Args())
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	cmd := exec.Command(self, childArgs...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// the manifest has the settings PERTOOLS_ variables made; newer ones mustn't change them
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "PERTOOLS_") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	runErr := cmd.Run()
+
+	if dir, err := latestSuiteDir(resultsRoot); err == nil {
+		if replay, err := loadManifest(filepath.Join(dir, "manifest.json")); err == nil {
+			compareManifestTargets(m, replay)
+		}
+	}
+	if exit, ok := runErr.(*exec.ExitError); ok {
+		os.Exit(exit.ExitCode())
+	}
+	if runErr != nil {
+		fmt.Println("❌", runErr)
+		os.Exit(1)
+	}
+}
+
+// rerunArgs is how the suite of m is run again, with its config from
+// config and extra flags after the manifest's, and the results directory
+// the replay will write to.
+func rerunArgs(m *Manifest, config string, extra []string) (args []string, resultsRoot string) {
+	resultsRoot = "results"
+	for _, f := range m.Flags {
+		name, value, _ := strings.Cut(strings.TrimPrefix(f, "--"), "=")
+		if replayedElsewhere[name] {
+			continue
+		}
+		if strings.Contains(f, redacted) {
+			fmt.Printf("⚠️  --%s was redacted in the manifest and is left out; give it again after --\n", name)
+			continue
+		}
+		if name == "results-dir" {
+			resultsRoot = value
+		}
+		args = append(args, f)
+	}
+	args = append(args, "--config", config, fmt.Sprintf("--seed=%d", m.Seed))
+	for _, a := range extra {
+		if v, ok := strings.CutPrefix(a, "--results-dir="); ok {
+			resultsRoot = v
+		}
+		args = append(args, a)
+	}
+	return args, resultsRoot
+}
+
+// compareManifestTargets warns of targets that reported another version
+// in the replay than in the original, whose results then compare
+// different code.
+func compareManifestTargets(orig, replay *Manifest) {
+	was := map[string]string{}
+	for _, t := range orig.Targets {
+		was[t.Name] = t.Version
+	}
+	var names []string
+	now := map[string]string{}
+	for _, t := range replay.Targets {
+		if _, seen := now[t.Name]; !seen {
+			names = append(names, t.Name)
+		}
+		now[t.Name] = t.Version
+	}
+	sort.Strings(names)
+	same := true
+	for _, name := range names {
+		if v, ok := was[name]; ok && v != now[name] {
+			same = false
+			fmt.Printf("⚠️  %s ran %s in the original suite and %s in the replay\n", name, orDash(v), orDash(now[name]))
+		}
+	}
+	if same {
+		fmt.Printf("✅ Replayed suite %s as %s\n", orig.Suite, replay.Suite)
+	} else {
+		fmt.Printf("⚠️  Replayed suite %s as %s against different target versions\n", orig.Suite, replay.Suite)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
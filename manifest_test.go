@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeedStreams(t *testing.T) {
+	savedSeed, savedStreams := suiteSeed, seedStreams
+	defer func() { suiteSeed, seedStreams = savedSeed, savedStreams }()
+
+	draw := func(seed int64) []int64 {
+		suiteSeed, seedStreams = seed, 0
+		return []int64{nextStream(), nextStream(), nextRand().Int63(), nextRand().Int63()}
+	}
+	first, again, other := draw(7), draw(7), draw(8)
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("seed 7 drew %v, then %v", first, again)
+	}
+	if first[0] == first[1] {
+		t.Errorf("consecutive streams are both %d", first[0])
+	}
+	if reflect.DeepEqual(first[2:], other[2:]) {
+		t.Errorf("seeds 7 and 8 drew the same numbers %v", first[2:])
+	}
+}
+
+func TestUniform(t *testing.T) {
+	u, same, other := uniform(42), uniform(42), uniform(43)
+	sum, differ := 0.0, 0
+	const n = 10000
+	for k := int64(n - 1); k >= 0; k-- { // drawn backwards: k alone decides the number
+		v := u(k)
+		if v < 0 || v >= 1 {
+			t.Fatalf("u(%d) = %v, want it in [0, 1)", k, v)
+		}
+		if same(k) != v {
+			t.Fatalf("u(%d) differs between two functions of stream 42", k)
+		}
+		if other(k) != v {
+			differ++
+		}
+		sum += v
+	}
+	if mean := sum / n; mean < 0.48 || mean > 0.52 {
+		t.Errorf("mean of %d draws = %.3f, want about 0.5", n, mean)
+	}
+	if differ < n-1 {
+		t.Errorf("streams 42 and 43 agree on %d of %d draws", n-differ, n)
+	}
+}
+
+func TestRerunArgs(t *testing.T) {
+	m := &Manifest{Seed: 99, Flags: []string{
+		"--config=suite.json",
+		"--profile=ci",
+		"--seed=1",
+		"--repeat=5",
+		"--results-dir=out",
+		"--header=Authorization: " + redacted,
+	}}
+	tests := []struct {
+		name    string
+		extra   []string
+		want    []string
+		results string
+	}{
+		{"manifest flags", nil, []string{"--repeat=5", "--results-dir=out", "--config", "/tmp/c.json", "--seed=99"}, "out"},
+		{"extra flags last", []string{"--header=Authorization: Bearer x", "--results-dir=replays"},
+			[]string{"--repeat=5", "--results-dir=out", "--config", "/tmp/c.json", "--seed=99", "--header=Authorization: Bearer x", "--results-dir=replays"}, "replays"},
+	}
+	for _, tt := range tests {
+		got, results := rerunArgs(m, "/tmp/c.json", tt.extra)
+		if !reflect.DeepEqual(got, tt.want) || results != tt.results {
+			t.Errorf("%s: rerunArgs = %q, %s, want %q, %s", tt.name, got, results, tt.want, tt.results)
+		}
+	}
+	if _, results := rerunArgs(&Manifest{}, "c.json", nil); results != "results" {
+		t.Errorf("results dir %q without --results-dir, want results", results)
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content, err string
+	}{
+		{"manifest", `{"manifest": 1, "suite": "s1", "seed": 5, "config": {"repeat": 1}}`, ""},
+		{"not a manifest", `{"repeat": 1}`, "isn't a suite manifest"},
+		{"no config", `{"manifest": 1}`, "isn't a suite manifest"},
+		{"newer format", `{"manifest": 2, "config": {}}`, "version 2 manifest"},
+		{"not json", `{"manifest": 1,`, ".json:1:16: unexpected end"},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(dir, strings.Repeat("m", i+1)+".json")
+		if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := loadManifest(file)
+		switch {
+		case tt.err == "" && (err != nil || m.Suite != "s1" || m.Seed != 5):
+			t.Errorf("%s: loadManifest = %+v, %v", tt.name, m, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: loadManifest error %v, want one with %q", tt.name, err, tt.err)
+		}
+	}
+}
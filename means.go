@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// errorBarItem draws a custom series value [x, low, high] as a vertical
+// whisker with caps, over the bar at category x.
+const errorBarItem = `function (params, api) {
+	var x = api.value(0);
+	var lo = api.coord([x, api.value(1)]);
+	var hi = api.coord([x, api.value(2)]);
+	var w = api.size([1, 0])[0] * 0.12;
+	var style = {stroke: '#333', lineWidth: 1.5};
+	return {type: 'group', children: [
+		{type: 'line', shape: {x1: lo[0], y1: lo[1], x2: hi[0], y2: hi[1]}, style: style},
+		{type: 'line', shape: {x1: lo[0] - w, y1: lo[1], x2: lo[0] + w, y2: lo[1]}, style: style},
+		{type: 'line', shape: {x1: hi[0] - w, y1: hi[1], x2: hi[0] + w, y2: hi[1]}, style: style}
+	]};
+}`
+
+// meanCI is the mean of xs and the half-width of its 95% confidence
+// interval, NaN with fewer than two values.
+func meanCI(xs []float64) (float64, float64) {
+	if len(xs) < 2 {
+		return mean(xs), math.NaN()
+	}
+	return mean(xs), tCritical95(len(xs)-1) * stddev(xs) / math.Sqrt(float64(len(xs)))
+}
+
+// niceCeil rounds v up to two significant digits, for an axis maximum.
+func niceCeil(v float64) float64 {
+	p := math.Pow(10, math.Floor(math.Log10(v))-1)
+	return math.Ceil(v/p) * p
+}
+
+// generateMeansChart draws one bar per target of its mean rps, and beside
+// it of its mean latency, each with a 95% confidence interval as an error
+// bar: the comparison at a glance, with how far to trust it.
+func generateMeansChart(rows []map[string]string, filename string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	var own []map[string]string
+	for _, row := range rows {
+		if row["agent"] == "" && seriesWanted(rowSeriesKey(row)) {
+			own = append(own, row)
+		}
+	}
+
+	page := components.NewPage()
+	page.SetPageTitle("Means with 95% Confidence Intervals")
+	page.SetLayout(components.PageFlexLayout)
+	for _, metric := range []string{"requests_per_sec", latency} {
+		values, order := seriesValues(own, metric)
+		if len(order) == 0 {
+			continue
+		}
+		name, unit := withUnit(metric), unitOf(metric)
+		if metric == "requests_per_sec" {
+			name, unit = "rps", "rps"
+		}
+		var means []opts.BarData
+		var bars []opts.CustomData
+		top := 0.0 // the y axis must reach the top whisker, which echarts doesn't see
+		for i, key := range order {
+			xs := values[key]
+			for j := range xs {
+				xs[j] = displayValue(metric, xs[j])
+			}
+			m, h := meanCI(xs)
+			detail := fmt.Sprintf("<b>%s</b> · %d runs", key, len(xs))
+			if !math.IsNaN(h) {
+				detail += fmt.Sprintf("<br/>95%% CI %.4g – %.4g %s", m-h, m+h, unit)
+				bars = append(bars, opts.CustomData{
+					Value:   []interface{}{i, round4(m - h), round4(m + h)},
+					Tooltip: &opts.Tooltip{Show: opts.Bool(false)}, // the bar's tooltip has the interval
+				})
+			}
+			top = math.Max(top, m)
+			if !math.IsNaN(h) {
+				top = math.Max(top, m+h)
+			}
+			means = append(means, opts.BarData{Name: detail, Value: round4(m)})
+		}
+
+		var yMax interface{}
+		if top > 0 {
+			yMax = niceCeil(top)
+		}
+		bar := charts.NewBar()
+		bar.SetGlobalOptions(
+			charts.WithTitleOpts(chartTitle("Mean "+name, "error bars: 95% confidence interval")),
+			charts.WithYAxisOpts(opts.YAxis{Name: name, Max: yMax}),
+			charts.WithXAxisOpts(opts.XAxis{Name: "Target"}),
+			chartToolbox(),
+			pointTooltip(unit),
+		)
+		bar.SetXAxis(order)
+		bar.AddSeries("mean", means, charts.WithItemStyleOpts(opts.ItemStyle{Color: seriesColors[0]}))
+		ci := charts.NewCustom()
+		ci.AddSeries("95% CI", bars, charts.WithCustomChartOpts(opts.CustomChart{RenderItem: opts.FuncOpts(errorBarItem)}))
+		bar.Overlap(ci)
+		page.AddCharts(bar)
+	}
+
+	renderChart(page, filename)
+}
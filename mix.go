@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var mixEntryRe = regexp.MustCompile(`(?:^|,)\s*(\d+(?:\.\d+)?)%?\s+([A-Z]+)\s+`)
+
+// parseMix reads a traffic mix such as
+//
+//	80% GET /persons, 15% GET /persons/1, 5% POST /persons {"name":"x"}
+//
+// and expands it against every deployment base URL. Anything after the
+// path is sent as the request body.
+func parseMix(spec string, bases map[string]string) ([]Target, error) {
+	matches := mixEntryRe.FindAllStringSubmatchIndex(spec, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("invalid mix %q, want \"<weight>%% <METHOD> <path> [body], ...\"", spec)
+	}
+	if lead := strings.TrimSpace(spec[:matches[0][0]]); lead != "" {
+		return nil, fmt.Errorf("invalid mix entry %q", lead)
+	}
+
+	var names []string
+	for name := range bases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []Target
+	for i, m := range matches {
+		end := len(spec)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		weight, _ := strconv.ParseFloat(spec[m[2]:m[3]], 64)
+		method := spec[m[4]:m[5]]
+		path, body, _ := strings.Cut(strings.TrimSpace(spec[m[1]:end]), " ")
+		if weight <= 0 || path == "" {
+			return nil, fmt.Errorf("invalid mix entry %q", strings.TrimSpace(spec[m[0]:end]))
+		}
+
+		for _, name := range names {
+			t := Target{
+				Name:   name,
+				Route:  method + " " + path,
+				Method: method,
+				URL:    strings.TrimRight(bases[name], "/") + path,
+				Body:   strings.TrimSpace(body),
+				Weight: weight,
+			}
+			if t.Body != "" && strings.HasPrefix(t.Body, "{") {
+				t.Headers = append(t.Headers, Header{Name: "Content-Type", Value: "application/json"})
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
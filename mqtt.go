@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT publishes the suite's metrics to a broker as it runs, so wallboards
+// subscribed to it show the live comparison. Broker is mqtt://host:port,
+// or mqtts:// for TLS; with a username, the password is read from the
+// variable named by PasswordEnv. Topic is the prefix of every topic,
+// custom-per-tools by default:
+//
+//	<topic>/suite       the suite's state, retained
+//	<topic>/run         every run's results as it finishes
+//	<topic>/comparison  every series' means so far, retained
+type MQTT struct {
+	Broker      string `json:"broker"`
+	Topic       string `json:"topic"`
+	ClientID    string `json:"client_id"`
+	Username    string `json:"username"`
+	PasswordEnv string `json:"password_env"`
+}
+
+const (
+	defaultMQTTTopic = "custom-per-tools"
+	mqttKeepAlive    = 60 * time.Second
+	mqttRetry        = 30 * time.Second
+)
+
+func validateMQTT(m *MQTT) error {
+	u, err := url.Parse(m.Broker)
+	if err != nil || (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Hostname() == "" {
+		return fmt.Errorf("invalid broker %q, want mqtt://host:port or mqtts://host:port", redact(m.Broker))
+	}
+	if strings.ContainsAny(m.Topic, "+#") || strings.HasPrefix(m.Topic, "/") || strings.HasSuffix(m.Topic, "/") {
+		return fmt.Errorf("invalid topic %q; wildcards and leading or trailing slashes can't be published to", m.Topic)
+	}
+	if m.PasswordEnv != "" && os.Getenv(m.PasswordEnv) == "" {
+		return fmt.Errorf("password_env %s isn't set", m.PasswordEnv)
+	}
+	return nil
+}
+
+// MQTTSuite is published, retained, to <topic>/suite when the suite
+// starts and when it's done.
+type MQTTSuite struct {
+	Suite   string            `json:"suite"`
+	State   string            `json:"state"` // running or done
+	Started time.Time         `json:"started"`
+	Time    time.Time         `json:"time"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Targets []ManifestTarget  `json:"targets,omitempty"`
+	Runs    int               `json:"runs"`
+	Passed  *bool             `json:"passed,omitempty"`
+}
+
+// mqttPublisher is a minimal MQTT 3.1.1 client that publishes at QoS 0,
+// reconnecting once per message if the broker dropped it. After a failed
+// connection it waits mqttRetry before trying again, so a broker that's
+// unreachable doesn't hold up every run by the dial timeout.
+type mqttPublisher struct {
+	cfg   *MQTT
+	topic string
+	suite MQTTSuite
+
+	mu      sync.Mutex
+	conn    net.Conn
+	stop    chan struct{}
+	retryAt time.Time
+	warned  bool
+	publish int
+}
+
+var mqttPub *mqttPublisher
+
+func startMQTT(started time.Time, targets []Target) {
+	if cfg.MQTT == nil {
+		return
+	}
+	p := &mqttPublisher{cfg: cfg.MQTT, topic: cfg.MQTT.Topic}
+	if p.topic == "" {
+		p.topic = defaultMQTTTopic
+	}
+	p.suite = MQTTSuite{Suite: suiteID, State: "running", Started: started.UTC(), Labels: labels}
+	for _, t := range targets {
+		p.suite.Targets = append(p.suite.Targets, ManifestTarget{Name: t.Name, Route: t.Route, URL: redact(t.URL), Version: versions[t.Name]})
+	}
+	mqttPub = p
+	p.suite.Time = time.Now().UTC()
+	if p.send("suite", p.suite, true) {
+		fmt.Printf("→ Publishing metrics to %s on %s\n", p.topic, redact(p.cfg.Broker))
+	}
+}
+
+// publishRun publishes run i of target's rows, then the comparison so far
+// over results.
+func publishRun(target string, i, concurrency int, rows, results []map[string]string) {
+	if mqttPub == nil {
+		return
+	}
+	ev := WebhookEvent{Event: "run_complete", Suite: suiteID, Time: time.Now().UTC(), Labels: labels, Target: target, Run: i, Concurrency: concurrency}
+	for _, row := range rows {
+		ev.Results = append(ev.Results, publicRow(row))
+	}
+	mqttPub.send("run", ev, false)
+	mqttPub.suite.Runs++
+	mqttPub.sendComparison(results, nil)
+}
+
+func finishMQTT(results []map[string]string, passed bool) {
+	p := mqttPub
+	if p == nil {
+		return
+	}
+	p.sendComparison(results, &passed)
+	p.suite.State, p.suite.Time, p.suite.Passed = "done", time.Now().UTC(), &passed
+	p.send("suite", p.suite, true)
+	p.close()
+	if !p.warned {
+		fmt.Printf("✅ Published %d messages to %s\n", p.publish, p.topic)
+	}
+}
+
+func (p *mqttPublisher) sendComparison(results []map[string]string, passed *bool) {
+	p.send("comparison", WebhookEvent{Event: "comparison", Suite: suiteID, Time: time.Now().UTC(), Labels: labels,
+		Series: suiteSeries(results), Passed: passed}, true)
+}
+
+// send publishes v as JSON to the topic's sub, connecting first if it
+// isn't. It warns of the first failure only, so a broker that's down
+// doesn't flood the output; the suite carries on either way.
+func (p *mqttPublisher) send(sub string, v interface{}, retain bool) bool {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if p.conn == nil {
+			if time.Now().Before(p.retryAt) {
+				return false
+			}
+			if err = p.connect(); err != nil {
+				p.retryAt = time.Now().Add(mqttRetry)
+				break
+			}
+		}
+		if err = p.write(mqttPublish(p.topic+"/"+sub, payload, retain)); err == nil {
+			p.publish++
+			return true
+		}
+		p.drop()
+	}
+	if !p.warned {
+		p.warned = true
+		fmt.Printf("⚠️  Couldn't publish to %s: %s\n", redact(p.cfg.Broker), redact(err.Error()))
+	}
+	return false
+}
+
+func (p *mqttPublisher) connect() error {
+	u, _ := url.Parse(p.cfg.Broker) // validated
+	host := u.Host
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "mqtts" {
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return err
+	}
+	clientID := p.cfg.ClientID
+	if clientID == "" {
+		clientID = "custom-per-tools-" + suiteID[:8]
+	}
+	password := ""
+	if p.cfg.PasswordEnv != "" {
+		password = os.Getenv(p.cfg.PasswordEnv)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write(mqttConnect(clientID, p.cfg.Username, password)); err != nil {
+		conn.Close()
+		return err
+	}
+	r := bufio.NewReader(conn)
+	kind, body, err := mqttRead(r)
+	switch {
+	case err != nil:
+		conn.Close()
+		return fmt.Errorf("no CONNACK: %v", err)
+	case kind != 2 || len(body) != 2:
+		conn.Close()
+		return fmt.Errorf("broker answered packet type %d, not CONNACK", kind)
+	case body[1] != 0:
+		conn.Close()
+		return fmt.Errorf("broker refused the connection: %s", mqttRefusal(body[1]))
+	}
+	conn.SetDeadline(time.Time{})
+	p.conn, p.stop = conn, make(chan struct{})
+	go p.keepAlive(conn, r, p.stop)
+	return nil
+}
+
+// keepAlive pings the broker within the keep-alive interval and reads
+// what it sends back, PINGRESPs, until stop is closed or the connection
+// fails.
+func (p *mqttPublisher) keepAlive(conn net.Conn, r *bufio.Reader, stop chan struct{}) {
+	go func() {
+		for {
+			if _, _, err := mqttRead(r); err != nil {
+				return
+			}
+		}
+	}()
+	tick := time.NewTicker(mqttKeepAlive / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			p.mu.Lock()
+			if p.conn == conn && p.write([]byte{0xC0, 0}) != nil {
+				p.drop()
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *mqttPublisher) write(packet []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := p.conn.Write(packet)
+	return err
+}
+
+// drop forgets a broken connection; p.mu is held.
+func (p *mqttPublisher) drop() {
+	if p.conn != nil {
+		close(p.stop)
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *mqttPublisher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.write([]byte{0xE0, 0}) // DISCONNECT
+		p.drop()
+	}
+}
+
+func mqttConnect(clientID, username, password string) []byte {
+	body := mqttString("MQTT")
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = append(body, mqttString(clientID)...)
+	if username != "" {
+		body = append(body, mqttString(username)...)
+		if password != "" {
+			body = append(body, mqttString(password)...)
+		}
+	}
+	return mqttPacket(0x10, body)
+}
+
+func mqttPublish(topic string, payload []byte, retain bool) []byte {
+	header := byte(0x30) // QoS 0
+	if retain {
+		header |= 0x01
+	}
+	return mqttPacket(header, append(mqttString(topic), payload...))
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	// the remaining length, 7 bits a byte
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+// mqttRead reads a packet, returning its type and body.
+func mqttRead(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, fmt.Errorf("malformed remaining length")
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header >> 4, body, err
+}
+
+func mqttRefusal(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client ID rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+type openAPIParameter struct {
+	Ref      string                     `json:"$ref"`
+	Name     string                     `json:"name"`
+	In       string                     `json:"in"`
+	Required bool                       `json:"required"`
+	Example  interface{}                `json:"example"`
+	Examples map[string]json.RawMessage `json:"examples"`
+	Schema   *openAPISchema             `json:"schema"`
+	Type     string                     `json:"type"` // swagger 2.0
+	Default  interface{}                `json:"default"`
+}
+
+type openAPISchema struct {
+	Type    string        `json:"type"`
+	Format  string        `json:"format"`
+	Example interface{}   `json:"example"`
+	Default interface{}   `json:"default"`
+	Enum    []interface{} `json:"enum"`
+}
+
+type openAPIOperation struct {
+	Parameters []openAPIParameter `json:"parameters"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation  `json:"get"`
+}
+
+type openAPIDocument struct {
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `json:"parameters"`
+	} `json:"components"`
+	Parameters map[string]openAPIParameter `json:"parameters"` // swagger 2.0
+}
+
+// loadOpenAPI generates one GET target per route in the document for every
+// deployment base URL, filling path and required query parameters with the
+// examples the spec provides.
+func loadOpenAPI(path string, bases map[string]string) ([]Target, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc openAPIDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var routes []string
+	for p, item := range doc.Paths {
+		if item.Get != nil {
+			routes = append(routes, p)
+		}
+	}
+	sort.Strings(routes)
+
+	var names []string
+	for name := range bases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []Target
+	for _, p := range routes {
+		item := doc.Paths[p]
+		params := append(append([]openAPIParameter{}, item.Parameters...), item.Get.Parameters...)
+
+		resolved := p
+		query := url.Values{}
+		for _, param := range params {
+			param = doc.resolve(param)
+			value := exampleValue(param)
+			switch param.In {
+			case "path":
+				resolved = strings.ReplaceAll(resolved, "{"+param.Name+"}", url.PathEscape(value))
+			case "query":
+				if param.Required {
+					query.Set(param.Name, value)
+				}
+			}
+		}
+		if len(query) > 0 {
+			resolved += "?" + query.Encode()
+		}
+
+		for _, name := range names {
+			targets = append(targets, Target{
+				Name:   name,
+				Route:  "GET " + p,
+				Method: "GET",
+				URL:    strings.TrimRight(bases[name], "/") + resolved,
+			})
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s defines no GET operations", path)
+	}
+	return targets, nil
+}
+
+func (doc openAPIDocument) resolve(p openAPIParameter) openAPIParameter {
+	if p.Ref == "" {
+		return p
+	}
+	name := p.Ref[strings.LastIndex(p.Ref, "/")+1:]
+	if r, ok := doc.Components.Parameters[name]; ok {
+		return r
+	}
+	if r, ok := doc.Parameters[name]; ok {
+		return r
+	}
+	return p
+}
+
+func exampleValue(p openAPIParameter) string {
+	candidates := []interface{}{p.Example}
+	for _, k := range sortedKeys(p.Examples) {
+		var ex struct {
+			Value interface{} `json:"value"`
+		}
+		if json.Unmarshal(p.Examples[k], &ex) == nil {
+			candidates = append(candidates, ex.Value)
+		}
+	}
+	typ := p.Type
+	if p.Schema != nil {
+		candidates = append(candidates, p.Schema.Example, p.Schema.Default)
+		if len(p.Schema.Enum) > 0 {
+			candidates = append(candidates, p.Schema.Enum[0])
+		}
+		typ = p.Schema.Type
+	}
+	candidates = append(candidates, p.Default)
+
+	for _, c := range candidates {
+		if c != nil {
+			return fmt.Sprint(c)
+		}
+	}
+	switch typ {
+	case "integer", "number":
+		return "1"
+	case "boolean":
+		return "true"
+	default:
+		return "example"
+	}
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseBaseURLs reads "name=url,name=url"; without any it falls back to the
+// hosts of the built-in targets.
+func parseBaseURLs(s string) (map[string]string, error) {
+	bases := map[string]string{}
+	if s == "" {
+		for _, t := range defaultTargets() {
+			u, err := url.Parse(t.URL)
+			if err != nil {
+				return nil, err
+			}
+			bases[t.Name] = u.Scheme + "://" + u.Host
+		}
+		return bases, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, base, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || base == "" {
+			return nil, fmt.Errorf("invalid base url %q, want name=url", pair)
+		}
+		bases[name] = base
+	}
+	return bases, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Environments overlay the config for one deployment stage, selected with
+// --env: each holds config keys, like a Helm values file, replacing the
+// config's, so dev, staging and prod share the scenario, load and
+// thresholds and differ only in what they set, usually the urls:
+//
+//	"environments": {
+//	  "staging": {"urls": ["https://staging-a.example.com/", "https://staging-b.example.com/"]},
+//	  "prod":    {"urls": ["https://prod.example.com/"], "concurrency": 200, "labels": {"tier": "gold"}}
+//	}
+//
+// Objects like labels and overrides are merged key by key; lists replace
+// the config's.
+func (c Config) environment(name string) (Config, error) {
+	overlay, ok := c.Environments[name]
+	if !ok {
+		names := environmentNames(c)
+		if len(names) == 0 {
+			return c, fmt.Errorf("unknown environment %q: the config defines no environments", name)
+		}
+		return c, fmt.Errorf("unknown environment %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(overlay, &keys); err != nil {
+		return c, fmt.Errorf("environment %s: want an object of config keys", name)
+	}
+	if _, nested := keys["environments"]; nested {
+		return c, fmt.Errorf("environment %s: environments can't define environments", name)
+	}
+	// decode the overlay onto a copy, so merging its maps leaves c's alone
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return c, err
+	}
+	var env Config
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(overlay, &env); err != nil {
+		return c, fmt.Errorf("environment %s: %w", name, err)
+	}
+	return env, nil
+}
+
+// applyEnvironment replaces c with its named environment, labelled with
+// env=name so the results and trends of each can be told apart.
+func applyEnvironment(c *Config, name string) error {
+	env, err := c.environment(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := env.Labels["env"]; !ok {
+		if env.Labels == nil {
+			env.Labels = map[string]string{}
+		}
+		env.Labels["env"] = name
+	}
+	*c = env
+	return nil
+}
+
+// environmentNames lists c's environments, sorted.
+func environmentNames(c Config) []string {
+	var names []string
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// outputMu keeps lines from hey's stdout and stderr whole when both are
+// shown live.
+var outputMu sync.Mutex
+
+// linePrefixer copies whole lines to w, each starting with prefix, so live
+// hey output (--show-hey-output) says which run it belongs to.
+type linePrefixer struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *linePrefixer) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.emit(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes out a final line that had no newline.
+func (p *linePrefixer) Flush() {
+	if len(p.buf) > 0 {
+		p.emit(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *linePrefixer) emit(line []byte) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	io.WriteString(p.w, p.prefix)
+	p.w.Write(line)
+}
+
+// lastLine returns the last non-empty line of out, the most useful part of
+// a failing command's stderr for an error message.
+func lastLine(out []byte) string {
+	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+	return string(bytes.TrimSpace(lines[len(lines)-1]))
+}
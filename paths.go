@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// maxSlugLen keeps output file names, a slug plus prefixes, run numbers
+// and suffixes, well under the 255-byte name limit, and paths under
+// Windows' 260 characters for typical working directories.
+const maxSlugLen = 64
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// windowsReserved are device names Windows refuses as file names, with
+// any extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// safeSlug makes s usable in a file name on every OS: only ASCII letters,
+// digits and _, never empty or a Windows device name, and at most
+// maxSlugLen long. A shortened slug ends in a hash of the whole one so it
+// stays unique.
+func safeSlug(s string) string {
+	slug := unsafeChars.ReplaceAllString(s, "_")
+	if slug == "" || windowsReserved[strings.ToUpper(slug)] {
+		slug = "_" + slug
+	}
+	if len(slug) > maxSlugLen {
+		sum := sha1.Sum([]byte(slug))
+		slug = slug[:maxSlugLen-9] + "_" + hex.EncodeToString(sum[:4])
+	}
+	return slug
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeSlug(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "api_example_com", "api_example_com"},
+		{"dot dot", "..", "__"},
+		{"slash", "/", "_"},
+		{"backslash", `a\b`, "a_b"},
+		{"path traversal", "../../etc/passwd", "______etc_passwd"},
+		{"empty", "", "_"},
+		{"unicode", "café/ü", "caf___"},
+		{"reserved", "CON", "_CON"},
+		{"reserved lower case", "nul", "_nul"},
+		{"reserved with digit", "com1", "_com1"},
+		{"not reserved", "COM10", "COM10"},
+		{"reserved with extension", "AUX.txt", "AUX_txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeSlug(tt.in); got != tt.want {
+				t.Errorf("safeSlug(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeSlugShortened(t *testing.T) {
+	long := strings.Repeat("x", 80)
+	a, b := safeSlug(long+"a"), safeSlug(long+"b")
+	if len(a) != maxSlugLen || len(b) != maxSlugLen {
+		t.Errorf("shortened slugs %q and %q aren't %d long", a, b, maxSlugLen)
+	}
+	if !strings.HasPrefix(a, long[:maxSlugLen-9]+"_") {
+		t.Errorf("shortened slug %q doesn't keep the name's start", a)
+	}
+	if a == b {
+		t.Errorf("shortened slugs of different names collide: %q", a)
+	}
+}
+
+func TestAssignSlugs(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want []string
+	}{
+		{"distinct", []string{"https://a.example/x", "https://b.example/x"}, []string{"a_example_x", "b_example_x"}},
+		{"same url", []string{"https://a.example/", "https://a.example/"}, []string{"a_example_", "a_example__2"}},
+		{"separators collide", []string{"https://a.example/b/c", `https://a.example/b\c`, "https://a.example/b_c"},
+			[]string{"a_example_b_c", "a_example_b_c_2", "a_example_b_c_3"}},
+		{"case collides", []string{"https://A.example/", "https://a.example/"}, []string{"A_example_", "a_example__2"}},
+		{"numbered slug taken", []string{"https://a/", "https://a/_2", "https://a/"}, []string{"a_", "a__2", "a__3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []Target
+			for _, u := range tt.urls {
+				targets = append(targets, Target{URL: u})
+			}
+			assignSlugs(targets)
+			for i, target := range targets {
+				if target.Slug != tt.want[i] {
+					t.Errorf("slug of %q = %q, want %q", tt.urls[i], target.Slug, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bandwidth is a run's response throughput in MB/s: the mean size of a
+// response times requests per second.
+func bandwidth(values map[string]float64) (float64, bool) {
+	size, ok1 := values["size_request"]
+	rps, ok2 := values["requests_per_sec"]
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return size * rps / 1e6, true
+}
+
+// hasSizes reports whether any run recorded response sizes; hey's CSV
+// output doesn't, so with hey they need --hey-output text.
+func hasSizes(rows []map[string]string) bool {
+	for _, row := range rows {
+		if _, ok := rowFloat(row, "size_request"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzePayload adds each target's mean response size and bandwidth to
+// the report, noting when sizes differ enough between targets to explain
+// a throughput gap: a deployment returning twice the bytes per response
+// can't be expected to match the other's requests per second.
+func analyzePayload(rows []map[string]string) {
+	if !hasSizes(rows) {
+		return
+	}
+	sizes, order := seriesValues(rows, "size_request")
+	rps, _ := seriesValues(rows, "requests_per_sec")
+	mbps := map[string][]float64{}
+	for _, row := range rows {
+		if v, ok := bandwidth(resultFromRow(row).Values); ok {
+			mbps[rowSeriesKey(row)] = append(mbps[rowSeriesKey(row)], v)
+		}
+	}
+	var table [][]string
+	var smallest, largest string
+	for _, key := range order {
+		size := mean(sizes[key])
+		table = append(table, []string{key, fmt.Sprintf("%.0f", size), fmt.Sprintf("%.2f", mean(rps[key])), fmt.Sprintf("%.3f", mean(mbps[key]))})
+		if smallest == "" || size < mean(sizes[smallest]) {
+			smallest = key
+		}
+		if largest == "" || size > mean(sizes[largest]) {
+			largest = key
+		}
+	}
+	var b strings.Builder
+	b.WriteString(markdownTable([]string{"target", withUnit("size_request"), "rps", withUnit("bandwidth")}, table))
+	if lo, hi := mean(sizes[smallest]), mean(sizes[largest]); lo > 0 && hi > 1.1*lo {
+		fmt.Fprintf(&b, "\n⚠️ %s returns %.1f× the bytes per response of %s, which may explain part of any throughput gap between them.\n", largest, hi/lo, smallest)
+	}
+	addReportSection("Payload size and bandwidth", b.String())
+}
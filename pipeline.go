@@ -0,0 +1,32 @@
+package main
+
+import "sync"
+
+// parallel calls fn(0) … fn(n-1) on up to --workers goroutines and waits
+// for them all, so parsing many run files or rendering many charts uses
+// every core.
+func parallel(n int, fn func(i int)) {
+	w := *workers
+	if w < 1 {
+		w = 1
+	}
+	if w > n {
+		w = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for k := 0; k < w; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type postmanCollection struct {
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string `json:"method"`
+	Header []struct {
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		Disabled bool   `json:"disabled"`
+	} `json:"header"`
+	Body *struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw"`
+		URLEncoded []postmanVariable `json:"urlencoded"`
+	} `json:"body"`
+	URL json.RawMessage `json:"url"`
+}
+
+type postmanVariable struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+type postmanEnvironment struct {
+	Values []postmanVariable `json:"values"`
+}
+
+var postmanVarRe = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// loadPostman flattens a Postman v2.1 collection (folders included) into
+// targets, substituting {{variables}} from the collection and, with higher
+// precedence, from the optional environment file.
+func loadPostman(path, envPath string) ([]Target, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var col postmanCollection
+	if err := json.Unmarshal(raw, &col); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	vars := map[string]string{}
+	addPostmanVars(vars, col.Variable)
+	if envPath != "" {
+		raw, err := os.ReadFile(envPath)
+		if err != nil {
+			return nil, err
+		}
+		var env postmanEnvironment
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", envPath, err)
+		}
+		addPostmanVars(vars, env.Values)
+	}
+
+	var targets []Target
+	var walk func(items []postmanItem) error
+	walk = func(items []postmanItem) error {
+		for _, it := range items {
+			if len(it.Item) > 0 {
+				if err := walk(it.Item); err != nil {
+					return err
+				}
+			}
+			if it.Request == nil {
+				continue
+			}
+			t, err := postmanTarget(it, vars)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, t)
+		}
+		return nil
+	}
+	if err := walk(col.Item); err != nil {
+		return nil, err
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s contains no requests", path)
+	}
+	return targets, nil
+}
+
+func addPostmanVars(vars map[string]string, list []postmanVariable) {
+	for _, v := range list {
+		if v.Disabled || (v.Enabled != nil && !*v.Enabled) {
+			continue
+		}
+		vars[v.Key] = v.Value
+	}
+}
+
+func substituteVars(s string, vars map[string]string) string {
+	return postmanVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		key := postmanVarRe.FindStringSubmatch(m)[1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+func postmanTarget(it postmanItem, vars map[string]string) (Target, error) {
+	req := it.Request
+	rawURL, err := postmanURL(req.URL)
+	if err != nil {
+		return Target{}, fmt.Errorf("request %q: %w", it.Name, err)
+	}
+	rawURL = substituteVars(rawURL, vars)
+	if left := postmanVarRe.FindString(rawURL); left != "" {
+		return Target{}, fmt.Errorf("request %q: unresolved variable %s in url", it.Name, left)
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Target{}, fmt.Errorf("request %q: %w", it.Name, err)
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	t := Target{
+		Name:   u.Host,
+		Method: method,
+		URL:    rawURL,
+		Weight: 1,
+	}
+	t.Route = routeOf(method, rawURL)
+	for _, h := range req.Header {
+		if h.Disabled {
+			continue
+		}
+		t.Headers = append(t.Headers, Header{
+			Name:  substituteVars(h.Key, vars),
+			Value: substituteVars(h.Value, vars),
+		})
+	}
+	if req.Body != nil {
+		switch req.Body.Mode {
+		case "raw":
+			t.Body = substituteVars(req.Body.Raw, vars)
+		case "urlencoded":
+			form := url.Values{}
+			for _, kv := range req.Body.URLEncoded {
+				if !kv.Disabled {
+					form.Add(substituteVars(kv.Key, vars), substituteVars(kv.Value, vars))
+				}
+			}
+			t.Body = form.Encode()
+			t.Headers = append(t.Headers, Header{Name: "Content-Type", Value: "application/x-www-form-urlencoded"})
+		}
+	}
+	return t, nil
+}
+
+// postmanURL accepts both forms Postman writes: a plain string or an object
+// whose raw field holds the full URL.
+func postmanURL(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var obj struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil || obj.Raw == "" {
+		return "", fmt.Errorf("missing url")
+	}
+	return obj.Raw, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Open files the suite needs besides one socket per virtual user: its
+// own outputs, hey's, stdio and the resolver.
+const preflightFileReserve = 64
+
+// Virtual users per core above which the native engine's goroutines,
+// rather than the target, may bound latency.
+const usersPerCore = 1000
+
+// peakConcurrency is the most virtual users any run of the suite starts.
+func peakConcurrency(targets []Target, levels []int) int {
+	peak := 0
+	for _, c := range levels {
+		for _, t := range targets {
+			if t.Concurrency > 0 {
+				c = max(c, t.Concurrency)
+			}
+			peak = max(peak, c)
+		}
+	}
+	return peak
+}
+
+// preflight checks this machine can sustain c virtual users before the
+// suite spends any time, raising the open-file limit when it's too low
+// and the hard limit allows, and returns why it can't otherwise.
+// Exhausted files or ports don't fail loudly: requests error out or
+// queue, and the results are skewed.
+func preflight(c int) error {
+	need := uint64(c + preflightFileReserve)
+	if soft, hard, ok := fileLimit(); ok && soft < need {
+		if hard < need {
+			return fmt.Errorf("c=%d needs about %d open files, but the limit is %d (hard %d); raise it with ulimit -n or lower the concurrency", c, need, soft, hard)
+		}
+		if err := raiseFileLimit(need); err != nil {
+			return fmt.Errorf("c=%d needs about %d open files, over the limit of %d, which couldn't be raised: %v", c, need, soft, err)
+		}
+		fmt.Printf("→ Raised the open-file limit from %d to %d for c=%d\n", soft, need, c)
+	}
+
+	if runtime.GOOS == "linux" {
+		if size := ephemeralPortRange(); size > 0 {
+			free := size - ephemeralPortsInUse()
+			switch {
+			case c > free:
+				return fmt.Errorf("c=%d needs a local port per connection, but only %d of the %d ephemeral ports (%d–%d) are free; widen net.ipv4.ip_local_port_range or lower the concurrency",
+					c, free, size, portLow, portHigh)
+			case float64(c) > clientShareLimit/100*float64(free):
+				fmt.Printf("⚠️  c=%d takes most of the %d free ephemeral ports; connections that aren't reused will run out\n", c, free)
+			}
+		}
+	}
+
+	if *engine == "native" {
+		procs := runtime.GOMAXPROCS(0)
+		if procs < runtime.NumCPU() && os.Getenv("GOMAXPROCS") == "" {
+			fmt.Printf("→ Raised GOMAXPROCS from %d to the %d CPUs\n", procs, runtime.NumCPU())
+			procs = runtime.NumCPU()
+			runtime.GOMAXPROCS(procs)
+		}
+		if c > usersPerCore*procs {
+			fmt.Printf("⚠️  c=%d is over %d virtual users per core on GOMAXPROCS=%d; the client may bound latency, so spread the load over --agents\n", c, usersPerCore, procs)
+		}
+	}
+	return nil
+}
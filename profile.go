@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile presets a suite's rigour with --profile: how many runs of how
+// much load, the warm-up runs before them and the thresholds it's held
+// to. Unset fields leave the config's value alone; Thresholds add to the
+// config's.
+type Profile struct {
+	Repeat      int      `json:"repeat"`
+	Requests    int      `json:"requests"`
+	Concurrency int      `json:"concurrency"`
+	Warmup      int      `json:"warmup"`
+	Cooldown    string   `json:"cooldown"`
+	Thresholds  []string `json:"thresholds"`
+}
+
+// builtinProfiles are always available; a config's "profiles" can add
+// more or redefine these.
+var builtinProfiles = map[string]Profile{
+	// a sanity check that finishes in seconds
+	"quick": {Repeat: 3, Requests: 200, Concurrency: 10, Cooldown: "0s"},
+	// everyday comparisons
+	"standard": {Repeat: 10, Requests: 1000, Concurrency: 50, Warmup: 1},
+	// results worth publishing: many long runs, well separated
+	"thorough": {Repeat: 30, Requests: 5000, Concurrency: 100, Warmup: 2, Cooldown: "5s"},
+	// a pipeline gate: short, and failing on errors or a slow p95
+	"ci": {Repeat: 5, Requests: 500, Concurrency: 20, Warmup: 1, Thresholds: []string{"error_rate < 1", "p95 < 1000ms"}},
+}
+
+// applyProfile presets c with the named profile, looked up in c's
+// profiles before the built-in ones.
+func applyProfile(c *Config, name string) error {
+	p, ok := c.Profiles[name]
+	if !ok {
+		if p, ok = builtinProfiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q, want one of %s", name, strings.Join(profileNames(*c), ", "))
+		}
+	}
+	if p.Repeat < 0 || p.Requests < 0 || p.Concurrency < 0 || p.Warmup < 0 {
+		return fmt.Errorf("profile %s: counts can't be negative", name)
+	}
+	if p.Repeat > 0 {
+		c.Repeat = p.Repeat
+	}
+	if p.Requests > 0 {
+		c.Requests = p.Requests
+	}
+	if p.Concurrency > 0 {
+		c.Concurrency = p.Concurrency
+	}
+	if p.Warmup > 0 {
+		c.Warmup = p.Warmup
+	}
+	if p.Cooldown != "" {
+		c.Cooldown = p.Cooldown
+	}
+	c.Thresholds = append(c.Thresholds, p.Thresholds...)
+	return nil
+}
+
+// profileNames lists the profiles c can select, sorted.
+func profileNames(c Config) []string {
+	var names []string
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	for name := range c.Profiles {
+		if _, ok := builtinProfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
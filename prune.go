@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var suiteDirRe = regexp.MustCompile(`^[0-9a-f]{8}-(\d{8}T\d{6})$`)
+
+// suiteEntry is one suite known to prune, a directory or a suite's rows
+// in the results store.
+type suiteEntry struct {
+	name    string
+	started time.Time
+}
+
+// runPrune implements `prune`: it deletes suite directories under
+// --results-dir and suites' rows in the results store beyond the newest
+// --keep-last and/or older than --older-than, optionally archiving them
+// first.
+func runPrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	config := fs.String("config", "", "config whose results store to prune")
+	root := fs.String("results-dir", "results", "directory holding the suite directories")
+	store := fs.String("store", "", "results store to prune (default: the config's, or "+defaultStore+" if it exists)")
+	keepLast := fs.Int("keep-last", 0, "keep the newest N suites")
+	olderThan := fs.String("older-than", "", "prune suites older than this, e.g. 30d, 2w or 12h")
+	archive := fs.String("archive", "", "first bundle what's pruned into this .tar.gz, or upload it to an s3:// or gs:// URL")
+	dryRun := fs.Bool("dry-run", false, "only list what would be pruned")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	var age time.Duration
+	if *olderThan != "" {
+		if age, err = parseAge(*olderThan); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	if *keepLast <= 0 && age == 0 {
+		fmt.Println("❌ Nothing to prune by: give --keep-last and/or --older-than")
+		os.Exit(1)
+	}
+	if *store == "" {
+		*store = c.Store
+	}
+	if *store == "" {
+		if _, err := os.Stat(defaultStore); err == nil {
+			*store = defaultStore
+		}
+	}
+
+	now := time.Now()
+	dirs, err := listSuiteDirs(*root)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	var pruneDirs []string
+	for _, s := range selectPrune(dirs, *keepLast, age, now) {
+		pruneDirs = append(pruneDirs, filepath.Join(*root, s.name))
+	}
+
+	var kept, pruned [][]byte
+	if *store != "" {
+		if kept, pruned, err = splitStore(*store, *keepLast, age, now); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(pruneDirs) == 0 && len(pruned) == 0 {
+		fmt.Println("✅ Nothing to prune")
+		return
+	}
+	for _, d := range pruneDirs {
+		fmt.Println("→", d)
+	}
+	if len(pruned) > 0 {
+		fmt.Printf("→ %d rows of %s\n", len(pruned), *store)
+	}
+	if *dryRun {
+		fmt.Printf("✅ Would prune %d suite directories and %d store rows\n", len(pruneDirs), len(pruned))
+		return
+	}
+
+	if *archive != "" {
+		extra := map[string][]byte{}
+		if len(pruned) > 0 {
+			extra[filepath.Base(*store)] = bytes.Join(append(pruned, nil), []byte("\n"))
+		}
+		if err := saveArchive(*archive, pruneDirs, extra); err != nil {
+			fmt.Println("❌ Archiving failed, nothing was pruned:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Archived to %s\n", *archive)
+	}
+
+	for _, d := range pruneDirs {
+		if err := os.RemoveAll(d); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	if _, err := latestSuiteDir(*root); err != nil {
+		// it pointed at a pruned suite
+		os.Remove(filepath.Join(*root, "latest"))
+		os.Remove(filepath.Join(*root, "latest.txt"))
+	}
+	if len(pruned) > 0 {
+		if err := rewriteStore(*store, kept); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("✅ Pruned %d suite directories and %d store rows\n", len(pruneDirs), len(pruned))
+}
+
+// parseAge reads a Go duration, or a number of days or weeks like 30d or 2w.
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) && n > 0 {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q, want e.g. 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+// selectPrune returns the suites beyond the newest keepLast (if set) that
+// are also older than age (if set).
+func selectPrune(suites []suiteEntry, keepLast int, age time.Duration, now time.Time) []suiteEntry {
+	sort.Slice(suites, func(i, j int) bool { return suites[i].started.After(suites[j].started) })
+	var out []suiteEntry
+	for i, s := range suites {
+		if keepLast > 0 && i < keepLast {
+			continue
+		}
+		if age > 0 && now.Sub(s.started) < age {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func listSuiteDirs(root string) ([]suiteEntry, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []suiteEntry
+	for _, e := range entries {
+		m := suiteDirRe.FindStringSubmatch(e.Name())
+		if m == nil || !e.IsDir() {
+			continue
+		}
+		started, err := time.ParseInLocation("20060102T150405", m[1], time.Local)
+		if err != nil {
+			continue
+		}
+		out = append(out, suiteEntry{name: e.Name(), started: started})
+	}
+	return out, nil
+}
+
+// splitStore divides the store's lines into those kept and those of the
+// suites the policy prunes.
+func splitStore(path string, keepLast int, age time.Duration, now time.Time) (kept, pruned [][]byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	var suiteOf []string
+	started := map[string]time.Time{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		var rec StoreRecord
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &rec) != nil {
+			lines, suiteOf = append(lines, line), append(suiteOf, "")
+			continue
+		}
+		if t, ok := started[rec.Suite]; !ok || rec.Time.Before(t) {
+			started[rec.Suite] = rec.Time
+		}
+		lines, suiteOf = append(lines, line), append(suiteOf, rec.Suite)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var suites []suiteEntry
+	for name, t := range started {
+		suites = append(suites, suiteEntry{name: name, started: t})
+	}
+	drop := map[string]bool{}
+	for _, s := range selectPrune(suites, keepLast, age, now) {
+		drop[s.name] = true
+	}
+	for i, line := range lines {
+		if suiteOf[i] != "" && drop[suiteOf[i]] {
+			pruned = append(pruned, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	return kept, pruned, nil
+}
+
+func rewriteStore(path string, lines [][]byte) error {
+	tmp := path + ".tmp"
+	var data []byte
+	if len(lines) > 0 {
+		data = bytes.Join(append(lines, nil), []byte("\n"))
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
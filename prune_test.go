@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * day},
+		{"1d", day},
+		{"2w", 14 * day},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "0d", "-3d", "0", "0s", "-1h", "d", "w", "1.5d", "3y", "30 d"} {
+		if _, err := parseAge(in); err == nil {
+			t.Errorf("parseAge(%q) accepted it", in)
+		}
+	}
+}
+
+func TestSelectPrune(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	var suites []suiteEntry
+	for _, days := range []int{40, 1, 10, 20, 5} {
+		suites = append(suites, suiteEntry{started: now.AddDate(0, 0, -days)})
+	}
+	tests := []struct {
+		name     string
+		keepLast int
+		age      time.Duration
+		want     []int // days ago of the suites pruned, newest first
+	}{
+		{"keep last", 2, 0, []int{10, 20, 40}},
+		{"older than", 0, 15 * 24 * time.Hour, []int{20, 40}},
+		{"both", 4, 15 * 24 * time.Hour, []int{40}},
+		{"keep more than there are", 10, 0, nil},
+	}
+	for _, tt := range tests {
+		got := selectPrune(append([]suiteEntry(nil), suites...), tt.keepLast, tt.age, now)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: pruned %d suites, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, s := range got {
+			if want := now.AddDate(0, 0, -tt.want[i]); !s.started.Equal(want) {
+				t.Errorf("%s: suite %d started %v, want %v", tt.name, i, s.started, want)
+			}
+		}
+	}
+}
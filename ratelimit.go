@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A run is throttled when at least heavyThrottling percent of its
+// responses are 429 Too Many Requests: its throughput then measures the
+// target's rate limit, not its capacity.
+const heavyThrottling = 5.0
+
+// maxRetryAfter caps a single back-off, so a misbehaving Retry-After
+// can't stall the suite.
+const maxRetryAfter = time.Minute
+
+// parseRetryAfter reads a Retry-After header, delay-seconds or an
+// HTTP-date, as how long to wait; zero when absent or unreadable.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	var d time.Duration
+	if s, err := strconv.Atoi(h); err == nil {
+		d = time.Duration(s) * time.Second
+	} else if at, err := http.ParseTime(h); err == nil {
+		d = time.Until(at)
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
+
+// addThrottled stores the percentage of the run's responses that were
+// 429s in the row.
+func addThrottled(row map[string]string) {
+	codes, _, err := runStatuses(row["file"])
+	if err != nil {
+		return
+	}
+	total := 0
+	for _, count := range codes {
+		total += count
+	}
+	if total > 0 {
+		row["throttled"] = fmt.Sprintf("%.4f", 100*float64(codes[http.StatusTooManyRequests])/float64(total))
+	}
+}
+
+// waitRetryAfter pauses a virtual user after a 429, as long as its
+// Retry-After says, with --retry-after.
+func waitRetryAfter(s sample, opts nativeOptions) {
+	if opts.retryAfter && s.status == http.StatusTooManyRequests && s.retryAfter > 0 {
+		time.Sleep(s.retryAfter)
+	}
+}
+
+func throttledRun(row map[string]string) bool {
+	share, ok := rowFloat(row, "throttled")
+	return ok && share >= heavyThrottling
+}
+
+// backOff waits out the target's rate limit before the next run, as long
+// as the Retry-After of one probe request says, when it's still throttled.
+func backOff(t Target) {
+	opts := suiteOptions()
+	client := newVUClient(newNativeTransport(1, opts), timeoutFor(t), opts)
+	s := doRequest(client, t)
+	if s.status != http.StatusTooManyRequests || s.retryAfter == 0 {
+		return
+	}
+	fmt.Printf("→ Backing off %v per Retry-After before the next run\n", s.retryAfter)
+	time.Sleep(s.retryAfter)
+}
+
+// analyzeThrottling reports the targets whose runs were rate limited, with
+// the warning that their throughput isn't their capacity.
+func analyzeThrottling(rows []map[string]string) {
+	type counts struct {
+		runs, throttled int
+		shares          []float64
+	}
+	byKey := map[string]*counts{}
+	for _, row := range rows {
+		share, ok := rowFloat(row, "throttled")
+		if !ok || row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if byKey[key] == nil {
+			byKey[key] = &counts{}
+		}
+		c := byKey[key]
+		c.runs++
+		c.shares = append(c.shares, share)
+		if share >= heavyThrottling {
+			c.throttled++
+		}
+	}
+	var keys []string
+	for k, c := range byKey {
+		if c.throttled > 0 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	var table [][]string
+	for _, k := range keys {
+		c := byKey[k]
+		peak := 0.0
+		for _, s := range c.shares {
+			peak = max(peak, s)
+		}
+		table = append(table, []string{k, fmt.Sprintf("%d of %d", c.throttled, c.runs),
+			fmt.Sprintf("%.1f%%", mean(c.shares)), fmt.Sprintf("%.1f%%", peak)})
+		fmt.Printf("⚠️ %s was rate limited in %d of %d runs; its throughput reflects the limit, not its capacity\n", k, c.throttled, c.runs)
+	}
+	addReportSection("Rate limiting",
+		fmt.Sprintf("⚠️ These targets answered at least %.0f%% of a run's requests with 429 Too Many Requests. "+
+			"Their throughput and latency measure the rate limiter in front of them, not what the service can handle: "+
+			"raise the limit for the load test, or lower the load below it.\n\n", heavyThrottling)+
+			markdownTable([]string{"target", "throttled runs", "mean 429 share", "peak 429 share"}, table))
+}
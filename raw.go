@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Raw latency files use hey's `-o csv` column names so files captured from
+// either engine read the same way. The native engine adds size and error.
+var rawHeaders = []string{"response-time", "status-code", "offset", "size", "error"}
+
+func rawFileName(slug string, i int) string {
+	return "hey_raw_" + runStem(slug, i) + ".csv"
+}
+
+func writeRawLatencies(file string, samples []sample) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	w.Write(rawHeaders)
+	for _, s := range samples {
+		w.Write([]string{
+			strconv.FormatFloat(s.latency.Seconds(), 'f', 6, 64),
+			strconv.Itoa(s.status),
+			strconv.FormatFloat(s.offset.Seconds(), 'f', 6, 64),
+			strconv.FormatInt(s.size, 10),
+			s.err,
+		})
+	}
+	return w.Error()
+}
+
+// readRawLatencies loads a raw file written by writeRawLatencies or by
+// `hey -o csv`, returning the samples and the wall time they span.
+func readRawLatencies(file string) ([]sample, time.Duration, error) {
+	var samples []sample
+	span, err := streamRawLatencies(file, func(s sample) { samples = append(samples, s) })
+	return samples, span, err
+}
+
+// streamRawLatencies calls fn with each sample of a raw file in turn,
+// without holding them in memory, and returns the wall time they span.
+func streamRawLatencies(file string, fn func(sample)) (time.Duration, error) {
+	f, err := openOutput(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	headers, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", file, err)
+	}
+	index := map[string]int{}
+	for i, h := range headers {
+		index[h] = i
+	}
+	col := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	if _, ok := index["response-time"]; !ok {
+		return 0, fmt.Errorf("%s: no response-time column", file)
+	}
+
+	var span time.Duration
+	for n := 0; ; n++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a partial run would skew every metric computed from it
+			return span, fmt.Errorf("%s: %w, after %d rows", file, err, n)
+		}
+		s := sample{
+			latency: secondsToDuration(col(row, "response-time")),
+			offset:  secondsToDuration(col(row, "offset")),
+			err:     col(row, "error"),
+		}
+		s.status, _ = strconv.Atoi(col(row, "status-code"))
+		s.size, _ = strconv.ParseInt(col(row, "size"), 10, 64)
+		if end := s.offset + s.latency; end > span {
+			span = end
+		}
+		fn(s)
+	}
+	return span, nil
+}
+
+// writeStreamedSummary writes the hey-format summary of a raw file in
+// constant memory: one pass feeds a t-digest for the percentiles, a
+// second counts the histogram buckets.
+func writeStreamedSummary(file, rawFile string) (runSummary, error) {
+	sum := runSummary{statuses: map[int]int{}, errs: map[string]int{}}
+	digest := NewTDigest(200)
+	span, err := streamRawLatencies(rawFile, func(s sample) {
+		if sum.add(s) {
+			digest.Add(s.latency.Seconds())
+		}
+	})
+	if err != nil {
+		return sum, err
+	}
+	sum.total = span
+	sum.quantile = func(p float64) float64 { return digest.Quantile(p / 100) }
+	if sum.count > 0 {
+		sum.marks = histogramMarks(sum.fastest, sum.slowest)
+		sum.counts = make([]int, len(sum.marks))
+		_, err := streamRawLatencies(rawFile, func(s sample) {
+			if s.err == "" {
+				sum.counts[bucketOf(sum.marks, s.latency.Seconds())]++
+			}
+		})
+		if err != nil {
+			return sum, err
+		}
+	}
+	return sum, sum.write(file)
+}
+
+func secondsToDuration(s string) time.Duration {
+	v, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(v * float64(time.Second))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRawLatencies(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	good := write("good.csv", "response-time,DNS+dialup,DNS,Request-write,Response-delay,Response-read,status-code,offset\n"+
+		"0.0100,0,0,0,0,0,200,0.0000\n"+
+		"0.0300,0,0,0,0,0,500,0.0200\n")
+	samples, span, err := readRawLatencies(good)
+	if err != nil {
+		t.Fatalf("readRawLatencies: %v", err)
+	}
+	if len(samples) != 2 || samples[1].status != 500 || samples[0].latency != 10*time.Millisecond {
+		t.Errorf("readRawLatencies = %+v", samples)
+	}
+	if span != 50*time.Millisecond {
+		t.Errorf("span = %v, want 50ms", span)
+	}
+
+	bad := write("bad.csv", "response-time,status-code,offset\n"+
+		"0.0100,200,0.0000\n"+
+		"0.0200,\"200,0.0100\n")
+	if _, _, err := readRawLatencies(bad); err == nil || !strings.Contains(err.Error(), "after 1 rows") {
+		t.Errorf("readRawLatencies of a malformed file: %v, want an error after 1 row", err)
+	}
+
+	if _, _, err := readRawLatencies(write("nocol.csv", "status-code\n200\n")); err == nil {
+		t.Error("readRawLatencies of a file without response-time: want an error")
+	}
+}
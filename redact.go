@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Header and query parameter names whose values are secrets: any name
+// containing one of sensitiveParts, or equal to one of sensitiveNames,
+// plus those in the config's "redact".
+var (
+	sensitiveParts = []string{"auth", "token", "secret", "password", "passwd", "cookie", "session", "credential", "api-key", "api_key", "apikey", "signature"}
+	sensitiveNames = map[string]bool{"key": true, "sig": true, "code": true, "jwt": true}
+)
+
+func sensitiveName(name string) bool {
+	n := strings.ToLower(name)
+	if sensitiveNames[n] {
+		return true
+	}
+	for _, part := range sensitiveParts {
+		if strings.Contains(n, part) {
+			return true
+		}
+	}
+	for _, extra := range cfg.Redact {
+		if strings.EqualFold(extra, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveHeader is the stricter sensitiveName for text that merely looks
+// like a header: one of the words of the name, split at - and _, must be
+// sensitive on its own, so Authorization and X-Auth-Token are but a log's
+// "authority: ..." isn't.
+func sensitiveHeader(name string) bool {
+	for _, extra := range cfg.Redact {
+		if strings.EqualFold(extra, name) {
+			return true
+		}
+	}
+	for _, w := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == '-' || r == '_' }) {
+		if sensitiveNames[w] || w == "authorization" || contains(sensitiveParts, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValues are the secrets the targets carry, replaced wherever they
+// turn up: sensitive header values, URL passwords and sensitive query
+// parameters, as registered by registerSecrets.
+var secretValues []string
+
+// registerSecrets collects the secrets of targets, the config's chaos
+// and error budget webhooks, its lifecycle webhooks and its MQTT broker,
+// so redact removes them from every output.
+func registerSecrets(targets []Target) {
+	add := func(v string) {
+		// too short a value would redact innocent text
+		if len(v) >= 4 {
+			secretValues = append(secretValues, v)
+		}
+	}
+	addURL := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return
+		}
+		if pass, ok := u.User.Password(); ok {
+			add(pass)
+		}
+		for name, vs := range u.Query() {
+			if sensitiveName(name) {
+				for _, v := range vs {
+					add(v)
+				}
+			}
+		}
+	}
+	for _, t := range targets {
+		addURL(t.URL)
+		for _, h := range t.Headers {
+			if sensitiveName(h.Name) {
+				add(h.Value)
+				// "Bearer abc" may also appear as just abc
+				if _, cred, ok := strings.Cut(h.Value, " "); ok {
+					add(cred)
+				}
+			}
+		}
+	}
+	for _, a := range cfg.Chaos {
+		addURL(a.Webhook)
+	}
+	if cfg.ErrorBudget != nil {
+		addURL(cfg.ErrorBudget.Webhook)
+	}
+	for i := range cfg.Webhooks {
+		h := &cfg.Webhooks[i]
+		addURL(h.URL)
+		add(h.secret())
+		for name, v := range h.Headers {
+			if sensitiveName(name) {
+				add(v)
+			}
+		}
+	}
+	if cfg.MQTT != nil {
+		addURL(cfg.MQTT.Broker)
+		if cfg.MQTT.PasswordEnv != "" {
+			add(os.Getenv(cfg.MQTT.PasswordEnv))
+		}
+	}
+}
+
+var (
+	secretParamRe  = regexp.MustCompile(`([?&;])([A-Za-z0-9_.\-]+)=([^&\s"'#]+)`)
+	secretHeaderRe = regexp.MustCompile(`(?m)(^[ \t]*|(?:^|\s)(?:-H|--header)(?:\s+|=)?)(['"]?)([A-Za-z0-9_\-]+)[ \t]*:[ \t]*`)
+	userInfoRe     = regexp.MustCompile(`(://[^/:@\s]+:)[^@/\s]+@`)
+	curlUserRe     = regexp.MustCompile(`(\s|^)(-u|--user)(\s+|=)('[^']*'|"[^"]*"|\S+)`)
+)
+
+// redact removes secrets from s before it's logged or written anywhere:
+// the registered values, sensitive query parameters and headers, URL
+// passwords and curl's -u credentials, even those of targets never
+// registered.
+func redact(s string) string {
+	for _, v := range secretValues {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	s = secretParamRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := secretParamRe.FindStringSubmatch(m)
+		if !sensitiveName(p[2]) {
+			return m
+		}
+		return p[1] + p[2] + "=" + redacted
+	})
+	s = redactHeaders(s)
+	s = userInfoRe.ReplaceAllString(s, "${1}"+redacted+"@")
+	return curlUserRe.ReplaceAllString(s, "${1}${2}${3}"+redacted)
+}
+
+// redactHeaders replaces the values of sensitive headers in s, where s
+// has a header at the start of a line or as curl's -H, up to where each
+// value ends: its closing quote, the end of an unquoted -H argument, or
+// else the end of the line.
+func redactHeaders(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range secretHeaderRe.FindAllStringSubmatchIndex(s, -1) {
+		start, quote, name := m[1], s[m[4]:m[5]], s[m[6]:m[7]]
+		if m[0] < last || !sensitiveHeader(name) {
+			continue
+		}
+		var end int
+		switch {
+		case quote != "":
+			end = strings.Index(s[start:], quote)
+		case strings.TrimSpace(s[m[2]:m[3]]) != "":
+			end = strings.IndexAny(s[start:], " \t\r\n")
+		default:
+			end = strings.IndexAny(s[start:], "\r\n")
+		}
+		if end < 0 {
+			end = len(s) - start
+		}
+		if end == 0 || s[start:start+end] == redacted {
+			continue
+		}
+		b.WriteString(s[last:m[6]])
+		b.WriteString(name + ": " + redacted)
+		last = start + end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactRow redacts the text cells of a result row, such as its URL,
+// error and labels, before it reaches the CSVs, JSON, workbook, store and
+// reports. Numeric cells are metrics, and a secret that happens to be
+// digits mustn't punch holes in them.
+func redactRow(row map[string]string) {
+	for k, v := range row {
+		if !isNumber(v) {
+			row[k] = redact(v)
+		}
+	}
+}
+
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = redact(a)
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// originNames are the region names given to --agents or --ssh entries as
+// region=address, index-aligned with agents or sshHosts; "" if unnamed.
+var originNames []string
+
+// parseOrigins splits a comma-separated list of load origins, recording
+// the optional region= prefix of each in originNames.
+func parseOrigins(s string) []string {
+	var out []string
+	for _, o := range strings.Split(s, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		name := ""
+		if i := strings.Index(o, "="); i > 0 && !strings.Contains(o[:i], "/") {
+			name, o = o[:i], o[i+1:]
+		}
+		originNames = append(originNames, name)
+		out = append(out, o)
+	}
+	return out
+}
+
+// analyzeRegions builds the region × target matrix from the per-origin
+// results: a report table of p95 latency and RPS, and a heatmap of the
+// latency, showing how placement of the load affects each deployment.
+func analyzeRegions(filename string) {
+	metric := "p95"
+	if !hasPercentile(95) {
+		metric = "average"
+	}
+
+	var regions, targets []string
+	seenRegion, seenTarget := map[string]bool{}, map[string]bool{}
+	type cell struct{ latency, rps []float64 }
+	cells := map[[2]string]*cell{}
+	for _, row := range agentRows {
+		region, target := row["agent"], row["target"]
+		if len(cfg.Sweep) > 1 {
+			target += " c=" + row["concurrency"]
+		}
+		if !seenRegion[region] {
+			seenRegion[region] = true
+			regions = append(regions, region)
+		}
+		if !seenTarget[target] {
+			seenTarget[target] = true
+			targets = append(targets, target)
+		}
+		c := cells[[2]string{region, target}]
+		if c == nil {
+			c = &cell{}
+			cells[[2]string{region, target}] = c
+		}
+		if v, ok := rowFloat(row, metric); ok {
+			c.latency = append(c.latency, v)
+		}
+		if v, ok := rowFloat(row, "requests_per_sec"); ok {
+			c.rps = append(c.rps, v)
+		}
+	}
+	if len(regions) < 2 {
+		return
+	}
+
+	// --targets only narrows the heatmap's columns
+	var shown []string
+	column := map[string]int{}
+	for _, target := range targets {
+		if seriesWanted(target) {
+			column[target] = len(shown)
+			shown = append(shown, target)
+		}
+	}
+	var table [][]string
+	var data []opts.HeatMapData
+	lo, hi := -1.0, 0.0
+	for y, region := range regions {
+		line := []string{region}
+		for _, target := range targets {
+			c := cells[[2]string{region, target}]
+			if c == nil || len(c.latency) == 0 {
+				line = append(line, "–")
+				continue
+			}
+			lat := inUnit(mean(c.latency))
+			line = append(line, fmt.Sprintf("%.4f %s · %.1f rps", lat, displayUnit, mean(c.rps)))
+			x, ok := column[target]
+			if !ok {
+				continue
+			}
+			name := fmt.Sprintf("%s → %s · %d runs", region, target, len(c.latency))
+			data = append(data, opts.HeatMapData{Name: name, Value: [3]interface{}{x, y, round4(lat)}})
+			if lo < 0 || lat < lo {
+				lo = lat
+			}
+			if lat > hi {
+				hi = lat
+			}
+		}
+		table = append(table, line)
+	}
+	addReportSection("Region × target matrix",
+		fmt.Sprintf("Mean %s latency and RPS of each load origin's share, per target.\n\n", metric)+
+			markdownTable(append([]string{"region"}, targets...), table))
+
+	hm := charts.NewHeatMap()
+	hm.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Latency by Region", withUnit(metric))),
+		charts.WithXAxisOpts(opts.XAxis{Type: "category", Data: shown}),
+		charts.WithYAxisOpts(opts.YAxis{Type: "category", Data: regions}),
+		charts.WithVisualMapOpts(opts.VisualMap{
+			Calculable: opts.Bool(true),
+			Min:        float32(lo),
+			Max:        float32(hi),
+			InRange:    &opts.VisualMapInRange{Color: []string{"#50a3ba", "#eac736", "#d94e5d"}},
+		}),
+		chartToolbox(),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Formatter: opts.FuncOpts(
+			"function (p) { return p.data.name + ': <b>' + p.value[2] + ' " + displayUnit + "</b>'; }")}),
+	)
+	hm.AddSeries(metric, data, charts.WithLabelOpts(opts.Label{Show: opts.Bool(true)}))
+
+	renderChart(hm, filename)
+}
+
+func hasPercentile(p float64) bool {
+	for _, q := range cfg.Percentiles {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportSection is one block of the suite report. Analyses append their
+// findings with addReportSection and main writes them out at the end.
+type ReportSection struct {
+	Title string
+	Body  string
+}
+
+var reportSections []ReportSection
+
+func addReportSection(title, body string) {
+	reportSections = append(reportSections, ReportSection{Title: redact(title), Body: redact(body)})
+}
+
+// ReportData is what report templates are executed with, the built-in
+// ones and those given with --report-template.
+type ReportData struct {
+	Title       string
+	SuiteID     string
+	Started     time.Time
+	Labels      string            // "k=v, k=v"
+	LabelValues map[string]string // the same labels by key
+	Unit        string            // the display unit of latencies, ms or s
+	LatencyName string            // p95, or average without a p95
+	Sections    []ReportSection   // the findings of every analysis, in markdown
+	Cards       []kpiCard         // one per series, the first being the baseline
+	Charts      []string          // chart file names, relative to the suite directory
+	Runs        []reportRun       // every run, linking to its files
+	Rows        []map[string]string
+}
+
+// reportFuncs are the functions report templates can call besides the
+// standard ones.
+var reportFuncs = map[string]interface{}{
+	"withUnit": withUnit,
+	// display formats a stored cell in its display unit
+	"display": func(metric, v string) string {
+		f, ok := rowFloat(map[string]string{metric: v}, metric)
+		if !ok {
+			return v
+		}
+		return fmt.Sprintf("%.4g", displayValue(metric, f))
+	},
+}
+
+var markdownReportTemplate = template.Must(template.New("report.md").Funcs(reportFuncs).Parse(
+	"# Performance comparison report\n" +
+		"{{if .Labels}}\nLabels: {{.Labels}}\n{{end}}" +
+		"{{range .Sections}}\n## {{.Title}}\n\n{{.Body}}\n{{end}}"))
+
+// customReports are the --report-template files, a .html one replacing
+// report.html and any other report.md.
+var customReports = map[string]interface{ Execute(io.Writer, any) error }{}
+
+func loadReportTemplates(paths []string) error {
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		if strings.Contains(name, ".html") {
+			t, err := htmltemplate.New(name).Funcs(reportFuncs).Parse(string(raw))
+			if err != nil {
+				return err
+			}
+			customReports["report.html"] = t
+			continue
+		}
+		t, err := template.New(name).Funcs(reportFuncs).Parse(string(raw))
+		if err != nil {
+			return err
+		}
+		customReports["report.md"] = t
+	}
+	return nil
+}
+
+// newReportData gathers the suite's results for the report templates.
+func newReportData(started time.Time, rows []map[string]string) ReportData {
+	d := ReportData{
+		Title:       "Performance comparison",
+		SuiteID:     suiteID,
+		Started:     started,
+		Labels:      labelString(),
+		LabelValues: labels,
+		Unit:        displayUnit,
+		LatencyName: "average",
+		Rows:        rows,
+	}
+	if hasPercentile(95) {
+		d.LatencyName = "p95"
+	}
+	for _, s := range reportSections {
+		d.Sections = append(d.Sections, ReportSection{Title: s.Title, Body: strings.TrimRight(s.Body, "\n")})
+	}
+	d.Cards = kpiCards(rows, d.LatencyName)
+	d.Runs = reportRuns(rows, d.LatencyName)
+	charts, _ := filepath.Glob(suiteFile("chart_*.html"))
+	for _, c := range charts {
+		d.Charts = append(d.Charts, filepath.Base(c))
+	}
+	return d
+}
+
+// writeTemplate executes the report named name, a custom one if given,
+// into filename.
+func writeTemplate(filename, name string, builtin interface{ Execute(io.Writer, any) error }, data ReportData) error {
+	t := builtin
+	if custom, ok := customReports[name]; ok {
+		t = custom
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeReport(filename string, data ReportData) error {
+	if len(data.Sections) == 0 {
+		return nil
+	}
+	if err := writeTemplate(filename, "report.md", markdownReportTemplate, data); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Report written to %s\n", filename)
+	return nil
+}
+
+// markdownTable renders rows under headers as a GitHub-flavoured table.
+func markdownTable(headers []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
+	for _, r := range rows {
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+	}
+	return b.String()
+}
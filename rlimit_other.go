@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// fileLimit reports no limit on platforms whose limits aren't read.
+func fileLimit() (soft, hard uint64, ok bool) {
+	return 0, 0, false
+}
+
+func raiseFileLimit(n uint64) error {
+	return errors.New("open-file limits aren't supported on this platform")
+}
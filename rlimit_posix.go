@@ -0,0 +1,25 @@
+//go:build linux || darwin
+
+package main
+
+import "syscall"
+
+// fileLimit returns the soft and hard limits on open files.
+func fileLimit() (soft, hard uint64, ok bool) {
+	var l syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &l); err != nil {
+		return 0, 0, false
+	}
+	return uint64(l.Cur), uint64(l.Max), true
+}
+
+// raiseFileLimit sets the soft limit on open files to n, which hey
+// processes started afterwards inherit.
+func raiseFileLimit(n uint64) error {
+	var l syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &l); err != nil {
+		return err
+	}
+	l.Cur = n
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &l)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// suiteID identifies one invocation of the suite. With a target's slug and
+// the run index it gives every run an ID that stays the same however often
+// its output is parsed, merged or appended, so a run can't be counted twice.
+var suiteID = newSuiteID()
+
+// newSuiteID returns a random (version 4) UUID.
+func newSuiteID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func runID(slug string, i int) string {
+	return fmt.Sprintf("%s-%s-%d", suiteID, slug, i)
+}
+
+// runStem names run i's output files: the slug, index and short suite ID,
+// so files of different suites never collide.
+func runStem(slug string, i int) string {
+	return fmt.Sprintf("%s_%d_%s", slug, i, suiteID[:8])
+}
+
+// dedupeRuns returns the rows whose run ID isn't in seen yet and adds them
+// to it. Rows without an ID, written before run IDs existed, are all kept.
+func dedupeRuns(rows []map[string]string, seen map[string]bool) (kept []map[string]string, dropped int) {
+	for _, row := range rows {
+		id := row["run_id"]
+		if id != "" && seen[id] {
+			dropped++
+			continue
+		}
+		if id != "" {
+			seen[id] = true
+		}
+		kept = append(kept, row)
+	}
+	return kept, dropped
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// job is one unit of work repeated for every test run: a single target, a
+// deployment's whole weighted mix when the native engine drives it, or a
+// deployment's scenario steps.
+type job struct {
+	name    string
+	targets []Target
+}
+
+func (j job) label() string {
+	if len(j.targets) == 1 {
+		return j.targets[0].label()
+	}
+	if *abMode {
+		return fmt.Sprintf("A/B of %s vs %s", j.targets[0].label(), j.targets[1].label())
+	}
+	if j.scenario() {
+		return fmt.Sprintf("%s (scenario of %d steps)", j.name, len(j.targets))
+	}
+	return fmt.Sprintf("%s (mix of %d requests)", j.name, len(j.targets))
+}
+
+// withConcurrency returns j running at c, the sweep level, except for
+// targets whose own concurrency overrides it.
+func (j job) withConcurrency(c int) job {
+	out := job{name: j.name}
+	for _, t := range j.targets {
+		if t.Concurrency == 0 {
+			t.Concurrency = c
+		}
+		out.targets = append(out.targets, t)
+	}
+	return out
+}
+
+// atConcurrency is withConcurrency with output file names suffixed by the
+// sweep level so runs at different concurrency don't overwrite each other.
+func (j job) atConcurrency(c int) job {
+	out := j.withConcurrency(c)
+	for k := range out.targets {
+		out.targets[k].Slug = fmt.Sprintf("%s_c%d", out.targets[k].Slug, c)
+	}
+	return out
+}
+
+func (j job) scenario() bool {
+	return j.targets[0].Step > 0
+}
+
+// planJobs groups targets into jobs. Scenarios always run on the native
+// engine since hey can't chain requests. An A/B experiment is a single job
+// of both targets.
+func planJobs(targets []Target, engine string) []job {
+	if *abMode {
+		return []job{{name: "A/B", targets: targets}}
+	}
+	var jobs []job
+	mixes := map[string]int{}
+	for _, t := range targets {
+		if t.Step == 0 && (engine != "native" || t.Weight <= 0) {
+			jobs = append(jobs, job{name: t.Name, targets: []Target{t}})
+			continue
+		}
+		if i, ok := mixes[t.Name]; ok {
+			jobs[i].targets = append(jobs[i].targets, t)
+			continue
+		}
+		mixes[t.Name] = len(jobs)
+		jobs = append(jobs, job{name: t.Name, targets: []Target{t}})
+	}
+	return jobs
+}
+
+// runJob executes run i of j and returns the parsed result rows: one per
+// target, plus an aggregate row for native mixes.
+func runJob(j job, all []Target, engine string, i int) ([]map[string]string, error) {
+	j = j.withRunTokens()
+	if engine != "native" && !j.scenario() {
+		t := j.targets[0]
+		n := requestsFor(t, all)
+		if len(sshHosts) > 0 {
+			run, err := runSSH(t, n, i)
+			if err != nil {
+				return nil, err
+			}
+			row, err := writeNativeRun(t, i, run.samples, run.total)
+			if err != nil {
+				return nil, err
+			}
+			row["requests"] = strconv.Itoa(n)
+			return []map[string]string{row}, nil
+		}
+		file, data, err := runHey(t, n, i)
+		if err != nil {
+			return nil, err
+		}
+		row := resultRow(data, file, t, i)
+		row["requests"] = strconv.Itoa(n)
+		return []map[string]string{row}, nil
+	}
+
+	// a mix shares its deployment's overrides, so the first target's apply
+	lead := j.targets[0]
+	n := requestsFor(lead, all)
+	switch {
+	case j.scenario():
+		n /= len(j.targets)
+		if n < 1 {
+			n = 1
+		}
+	case len(j.targets) > 1 && lead.Requests > 0:
+		n = lead.Requests
+	case len(j.targets) > 1:
+		n = cfg.Requests
+	}
+	var run nativeRun
+	if len(agents) > 0 {
+		var err error
+		if run, err = runDistributed(j, n, i); err != nil {
+			return nil, err
+		}
+	} else {
+		run = runLocal(j.targets, n, concurrencyFor(lead), cfg.Rate, suiteOptions())
+	}
+
+	var rows []map[string]string
+	for ti, t := range j.targets {
+		row, err := writeNativeRun(t, i, run.filter(ti), run.total)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Autoscale != nil {
+			recordAutoscale(rowSeriesKey(row), run.filter(ti))
+		}
+		if cfg.ColdStart != nil {
+			recordColdStart(rowSeriesKey(row), run.filter(ti))
+		}
+		// each scenario iteration runs every step once, while a mix
+		// member's share of n is random
+		switch {
+		case len(j.targets) == 1 || j.scenario():
+			row["requests"] = strconv.Itoa(n)
+		case *abMode:
+			row["requests"] = strconv.Itoa(share(n, len(j.targets), ti))
+		}
+		if cfg.Autoscale != nil || cfg.ColdStart != nil {
+			row["requests"] = strconv.Itoa(len(run.filter(ti))) // the profile sets the count
+		}
+		rows = append(rows, row)
+	}
+	if len(j.targets) > 1 && !*abMode {
+		mix := Target{Name: j.name, URL: j.name, Slug: slugifyURL(j.name) + "_mix",
+			Concurrency: lead.Concurrency, Timeout: lead.Timeout}
+		row, err := writeNativeRun(mix, i, run.samples, run.total)
+		if err != nil {
+			return nil, err
+		}
+		if j.scenario() {
+			row["requests"] = strconv.Itoa(n * len(j.targets))
+		} else {
+			row["requests"] = strconv.Itoa(n)
+		}
+		row[aggregateKey] = "1"
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// runLocal drives the native engine on this machine: n scenario iterations
+// or n requests, closed-loop or at a constant rate.
+func runLocal(targets []Target, n, c int, rate float64, opts nativeOptions) nativeRun {
+	switch {
+	case targets[0].Step > 0:
+		return runScenario(targets, n, c, opts)
+	case cfg.Autoscale != nil:
+		return runNativeSchedule(targets, c, cfg.Autoscale.offsets(), opts)
+	case cfg.ColdStart != nil:
+		return runColdStart(targets, cfg.ColdStart, opts)
+	case rate > 0:
+		return runNativeRate(targets, n, c, rate, opts)
+	default:
+		return runNative(targets, n, c, opts)
+	}
+}
+
+// writeNativeRun stores the run's summary (and raw latencies with --raw)
+// and returns its result row.
+func writeNativeRun(t Target, i int, samples []sample, total time.Duration) (map[string]string, error) {
+	file := filepath.Join(outDir, "hey_result_"+runStem(t.Slug, i)+".txt")
+	sum, err := writeHeySummary(file, samples, total)
+	if err != nil {
+		return nil, err
+	}
+	if *rawCapture {
+		if err := writeRawLatencies(filepath.Join(outDir, rawFileName(t.Slug, i)), samples); err != nil {
+			return nil, err
+		}
+	}
+	row := resultRow(sum.metrics(file), file, t, i)
+	if *engine == "native" {
+		addConnStats(row, samples) // hey, here over SSH, doesn't report connections
+	}
+	return row, nil
+}
+
+// resultRow completes a run's metrics, parsed from or computed alongside
+// its summary file, into a result row.
+func resultRow(data map[string]string, file string, t Target, i int) map[string]string {
+	data["run_id"] = runID(t.Slug, i)
+	data["url"] = t.URL
+	data["target"] = t.Name
+	data["route"] = t.Route
+	data["concurrency"] = strconv.Itoa(concurrencyFor(t))
+	data["method"] = t.Method
+	data["timeout"] = strconv.FormatFloat(millis(timeoutFor(t)), 'f', 0, 64)
+	if t.Family != "" {
+		data["family"] = familyName(t.Family)
+	}
+	if cacheBusting != nil {
+		data["cache"] = "cached"
+		if t.Bust != nil {
+			data["cache"] = "uncached"
+		}
+	}
+	addSpread(data)
+	if *rawCapture {
+		data["raw_file"] = rawFileName(t.Slug, i)
+	}
+	if gz, err := compressOutput(file); err == nil {
+		data["file"] = filepath.Base(gz)
+	}
+	if data["raw_file"] != "" {
+		if gz, err := compressOutput(filepath.Join(outDir, data["raw_file"])); err == nil {
+			data["raw_file"] = filepath.Base(gz)
+		}
+	}
+	return data
+}
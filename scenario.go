@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scenario is an ordered list of steps a virtual user performs on every
+// iteration. Values extracted from one response are available to later
+// steps as {{name}} in the path, headers and body.
+//
+//	{
+//	  "steps": [
+//	    {"name": "login", "method": "POST", "path": "/login", "body": "{\"user\":\"a\"}",
+//	     "extract": {"token": "json:token"}},
+//	    {"name": "list", "path": "/persons", "headers": {"Authorization": "Bearer {{token}}"},
+//	     "extract": {"id": "json:items.0.id"}},
+//	    {"name": "detail", "path": "/persons/{{id}}"}
+//	  ]
+//	}
+//
+// Extraction rules are json:<dot.path>, header:<Name> or regex:<expr with
+// one group>.
+type Scenario struct {
+	Steps []ScenarioStep `json:"steps"`
+}
+
+type ScenarioStep struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Extract map[string]string `json:"extract"`
+}
+
+func loadScenario(path string, bases map[string]string) ([]Target, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(raw, &sc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("%s defines no steps", path)
+	}
+	for i, st := range sc.Steps {
+		for name, rule := range st.Extract {
+			if _, err := compileExtract(rule); err != nil {
+				return nil, fmt.Errorf("step %d extract %s: %w", i+1, name, err)
+			}
+		}
+	}
+
+	var names []string
+	for name := range bases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []Target
+	for _, name := range names {
+		for i, st := range sc.Steps {
+			method := strings.ToUpper(st.Method)
+			if method == "" {
+				method = "GET"
+			}
+			stepName := st.Name
+			if stepName == "" {
+				stepName = fmt.Sprintf("step %d", i+1)
+			}
+			t := Target{
+				Name:    name,
+				Route:   fmt.Sprintf("%d. %s", i+1, stepName),
+				Method:  method,
+				URL:     strings.TrimRight(bases[name], "/") + st.Path,
+				Body:    st.Body,
+				Extract: st.Extract,
+				Step:    i + 1,
+			}
+			var keys []string
+			for k := range st.Headers {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				t.Headers = append(t.Headers, Header{Name: k, Value: st.Headers[k]})
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+type extractor func(resp *http.Response, body []byte) (string, bool)
+
+func compileExtract(rule string) (extractor, error) {
+	kind, arg, ok := strings.Cut(rule, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid rule %q, want json:, header: or regex:", rule)
+	}
+	switch kind {
+	case "json":
+		return func(_ *http.Response, body []byte) (string, bool) {
+			return jsonPath(body, arg)
+		}, nil
+	case "header":
+		return func(resp *http.Response, _ []byte) (string, bool) {
+			v := resp.Header.Get(arg)
+			return v, v != ""
+		}, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("regex %q needs a capture group", arg)
+		}
+		return func(_ *http.Response, body []byte) (string, bool) {
+			m := re.FindSubmatch(body)
+			if m == nil {
+				return "", false
+			}
+			return string(m[1]), true
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown extraction kind %q", kind)
+}
+
+func jsonPath(body []byte, path string) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	default:
+		raw, _ := json.Marshal(val)
+		return string(raw), true
+	}
+}
+
+// runScenario has c virtual users walk the steps in order until
+// iterations scenario runs have been started. A failed step or extraction
+// ends that user's current iteration.
+func runScenario(steps []Target, iterations, c int, opts nativeOptions) nativeRun {
+	if c > iterations {
+		c = iterations
+	}
+	transport := newNativeTransport(c, opts)
+
+	extractors := make([]map[string]extractor, len(steps))
+	for i, st := range steps {
+		extractors[i] = map[string]extractor{}
+		for name, rule := range st.Extract {
+			extractors[i][name], _ = compileExtract(rule)
+		}
+	}
+
+	var remaining int64 = int64(iterations)
+	results := make([][]sample, c)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			client := newVUClient(transport, timeoutFor(steps[0]), opts)
+			for atomic.AddInt64(&remaining, -1) >= 0 {
+				vars := map[string]string{}
+				for i, st := range steps {
+					at := time.Since(start)
+					s := doStep(client, st, vars, extractors[i])
+					s.target = i
+					s.offset = at
+					results[w] = append(results[w], s)
+					waitRetryAfter(s, opts)
+					if s.err != "" {
+						break
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	run := nativeRun{total: time.Since(start)}
+	for _, r := range results {
+		run.samples = append(run.samples, r...)
+	}
+	return run
+}
+
+func doStep(client *http.Client, t Target, vars map[string]string, extract map[string]extractor) sample {
+	if t.Bust != nil {
+		t = t.Bust.apply(t)
+	}
+	var body io.Reader
+	if t.Body != "" {
+		body = strings.NewReader(substituteVars(t.Body, vars))
+	}
+	req, err := http.NewRequest(t.Method, substituteVars(t.URL, vars), body)
+	if err != nil {
+		return sample{err: err.Error()}
+	}
+	for _, h := range t.Headers {
+		req.Header.Set(h.Name, substituteVars(h.Value, vars))
+	}
+	var conn connTrace
+	req = conn.attach(withFamily(req, t))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return conn.record(sample{latency: time.Since(start), err: err.Error()})
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	s := conn.record(sample{latency: time.Since(start), status: resp.StatusCode, size: int64(len(raw))})
+	if s.status == http.StatusTooManyRequests {
+		s.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+
+	for name, ex := range extract {
+		v, ok := ex(resp, raw)
+		if !ok {
+			s.err = fmt.Sprintf("extract %s: no match", name)
+			return s
+		}
+		vars[name] = v
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Schedule runs a suite on a cron expression. Args are extra flags for
+// that suite, e.g. a nightly full sweep and an hourly smoke test:
+//
+//	"schedules": [
+//	  {"name": "nightly", "cron": "0 2 * * *", "args": ["--sweep", "10,50,100,200"]},
+//	  {"name": "hourly-smoke", "cron": "@hourly", "args": ["--config", "smoke.json"]}
+//	]
+type Schedule struct {
+	Name string   `json:"name"`
+	Cron string   `json:"cron"`
+	Args []string `json:"args"`
+}
+
+const defaultStore = "results_store.jsonl"
+
+// runScheduler implements `schedule --config suites.json`: it waits for
+// each schedule's next slot and runs the suite as a child process, so
+// every run starts from a clean state, tagged with the schedule name in
+// the results store. Runs never overlap; a slot missed while another suite
+// was running is skipped.
+func runScheduler(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	config := fs.String("config", "", "config file defining \"schedules\"; also passed to every suite")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	if len(c.Schedules) == 0 {
+		fmt.Println("❌ No schedules defined in the config")
+		os.Exit(1)
+	}
+	store := c.Store
+	if store == "" {
+		store = defaultStore
+	}
+
+	crons := make([]cronSchedule, len(c.Schedules))
+	for i, s := range c.Schedules {
+		if crons[i], err = parseCron(s.Cron); err != nil {
+			fmt.Printf("❌ Schedule %s: %v\n", s.Name, err)
+			os.Exit(1)
+		}
+	}
+	var budget *budgetMonitor
+	if c.ErrorBudget != nil {
+		if budget, err = newBudgetMonitor(c, store); err != nil {
+			fmt.Println("❌ Invalid error_budget:", err)
+			os.Exit(1)
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+
+	nextRun := make([]time.Time, len(crons))
+	for i := range crons {
+		nextRun[i] = crons[i].next(time.Now())
+		fmt.Printf("→ %s: next run at %s\n", c.Schedules[i].Name, nextRun[i].Format(time.RFC1123))
+	}
+	for {
+		order := make([]int, len(crons))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return nextRun[order[a]].Before(nextRun[order[b]]) })
+		i := order[0]
+		time.Sleep(time.Until(nextRun[i]))
+
+		s := c.Schedules[i]
+		childArgs := []string{"--store", store, "--tag", s.Name}
+		if *config != "" {
+			childArgs = append(childArgs, "--config", *config)
+		}
+		childArgs = append(childArgs, s.Args...)
+		fmt.Printf("→ Running schedule %s\n", s.Name)
+		cmd := exec.Command(self, childArgs...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("⚠️  Schedule %s finished with %v\n", s.Name, err)
+		} else {
+			fmt.Printf("✅ Schedule %s done\n", s.Name)
+		}
+		if budget != nil {
+			if err := budget.check(time.Now()); err != nil {
+				fmt.Println("⚠️  Error budget:", err)
+			}
+		}
+
+		now := time.Now()
+		for j := range crons {
+			if !nextRun[j].After(now) {
+				nextRun[j] = crons[j].next(now)
+			}
+		}
+		fmt.Printf("→ %s: next run at %s\n", s.Name, nextRun[i].Format(time.RFC1123))
+	}
+}
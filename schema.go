@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is stamped into every result CSV row (the schema column)
+// and the JSON output. Bump it when the meaning of a column changes or a
+// column becomes required, and add the upgrade step to migrations.
+//
+//	1  file, total … p99: one row per hey run of the built-in URLs
+//	2  + target, route
+//	3  + concurrency
+//	4  + schema, spread
+//	5  latencies and total in milliseconds instead of seconds
+//	6  + run_id
+//	7  + requests, method, timeout: each target's effective load parameters
+const schemaVersion = 7
+
+// migrations[v] upgrades a row from schema v to v+1.
+var migrations = map[int]func(row map[string]string){
+	1: func(row map[string]string) {
+		row["target"] = inferURLFromFile(row["file"])
+		row["route"] = ""
+	},
+	2: func(row map[string]string) {
+		row["concurrency"] = ""
+	},
+	3: func(row map[string]string) {
+		addSpread(row)
+	},
+	4: func(row map[string]string) {
+		for h, v := range row {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && timeColumn(h) {
+				row[h] = strconv.FormatFloat(f*1000, 'f', 4, 64)
+			}
+		}
+	},
+	5: func(row map[string]string) {
+		row["run_id"] = "" // unknown, so never deduplicated
+	},
+	6: func(row map[string]string) {
+		row["requests"] = ""
+		row["method"] = ""
+		row["timeout"] = ""
+	},
+}
+
+// detectSchema reads the version from the schema column, or infers it for
+// files written before versions were stamped.
+func detectSchema(headers []string, row map[string]string) int {
+	if v, err := strconv.Atoi(row["schema"]); err == nil {
+		return v
+	}
+	has := map[string]bool{}
+	for _, h := range headers {
+		has[h] = true
+	}
+	switch {
+	case has["concurrency"]:
+		return 3
+	case has["target"]:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// migrateRow upgrades row in place to the current schema and returns the
+// version it started at.
+func migrateRow(headers []string, row map[string]string) (int, error) {
+	from := detectSchema(headers, row)
+	if from > schemaVersion {
+		return from, fmt.Errorf("schema %d is newer than this tool's %d", from, schemaVersion)
+	}
+	for v := from; v < schemaVersion; v++ {
+		migrations[v](row)
+	}
+	row["schema"] = strconv.Itoa(schemaVersion)
+	return from, nil
+}
+
+// runMigrate implements `migrate FILE...`: it upgrades result CSV and JSON
+// files written by older versions of the tool in place, keeping the
+// original as FILE.bak.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	config := fs.String("config", "", "config whose csv dialect the files use")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	cfg = c
+	failed := false
+	for _, file := range fs.Args() {
+		var from int
+		if strings.HasSuffix(file, ".json") {
+			from, err = migrateJSON(file)
+		} else {
+			from, err = migrateCSV(file)
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %s: %v\n", file, err)
+			failed = true
+		case from == schemaVersion:
+			fmt.Printf("✅ %s is already at schema %d\n", file, schemaVersion)
+		default:
+			fmt.Printf("✅ %s: schema %d → %d (original kept as %s.bak)\n", file, from, schemaVersion, file)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func migrateCSV(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	records, err := newCSVReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, fmt.Errorf("empty file")
+	}
+	headers := records[0]
+	from := schemaVersion
+	var rows []map[string]string
+	for _, rec := range records[1:] {
+		row := map[string]string{}
+		for i, h := range headers {
+			if i < len(rec) {
+				row[h] = csvNumber(rec[i])
+			}
+		}
+		v, err := migrateRow(headers, row)
+		if err != nil {
+			return v, err
+		}
+		if v < from {
+			from = v
+		}
+		rows = append(rows, row)
+	}
+	if from == schemaVersion {
+		return from, nil
+	}
+
+	// keep the original order, the schema column first and new columns last
+	out := []string{"schema"}
+	seen := map[string]bool{"schema": true}
+	for _, h := range headers {
+		if !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	var added []string
+	for _, row := range rows {
+		for h := range row {
+			if !seen[h] {
+				seen[h] = true
+				added = append(added, h)
+			}
+		}
+	}
+	sort.Strings(added)
+	out = append(out, added...)
+	if err := os.Rename(file, file+".bak"); err != nil {
+		return from, err
+	}
+	return from, writeRows(file, out, rows)
+}
+
+func writeRows(file string, headers []string, rows []map[string]string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := newCSVWriter(f)
+	w.Write(headers)
+	for _, row := range rows {
+		rec := make([]string, len(headers))
+		for i, h := range headers {
+			rec[i] = row[h]
+		}
+		w.Write(rec)
+	}
+	return w.Flush()
+}
+
+func migrateJSON(file string) (int, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	var suite SuiteJSON
+	if err := json.Unmarshal(raw, &suite); err != nil {
+		return 0, err
+	}
+	// JSON output appeared at schema 4, so an unstamped file is a 4
+	from := suite.Schema
+	if from == 0 {
+		from = 4
+	}
+	if from > schemaVersion {
+		return from, fmt.Errorf("schema %d is newer than this tool's %d", from, schemaVersion)
+	}
+	if from == schemaVersion {
+		return from, nil
+	}
+	for _, row := range suite.Results {
+		row["schema"] = strconv.Itoa(from)
+		if _, err := migrateRow(nil, row); err != nil {
+			return from, err
+		}
+	}
+	suite.Schema = schemaVersion
+	out, err := json.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return from, err
+	}
+	if err := os.Rename(file, file+".bak"); err != nil {
+		return from, err
+	}
+	return from, os.WriteFile(file, out, 0644)
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slackArgs are the key=value pairs `/loadtest run` takes and the flags
+// of the suite they set.
+var slackArgs = map[string]string{
+	"profile": "--profile",
+	"env":     "--env",
+	"target":  "--only",
+}
+
+// slackValueRe is what a value may be, so nothing typed in Slack turns
+// into a flag of its own.
+var slackValueRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._,:/-]*$`)
+
+// slackMaxAge is how old a request's timestamp may be before it's refused
+// as a replay, as Slack recommends.
+const slackMaxAge = 5 * time.Minute
+
+// slackServer runs suites on Slack slash commands, one at a time.
+type slackServer struct {
+	config    string
+	secret    string
+	token     string
+	api       string
+	reportURL string
+	channels  []string
+	self      string
+
+	mu      sync.Mutex
+	running *slackRun
+}
+
+// slackRun is a suite started from Slack.
+type slackRun struct {
+	args    []string // the key=value pairs as typed
+	user    string
+	channel string
+	started time.Time
+}
+
+// runServe implements `serve`: an HTTP server whose /slack/command
+// endpoint takes a Slack slash command, e.g. `/loadtest run profile=ci
+// target=green`, runs the suite as a child process, like a schedule, and
+// replies with its summary and a link to its report when it's done.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8088", "address to serve on")
+	config := fs.String("config", "", "config file passed to every suite")
+	secretEnv := fs.String("slack-signing-secret-env", "SLACK_SIGNING_SECRET", "environment variable holding the Slack app's signing secret")
+	tokenEnv := fs.String("slack-token-env", "SLACK_BOT_TOKEN", "environment variable holding a bot token, to reply in a thread (default: reply through the command's response URL)")
+	api := fs.String("slack-api", "https://slack.com/api", "base URL of the Slack Web API")
+	reportURL := fs.String("report-url", "", "base URL the results directory is served at, to link reports, e.g. https://perf.example.com/results")
+	channels := fs.String("slack-channels", "", "only take commands from these channel IDs, comma-separated (default: any)")
+	fs.Parse(args)
+
+	s := &slackServer{config: *config, secret: os.Getenv(*secretEnv), token: os.Getenv(*tokenEnv), api: strings.TrimRight(*api, "/"),
+		reportURL: strings.TrimRight(*reportURL, "/"), channels: splitList(*channels)}
+	if s.secret == "" {
+		fmt.Printf("❌ %s isn't set; every command must be verified with the Slack app's signing secret\n", *secretEnv)
+		os.Exit(1)
+	}
+	if *config != "" {
+		if _, err := loadConfig(*config); err != nil {
+			fmt.Println("❌ Error loading config:", err)
+			os.Exit(1)
+		}
+	}
+	var err error
+	if s.self, err = os.Executable(); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+
+	http.HandleFunc("/slack/command", s.handleCommand)
+	fmt.Printf("✅ Serving Slack commands on %s/slack/command\n", *listen)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+}
+
+func (s *slackServer) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a slash command", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "unreadable body", http.StatusBadRequest)
+		return
+	}
+	if err := verifySlack(s.secret, r.Header, body, time.Now()); err != nil {
+		fmt.Println("⚠️  Refused a Slack command:", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	channel, user := form.Get("channel_id"), form.Get("user_name")
+	if len(s.channels) > 0 && !contains(s.channels, channel) {
+		slackReply(w, "ephemeral", fmt.Sprintf("❌ %s doesn't take commands from this channel", form.Get("command")))
+		return
+	}
+
+	words := strings.Fields(form.Get("text"))
+	if len(words) == 0 || words[0] == "help" {
+		slackReply(w, "ephemeral", s.usage(form.Get("command")))
+		return
+	}
+	switch words[0] {
+	case "status":
+		s.mu.Lock()
+		run := s.running
+		s.mu.Unlock()
+		if run == nil {
+			slackReply(w, "ephemeral", "No suite is running.")
+			return
+		}
+		slackReply(w, "ephemeral", fmt.Sprintf("→ %s, started by @%s %v ago", sentence(run.describe()), run.user, time.Since(run.started).Round(time.Second)))
+		return
+	case "run":
+	default:
+		slackReply(w, "ephemeral", fmt.Sprintf("❌ Unknown command %q\n\n%s", words[0], s.usage(form.Get("command"))))
+		return
+	}
+
+	childArgs, err := slackSuiteArgs(words[1:])
+	if err != nil {
+		slackReply(w, "ephemeral", "❌ "+err.Error())
+		return
+	}
+	run := &slackRun{args: words[1:], user: user, channel: channel, started: time.Now()}
+	s.mu.Lock()
+	busy := s.running
+	if busy == nil {
+		s.running = run
+	}
+	s.mu.Unlock()
+	if busy != nil {
+		slackReply(w, "ephemeral", fmt.Sprintf("⚠️ %s is still running, started by @%s; try again when it's done", sentence(busy.describe()), busy.user))
+		return
+	}
+
+	fmt.Printf("→ @%s started %s\n", user, run.describe())
+	start := fmt.Sprintf("→ Running %s for @%s", run.describe(), user)
+	if s.token != "" {
+		slackReply(w, "ephemeral", "→ Starting the suite; it'll be reported in a thread.")
+	} else {
+		slackReply(w, "in_channel", start)
+	}
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.running = nil
+			s.mu.Unlock()
+		}()
+		// with a bot token the summary replies in the thread of a message
+		// announcing the run; without, it goes to the command's response
+		// URL, which Slack keeps valid for 30 minutes
+		thread := ""
+		if s.token != "" {
+			var err error
+			if thread, err = s.postMessage(channel, "", start); err != nil {
+				fmt.Println("⚠️  Couldn't post to Slack:", redact(err.Error()))
+			}
+		}
+		summary := s.runSuite(run, childArgs)
+		var err error
+		if s.token != "" {
+			_, err = s.postMessage(channel, thread, summary)
+		} else {
+			err = postJSON(form.Get("response_url"), map[string]string{"response_type": "in_channel", "text": summary})
+		}
+		if err != nil {
+			fmt.Println("⚠️  Couldn't post the summary to Slack:", redact(err.Error()))
+		}
+	}()
+}
+
+func (s *slackServer) usage(command string) string {
+	if command == "" {
+		command = "/loadtest"
+	}
+	return fmt.Sprintf("`%s run [profile=NAME] [env=NAME] [target=NAME,...]` runs the suite; "+
+		"`%s status` says what's running; `%s help` shows this.", command, command, command)
+}
+
+func (r *slackRun) describe() string {
+	if len(r.args) == 0 {
+		return "the suite"
+	}
+	return "the suite with " + strings.Join(r.args, " ")
+}
+
+// sentence capitalises s to start one.
+func sentence(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// slackSuiteArgs turns `run`'s key=value pairs into the suite's flags.
+func slackSuiteArgs(pairs []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		flagName, known := slackArgs[k]
+		switch {
+		case !ok || !known:
+			return nil, fmt.Errorf("%q isn't one of profile=, env= or target=", p)
+		case !slackValueRe.MatchString(v):
+			return nil, fmt.Errorf("invalid %s %q", k, v)
+		case seen[k]:
+			return nil, fmt.Errorf("%s is given twice", k)
+		}
+		seen[k] = true
+		out = append(out, flagName, v)
+	}
+	return out, nil
+}
+
+// verifySlack checks the request's X-Slack-Signature: the HMAC-SHA256,
+// under the app's signing secret, of "v0:", its timestamp, ":" and its
+// body. A timestamp older than slackMaxAge is refused as a replay.
+func verifySlack(secret string, h http.Header, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(h.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("no request timestamp")
+	}
+	if age := now.Sub(time.Unix(ts, 0)); math.Abs(float64(age)) > float64(slackMaxAge) {
+		return fmt.Errorf("request timestamp is %v off", age.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:", ts)
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(h.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+func slackReply(w http.ResponseWriter, responseType, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response_type": responseType, "text": text})
+}
+
+// postMessage posts text to channel, in the thread of thread unless it's
+// empty, and returns the message's ts.
+func (s *slackServer) postMessage(channel, thread, text string) (string, error) {
+	msg := map[string]string{"channel": channel, "text": text}
+	if thread != "" {
+		msg["thread_ts"] = thread
+	}
+	var answer struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.api+"/chat.postMessage", bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("chat.postMessage answered %s", resp.Status)
+	}
+	if !answer.OK {
+		return "", fmt.Errorf("chat.postMessage: %s", answer.Error)
+	}
+	return answer.TS, nil
+}
+
+func postJSON(target string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Post(target, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", redact(target), resp.Status)
+	}
+	return nil
+}
+
+var writingResultsRe = regexp.MustCompile(`→ Writing results to (.+)`)
+
+// runSuite runs the suite as a child process and summarises it for Slack:
+// the outcome, how long it took, every series' rps, latency and errors,
+// and a link to its report.
+func (s *slackServer) runSuite(run *slackRun, suiteArgs []string) string {
+	childArgs := []string{"--label", "triggered_by=slack:" + run.user}
+	if s.config != "" {
+		childArgs = append(childArgs, "--config", s.config)
+	}
+	childArgs = append(childArgs, suiteArgs...)
+	var out bytes.Buffer
+	cmd := exec.Command(s.self, childArgs...)
+	cmd.Stdout, cmd.Stderr = io.MultiWriter(os.Stdout, &out), io.MultiWriter(os.Stderr, &out)
+	err := cmd.Run()
+	took := time.Since(run.started).Round(time.Second)
+
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "❌ %s failed after %v (%v)", sentence(run.describe()), took, err)
+		fmt.Printf("⚠️  Suite for @%s finished with %v\n", run.user, err)
+	} else {
+		fmt.Fprintf(&b, "✅ %s passed in %v", sentence(run.describe()), took)
+		fmt.Printf("✅ Suite for @%s done\n", run.user)
+	}
+	m := writingResultsRe.FindStringSubmatch(out.String())
+	if m == nil {
+		// it stopped before writing anything; its last lines say why
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) > 5 {
+			lines = lines[len(lines)-5:]
+		}
+		return b.String() + "\n```\n" + redact(strings.Join(lines, "\n")) + "\n```"
+	}
+	dir := strings.TrimSpace(m[1])
+	var suite SuiteJSON
+	if raw, err := os.ReadFile(filepath.Join(dir, "hey_results.json")); err == nil {
+		json.Unmarshal(raw, &suite)
+	}
+	if suite.Truncated != "" {
+		fmt.Fprintf(&b, "\n⚠️ Truncated: %s", suite.Truncated)
+	}
+	for target, why := range suite.Aborted {
+		fmt.Fprintf(&b, "\n❌ %s aborted: %s", target, why)
+	}
+	if series := suiteSeries(suite.Results); len(series) > 0 {
+		b.WriteString("\n```\n" + slackTable(series) + "```")
+	}
+	report := filepath.Join(dir, "report.html")
+	if s.reportURL != "" {
+		report = s.reportURL + "/" + filepath.ToSlash(filepath.Base(dir)) + "/report.html"
+		fmt.Fprintf(&b, "\n<%s|Report>", report)
+	} else {
+		fmt.Fprintf(&b, "\nReport: `%s` on %s", report, hostname())
+	}
+	return b.String()
+}
+
+// slackTable lays the series out in fixed-width columns for a code block.
+func slackTable(series []WebhookSeries) string {
+	latency := "average"
+	for _, s := range series {
+		if _, ok := s.Metrics["p95"]; ok {
+			latency = "p95"
+		}
+	}
+	rows := [][]string{{"series", "runs", "rps", latency + " (ms)", "errors"}}
+	for _, s := range series {
+		rows = append(rows, []string{s.Series, strconv.Itoa(s.Runs), fmt.Sprintf("%.1f", s.Metrics["requests_per_sec"]),
+			fmt.Sprintf("%.4g", s.Metrics[latency]), fmt.Sprintf("%.2f%%", s.Metrics["error_rate"])})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, r := range rows {
+		for i, c := range r {
+			if n := len([]rune(c)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	var b strings.Builder
+	for _, r := range rows {
+		for i, c := range r {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[i]-len([]rune(c)))
+			if i == 0 {
+				b.WriteString(c + pad)
+			} else {
+				b.WriteString(pad + c)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVerifySlack(t *testing.T) {
+	// the example in Slack's guide to verifying requests
+	const (
+		secret    = "8f742231b10e8888abcd99yyyzzz85a5"
+		timestamp = "1531420618"
+		signature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+		body      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	)
+	sent := time.Unix(1531420618, 0)
+	header := func(ts, sig string) http.Header {
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", ts)
+		h.Set("X-Slack-Signature", sig)
+		return h
+	}
+	tests := []struct {
+		name   string
+		secret string
+		header http.Header
+		body   string
+		now    time.Time
+		ok     bool
+	}{
+		{"slack's example", secret, header(timestamp, signature), body, sent, true},
+		{"a minute later", secret, header(timestamp, signature), body, sent.Add(time.Minute), true},
+		{"clock a minute behind", secret, header(timestamp, signature), body, sent.Add(-time.Minute), true},
+		{"replayed after 5 minutes", secret, header(timestamp, signature), body, sent.Add(slackMaxAge + time.Second), false},
+		{"timestamp from the future", secret, header(timestamp, signature), body, sent.Add(-slackMaxAge - time.Second), false},
+		{"no timestamp", secret, header("", signature), body, sent, false},
+		{"other timestamp", secret, header("1531420619", signature), body, sent, false},
+		{"tampered body", secret, header(timestamp, signature), body + "&text=run", sent, false},
+		{"other secret", "not-the-secret", header(timestamp, signature), body, sent, false},
+		{"no signature", secret, header(timestamp, ""), body, sent, false},
+		{"without v0=", secret, header(timestamp, signature[3:]), body, sent, false},
+	}
+	for _, tt := range tests {
+		if err := verifySlack(tt.secret, tt.header, []byte(tt.body), tt.now); (err == nil) != tt.ok {
+			t.Errorf("%s: verifySlack = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestSlackSuiteArgs(t *testing.T) {
+	got, err := slackSuiteArgs([]string{"profile=ci", "target=api"})
+	if want := []string{"--profile", "ci", "--only", "api"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("slackSuiteArgs = %q, %v, want %q", got, err, want)
+	}
+	for _, pairs := range [][]string{
+		{"profile"},
+		{"concurrency=100"},
+		{"profile=--rate=1e9"},
+		{"target=api;rm"},
+		{"profile=ci", "profile=prod"},
+	} {
+		if got, err := slackSuiteArgs(pairs); err == nil {
+			t.Errorf("slackSuiteArgs(%q) = %q, want an error", pairs, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// networkShape emulates a constrained client link on the native engine:
+// every connection is capped at down and up bits per second, and pays rtt
+// when it connects and again before each response arrives.
+type networkShape struct {
+	down, up float64 // bits per second, 0 for no cap
+	rtt      time.Duration
+}
+
+// shape is the suite's --network, nil for none.
+var shape *networkShape
+
+// Presets of --network, after typical mobile links.
+var networkPresets = map[string]string{
+	"slow-3g": "down=400kbit up=400kbit rtt=400ms",
+	"3g":      "down=1.6mbit up=768kbit rtt=150ms",
+	"4g":      "down=12mbit up=6mbit rtt=50ms",
+}
+
+// parseNetworkShape reads a preset name or "down=1.6mbit up=768kbit
+// rtt=150ms", any of the three optional.
+func parseNetworkShape(spec string) (*networkShape, error) {
+	if preset, ok := networkPresets[spec]; ok {
+		spec = preset
+	}
+	s := &networkShape{}
+	for _, field := range strings.Fields(spec) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %q, want e.g. down=1.6mbit, up=768kbit or rtt=150ms", field)
+		}
+		var err error
+		switch k {
+		case "down":
+			s.down, err = parseBitRate(v)
+		case "up":
+			s.up, err = parseBitRate(v)
+		case "rtt":
+			if s.rtt, err = time.ParseDuration(v); err == nil && s.rtt < 0 {
+				err = fmt.Errorf("negative rtt %q", v)
+			}
+		default:
+			return nil, fmt.Errorf("unknown %q, want down, up or rtt", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *s == (networkShape{}) {
+		return nil, fmt.Errorf("%q shapes nothing", spec)
+	}
+	return s, nil
+}
+
+// parseBitRate reads "768kbit", "1.6mbit" or "1gbit".
+func parseBitRate(s string) (float64, error) {
+	lower := strings.ToLower(s)
+	scale := 1.0
+	for _, u := range []struct {
+		suffix string
+		scale  float64
+	}{{"kbit", 1e3}, {"mbit", 1e6}, {"gbit", 1e9}, {"bit", 1}} {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower, scale = strings.TrimSuffix(lower, u.suffix), u.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(lower, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid bit rate %q, want e.g. 768kbit", s)
+	}
+	return v * scale, nil
+}
+
+func (s *networkShape) String() string {
+	var parts []string
+	if s.down > 0 {
+		parts = append(parts, "down "+formatBitRate(s.down))
+	}
+	if s.up > 0 {
+		parts = append(parts, "up "+formatBitRate(s.up))
+	}
+	if s.rtt > 0 {
+		parts = append(parts, fmt.Sprintf("%v round trip", s.rtt))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatBitRate(bps float64) string {
+	switch {
+	case bps >= 1e6:
+		return strconv.FormatFloat(bps/1e6, 'g', 4, 64) + " Mbit/s"
+	case bps >= 1e3:
+		return strconv.FormatFloat(bps/1e3, 'g', 4, 64) + " kbit/s"
+	}
+	return strconv.FormatFloat(bps, 'g', 4, 64) + " bit/s"
+}
+
+// wrap shapes a freshly dialed connection, paying the connect's round
+// trip first.
+func (s *networkShape) wrap(c net.Conn) net.Conn {
+	time.Sleep(s.rtt)
+	return &shapedConn{Conn: c, shape: s}
+}
+
+// shapedConn paces reads and writes to the shape's rates, a token bucket
+// with no burst, and delays the first data read after each write by the
+// round trip: when a response starts arriving.
+type shapedConn struct {
+	net.Conn
+	shape               *networkShape
+	readFree, writeFree time.Time   // when the link is next free
+	wrote               atomic.Bool // the transport reads and writes from separate goroutines
+}
+
+// pace blocks until n bytes have had time to cross a link of bps, the
+// link being busy until free.
+func pace(free *time.Time, n int, bps float64) {
+	if bps <= 0 || n <= 0 {
+		return
+	}
+	now := time.Now()
+	if free.Before(now) {
+		*free = now
+	}
+	*free = free.Add(time.Duration(float64(n) * 8 / bps * float64(time.Second)))
+	time.Sleep(time.Until(*free))
+}
+
+// chunk bounds a read or write to a twentieth of a second of the link, so
+// pacing stays smooth.
+func chunk(p []byte, bps float64) []byte {
+	if bps <= 0 {
+		return p
+	}
+	return p[:min(len(p), max(1, int(bps/8/20)))]
+}
+
+func (c *shapedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(chunk(p, c.shape.down))
+	if n > 0 && c.wrote.Swap(false) {
+		// the transport may be blocked in Read before the request is
+		// written, so the delay is taken once the response arrives
+		time.Sleep(c.shape.rtt)
+	}
+	pace(&c.readFree, n, c.shape.down)
+	return n, err
+}
+
+func (c *shapedConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := c.Conn.Write(chunk(p[written:], c.shape.up))
+		written += n
+		pace(&c.writeFree, n, c.shape.up)
+		if err != nil {
+			return written, err
+		}
+	}
+	c.wrote.Store(true)
+	return written, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SLO is a latency objective such as "99% of requests under 300ms".
+type SLO struct {
+	Percent   float64
+	Threshold time.Duration
+}
+
+var sloRe = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*%\s*(?:<|under)\s*(\d+(?:\.\d+)?\s*(?:us|µs|ms|s))\s*$`)
+
+// parseSLO reads "99% < 300ms" (or "99% under 0.3s").
+func parseSLO(s string) (*SLO, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := sloRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid slo %q, want e.g. \"99%% < 300ms\"", s)
+	}
+	pct, _ := strconv.ParseFloat(m[1], 64)
+	d, err := time.ParseDuration(strings.ReplaceAll(m[2], " ", ""))
+	if err != nil || pct <= 0 || pct > 100 {
+		return nil, fmt.Errorf("invalid slo %q", s)
+	}
+	return &SLO{Percent: pct, Threshold: d}, nil
+}
+
+func (s SLO) String() string {
+	return fmt.Sprintf("%v%% < %v", s.Percent, s.Threshold)
+}
+
+var heyBucketRe = regexp.MustCompile(`^\s+([\d.]+) \[(\d+)\]\s*\|`)
+var heyErrorRe = regexp.MustCompile(`^\s+\[(\d+)\]\s+\D`)
+
+// sloCounts returns how many requests of a run met the latency threshold.
+// Raw latencies give an exact count; otherwise hey's histogram is used,
+// counting a bucket only when its upper bound is within the threshold.
+// Failed requests always count against the objective.
+func sloCounts(row map[string]string, threshold time.Duration) (good, total int, err error) {
+	if row["raw_file"] != "" {
+		_, err := streamRawLatencies(filepath.Join(outDir, row["raw_file"]), func(s sample) {
+			total++
+			if s.err == "" && s.latency <= threshold {
+				good++
+			}
+		})
+		return good, total, err
+	}
+
+	f, err := openOutput(filepath.Join(outDir, row["file"]))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			section = line
+			continue
+		}
+		switch section {
+		case "Response time histogram:":
+			if m := heyBucketRe.FindStringSubmatch(line); m != nil {
+				mark, _ := strconv.ParseFloat(m[1], 64)
+				count, _ := strconv.Atoi(m[2])
+				total += count
+				if mark <= threshold.Seconds() {
+					good += count
+				}
+			}
+		case "Error distribution:":
+			if m := heyErrorRe.FindStringSubmatch(line); m != nil {
+				count, _ := strconv.Atoi(m[1])
+				total += count
+			}
+		}
+	}
+	return good, total, nil
+}
+
+// addSLOCompliance stores the run's compliance percentage in the row, plus
+// the underlying counts for pooling across runs.
+func addSLOCompliance(row map[string]string, slo *SLO) {
+	good, total, err := sloCounts(row, slo.Threshold)
+	if err != nil || total == 0 {
+		return
+	}
+	row["slo_compliance"] = fmt.Sprintf("%.4f", 100*float64(good)/float64(total))
+	row["slo_good"] = strconv.Itoa(good)
+	row["slo_total"] = strconv.Itoa(total)
+}
+
+// suiteCompliance pools the counts of every run per series, which is the
+// correct suite-level figure rather than an average of run percentages.
+func suiteCompliance(rows []map[string]string) map[string]float64 {
+	good := map[string]int{}
+	total := map[string]int{}
+	for _, row := range rows {
+		key := rowSeriesKey(row)
+		g, _ := strconv.Atoi(row["slo_good"])
+		t, _ := strconv.Atoi(row["slo_total"])
+		good[key] += g
+		total[key] += t
+	}
+	out := map[string]float64{}
+	for key, t := range total {
+		if t > 0 {
+			out[key] = 100 * float64(good[key]) / float64(t)
+		}
+	}
+	return out
+}
+
+// rowSeriesKey identifies the chart series a result row belongs to; in a
+// concurrency sweep every level is its own series.
+func rowSeriesKey(row map[string]string) string {
+	key := rowTargetKey(row)
+	if len(cfg.Sweep) > 1 {
+		key += " c=" + row["concurrency"]
+	}
+	return key
+}
+
+func rowTargetKey(row map[string]string) string {
+	if row["route"] == "" {
+		return row["target"]
+	}
+	return row["target"] + " " + row["route"]
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// sshHosts are the [user@]host entries set with --ssh. Each hey run is
+// then split across them and executed remotely, without an agent: the
+// hosts only need sshd, and hey is uploaded when missing.
+var sshHosts []string
+
+// remoteHey is the hey command to use on each host, filled in by
+// prepareSSHHosts.
+var remoteHey = map[string]string{}
+
+const remoteDir = "hey_remote"
+
+var sshOpts = []string{"-o", "BatchMode=yes"}
+
+// prepareSSHHosts checks every host is reachable and uploads the local
+// hey binary to the ones that don't have hey on their PATH, provided they
+// run the OS and architecture it was built for.
+func prepareSSHHosts() error {
+	for _, host := range sshHosts {
+		if err := sshRun(host, "mkdir -p "+remoteDir); err != nil {
+			return fmt.Errorf("%s unreachable: %w", host, err)
+		}
+		if sshRun(host, "command -v hey") == nil {
+			remoteHey[host] = "hey"
+			continue
+		}
+		local, err := exec.LookPath(heyBin)
+		if err != nil {
+			return fmt.Errorf("%s has no hey and there's none locally to upload", host)
+		}
+		remote, err := remotePlatform(host)
+		if err != nil {
+			return fmt.Errorf("%s has no hey, and its platform is unknown so the local one can't be uploaded: %w", host, err)
+		}
+		if built := binaryPlatform(local); remote != built {
+			return fmt.Errorf("%s has no hey and is %s, but the local hey is built for %s; install hey there "+
+				"with `go install github.com/rakyll/hey@latest`, or point --hey-path at a %s build", host, remote, built, remote)
+		}
+		fmt.Printf("→ Uploading hey to %s\n", host)
+		if err := scp(local, host+":"+remoteDir+"/hey"); err != nil {
+			return fmt.Errorf("upload hey to %s: %w", host, err)
+		}
+		remoteHey[host] = remoteDir + "/hey"
+	}
+	return nil
+}
+
+// runSSH runs t on every host at once, each sending its share of the n
+// requests, then downloads the raw CSV each host recorded and merges them
+// into one run.
+func runSSH(t Target, n, i int) (nativeRun, error) {
+	runs := make([]nativeRun, len(sshHosts))
+	errs := make([]error, len(sshHosts))
+	var wg sync.WaitGroup
+	for k, host := range sshHosts {
+		wg.Add(1)
+		go func(k int, host string) {
+			defer wg.Done()
+			runs[k], errs[k] = runSSHShare(host, t, share(n, len(sshHosts), k), share(concurrencyFor(t), len(sshHosts), k), i, k)
+		}(k, host)
+	}
+	wg.Wait()
+
+	var merged nativeRun
+	for k, run := range runs {
+		if errs[k] != nil {
+			return merged, fmt.Errorf("%s: %w", sshHosts[k], errs[k])
+		}
+		merged.samples = append(merged.samples, run.samples...)
+		if run.total > merged.total {
+			merged.total = run.total
+		}
+		origin := sshHosts[k]
+		if originNames[k] != "" {
+			origin = originNames[k]
+		}
+		if err := recordAgentShare(t, i, k, origin, run); err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
+
+func runSSHShare(host string, t Target, n, c, i, k int) (nativeRun, error) {
+	name := fmt.Sprintf("hey_raw_%s_host%d.csv", runStem(t.Slug, i), k+1)
+	remote := remoteDir + "/" + name
+	args := append([]string{remoteHey[host], "-o", "csv"}, heyArgs(t, n, c)...)
+	for a := range args {
+		args[a] = shellQuote(args[a])
+	}
+	if err := sshRun(host, strings.Join(args, " ")+" > "+remote); err != nil {
+		return nativeRun{}, err
+	}
+
+	local := filepath.Join(outDir, name)
+	if err := scp(host+":"+remote, local); err != nil {
+		return nativeRun{}, fmt.Errorf("collect %s: %w", remote, err)
+	}
+	sshRun(host, "rm -f "+remote)
+	defer func() {
+		if !*rawCapture {
+			os.Remove(local)
+		} else {
+			compressOutput(local)
+		}
+	}()
+
+	samples, span, err := readRawLatencies(local)
+	if err != nil {
+		return nativeRun{}, err
+	}
+	for missing := heySent(n, c) - len(samples); missing > 0; missing-- {
+		samples = append(samples, sample{err: heyLostError})
+	}
+	return nativeRun{samples: samples, total: span}, nil
+}
+
+func sshRun(host, command string) error {
+	_, err := sshOutput(host, command)
+	return err
+}
+
+func sshOutput(host, command string) (string, error) {
+	args := append(append([]string{}, sshOpts...), host, command)
+	out, err := exec.Command("ssh", args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), err
+}
+
+// unameArch maps `uname -m` to GOARCH where the two differ.
+var unameArch = map[string]string{
+	"x86_64": "amd64", "aarch64": "arm64", "armv6l": "arm", "armv7l": "arm",
+	"i386": "386", "i486": "386", "i586": "386", "i686": "386",
+}
+
+// remotePlatform is host's GOOS/GOARCH, from `uname -sm`.
+func remotePlatform(host string) (string, error) {
+	out, err := sshOutput(host, "uname -sm")
+	if err != nil {
+		return "", err
+	}
+	f := strings.Fields(out)
+	if len(f) != 2 {
+		return "", fmt.Errorf("uname -sm printed %q", strings.TrimSpace(out))
+	}
+	arch := f[1]
+	if a, ok := unameArch[arch]; ok {
+		arch = a
+	}
+	return strings.ToLower(f[0]) + "/" + arch, nil
+}
+
+// binaryPlatform is the GOOS/GOARCH path was built for, from its Go build
+// info, or this machine's when it has none.
+func binaryPlatform(path string) string {
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if info, err := buildinfo.ReadFile(path); err == nil {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "GOOS":
+				goos = s.Value
+			case "GOARCH":
+				goarch = s.Value
+			}
+		}
+	}
+	return goos + "/" + goarch
+}
+
+func scp(from, to string) error {
+	args := append(append([]string{"-q"}, sshOpts...), from, to)
+	out, err := exec.Command("scp", args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// shellQuote quotes s for the remote POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stage is one step of a test plan run by `stages`, e.g. a smoke test,
+// then load, stress and soak. Its load settings replace the config's,
+// which a stage leaving them unset inherits, and its thresholds replace
+// the config's so each stage is held to its own:
+//
+//	"stages": [
+//	  {"name": "smoke", "repeat": 1, "requests": 20, "concurrency": 1, "thresholds": ["error_rate == 0"]},
+//	  {"name": "load", "concurrency": 50, "thresholds": ["p95 < 300ms", "error_rate < 1"]},
+//	  {"name": "stress", "sweep": [100, 200, 400], "thresholds": ["error_rate < 5"], "optional": true},
+//	  {"name": "soak", "repeat": 60, "requests": 10000, "duration": "1h", "thresholds": ["p99 < 800ms"]}
+//	]
+type Stage struct {
+	Name        string   `json:"name"`
+	Repeat      int      `json:"repeat"`
+	Requests    int      `json:"requests"`
+	Concurrency int      `json:"concurrency"`
+	Sweep       []int    `json:"sweep"`
+	Rate        float64  `json:"rate"`
+	Duration    string   `json:"duration"` // caps the stage, as --max-duration
+	Thresholds  []string `json:"thresholds"`
+	Args        []string `json:"args"`     // extra suite flags for this stage
+	Optional    bool     `json:"optional"` // a failure doesn't stop the stages after it
+}
+
+func validateStages(stages []Stage) error {
+	seen := map[string]bool{}
+	for i, s := range stages {
+		if s.Name == "" {
+			return fmt.Errorf("stage %d has no name", i+1)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("stage %s is defined twice", s.Name)
+		}
+		seen[s.Name] = true
+		if _, err := parseThresholds(s.Thresholds); err != nil {
+			return fmt.Errorf("stage %s: %w", s.Name, err)
+		}
+		if s.Duration != "" {
+			if d, err := time.ParseDuration(s.Duration); err != nil || d <= 0 {
+				return fmt.Errorf("stage %s: invalid duration %q", s.Name, s.Duration)
+			}
+		}
+	}
+	return nil
+}
+
+// configFor is the suite config of stage s: c with the stage's load and
+// thresholds.
+func (s Stage) configFor(c Config) Config {
+	c.Stages, c.Schedules = nil, nil
+	if s.Repeat > 0 {
+		c.Repeat = s.Repeat
+	}
+	if s.Requests > 0 {
+		c.Requests = s.Requests
+	}
+	if s.Concurrency > 0 {
+		c.Concurrency = s.Concurrency
+	}
+	if len(s.Sweep) > 0 || s.Concurrency > 0 {
+		c.Sweep = s.Sweep
+	}
+	if s.Rate > 0 {
+		c.Rate = s.Rate
+	}
+	c.Thresholds = s.Thresholds
+	return c
+}
+
+// load describes the stage's load for the plan report.
+func (s Stage) load(c Config) string {
+	sc := s.configFor(c)
+	var parts []string
+	if len(sc.Sweep) > 0 {
+		levels := make([]string, len(sc.Sweep))
+		for i, l := range sc.Sweep {
+			levels[i] = fmt.Sprint(l)
+		}
+		parts = append(parts, "c="+strings.Join(levels, ","))
+	} else {
+		parts = append(parts, fmt.Sprintf("c=%d", sc.Concurrency))
+	}
+	if sc.Rate > 0 {
+		parts = append(parts, fmt.Sprintf("%g req/s", sc.Rate))
+	}
+	runs := fmt.Sprintf("%d runs", sc.Repeat)
+	if sc.Repeat == 1 {
+		runs = "1 run"
+	}
+	parts = append(parts, fmt.Sprintf("%d requests × %s", sc.Requests, runs))
+	if s.Duration != "" {
+		parts = append(parts, "at most "+s.Duration)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stageResult is how a stage went, for the plan report.
+type stageResult struct {
+	stage   Stage
+	status  string // passed, failed or skipped
+	dir     string // the stage's suite directory
+	results []map[string]string
+}
+
+// runStages implements `stages --config plan.json [-- suite flags]`: it
+// runs each stage of the config as a suite in a child process, in order,
+// under one plan directory, and writes plan.md summing them up. A stage
+// fails when its suite does, on its thresholds or an abort; the stages
+// after a failed one are skipped unless it's optional. It exits 1 when a
+// stage that isn't optional failed.
+func runStages(args []string) {
+	fs := flag.NewFlagSet("stages", flag.ExitOnError)
+	config := fs.String("config", "", "config file defining \"stages\"")
+	root := fs.String("results-dir", "results", "write the plan to its own plan-<time> directory here")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	if len(c.Stages) == 0 {
+		fmt.Println("❌ No stages defined in the config")
+		os.Exit(1)
+	}
+	if err := validateStages(c.Stages); err != nil {
+		fmt.Println("❌ Invalid stages:", err)
+		os.Exit(1)
+	}
+	cfg = c // for the plan report's percentiles and units
+	if c.Units != "" {
+		displayUnit = c.Units
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	started := time.Now()
+	planDir := filepath.Join(*root, "plan-"+started.Format("20060102T150405"))
+	if err := os.MkdirAll(planDir, 0755); err != nil {
+		fmt.Println("❌ Error creating the plan directory:", err)
+		os.Exit(1)
+	}
+
+	var done []stageResult
+	stopped := ""
+	for _, s := range c.Stages {
+		res := stageResult{stage: s, status: "skipped"}
+		if stopped != "" {
+			fmt.Printf("⚠️  Skipping stage %s: stage %s failed\n", s.Name, stopped)
+			done = append(done, res)
+			continue
+		}
+		fmt.Printf("→ Stage %s: %s\n", s.Name, s.load(c))
+		res.status = "passed"
+		if err := runStage(self, s, c, planDir, fs.Args(), &res); err != nil {
+			fmt.Printf("❌ Stage %s failed: %v\n", s.Name, err)
+			res.status = "failed"
+			if !s.Optional {
+				stopped = s.Name
+			}
+		} else {
+			fmt.Printf("✅ Stage %s passed\n", s.Name)
+		}
+		done = append(done, res)
+	}
+
+	report := filepath.Join(planDir, "plan.md")
+	if err := writePlanReport(report, c, done, started); err != nil {
+		fmt.Println("❌ Error writing plan report:", err)
+	} else {
+		fmt.Println("✅ Plan report written to", report)
+	}
+	for _, res := range done {
+		if res.status == "failed" && !res.stage.Optional {
+			os.Exit(1)
+		}
+	}
+}
+
+// runStage runs stage s as a child suite with its config written to a
+// temporary file, its results in planDir/<stage>, and keeps its results in
+// res. It fails when the suite does.
+func runStage(self string, s Stage, c Config, planDir string, extra []string, res *stageResult) error {
+	tmp, err := os.CreateTemp("", "stage-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(s.configFor(c)); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	root := filepath.Join(planDir, safeSlug(s.Name))
+	childArgs := append([]string{"--config", tmp.Name()}, extra...)
+	childArgs = append(childArgs, s.Args...)
+	if s.Duration != "" {
+		childArgs = append(childArgs, "--max-duration", s.Duration)
+	}
+	childArgs = append(childArgs, "--results-dir", root, "--label", "stage="+s.Name)
+	cmd := exec.Command(self, childArgs...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	runErr := cmd.Run()
+
+	if dir, err := latestSuiteDir(root); err == nil {
+		res.dir = dir
+		var suite SuiteJSON
+		if raw, err := os.ReadFile(filepath.Join(dir, "hey_results.json")); err == nil && json.Unmarshal(raw, &suite) == nil {
+			res.results = suite.Results
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if len(res.results) == 0 {
+		return fmt.Errorf("no results")
+	}
+	return nil
+}
+
+// thresholdHeld reports whether t holds for every series of rows.
+func thresholdHeld(rows []map[string]string, t Threshold) bool {
+	values := seriesMeans(rows, t.Metric)
+	if t.Metric == "slo_compliance" {
+		values = suiteCompliance(rows)
+	}
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		if !t.holds(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// writePlanReport writes the consolidated report of a plan: every stage's
+// load, headline metrics and thresholds, linking to its own report.
+func writePlanReport(filename string, c Config, done []stageResult, started time.Time) error {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	var table [][]string
+	for _, res := range done {
+		s := res.stage
+		mark := map[string]string{"passed": "✅ passed", "failed": "❌ failed", "skipped": "⏭️ skipped"}[res.status]
+		if res.status == "failed" && s.Optional {
+			mark += " (optional)"
+		}
+		row := []string{s.Name, s.load(c), mark}
+		for _, m := range []string{"requests_per_sec", latency, "error_rate"} {
+			var xs []float64
+			for _, r := range res.results {
+				if v, ok := rowFloat(r, m); ok && r["agent"] == "" {
+					xs = append(xs, v)
+				}
+			}
+			switch {
+			case len(xs) == 0:
+				row = append(row, "–")
+			case m == "error_rate":
+				row = append(row, fmt.Sprintf("%.1f%%", mean(xs)))
+			case timeColumn(m):
+				row = append(row, fmt.Sprintf("%.4g", inUnit(mean(xs))))
+			default:
+				row = append(row, fmt.Sprintf("%.1f", mean(xs)))
+			}
+		}
+		var checks []string
+		if thresholds, err := parseThresholds(s.Thresholds); err == nil && res.status != "skipped" {
+			for _, t := range thresholds {
+				if thresholdHeld(res.results, t) {
+					checks = append(checks, "✅ "+t.String())
+				} else {
+					checks = append(checks, "❌ "+t.String())
+				}
+			}
+		}
+		if len(checks) == 0 {
+			checks = []string{"–"}
+		}
+		row = append(row, strings.Join(checks, "<br>"))
+		report := "–"
+		if res.dir != "" {
+			if rel, err := filepath.Rel(filepath.Dir(filename), filepath.Join(res.dir, "report.md")); err == nil {
+				report = fmt.Sprintf("[report](%s)", filepath.ToSlash(rel))
+			}
+		}
+		table = append(table, append(row, report))
+	}
+
+	var b strings.Builder
+	b.WriteString("# Performance test plan\n\n")
+	fmt.Fprintf(&b, "Started %s; %d stages, run in order. A failed stage skips the ones after it unless it's optional. "+
+		"Metrics are means over every run of the stage, latencies in %s.\n\n", started.Format(time.RFC1123), len(done), displayUnit)
+	b.WriteString(markdownTable([]string{"stage", "load", "result", "rps", latency + " (" + displayUnit + ")", "errors", "thresholds", "details"}, table))
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// stddev is the sample standard deviation.
+func stddev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	m := mean(xs)
+	ss := 0.0
+	for _, x := range xs {
+		ss += (x - m) * (x - m)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// quantile uses linear interpolation between closest ranks; q is 0-1.
+func quantile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	s := append([]float64{}, xs...)
+	sort.Float64s(s)
+	pos := q * float64(len(s)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return s[lo] + (s[hi]-s[lo])*(pos-float64(lo))
+}
+
+func iqr(xs []float64) float64 {
+	return quantile(xs, 0.75) - quantile(xs, 0.25)
+}
+
+func rowFloat(row map[string]string, metric string) (float64, bool) {
+	v, err := strconv.ParseFloat(row[metric], 64)
+	return v, err == nil
+}
+
+// seriesValues collects a numeric column per series in run order,
+// skipping empty cells.
+func seriesValues(rows []map[string]string, metric string) (map[string][]float64, []string) {
+	out := map[string][]float64{}
+	var order []string
+	for _, row := range rows {
+		v, ok := rowFloat(row, metric)
+		if !ok {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if _, seen := out[key]; !seen {
+			order = append(order, key)
+		}
+		out[key] = append(out[key], v)
+	}
+	return out, order
+}
+
+// welchP is the two-sided p-value of Welch's t-test that xs and ys have
+// the same mean, or NaN with fewer than two values in either.
+func welchP(xs, ys []float64) float64 {
+	n1, n2 := float64(len(xs)), float64(len(ys))
+	if n1 < 2 || n2 < 2 {
+		return math.NaN()
+	}
+	v1, v2 := stddev(xs)*stddev(xs)/n1, stddev(ys)*stddev(ys)/n2
+	if v1+v2 == 0 {
+		if mean(xs) == mean(ys) {
+			return 1
+		}
+		return 0
+	}
+	t := (mean(xs) - mean(ys)) / math.Sqrt(v1+v2)
+	df := (v1 + v2) * (v1 + v2) / (v1*v1/(n1-1) + v2*v2/(n2-1))
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b),
+// evaluated with Lentz's continued fraction.
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x > (a+1)/(a+b+2) {
+		return 1 - incompleteBeta(b, a, 1-x)
+	}
+
+	const tiny = 1e-300
+	f, c, d := 1.0, 1.0, 0.0
+	for i := 0; i <= 200; i++ {
+		m := float64(i / 2)
+		var num float64
+		switch {
+		case i == 0:
+			num = 1
+		case i%2 == 0:
+			num = m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		default:
+			num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		}
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		d = 1 / d
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		f *= c * d
+		if math.Abs(1-c*d) < 1e-10 {
+			break
+		}
+	}
+	return front * (f - 1) / a
+}
+
+// pairedP is the two-sided p-value of a paired t-test that the mean of
+// the differences ds is zero, or NaN with fewer than two pairs.
+func pairedP(ds []float64) float64 {
+	n := float64(len(ds))
+	if n < 2 {
+		return math.NaN()
+	}
+	sd := stddev(ds)
+	if sd == 0 {
+		if mean(ds) == 0 {
+			return 1
+		}
+		return 0
+	}
+	t := mean(ds) / (sd / math.Sqrt(n))
+	df := n - 1
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
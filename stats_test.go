@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIncompleteBeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, x float64
+		want    float64
+		within  float64
+	}{
+		{"x=0", 2, 3, 0, 0, 0},
+		{"x=1", 2, 3, 1, 1, 0},
+		{"uniform", 1, 1, 0.3, 0.3, 1e-12},
+		{"b=1 is x^a", 2.5, 1, 0.6, math.Pow(0.6, 2.5), 1e-10},
+		{"a=1 is 1-(1-x)^b", 1, 4, 0.2, 1 - math.Pow(0.8, 4), 1e-10},
+		{"symmetric at a half", 7, 7, 0.5, 0.5, 1e-10},
+		{"upper tail", 0.5, 0.5, 0.9, 2 / math.Pi * math.Asin(math.Sqrt(0.9)), 1e-10},
+		{"large parameters", 50, 60, 0.45, 0.4642352914, 1e-9}, // by numerical integration
+	}
+	for _, tt := range tests {
+		if got := incompleteBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > tt.within {
+			t.Errorf("%s: I_%v(%v, %v) = %v, want %v", tt.name, tt.x, tt.a, tt.b, got, tt.want)
+		}
+	}
+	// symmetry: I_x(a, b) = 1 - I_{1-x}(b, a)
+	for _, x := range []float64{0.05, 0.3, 0.7, 0.95} {
+		if got, want := incompleteBeta(3, 5, x), 1-incompleteBeta(5, 3, 1-x); math.Abs(got-want) > 1e-10 {
+			t.Errorf("I_%v(3, 5) = %v, but 1 - I_%v(5, 3) = %v", x, got, 1-x, want)
+		}
+	}
+}
+
+func TestWelchP(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+		within float64
+	}{
+		// equal variances and two values each give 2 degrees of freedom,
+		// where p = 1 - |t| / sqrt(2 + t²)
+		{"two degrees of freedom", []float64{0, 2}, []float64{2, 4}, 1 - math.Sqrt(2)/2, 1e-9},
+		{"same samples", []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}, 1, 1e-12},
+		{"order doesn't matter", []float64{4, 3, 2, 1}, []float64{1, 2, 3, 4}, 1, 1e-12},
+		{"constant and equal", []float64{5, 5, 5}, []float64{5, 5}, 1, 0},
+		{"constant and different", []float64{5, 5, 5}, []float64{6, 6}, 0, 0},
+		{"far apart", []float64{10, 10.1, 9.9, 10.05, 9.95}, []float64{20, 20.1, 19.9, 20.05, 19.95}, 0, 1e-9},
+	}
+	for _, tt := range tests {
+		if got := welchP(tt.xs, tt.ys); math.Abs(got-tt.want) > tt.within {
+			t.Errorf("%s: welchP = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := welchP([]float64{1}, []float64{1, 2, 3}); !math.IsNaN(got) {
+		t.Errorf("welchP with one value = %v, want NaN", got)
+	}
+	xs, ys := []float64{1, 2, 3, 4, 5}, []float64{2, 3, 4, 5, 6, 7, 8}
+	if a, b := welchP(xs, ys), welchP(ys, xs); a != b {
+		t.Errorf("welchP isn't symmetric: %v and %v", a, b)
+	}
+}
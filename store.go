@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// StoreRecord is one result row kept in the results store, an append-only
+// JSON Lines file that accumulates every suite run with --store, so history
+// survives the per-run output directory being recreated.
+type StoreRecord struct {
+	Suite  string            `json:"suite"`
+	Time   time.Time         `json:"time"`
+	Tags   []string          `json:"tags,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Row    map[string]string `json:"row"`
+}
+
+func appendStore(path, suite string, started time.Time, tags []string, rows []map[string]string) error {
+	seen, err := storedRuns(path)
+	if err != nil {
+		return err
+	}
+	rows, dropped := dedupeRuns(rows, seen)
+	if dropped > 0 {
+		fmt.Printf("⚠️  %d runs are already in the results store %s\n", dropped, path)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		rec := StoreRecord{Suite: suite, Time: started, Tags: tags, Labels: labels, Row: publicRow(row)}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %d rows added to the results store %s\n", len(rows), path)
+	return nil
+}
+
+// storedRuns returns the run IDs already in the store at path.
+func storedRuns(path string) (map[string]bool, error) {
+	seen := map[string]bool{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec StoreRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil && rec.Row["run_id"] != "" {
+			seen[rec.Row["run_id"]] = true
+		}
+	}
+	return seen, scanner.Err()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Target is a single request definition the runner sends load to.
+// Name is the deployment label used to group results in charts, Route
+// identifies the endpoint when a deployment is exercised with several.
+// A positive Weight makes the target part of its deployment's weighted
+// load mix; zero means it is benchmarked on its own. Targets with a Step
+// belong to a scenario and are executed in Step order by each virtual user.
+// Requests, Concurrency, Timeout and Cooldown come from the config's
+// overrides; zero means the suite's setting. A non-nil Bust makes its
+// requests cache-busting, and Family, tcp4 or tcp6, forces the address
+// family they're sent over.
+type Target struct {
+	Name    string
+	Route   string
+	Method  string
+	URL     string
+	Headers []Header
+	Body    string
+	Weight  float64
+	Step    int
+	Extract map[string]string
+	Slug    string
+
+	Requests    int
+	Concurrency int
+	Timeout     time.Duration
+	Cooldown    *Cooldown
+
+	Bust   *CacheBust
+	Family string
+}
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+func defaultTargets() []Target {
+	var targets []Target
+	names := targetNames(cfg.URLs)
+	for i, u := range cfg.URLs {
+		targets = append(targets, Target{
+			Name:   names[i],
+			Method: "GET",
+			URL:    u,
+		})
+	}
+	return targets
+}
+
+// targetNames names the deployment behind each URL. The two built-in URLs
+// keep their historical names; any other is named after its host, or its
+// host and path when several URLs share a host, so every URL is its own
+// series however many there are.
+func targetNames(urls []string) []string {
+	hosts := map[string]int{}
+	for _, u := range urls {
+		if p, err := url.Parse(u); err == nil {
+			hosts[p.Host]++
+		}
+	}
+	builtin := map[string]bool{}
+	for _, u := range defaultConfig().URLs {
+		builtin[u] = true
+	}
+	names := make([]string, len(urls))
+	for i, u := range urls {
+		p, err := url.Parse(u)
+		switch {
+		case builtin[u]:
+			names[i] = inferURLFromFile(u)
+		case err != nil || p.Host == "":
+			names[i] = u
+		case hosts[p.Host] > 1:
+			names[i] = p.Host + p.Path
+		default:
+			names[i] = p.Host
+		}
+	}
+	return names
+}
+
+func (t Target) label() string {
+	if t.Route == "" {
+		return redact(t.URL)
+	}
+	return redact(t.Name + " " + t.Route)
+}
+
+// assignSlugs gives every target a unique, filesystem-safe slug used in
+// output file names. Slugs differing only in case count as the same, since
+// Windows and macOS file systems don't tell them apart.
+func assignSlugs(targets []Target) {
+	seen := map[string]int{}
+	for i := range targets {
+		slug := slugifyURL(redact(targets[i].URL)) // file names are outputs too
+		if targets[i].Method != "" && targets[i].Method != "GET" {
+			slug = safeSlug(targets[i].Method + "_" + slug)
+		}
+		// a numbered slug may be another target's own, so count on
+		// until it's free
+		base := slug
+		for seen[strings.ToLower(slug)] > 0 {
+			seen[strings.ToLower(base)]++
+			slug = fmt.Sprintf("%s_%d", base, seen[strings.ToLower(base)])
+		}
+		seen[strings.ToLower(slug)]++
+		targets[i].Slug = slug
+	}
+}
+
+// variantName names a deployment's variant in a paired comparison mode.
+func variantName(name, variant string) string {
+	return name + " (" + variant + ")"
+}
+
+// targetVariants runs every deployment once per variant, its targets
+// renamed after the variant and set up by apply. A deployment's variants
+// are consecutive, so planJobs runs them back to back, and a mix or
+// scenario stays whole in each.
+func targetVariants(targets []Target, variants []string, apply func(t *Target, k int)) []Target {
+	var names []string
+	byName := map[string][]Target{}
+	for _, t := range targets {
+		if _, ok := byName[t.Name]; !ok {
+			names = append(names, t.Name)
+		}
+		byName[t.Name] = append(byName[t.Name], t)
+	}
+	var out []Target
+	for _, name := range names {
+		for k, v := range variants {
+			for _, t := range byName[name] {
+				t.Name = variantName(name, v)
+				apply(&t, k)
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// applyOverrides applies cfg.Overrides to the targets they name, by target
+// name or URL, the URL's taking precedence.
+func applyOverrides(targets []Target) error {
+	used := map[string]bool{}
+	for i := range targets {
+		t := &targets[i]
+		for _, key := range []string{t.Name, t.URL} {
+			o, ok := cfg.Overrides[key]
+			if !ok {
+				continue
+			}
+			used[key] = true
+			if o.Requests < 0 || o.Concurrency < 0 {
+				return fmt.Errorf("override %q: requests and concurrency must be positive", key)
+			}
+			if o.Requests > 0 {
+				t.Requests = o.Requests
+			}
+			if o.Concurrency > 0 {
+				t.Concurrency = o.Concurrency
+			}
+			if o.Timeout != "" {
+				d, err := time.ParseDuration(o.Timeout)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("override %q: invalid timeout %q, want e.g. 5s", key, o.Timeout)
+				}
+				t.Timeout = d
+			}
+			if o.Cooldown != "" {
+				c, err := parseCooldown(o.Cooldown)
+				if err != nil {
+					return fmt.Errorf("override %q: %w", key, err)
+				}
+				t.Cooldown = &c
+			}
+			if o.Method != "" {
+				t.Method = strings.ToUpper(o.Method)
+				if t.Route != "" {
+					t.Route = routeOf(t.Method, t.URL)
+				}
+			}
+		}
+	}
+	for key := range cfg.Overrides {
+		if !used[key] {
+			fmt.Printf("⚠️  Override %q matches no target\n", key)
+		}
+	}
+	return nil
+}
+
+// concurrencyFor is the concurrency t runs at.
+func concurrencyFor(t Target) int {
+	if t.Concurrency > 0 {
+		return t.Concurrency
+	}
+	return cfg.Concurrency
+}
+
+// timeoutFor is how long a request to t may take.
+func timeoutFor(t Target) time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return nativeTimeout
+}
+
+// requestsFor splits t's request count across the weighted targets sharing
+// t's Name, proportionally to their weight.
+func requestsFor(t Target, targets []Target) int {
+	requests := cfg.Requests
+	if t.Requests > 0 {
+		requests = t.Requests
+	}
+	if t.Weight <= 0 {
+		return requests
+	}
+	total := 0.0
+	for _, o := range targets {
+		if o.Name == t.Name {
+			total += o.Weight
+		}
+	}
+	if total <= 0 {
+		return requests
+	}
+	n := int(math.Round(float64(requests) * t.Weight / total))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func heyArgs(t Target, n, c int) []string {
+	if t.Bust != nil {
+		t = t.Bust.apply(t)
+	}
+	if n < c {
+		c = n
+	}
+	method := t.Method
+	if method == "" {
+		method = "GET"
+	}
+	args := []string{"-n", strconv.Itoa(n), "-c", strconv.Itoa(c), "-m", method}
+	if t.Timeout > 0 {
+		// hey takes whole seconds
+		args = append(args, "-t", strconv.Itoa(int(math.Ceil(t.Timeout.Seconds()))))
+	}
+	for _, h := range t.Headers {
+		args = append(args, "-H", h.Name+": "+h.Value)
+	}
+	if t.Body != "" {
+		args = append(args, "-d", t.Body)
+	}
+	return append(args, t.URL)
+}
+
+func routeOf(method, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method + " " + rawURL
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return method + " " + path
+}
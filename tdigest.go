@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// TDigest is a merging t-digest (Dunning & Ertl): a quantile sketch whose
+// size depends only on its compression, not on how many values it has
+// seen, with centroids kept small near the tails so that p99 and p99.9
+// stay accurate. It keeps soak tests with millions of samples in bounded
+// memory.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []float64
+	count       float64
+	min, max    float64
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+func NewTDigest(compression float64) *TDigest {
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (t *TDigest) Add(x float64) {
+	t.buffer = append(t.buffer, x)
+	t.count++
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+	if len(t.buffer) >= int(10*t.compression) {
+		t.flush()
+	}
+}
+
+func (t *TDigest) Count() float64 { return t.count }
+
+// flush merges the buffered values into the centroids, combining
+// neighbours while the result stays within the size bound for its
+// quantile, 4·n·q(1−q)/compression.
+func (t *TDigest) flush() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := make([]centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	for _, x := range t.buffer {
+		all = append(all, centroid{x, 1})
+	}
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	out := all[:0:0]
+	cur := all[0]
+	soFar := 0.0
+	for _, c := range all[1:] {
+		q := (soFar + (cur.weight+c.weight)/2) / t.count
+		if cur.weight+c.weight <= 4*t.count*q*(1-q)/t.compression {
+			cur.mean += (c.mean - cur.mean) * c.weight / (cur.weight + c.weight)
+			cur.weight += c.weight
+			continue
+		}
+		soFar += cur.weight
+		out = append(out, cur)
+		cur = c
+	}
+	t.centroids = append(out, cur)
+}
+
+// Quantile returns the estimated q-quantile, 0 ≤ q ≤ 1, interpolating
+// between centroid centres and the exact minimum and maximum.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.flush()
+	if len(t.centroids) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	target := q * t.count
+	cum := 0.0
+	prevMean, prevCenter := t.min, 0.0
+	for _, c := range t.centroids {
+		center := cum + c.weight/2
+		if target < center {
+			if c.weight == 1 && target >= cum {
+				return c.mean
+			}
+			return prevMean + (c.mean-prevMean)*(target-prevCenter)/(center-prevCenter)
+		}
+		cum += c.weight
+		prevMean, prevCenter = c.mean, center
+	}
+	if prevCenter >= t.count {
+		return t.max
+	}
+	return prevMean + (t.max-prevMean)*(target-prevCenter)/(t.count-prevCenter)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigestQuantiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	dists := []struct {
+		name string
+		draw func() float64
+	}{
+		{"uniform", r.Float64},
+		{"exponential", r.ExpFloat64},
+		{"lognormal", func() float64 { return math.Exp(r.NormFloat64()) }},
+	}
+	for _, d := range dists {
+		t.Run(d.name, func(t *testing.T) {
+			digest := NewTDigest(200)
+			xs := make([]float64, 200000)
+			for i := range xs {
+				xs[i] = d.draw()
+				digest.Add(xs[i])
+			}
+			sort.Float64s(xs)
+			for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.95, 0.99, 0.999} {
+				got := digest.Quantile(q)
+				// the error bound is in rank, tighter at the tails
+				rank := float64(sort.SearchFloat64s(xs, got)) / float64(len(xs))
+				tolerance := math.Max(0.002, 0.02*q*(1-q))
+				if math.Abs(rank-q) > tolerance {
+					t.Errorf("quantile %v = %v, which is rank %.4f (off by more than %v)", q, got, rank, tolerance)
+				}
+			}
+			if got := digest.Quantile(0); got != xs[0] {
+				t.Errorf("quantile 0 = %v, want the minimum %v", got, xs[0])
+			}
+			if got := digest.Quantile(1); got != xs[len(xs)-1] {
+				t.Errorf("quantile 1 = %v, want the maximum %v", got, xs[len(xs)-1])
+			}
+			if digest.Count() != float64(len(xs)) {
+				t.Errorf("count = %v, want %d", digest.Count(), len(xs))
+			}
+		})
+	}
+}
+
+func TestTDigestSmall(t *testing.T) {
+	if got := NewTDigest(200).Quantile(0.5); got != 0 {
+		t.Errorf("empty digest's median = %v, want 0", got)
+	}
+	digest := NewTDigest(200)
+	for _, x := range []float64{5, 1, 3, 2, 4} {
+		digest.Add(x)
+	}
+	tests := []struct{ q, want float64 }{{0, 1}, {0.1, 1}, {0.5, 3}, {0.9, 5}, {1, 5}}
+	for _, tt := range tests {
+		if got := digest.Quantile(tt.q); got != tt.want {
+			t.Errorf("quantile %v of 1..5 = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	one := NewTDigest(200)
+	one.Add(7)
+	for _, q := range []float64{0, 0.5, 0.99, 1} {
+		if got := one.Quantile(q); got != 7 {
+			t.Errorf("quantile %v of a single 7 = %v", q, got)
+		}
+	}
+}
+
+func TestTDigestMonotonic(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	digest := NewTDigest(100)
+	for i := 0; i < 50000; i++ {
+		digest.Add(r.ExpFloat64())
+	}
+	prev := math.Inf(-1)
+	for q := 0.0; q <= 1; q += 0.001 {
+		v := digest.Quantile(q)
+		if v < prev {
+			t.Fatalf("quantile %v = %v is below quantile %v's %v", q, v, q-0.001, prev)
+		}
+		prev = v
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Threshold is a pass/fail criterion on a suite-level metric, e.g.
+// "p95<500ms" or "slo_compliance>=99". Metrics are CSV column names; the
+// value compared is the mean across runs of each target, except
+// slo_compliance which is pooled over all requests. Latencies take an ms
+// or s suffix and are kept in milliseconds; a bare number is in --units.
+type Threshold struct {
+	Metric string
+	Op     string
+	Value  float64
+}
+
+var thresholdRe = regexp.MustCompile(`^\s*([a-z0-9_.]+)\s*(<=|>=|==|<|>)\s*(-?\d+(?:\.\d+)?)\s*(ms|s)?\s*$`)
+
+func parseThreshold(s string) (Threshold, error) {
+	m := thresholdRe.FindStringSubmatch(s)
+	if m == nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q, want e.g. p95<500ms", s)
+	}
+	v, _ := strconv.ParseFloat(m[3], 64)
+	if timeColumn(m[1]) {
+		v = fromUnit(v, m[4])
+	} else if m[4] != "" {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: %s isn't a latency", s, m[1])
+	}
+	return Threshold{Metric: m[1], Op: m[2], Value: v}, nil
+}
+
+func parseThresholds(specs []string) ([]Threshold, error) {
+	var out []Threshold
+	for _, s := range specs {
+		t, err := parseThreshold(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (t Threshold) String() string {
+	if timeColumn(t.Metric) {
+		return fmt.Sprintf("%s %s %v%s", t.Metric, t.Op, inUnit(t.Value), displayUnit)
+	}
+	return fmt.Sprintf("%s %s %v", t.Metric, t.Op, t.Value)
+}
+
+func (t Threshold) holds(v float64) bool {
+	switch t.Op {
+	case "<":
+		return v < t.Value
+	case "<=":
+		return v <= t.Value
+	case ">":
+		return v > t.Value
+	case ">=":
+		return v >= t.Value
+	default:
+		return v == t.Value
+	}
+}
+
+// seriesMeans averages a numeric column per series, skipping empty cells.
+func seriesMeans(rows []map[string]string, metric string) map[string]float64 {
+	sum := map[string]float64{}
+	count := map[string]int{}
+	for _, row := range rows {
+		v, err := strconv.ParseFloat(row[metric], 64)
+		if err != nil {
+			continue
+		}
+		key := rowSeriesKey(row)
+		sum[key] += v
+		count[key]++
+	}
+	out := map[string]float64{}
+	for key, c := range count {
+		out[key] = sum[key] / float64(c)
+	}
+	return out
+}
+
+// evaluateThresholds prints a verdict per threshold and series and reports
+// whether all of them held.
+func evaluateThresholds(rows []map[string]string, thresholds []Threshold) bool {
+	ok := true
+	for _, t := range thresholds {
+		values := seriesMeans(rows, t.Metric)
+		if t.Metric == "slo_compliance" {
+			values = suiteCompliance(rows)
+		}
+		if len(values) == 0 {
+			fmt.Printf("⚠️  Threshold %s: no %s values in the results\n", t, t.Metric)
+			ok = false
+			continue
+		}
+		var keys []string
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			shown := displayValue(t.Metric, values[k])
+			if t.holds(values[k]) {
+				fmt.Printf("✅ %s: %s = %.4f (%s)\n", k, withUnit(t.Metric), shown, t)
+			} else {
+				fmt.Printf("❌ %s: %s = %.4f violates %s\n", k, withUnit(t.Metric), shown, t)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
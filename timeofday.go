@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// timedRun is one run's start and value of a metric.
+type timedRun struct {
+	at time.Time
+	v  float64
+}
+
+// timeDrift is how a metric moved with wall-clock time over a suite,
+// estimated within targets so their differences don't count: the slope
+// of a regression with an intercept per target, in metric units per
+// hour, and its t statistic.
+type timeDrift struct {
+	slope, t float64
+	ok       bool
+}
+
+func estimateDrift(byTarget map[string][]timedRun, origin time.Time) timeDrift {
+	var sxx, sxy float64
+	n, groups := 0, 0
+	type centred struct{ tbar, ybar float64 }
+	centres := map[string]centred{}
+	for name, runs := range byTarget {
+		if len(runs) < 2 {
+			continue
+		}
+		var ts, ys []float64
+		for _, r := range runs {
+			ts = append(ts, r.at.Sub(origin).Hours())
+			ys = append(ys, r.v)
+		}
+		c := centred{mean(ts), mean(ys)}
+		centres[name] = c
+		for i := range ts {
+			sxx += (ts[i] - c.tbar) * (ts[i] - c.tbar)
+			sxy += (ts[i] - c.tbar) * (ys[i] - c.ybar)
+		}
+		n += len(runs)
+		groups++
+	}
+	df := n - groups - 1
+	if groups < 2 || df < 2 || sxx == 0 {
+		return timeDrift{}
+	}
+	b := sxy / sxx
+	rss := 0.0
+	for name, c := range centres {
+		for _, r := range byTarget[name] {
+			e := r.v - c.ybar - b*(r.at.Sub(origin).Hours()-c.tbar)
+			rss += e * e
+		}
+	}
+	se := math.Sqrt(rss / float64(df) / sxx)
+	t := math.Inf(1)
+	if se > 0 {
+		t = b / se
+	} else if b == 0 {
+		t = 0
+	}
+	return timeDrift{slope: b, t: t, ok: true}
+}
+
+// driftSignificant is the |t| beyond which a drift is taken as real, about
+// p < 0.05.
+const driftSignificant = 2.0
+
+// minTimeBias is the share of a target's mean, in percent, time alone must
+// account for before a comparison is called biased.
+const minTimeBias = 5.0
+
+// analyzeTimeOfDay correlates the runs' results with when they started.
+// With the suite running every target's runs before the next target's, a
+// busier period on the network or the servers lands on whichever target
+// ran then. It estimates, per route, the drift of latency and rps within
+// targets over the suite and, for targets that ran at different times,
+// how much of their difference the drift alone would explain, warning
+// when it's a real share. Each run's deviation from its target's mean is
+// charted against its start time.
+func analyzeTimeOfDay(rows []map[string]string, filename string) {
+	latency := "average"
+	if hasPercentile(95) {
+		latency = "p95"
+	}
+	metrics := []string{latency, "requests_per_sec"}
+
+	type group struct {
+		targets []string
+		runs    map[string]map[string][]timedRun // metric -> target -> runs
+		starts  map[string][]time.Time
+	}
+	groups := map[string]*group{}
+	var order []string
+	var origin time.Time
+	for _, row := range rows {
+		at, err := time.Parse(time.RFC3339Nano, row["started"])
+		if err != nil || row["agent"] != "" {
+			continue
+		}
+		if origin.IsZero() || at.Before(origin) {
+			origin = at
+		}
+		ctx := row["route"]
+		if len(cfg.Sweep) > 1 {
+			ctx = strings.TrimSpace(ctx + " c=" + row["concurrency"])
+		}
+		g, ok := groups[ctx]
+		if !ok {
+			g = &group{runs: map[string]map[string][]timedRun{}, starts: map[string][]time.Time{}}
+			groups[ctx] = g
+			order = append(order, ctx)
+		}
+		name := row["target"]
+		if _, ok := g.starts[name]; !ok {
+			g.targets = append(g.targets, name)
+		}
+		g.starts[name] = append(g.starts[name], at)
+		for _, m := range metrics {
+			if v, ok := rowFloat(row, m); ok {
+				if g.runs[m] == nil {
+					g.runs[m] = map[string][]timedRun{}
+				}
+				g.runs[m][name] = append(g.runs[m][name], timedRun{at, v})
+			}
+		}
+	}
+
+	clock := func(t time.Time) string {
+		if t.UTC().YearDay() != origin.UTC().YearDay() || t.Sub(origin) > 24*time.Hour {
+			return t.UTC().Format("Jan 2 15:04")
+		}
+		return t.UTC().Format("15:04")
+	}
+	// drift rates read per minute for a suite shorter than an hour
+	var last time.Time
+	for _, row := range rows {
+		if at, err := time.Parse(time.RFC3339Nano, row["started"]); err == nil && at.After(last) {
+			last = at
+		}
+	}
+	rate := func(perHour, of float64) string {
+		if last.Sub(origin) < time.Hour {
+			return fmt.Sprintf("%+.1f%% a minute", 100*perHour/60/of)
+		}
+		return fmt.Sprintf("%+.1f%% an hour", 100*perHour/of)
+	}
+	var b strings.Builder
+	var warnings []string
+	for _, ctx := range order {
+		g := groups[ctx]
+		if len(g.targets) < 2 {
+			continue
+		}
+		if ctx != "" {
+			fmt.Fprintf(&b, "### %s\n\n", ctx)
+		}
+		var table [][]string
+		for _, name := range g.targets {
+			starts := g.starts[name]
+			sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+			table = append(table, []string{name, fmt.Sprint(len(starts)), clock(starts[0]), clock(starts[len(starts)-1])})
+		}
+		b.WriteString(markdownTable([]string{"target", "runs", "first run (UTC)", "last run (UTC)"}, table) + "\n")
+
+		for _, m := range metrics {
+			d := estimateDrift(g.runs[m], origin)
+			if !d.ok {
+				continue
+			}
+			name := m
+			if m == "requests_per_sec" {
+				name = "rps"
+			}
+			var all []float64
+			for _, runs := range g.runs[m] {
+				for _, r := range runs {
+					all = append(all, r.v)
+				}
+			}
+			overall := mean(all)
+			if overall == 0 {
+				continue
+			}
+			sig := "not significant"
+			if math.Abs(d.t) >= driftSignificant {
+				sig = "significant"
+			}
+			fmt.Fprintf(&b, "%s drifted %s within targets (t = %.1f, %s).\n\n", name, rate(d.slope, overall), d.t, sig)
+			if sig != "significant" {
+				continue
+			}
+			for i, a := range g.targets {
+				for _, c := range g.targets[i+1:] {
+					ra, rc := g.runs[m][a], g.runs[m][c]
+					if len(ra) == 0 || len(rc) == 0 || periodOverlap(g.starts[a], g.starts[c]) >= 0.5 {
+						continue
+					}
+					ta, tc := meanStart(ra, origin), meanStart(rc, origin)
+					first, later, rf, rl := a, c, ra, rc
+					if tc < ta {
+						first, later, rf, rl = c, a, rc, ra
+						ta, tc = tc, ta
+					}
+					base := runMean(rf)
+					if base == 0 {
+						continue
+					}
+					bias := 100 * d.slope * (tc - ta) / base
+					if math.Abs(bias) < minTimeBias {
+						continue
+					}
+					measured := 100 * (runMean(rl) - base) / base
+					// later runs were slower, or had less throughput: the later target got the busier period
+					busier := later
+					if (m == "requests_per_sec") == (d.slope > 0) {
+						busier = first
+					}
+					w := fmt.Sprintf("%s ran after %s while %s drifted %s: time alone would put %s %+.1f%% from %s (measured %+.1f%%), and %s got the busier period",
+						later, first, name, rate(d.slope, overall), later, bias, first, measured, busier)
+					if ctx != "" {
+						w = ctx + ": " + w
+					}
+					warnings = append(warnings, w)
+				}
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+	for _, w := range warnings {
+		fmt.Println("⚠️ ", w)
+	}
+	body := "When each target's runs took place, and how the results drifted with wall-clock time within targets. " +
+		"The suite runs every target's runs before the next's, so a drift shows up as a difference between targets that ran at different times.\n\n" + b.String()
+	if len(warnings) > 0 {
+		body += "⚠️ The comparison is biased by when the targets ran:\n\n"
+		for _, w := range warnings {
+			body += "- " + w + "\n"
+		}
+		body += "\nRe-run with the targets in the other order, or as an A/B experiment with --ab, to tell the two apart.\n"
+	} else {
+		body += "✅ No comparison is biased by when the targets ran.\n"
+	}
+	addReportSection("Time-of-day bias", body)
+
+	scatter := charts.NewScatter()
+	scatter.SetGlobalOptions(
+		charts.WithTitleOpts(chartTitle("Time-of-Day Bias", withUnit(latency)+" of each run against its target's mean")),
+		charts.WithYAxisOpts(opts.YAxis{Name: "% of the target's mean"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Start Time", Type: "time"}),
+		charts.WithColorsOpts(seriesColors),
+	)
+	scatter.SetGlobalOptions(interactiveOpts("%")...)
+	byKey := map[string][]timedRun{}
+	var keys []string
+	for _, row := range rows {
+		at, err := time.Parse(time.RFC3339Nano, row["started"])
+		v, ok := rowFloat(row, latency)
+		key := rowSeriesKey(row)
+		if err != nil || !ok || row["agent"] != "" || !seriesWanted(key) {
+			continue
+		}
+		if _, seen := byKey[key]; !seen {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], timedRun{at, v})
+	}
+	for _, key := range keys {
+		m := runMean(byKey[key])
+		if m == 0 {
+			continue
+		}
+		var points []opts.ScatterData
+		for _, r := range byKey[key] {
+			points = append(points, opts.ScatterData{Value: []interface{}{r.at.Format(time.RFC3339Nano), round4(100 * r.v / m)}})
+		}
+		scatter.AddSeries(key, points)
+	}
+	renderChart(scatter, filename)
+}
+
+func runMean(runs []timedRun) float64 {
+	var xs []float64
+	for _, r := range runs {
+		xs = append(xs, r.v)
+	}
+	return mean(xs)
+}
+
+func meanStart(runs []timedRun, origin time.Time) float64 {
+	var xs []float64
+	for _, r := range runs {
+		xs = append(xs, r.at.Sub(origin).Hours())
+	}
+	return mean(xs)
+}
+
+// periodOverlap is how much of the shorter of the two periods from first
+// to last start the other overlaps, from 0 for one after the other to 1.
+func periodOverlap(a, b []time.Time) float64 {
+	span := func(ts []time.Time) (time.Time, time.Time) {
+		lo, hi := ts[0], ts[0]
+		for _, t := range ts {
+			if t.Before(lo) {
+				lo = t
+			}
+			if t.After(hi) {
+				hi = t
+			}
+		}
+		return lo, hi
+	}
+	alo, ahi := span(a)
+	blo, bhi := span(b)
+	lo, hi := alo, ahi
+	if blo.After(lo) {
+		lo = blo
+	}
+	if bhi.Before(hi) {
+		hi = bhi
+	}
+	shorter := ahi.Sub(alo)
+	if d := bhi.Sub(blo); d < shorter {
+		shorter = d
+	}
+	if hi.Before(lo) {
+		return 0
+	}
+	if shorter <= 0 {
+		return 1
+	}
+	return math.Min(1, float64(hi.Sub(lo))/float64(shorter))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transientWindow is the resolution transients are found at, finer than
+// percentileWindow so a pause of a second or two isn't spread over ten.
+const transientWindow = time.Second
+
+// minTransientSamples is how many requests a transientWindow needs for its
+// p99 to count; fewer, and it's little more than the slowest request.
+const minTransientSamples = 10
+
+// transient is a spell of consecutive transientWindows whose p99 reached
+// stallFactor times the run's median window p99.
+type transient struct {
+	start, duration time.Duration
+	peak            float64 // the highest p99, as a multiple of the median
+}
+
+// transients finds r's spikes.
+func (r *windowedRun) transients() []transient {
+	var p99s []float64
+	for i, ok := range r.fineOK {
+		if ok {
+			p99s = append(p99s, r.fine[i])
+		}
+	}
+	if len(p99s) == 0 {
+		return nil
+	}
+	median := quantile(p99s, 0.5)
+	var out []transient
+	var cur *transient
+	for i, ok := range r.fineOK {
+		if !ok || r.fine[i] < stallFactor*median {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			out = append(out, transient{start: time.Duration(i) * transientWindow})
+			cur = &out[len(out)-1]
+		}
+		cur.duration += transientWindow
+		cur.peak = math.Max(cur.peak, r.fine[i]/median)
+	}
+	return out
+}
+
+// runTransients is how one run's transients read: a spike at its very
+// start is warm-up, and three or more of the rest evenly spaced are
+// periodic.
+type runTransients struct {
+	warmup   bool
+	spikes   []transient // after any warm-up
+	period   time.Duration
+	periodic bool
+}
+
+func classifyTransients(ts []transient) runTransients {
+	var rt runTransients
+	if len(ts) > 0 && ts[0].start < 2*transientWindow {
+		rt.warmup, ts = true, ts[1:]
+	}
+	rt.spikes = ts
+	if len(ts) < 3 {
+		return rt
+	}
+	var gaps []float64
+	for i := 1; i < len(ts); i++ {
+		gaps = append(gaps, (ts[i].start - ts[i-1].start).Seconds())
+	}
+	p := quantile(gaps, 0.5)
+	rt.periodic = true
+	for _, g := range gaps {
+		if math.Abs(g-p) > 0.25*p {
+			rt.periodic = false
+		}
+	}
+	rt.period = time.Duration(p * float64(time.Second)).Round(transientWindow)
+	return rt
+}
+
+// suspectedCause guesses what recurring spikes are: every minute or so
+// suggests a cron job or other scheduled work; short ones recurring
+// otherwise, garbage collection.
+func suspectedCause(spikes int, period time.Duration, periodic bool, meanDuration time.Duration) string {
+	if periodic && period >= 50*time.Second {
+		if off := period % time.Minute; off <= period/10 || time.Minute-off <= period/10 {
+			return "cron or scheduled work"
+		}
+	}
+	if spikes >= 3 && meanDuration <= 2*transientWindow {
+		return "GC pauses"
+	}
+	return ""
+}
+
+// transientNotes are what the comparison says about the transients of
+// each target's series, by target name.
+var transientNotes = map[string][]string{}
+
+// analyzeTransients reports, per target, the latency spikes within its
+// long runs: how many, how long and how high, whether they recur on a
+// period, and what they suggest, so a comparison isn't read as steady
+// state where GC or a cron job was interfering.
+func analyzeTransients(runs []*windowedRun) {
+	type summary struct {
+		target        string
+		runs, warmups int
+		spikes        []transient
+		periods       []float64
+	}
+	byKey := map[string]*summary{}
+	var order []string
+	for _, r := range runs {
+		s, ok := byKey[r.key]
+		if !ok {
+			s = &summary{target: r.target}
+			byKey[r.key] = s
+			order = append(order, r.key)
+		}
+		rt := classifyTransients(r.transients())
+		s.runs++
+		if rt.warmup {
+			s.warmups++
+		}
+		s.spikes = append(s.spikes, rt.spikes...)
+		if rt.periodic {
+			s.periods = append(s.periods, rt.period.Seconds())
+		}
+	}
+
+	var table [][]string
+	found := false
+	for _, key := range order {
+		s := byKey[key]
+		if len(s.spikes) == 0 && s.warmups == 0 {
+			table = append(table, []string{key, strconv.Itoa(s.runs), "0", "-", "-", "-", "-", "-"})
+			continue
+		}
+		found = true
+		var duration time.Duration
+		peak := 0.0
+		for _, t := range s.spikes {
+			duration += t.duration
+			peak = math.Max(peak, t.peak)
+		}
+		meanDuration := time.Duration(0)
+		if len(s.spikes) > 0 {
+			meanDuration = duration / time.Duration(len(s.spikes))
+		}
+		// periodic only if most runs recur on it
+		period, periodic := time.Duration(0), 2*len(s.periods) > s.runs
+		if periodic {
+			period = time.Duration(quantile(s.periods, 0.5) * float64(time.Second)).Round(transientWindow)
+		}
+		cause := suspectedCause(len(s.spikes)/s.runs, period, periodic, meanDuration)
+
+		cells := []string{key, strconv.Itoa(s.runs), strconv.Itoa(len(s.spikes)), "-", "-", "-", fmt.Sprintf("%d of %d runs", s.warmups, s.runs), "-"}
+		if len(s.spikes) > 0 {
+			cells[3], cells[4] = meanDuration.String(), fmt.Sprintf("%.1f×", peak)
+		}
+		if periodic {
+			cells[5] = "every ~" + period.String()
+		}
+		if cause != "" {
+			cells[7] = "⚠️ " + cause
+		}
+		table = append(table, cells)
+
+		var note []string
+		if len(s.spikes) > 0 {
+			runs := "runs"
+			if s.runs == 1 {
+				runs = "run"
+			}
+			n := fmt.Sprintf("%d p99 spikes in %d %s, lasting %v on average and up to %.1f× its usual p99", len(s.spikes), s.runs, runs, meanDuration, peak)
+			if periodic {
+				n += fmt.Sprintf(", every ~%v", period)
+			}
+			if cause != "" {
+				n += "; suspected " + cause
+			}
+			note = append(note, n)
+		}
+		if s.warmups > 0 {
+			note = append(note, fmt.Sprintf("a warm-up spike at the start of %d of %d runs", s.warmups, s.runs))
+		}
+		fmt.Printf("⚠️  %s: %s\n", key, strings.Join(note, "; "))
+		transientNotes[s.target] = append(transientNotes[s.target], key+" had "+strings.Join(note, " and "))
+	}
+	body := fmt.Sprintf("Latency spikes within the runs of any target with runs spanning two %v windows or more: spells of %v windows whose p99 "+
+		"reached %v× the run's median. A spike in the first seconds is counted as warm-up. Three or more evenly spaced spikes in a run "+
+		"recur on a period; about every minute suggests cron or other scheduled work, and short ones, GC pauses. Magnitude is the "+
+		"highest p99 as a multiple of the run's median.\n\n", percentileWindow, transientWindow, stallFactor)
+	if !found {
+		body += "No transients found.\n\n"
+	}
+	addReportSection("Transients", body+markdownTable([]string{"target", "runs", "spikes", "mean duration", "magnitude", "periodicity", "warm-up", "suspected"}, table))
+}
+
+// transientNarrative is the comparison's caution about the targets'
+// transients, or "" without any.
+func transientNarrative(targets []string) string {
+	var b strings.Builder
+	seen := map[string]bool{}
+	sorted := append([]string(nil), targets...)
+	sort.Strings(sorted)
+	for _, t := range sorted {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		for _, note := range transientNotes[t] {
+			fmt.Fprintf(&b, "- ⚠️ %s. Its means include them, so a difference may be interference rather than steady-state performance.\n", note)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "Transients:\n\n" + b.String() + "\n"
+}
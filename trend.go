@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// trendSuite is one suite of the results store: when it started and each
+// target's runs of every charted metric.
+type trendSuite struct {
+	id      string
+	started time.Time
+	values  map[string]map[string][]float64 // target -> metric -> runs
+}
+
+// runTrend implements `trend`: it charts each target's mean of every
+// metric across the last --last suites in the results store, one point per
+// suite, to show how deployments evolve week over week.
+func runTrend(args []string) {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	config := fs.String("config", "", "config whose results store and units to use")
+	store := fs.String("store", "", "results store to read (default: the config's, or "+defaultStore+")")
+	last := fs.Int("last", 10, "chart the newest N suites")
+	metricList := fs.String("metrics", "rps,p95", "metrics to chart, one chart each")
+	tag := fs.String("tag", "", "only suites run with this --tag")
+	out := fs.String("out", "trend", "directory to write the charts to")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	cfg = c
+	if cfg.Units != "" {
+		if err := validateUnits(cfg.Units); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		displayUnit = cfg.Units
+	}
+	if *store == "" {
+		*store = cfg.Store
+	}
+	if *store == "" {
+		*store = defaultStore
+	}
+	metrics := strings.Split(*metricList, ",")
+	for _, m := range metrics {
+		if !numericColumn(metricColumn(m)) {
+			fmt.Printf("❌ %s isn't a numeric metric\n", m)
+			os.Exit(1)
+		}
+	}
+
+	suites, err := loadTrend(*store, *tag, metrics)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	if len(suites) < 2 {
+		fmt.Printf("❌ %s has %d matching suites, need at least 2 for a trend\n", *store, len(suites))
+		os.Exit(1)
+	}
+	if len(suites) > *last {
+		suites = suites[len(suites)-*last:]
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+
+	var targets []string
+	seen := map[string]bool{}
+	for _, s := range suites {
+		for t := range s.values {
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	sort.Strings(targets)
+	for _, m := range metrics {
+		file := filepath.Join(*out, "trend_"+strings.ReplaceAll(m, ".", "_")+".html")
+		if err := writeTrendChart(file, suites, targets, m); err != nil {
+			fmt.Println("❌", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Chart written to %s\n", file)
+	}
+
+	first, latest := suites[0], suites[len(suites)-1]
+	fmt.Printf("→ %s → %s, %d suites\n", first.started.Format("2006-01-02"), latest.started.Format("2006-01-02"), len(suites))
+	for _, t := range targets {
+		var parts []string
+		for _, m := range metrics {
+			a, okA := first.values[t][m]
+			b, okB := latest.values[t][m]
+			if !okA || !okB || mean(a) == 0 {
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s %.2f → %.2f (%+.1f%%)", withUnit(m),
+				displayValue(metricColumn(m), mean(a)), displayValue(metricColumn(m), mean(b)), 100*(mean(b)-mean(a))/mean(a)))
+		}
+		if len(parts) > 0 {
+			fmt.Printf("   %s: %s\n", t, strings.Join(parts, ", "))
+		}
+	}
+}
+
+// loadTrend reads the store's suites, oldest first, upgrading older rows
+// to the current schema so their values are comparable.
+func loadTrend(path, tag string, metrics []string) ([]trendSuite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bySuite := map[string]*trendSuite{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec StoreRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.Row == nil || rec.Row["agent"] != "" {
+			continue
+		}
+		if tag != "" && !hasTag(rec.Tags, tag) {
+			continue
+		}
+		if _, err := migrateRow(nil, rec.Row); err != nil {
+			continue
+		}
+		s, ok := bySuite[rec.Suite]
+		if !ok {
+			s = &trendSuite{id: rec.Suite, started: rec.Time, values: map[string]map[string][]float64{}}
+			bySuite[rec.Suite] = s
+		}
+		target := rowTargetKey(rec.Row)
+		if s.values[target] == nil {
+			s.values[target] = map[string][]float64{}
+		}
+		for _, m := range metrics {
+			if v, ok := rowFloat(rec.Row, metricColumn(m)); ok {
+				s.values[target][m] = append(s.values[target][m], v)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var suites []trendSuite
+	for _, s := range bySuite {
+		suites = append(suites, *s)
+	}
+	sort.Slice(suites, func(i, j int) bool { return suites[i].started.Before(suites[j].started) })
+	return suites, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTrendChart(file string, suites []trendSuite, targets []string, metric string) error {
+	col := metricColumn(metric)
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Trend: " + metric, Subtitle: "mean per suite"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: withUnit(metric)}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Suite"}),
+		charts.WithColorsOpts(seriesColors),
+	)
+	line.SetGlobalOptions(interactiveOpts(unitOf(metric))...)
+	var xAxis []string
+	for _, s := range suites {
+		xAxis = append(xAxis, s.started.Local().Format("2006-01-02 15:04"))
+	}
+	line.SetXAxis(xAxis)
+	for _, t := range targets {
+		var points []opts.LineData
+		for _, s := range suites {
+			vs := s.values[t][metric]
+			if len(vs) == 0 {
+				points = append(points, opts.LineData{Value: "-"}) // the target wasn't in this suite
+				continue
+			}
+			points = append(points, opts.LineData{Value: round4(displayValue(col, mean(vs)))})
+		}
+		line.AddSeries(t, points)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return line.Render(f)
+}
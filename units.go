@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Time metrics are stored in milliseconds in every result row, whatever
+// hey or the raw files report them in; displayUnit (--units) only changes
+// how charts, the report and the console show them.
+var displayUnit = "ms"
+
+var percentileColumn = regexp.MustCompile(`^p\d+(\.\d+)?$`)
+
+// timeColumn reports whether a result column holds a duration.
+func timeColumn(h string) bool {
+	switch h {
+	case "total", "average", "fastest", "slowest", "spread", "timeout", "network_floor":
+		return true
+	}
+	return percentileColumn.MatchString(h)
+}
+
+func validateUnits(u string) error {
+	if u != "ms" && u != "s" {
+		return fmt.Errorf("invalid units %q, want ms or s", u)
+	}
+	return nil
+}
+
+// inUnit converts a stored millisecond value to the display unit.
+func inUnit(ms float64) float64 {
+	if displayUnit == "s" {
+		return ms / 1000
+	}
+	return ms
+}
+
+// fromUnit converts a value given in unit ("ms", "s", or "" for the display
+// unit) to milliseconds.
+func fromUnit(v float64, unit string) float64 {
+	if unit == "" {
+		unit = displayUnit
+	}
+	if unit == "s" {
+		return v * 1000
+	}
+	return v
+}
+
+// displayValue converts a column's stored value for display; only time
+// columns change.
+func displayValue(h string, v float64) float64 {
+	if timeColumn(h) {
+		return inUnit(v)
+	}
+	return v
+}
+
+// withUnit annotates a column name with its display unit, e.g. "p95 (ms)".
+func withUnit(h string) string {
+	if u := unitOf(h); u != "" {
+		return h + " (" + u + ")"
+	}
+	return h
+}
+
+// unitOf is the display unit of a column, "" for plain counts and rates.
+func unitOf(h string) string {
+	if timeColumn(h) {
+		return displayUnit
+	}
+	return fixedUnits[h]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fixedUnits are the units of the columns that aren't latencies.
+var fixedUnits = map[string]string{"size_request": "bytes", "bandwidth": "MB/s", "error_rate": "%"}
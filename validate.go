@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// runValidate implements `validate FILE...`: it checks result CSVs before
+// anyone trusts a report built on them, and exits 1 if any has anomalies:
+// an unknown schema or missing columns, non-numeric cells, percentiles
+// that aren't monotonic, negative throughput or latency, duplicate runs,
+// and targets with fewer runs than the others (or than --runs).
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	config := fs.String("config", "", "config whose csv dialect and repeat the files use")
+	runs := fs.Int("runs", 0, "expected runs per target and concurrency (default: the config's repeat with --config, else the most any target has)")
+	fs.Parse(args)
+
+	c, err := loadConfig(*config)
+	if err != nil {
+		fmt.Println("❌ Error loading config:", err)
+		os.Exit(1)
+	}
+	cfg = c
+	if *runs == 0 && *config != "" {
+		*runs = c.Repeat
+	}
+
+	if fs.NArg() == 0 && *config != "" {
+		fmt.Printf("✅ %s is valid\n", *config)
+	}
+	failed := false
+	for _, file := range fs.Args() {
+		anomalies, rows, err := validateCSV(file, *runs)
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %s: %v\n", file, err)
+			failed = true
+		case len(anomalies) > 0:
+			for _, a := range anomalies {
+				fmt.Println("⚠️ ", a)
+			}
+			fmt.Printf("❌ %s: %d anomalies in %d rows\n", file, len(anomalies), rows)
+			failed = true
+		default:
+			fmt.Printf("✅ %s: %d rows, no anomalies\n", file, rows)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+var requiredColumns = []string{"file", "target", "total", "average", "requests_per_sec"}
+
+func validateCSV(file string, runs int) ([]string, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	reader := newCSVReader(f)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, 0, fmt.Errorf("empty file")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var anomalies []string
+	var percentiles []string
+	for _, h := range headers {
+		if percentileColumn.MatchString(h) {
+			percentiles = append(percentiles, h)
+		}
+	}
+	sort.Slice(percentiles, func(i, j int) bool {
+		return parseFloat(percentiles[i][1:]) < parseFloat(percentiles[j][1:])
+	})
+
+	counts := map[string]int{}
+	var order []string
+	seen := map[string]bool{}
+	n := 0
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return anomalies, n, err
+		}
+		n++
+		line, _ := reader.FieldPos(0)
+		at := fmt.Sprintf("%s:%d", file, line)
+		if len(rec) != len(headers) {
+			anomalies = append(anomalies, fmt.Sprintf("%s: %d fields, want %d", at, len(rec), len(headers)))
+		}
+		row := map[string]string{}
+		for i, h := range headers {
+			if i < len(rec) {
+				row[h] = csvNumber(rec[i])
+			}
+		}
+		if _, err := migrateRow(headers, row); err != nil {
+			return anomalies, n, fmt.Errorf("%s: %w", at, err)
+		}
+		if n == 1 {
+			for _, h := range requiredColumns {
+				if _, ok := row[h]; !ok {
+					anomalies = append(anomalies, fmt.Sprintf("%s: missing column %s", file, h))
+				}
+			}
+		}
+		anomalies = append(anomalies, checkRow(at, headers, percentiles, row)...)
+		if kept, _ := dedupeRuns([]map[string]string{row}, seen); len(kept) == 0 {
+			anomalies = append(anomalies, fmt.Sprintf("%s: duplicate run %s", at, row["run_id"]))
+			continue
+		}
+
+		key := rowTargetKey(row)
+		if row["concurrency"] != "" {
+			key += " c=" + row["concurrency"]
+		}
+		if _, ok := counts[key]; !ok {
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	if n == 0 {
+		return nil, 0, fmt.Errorf("no result rows")
+	}
+
+	want := runs
+	if want == 0 {
+		for _, c := range counts {
+			if c > want {
+				want = c
+			}
+		}
+	}
+	for _, key := range order {
+		if counts[key] != want {
+			anomalies = append(anomalies, fmt.Sprintf("%s: %s has %d runs, want %d", file, key, counts[key], want))
+		}
+	}
+	return anomalies, n, nil
+}
+
+// checkRow reports a row's malformed and out-of-range values.
+func checkRow(at string, headers, percentiles []string, row map[string]string) []string {
+	var out []string
+	for _, h := range headers {
+		v := row[h]
+		if v == "" || !numericColumn(h) {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			out = append(out, fmt.Sprintf("%s: %s %q is not a number", at, h, v))
+		case f < 0 && (timeColumn(h) || h == "requests_per_sec" || h == "size_request" || h == "concurrency" || h == "error_rate"):
+			out = append(out, fmt.Sprintf("%s: %s is negative (%v)", at, h, v))
+		case (h == "slo_compliance" || h == "error_rate") && f > 100:
+			out = append(out, fmt.Sprintf("%s: %s is over 100%% (%v)", at, h, v))
+		}
+	}
+
+	// fastest ≤ p50 ≤ … ≤ p99 ≤ slowest, skipping missing values
+	chain := append(append([]string{"fastest"}, percentiles...), "slowest")
+	prev, prevName := 0.0, ""
+	for _, h := range chain {
+		v, ok := rowFloat(row, h)
+		if !ok {
+			continue
+		}
+		if prevName != "" && v < prev {
+			out = append(out, fmt.Sprintf("%s: %s (%v) is below %s (%v)", at, h, row[h], prevName, row[prevName]))
+		}
+		prev, prevName = v, h
+	}
+	if avg, ok := rowFloat(row, "average"); ok {
+		if lo, ok := rowFloat(row, "fastest"); ok && avg < lo {
+			out = append(out, fmt.Sprintf("%s: average (%v) is below fastest (%v)", at, row["average"], row["fastest"]))
+		}
+		if hi, ok := rowFloat(row, "slowest"); ok && avg > hi {
+			out = append(out, fmt.Sprintf("%s: average (%v) is above slowest (%v)", at, row["average"], row["slowest"]))
+		}
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Webhook is a URL POSTed the suite's lifecycle events as JSON, for
+// downstream automation: suite_start, run_complete after every run and
+// suite_complete with the suite's metrics. With a secret, from Secret or
+// the variable named by SecretEnv, every body is signed with HMAC-SHA256
+// in X-Pertools-Signature-256, as GitHub signs its webhooks. Events limits
+// which are sent, all by default.
+type Webhook struct {
+	URL       string            `json:"url"`
+	Secret    string            `json:"secret"`
+	SecretEnv string            `json:"secret_env"`
+	Events    []string          `json:"events"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// webhookEvents are the events a webhook can be sent, in the order a
+// suite sends them.
+var webhookEvents = []string{"suite_start", "run_complete", "suite_complete"}
+
+// WebhookEvent is a webhook's body. Every event has Event, Suite, Time and
+// Labels; the rest belong to one event each.
+type WebhookEvent struct {
+	Event  string            `json:"event"`
+	Suite  string            `json:"suite"`
+	Time   time.Time         `json:"time"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// suite_start
+	Engine  string           `json:"engine,omitempty"`
+	Targets []ManifestTarget `json:"targets,omitempty"`
+
+	// run_complete: the run's rows, the mix's routes and its aggregate
+	Target      string              `json:"target,omitempty"`
+	Run         int                 `json:"run,omitempty"`
+	Concurrency int                 `json:"concurrency,omitempty"`
+	Results     []map[string]string `json:"results,omitempty"`
+
+	// suite_complete
+	Series    []WebhookSeries   `json:"series,omitempty"`
+	Passed    *bool             `json:"passed,omitempty"`
+	Truncated string            `json:"truncated,omitempty"`
+	Aborted   map[string]string `json:"aborted,omitempty"`
+}
+
+// WebhookSeries is a series' metrics over the suite, the means of its
+// runs.
+type WebhookSeries struct {
+	Series  string             `json:"series"`
+	Runs    int                `json:"runs"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// webhookMetrics are the columns suite_complete averages per series.
+var webhookMetrics = []string{"requests_per_sec", "average", "p50", "p90", "p95", "p99", "error_rate", "slo_compliance"}
+
+const webhookAttempts = 3
+
+func validateWebhooks(hooks []Webhook) error {
+	for _, h := range hooks {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url %q", redact(h.URL))
+		}
+		for _, ev := range h.Events {
+			if !contains(webhookEvents, ev) {
+				return fmt.Errorf("%s: unknown event %q, want one of %s", redact(h.URL), ev, strings.Join(webhookEvents, ", "))
+			}
+		}
+		if h.SecretEnv != "" && os.Getenv(h.SecretEnv) == "" {
+			return fmt.Errorf("%s: secret_env %s isn't set", redact(h.URL), h.SecretEnv)
+		}
+	}
+	return nil
+}
+
+func (h *Webhook) secret() string {
+	if h.SecretEnv != "" {
+		return os.Getenv(h.SecretEnv)
+	}
+	return h.Secret
+}
+
+// webhookSink delivers one webhook's events in order, in the background so
+// a slow receiver doesn't hold up the suite between runs. Once it's
+// webhookQueue events behind, new events are dropped and counted.
+type webhookSink struct {
+	hook    *Webhook
+	queue   chan WebhookEvent
+	done    chan struct{}
+	sent    int
+	failed  int
+	dropped int
+}
+
+const webhookQueue = 64
+
+var webhookSinks []*webhookSink
+
+func startWebhooks(targets []Target) {
+	for i := range cfg.Webhooks {
+		s := &webhookSink{hook: &cfg.Webhooks[i], queue: make(chan WebhookEvent, webhookQueue), done: make(chan struct{})}
+		webhookSinks = append(webhookSinks, s)
+		go s.deliver()
+	}
+	if len(webhookSinks) == 0 {
+		return
+	}
+	ev := WebhookEvent{Event: "suite_start", Engine: *engine}
+	for _, t := range targets {
+		ev.Targets = append(ev.Targets, ManifestTarget{Name: t.Name, Route: t.Route, URL: redact(t.URL), Version: versions[t.Name]})
+	}
+	notifyWebhooks(ev)
+}
+
+// notifyRun sends run_complete for run i of the target labelled target.
+func notifyRun(target string, i, concurrency int, rows []map[string]string) {
+	ev := WebhookEvent{Event: "run_complete", Target: target, Run: i, Concurrency: concurrency}
+	for _, row := range rows {
+		ev.Results = append(ev.Results, publicRow(row))
+	}
+	notifyWebhooks(ev)
+}
+
+// finishWebhooks sends suite_complete with every series' means and waits
+// for the deliveries still queued.
+func finishWebhooks(rows []map[string]string, passed bool) {
+	if len(webhookSinks) == 0 {
+		return
+	}
+	// the suite waits for the deliveries now, so suite_complete waits for
+	// room rather than being dropped
+	ev := WebhookEvent{Event: "suite_complete", Series: suiteSeries(rows), Passed: &passed, Truncated: truncated, Aborted: abortedTargets}
+	ev.Suite, ev.Time, ev.Labels = suiteID, time.Now().UTC(), labels
+	for _, s := range webhookSinks {
+		if s.wants(ev) {
+			s.queue <- ev
+		}
+		close(s.queue)
+	}
+	for _, s := range webhookSinks {
+		<-s.done
+		if s.dropped > 0 {
+			fmt.Printf("⚠️  Dropped %d events for %s, which fell behind\n", s.dropped, redact(s.hook.URL))
+		}
+		if s.failed == 0 && s.dropped == 0 {
+			fmt.Printf("✅ Sent %d events to %s\n", s.sent, redact(s.hook.URL))
+		}
+	}
+}
+
+// suiteSeries is every series' runs and the means of its webhookMetrics,
+// by series.
+func suiteSeries(rows []map[string]string) []WebhookSeries {
+	values := map[string]map[string][]float64{}
+	runs := map[string]int{}
+	var order []string
+	for _, row := range rows {
+		if row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		if values[key] == nil {
+			values[key] = map[string][]float64{}
+			order = append(order, key)
+		}
+		runs[key]++
+		for _, m := range webhookMetrics {
+			if v, ok := rowFloat(row, m); ok {
+				values[key][m] = append(values[key][m], v)
+			}
+		}
+	}
+	sort.Strings(order)
+	var out []WebhookSeries
+	for _, key := range order {
+		s := WebhookSeries{Series: key, Runs: runs[key], Metrics: map[string]float64{}}
+		for m, xs := range values[key] {
+			s.Metrics[m] = round4(mean(xs))
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func notifyWebhooks(ev WebhookEvent) {
+	ev.Suite, ev.Time, ev.Labels = suiteID, time.Now().UTC(), labels
+	for _, s := range webhookSinks {
+		if !s.wants(ev) {
+			continue
+		}
+		select {
+		case s.queue <- ev:
+		default:
+			if s.dropped == 0 {
+				fmt.Printf("⚠️  Webhook %s is %d events behind; dropping events until it catches up\n", redact(s.hook.URL), webhookQueue)
+			}
+			s.dropped++
+		}
+	}
+}
+
+func (s *webhookSink) wants(ev WebhookEvent) bool {
+	return len(s.hook.Events) == 0 || contains(s.hook.Events, ev.Event)
+}
+
+func (s *webhookSink) deliver() {
+	defer close(s.done)
+	for ev := range s.queue {
+		if err := s.post(ev); err != nil {
+			s.failed++
+			fmt.Printf("⚠️  Webhook %s failed on %s: %s\n", redact(s.hook.URL), ev.Event, redact(err.Error()))
+			continue
+		}
+		s.sent++
+	}
+}
+
+var deliveries int64
+
+// post sends ev, retrying a failed connection or a 5xx or 429 answer with
+// a growing pause. Every attempt carries the same delivery ID, so the
+// receiver can tell a retry from a new event.
+func (s *webhookSink) post(ev WebhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	delivery := suiteID + "-" + strconv.FormatInt(atomic.AddInt64(&deliveries, 1), 10)
+	client := &http.Client{Timeout: 10 * time.Second}
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, s.hook.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "custom-per-tools")
+		req.Header.Set("X-Pertools-Event", ev.Event)
+		req.Header.Set("X-Pertools-Delivery", delivery)
+		if secret := s.hook.secret(); secret != "" {
+			req.Header.Set("X-Pertools-Signature-256", "sha256="+signBody(secret, body))
+		}
+		for k, v := range s.hook.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		retry := err != nil
+		if err == nil {
+			resp.Body.Close()
+			switch {
+			case resp.StatusCode < 300:
+				return nil
+			case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+				retry = true
+			}
+			err = fmt.Errorf("webhook answered %s", resp.Status)
+		}
+		if !retry || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// signBody is the hex HMAC-SHA256 of body under secret.
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignBody(t *testing.T) {
+	tests := []struct {
+		secret, body, want string
+	}{
+		// GitHub's example for validating webhook deliveries
+		{"It's a Secret to Everybody", "Hello, World!", "757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"},
+		// RFC 4231 test case 2
+		{"Jefe", "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+	}
+	for _, tt := range tests {
+		if got := signBody(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("signBody(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	var signature, delivery []string
+	var bodies [][]byte
+	status := []int{http.StatusServiceUnavailable, http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		signature = append(signature, r.Header.Get("X-Pertools-Signature-256"))
+		delivery = append(delivery, r.Header.Get("X-Pertools-Delivery"))
+		w.WriteHeader(status[len(bodies)-1])
+	}))
+	defer srv.Close()
+
+	s := &webhookSink{hook: &Webhook{URL: srv.URL, Secret: "s3cret"}}
+	if err := s.post(WebhookEvent{Event: "run_complete", Target: "api", Run: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("%d attempts, want a retry after the 503", len(bodies))
+	}
+	for i, body := range bodies {
+		if want := "sha256=" + signBody("s3cret", body); signature[i] != want {
+			t.Errorf("attempt %d signed %q, want %q", i+1, signature[i], want)
+		}
+	}
+	if delivery[0] == "" || delivery[0] != delivery[1] {
+		t.Errorf("deliveries %q, want one ID for both attempts", delivery)
+	}
+
+	status = []int{http.StatusBadRequest}
+	bodies = nil
+	unsigned := &webhookSink{hook: &Webhook{URL: srv.URL}}
+	if err := unsigned.post(WebhookEvent{Event: "suite_start"}); err == nil {
+		t.Error("post answered 400 = nil, want an error")
+	}
+	if len(bodies) != 1 || signature[len(signature)-1] != "" {
+		t.Errorf("%d attempts signed %q, want one unsigned attempt", len(bodies), signature[len(signature)-1])
+	}
+}
+
+func TestNotifyWebhooksDrops(t *testing.T) {
+	saved := webhookSinks
+	defer func() { webhookSinks = saved }()
+	// sinks nothing delivers from, as if their receivers had stalled
+	all := &webhookSink{hook: &Webhook{}, queue: make(chan WebhookEvent, webhookQueue)}
+	some := &webhookSink{hook: &Webhook{Events: []string{"suite_start"}}, queue: make(chan WebhookEvent, webhookQueue)}
+	webhookSinks = []*webhookSink{all, some}
+
+	for i := 0; i < webhookQueue+5; i++ {
+		notifyWebhooks(WebhookEvent{Event: "run_complete", Run: i + 1})
+	}
+	if len(all.queue) != webhookQueue || all.dropped != 5 {
+		t.Errorf("queued %d and dropped %d, want %d and 5", len(all.queue), all.dropped, webhookQueue)
+	}
+	if first := <-all.queue; first.Run != 1 {
+		t.Errorf("first queued run %d, want the oldest events kept", first.Run)
+	}
+	if len(some.queue) != 0 || some.dropped != 0 {
+		t.Errorf("a sink without run_complete queued %d and dropped %d, want neither", len(some.queue), some.dropped)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// percentileWindow is how long the windows a long run's percentiles are
+// taken over are.
+const percentileWindow = 10 * time.Second
+
+// stallFactor flags a window whose p99 is this many times the run's
+// median window p99.
+const stallFactor = 2.0
+
+// windowedRun is a run's p50, p95 and p99 in milliseconds per window;
+// ok is false for a window without a successful request.
+type windowedRun struct {
+	key, target, runID string
+	run                int
+	p50, p95           []float64
+	p99                []float64
+	ok                 []bool
+
+	// p99 per transientWindow, for finding transients; fineOK is false
+	// for a window with too few requests to tell
+	fine   []float64
+	fineOK []bool
+}
+
+// windowRun buckets a raw file's successful requests by when they were
+// sent, keeping a t-digest per window so an hour-long run needs little
+// memory. Runs spanning fewer than two windows have nothing to show.
+func windowRun(file string) (*windowedRun, error) {
+	var digests, fine []*TDigest
+	span, err := streamRawLatencies(file, func(s sample) {
+		if s.err != "" {
+			return
+		}
+		w := int(s.offset / percentileWindow)
+		for len(digests) <= w {
+			digests = append(digests, NewTDigest(100))
+		}
+		digests[w].Add(millis(s.latency))
+		f := int(s.offset / transientWindow)
+		for len(fine) <= f {
+			fine = append(fine, NewTDigest(20))
+		}
+		fine[f].Add(millis(s.latency))
+	})
+	if err != nil || span < 2*percentileWindow {
+		return nil, err
+	}
+	r := &windowedRun{}
+	for _, d := range digests {
+		ok := d.Count() > 0
+		r.ok = append(r.ok, ok)
+		if !ok {
+			r.p50, r.p95, r.p99 = append(r.p50, 0), append(r.p95, 0), append(r.p99, 0)
+			continue
+		}
+		r.p50 = append(r.p50, d.Quantile(0.50))
+		r.p95 = append(r.p95, d.Quantile(0.95))
+		r.p99 = append(r.p99, d.Quantile(0.99))
+	}
+	for _, d := range fine {
+		ok := d.Count() >= minTransientSamples
+		r.fineOK = append(r.fineOK, ok)
+		if !ok {
+			r.fine = append(r.fine, 0)
+			continue
+		}
+		r.fine = append(r.fine, d.Quantile(0.99))
+	}
+	return r, nil
+}
+
+// stalls returns the run's median window p99 and the windows whose p99 is
+// stallFactor times that or more.
+func (r *windowedRun) stalls() (float64, []int) {
+	var p99s []float64
+	for i, ok := range r.ok {
+		if ok {
+			p99s = append(p99s, r.p99[i])
+		}
+	}
+	if len(p99s) == 0 {
+		return 0, nil
+	}
+	median := quantile(p99s, 0.5)
+	var out []int
+	for i, ok := range r.ok {
+		if ok && r.p99[i] >= stallFactor*median {
+			out = append(out, i)
+		}
+	}
+	return median, out
+}
+
+// analyzeWindows charts p50, p95 and p99 over percentileWindow windows
+// within every run long enough to have two, from its raw latencies. A GC
+// pause or a periodic stall shows as a spike the run's summary numbers
+// average away; windows whose p99 reaches stallFactor times the run's
+// median window are listed in the report.
+func analyzeWindows(rows []map[string]string, filename string) {
+	runs := make([]*windowedRun, len(rows))
+	parallel(len(rows), func(i int) {
+		if rows[i]["raw_file"] == "" || rows[i]["agent"] != "" {
+			return
+		}
+		r, err := windowRun(filepath.Join(outDir, rows[i]["raw_file"]))
+		if err != nil {
+			fmt.Printf("⚠️  Couldn't read %s for percentiles over time: %v\n", rows[i]["raw_file"], err)
+			return
+		}
+		runs[i] = r
+	})
+	index := map[string]int{}
+	var long []*windowedRun
+	for i, row := range rows {
+		if row["agent"] != "" {
+			continue
+		}
+		key := rowSeriesKey(row)
+		index[key]++
+		if r := runs[i]; r != nil {
+			r.key, r.target, r.runID, r.run = key, row["target"], row["run_id"], index[key]
+			long = append(long, r)
+		}
+	}
+	if len(long) == 0 {
+		return
+	}
+	sort.SliceStable(long, func(i, j int) bool { return long[i].key < long[j].key })
+
+	var table [][]string
+	for _, r := range long {
+		median, stalled := r.stalls()
+		worst := 0
+		for i := range r.p99 {
+			if r.ok[i] && r.p99[i] > r.p99[worst] {
+				worst = i
+			}
+		}
+		var at []string
+		for _, w := range stalled {
+			at = append(at, windowLabel(w)+"s")
+		}
+		note := "-"
+		if len(at) > 0 {
+			note = "⚠️ " + strings.Join(at, ", ")
+			fmt.Printf("⚠️  %s run %d: p99 spiked to %.1f× its median window at %s\n", r.key, r.run, r.p99[worst]/median, strings.Join(at, ", "))
+		}
+		table = append(table, []string{r.key, strconv.Itoa(r.run), strconv.Itoa(len(r.ok)),
+			fmt.Sprintf("%.4g", inUnit(median)), fmt.Sprintf("%.4g at %ss", inUnit(r.p99[worst]), windowLabel(worst)), note})
+	}
+	addReportSection("Percentiles over time",
+		fmt.Sprintf("p50, p95 and p99 over %v windows within each run spanning two or more, from its raw latencies. "+
+			"Windows whose p99 reaches %v× the run's median window p99 are listed as stalls (%s).\n\n", percentileWindow, stallFactor, displayUnit)+
+			markdownTable([]string{"target", "run", "windows", "median window p99", "worst window p99", "stalls"}, table))
+	analyzeTransients(long)
+
+	page := components.NewPage()
+	page.SetPageTitle("Percentiles Over Time")
+	for _, r := range long {
+		if !seriesWanted(r.key) {
+			continue
+		}
+		var xAxis []string
+		for w := range r.ok {
+			xAxis = append(xAxis, windowLabel(w))
+		}
+		line := charts.NewLine()
+		line.SetGlobalOptions(
+			charts.WithTitleOpts(chartTitle(fmt.Sprintf("%s, run %d", r.key, r.run), fmt.Sprintf("%s · percentiles per %v window", r.runID, percentileWindow))),
+			charts.WithYAxisOpts(opts.YAxis{Name: displayUnit}),
+			charts.WithXAxisOpts(opts.XAxis{Name: "Seconds"}),
+			charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		)
+		line.SetGlobalOptions(interactiveOpts(displayUnit)...)
+		line.SetXAxis(xAxis)
+		for _, p := range []struct {
+			name   string
+			values []float64
+		}{{"p50", r.p50}, {"p95", r.p95}, {"p99", r.p99}} {
+			var points []opts.LineData
+			for w, v := range p.values {
+				if !r.ok[w] {
+					points = append(points, opts.LineData{Value: "-"})
+					continue
+				}
+				points = append(points, opts.LineData{Value: round4(inUnit(v))})
+			}
+			line.AddSeries(p.name, points)
+		}
+		page.AddCharts(line)
+	}
+	renderChart(page, filename)
+}
+
+// windowLabel is the second window w starts at.
+func windowLabel(w int) string {
+	return strconv.FormatFloat((time.Duration(w) * percentileWindow).Seconds(), 'f', -1, 64)
+}
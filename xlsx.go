@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// writeXLSX exports the suite as a workbook: a Summary sheet with one line
+// per series, deltas against the first (baseline) series highlighted green
+// or red, and a p95 sparkline per series, followed by one sheet per series
+// holding every run.
+func writeXLSX(filename string, rows []map[string]string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	p95Col := "average"
+	if hasPercentile(95) {
+		p95Col = "p95"
+	}
+	series, order := seriesRows(rows)
+	headers := resultHeaders()
+
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	sheets := map[string]string{}
+	used := map[string]bool{"Summary": true}
+	for _, key := range order {
+		name := sheetName(key, used)
+		sheets[key] = name
+		f.NewSheet(name)
+		f.SetSheetRow(name, "A1", &headers)
+		f.SetCellStyle(name, "A1", cellName(len(headers), 1), bold)
+		for r, row := range series[key] {
+			values := make([]interface{}, len(headers))
+			for c, h := range headers {
+				if v, err := strconv.ParseFloat(row[h], 64); err == nil && h != "target" && h != "route" {
+					values[c] = v
+				} else {
+					values[c] = row[h]
+				}
+			}
+			f.SetSheetRow(name, cellName(1, r+2), &values)
+		}
+		f.SetPanes(name, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	}
+
+	f.SetSheetName("Sheet1", "Summary")
+	summary := []interface{}{"series", "runs", "rps", "average (ms)", p95Col + " (ms)", "Δ rps %", "Δ " + p95Col + " %", p95Col + " per run"}
+	f.SetSheetRow("Summary", "A1", &summary)
+	f.SetCellStyle("Summary", "A1", "H1", bold)
+	var baseRPS, baseLat float64
+	spark := &excelize.SparklineOptions{Markers: true, High: true}
+	for i, key := range order {
+		rps := seriesMean(series[key], "requests_per_sec")
+		lat := seriesMean(series[key], p95Col)
+		if i == 0 {
+			baseRPS, baseLat = rps, lat
+		}
+		line := []interface{}{key, len(series[key]), rps, seriesMean(series[key], "average"), lat,
+			pctChange(baseRPS, rps), pctChange(baseLat, lat)}
+		f.SetSheetRow("Summary", cellName(1, i+2), &line)
+
+		col := columnOf(headers, p95Col)
+		spark.Location = append(spark.Location, cellName(8, i+2))
+		spark.Range = append(spark.Range, sheetRange(sheets[key], cellName(col, 2), cellName(col, len(series[key])+1)))
+	}
+	// one group for every series, as each AddSparkline call would repeat
+	// the earlier groups' sparklines
+	if len(spark.Location) > 0 {
+		f.AddSparkline("Summary", spark)
+	}
+	f.SetColWidth("Summary", "A", "A", 40)
+	f.SetColWidth("Summary", "B", "H", 14)
+
+	if n := len(order); n > 1 {
+		red, _ := f.NewConditionalStyle(&excelize.Style{
+			Font: &excelize.Font{Color: "9A0511"},
+			Fill: excelize.Fill{Type: "pattern", Color: []string{"FEC7CE"}, Pattern: 1},
+		})
+		green, _ := f.NewConditionalStyle(&excelize.Style{
+			Font: &excelize.Font{Color: "006100"},
+			Fill: excelize.Fill{Type: "pattern", Color: []string{"C6EFCE"}, Pattern: 1},
+		})
+		// less throughput or more latency than the baseline is a regression
+		f.SetConditionalFormat("Summary", fmt.Sprintf("F3:F%d", n+1), []excelize.ConditionalFormatOptions{
+			{Type: "cell", Criteria: "<", Format: red, Value: "0"},
+			{Type: "cell", Criteria: ">", Format: green, Value: "0"},
+		})
+		f.SetConditionalFormat("Summary", fmt.Sprintf("G3:G%d", n+1), []excelize.ConditionalFormatOptions{
+			{Type: "cell", Criteria: ">", Format: red, Value: "0"},
+			{Type: "cell", Criteria: "<", Format: green, Value: "0"},
+		})
+	}
+
+	if err := f.SaveAs(filename); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Workbook written to %s\n", filename)
+	return nil
+}
+
+// seriesRows groups rows by series, keeping first-seen order.
+func seriesRows(rows []map[string]string) (map[string][]map[string]string, []string) {
+	out := map[string][]map[string]string{}
+	var order []string
+	for _, row := range rows {
+		key := rowSeriesKey(row)
+		if _, ok := out[key]; !ok {
+			order = append(order, key)
+		}
+		out[key] = append(out[key], row)
+	}
+	return out, order
+}
+
+func seriesMean(rows []map[string]string, metric string) float64 {
+	var xs []float64
+	for _, row := range rows {
+		if v, ok := rowFloat(row, metric); ok {
+			xs = append(xs, v)
+		}
+	}
+	return round4(mean(xs))
+}
+
+func pctChange(base, v float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return round4((v - base) / base * 100)
+}
+
+// sheetName makes key a valid, unique sheet name: at most 31 characters,
+// none of []:*?/\ and no apostrophe at either end.
+func sheetName(key string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, key)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if strings.HasPrefix(name, "'") {
+		name = "_" + name[1:]
+	}
+	if strings.HasSuffix(name, "'") {
+		name = name[:len(name)-1] + "_"
+	}
+	base := name
+	for i := 2; used[name]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		r := []rune(base)
+		if len(r)+len(suffix) > 31 {
+			r = r[:31-len(suffix)]
+		}
+		name = string(r) + suffix
+	}
+	used[name] = true
+	return name
+}
+
+// sheetRange is a formula's reference to from:to on sheet, quoted, with
+// the apostrophes of the name doubled.
+func sheetRange(sheet, from, to string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'!" + from + ":" + to
+}
+
+func cellName(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
+
+func columnOf(headers []string, h string) int {
+	for i, x := range headers {
+		if x == h {
+			return i + 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSheetName(t *testing.T) {
+	used := map[string]bool{}
+	tests := []struct {
+		key, want string
+	}{
+		{"api GET /persons", "api GET _persons"},
+		{"a[b]:c*d?e\\f", "a_b__c_d_e_f"},
+		{"'quoted'", "_quoted_"},
+		{"o'brien", "o'brien"},
+		{strings.Repeat("x", 40), strings.Repeat("x", 31)},
+		{strings.Repeat("x", 40), strings.Repeat("x", 27) + " (2)"},
+		{strings.Repeat("x", 30) + "'y", strings.Repeat("x", 30) + "_"},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.key, used); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSheetRange(t *testing.T) {
+	tests := []struct {
+		sheet, want string
+	}{
+		{"api", "'api'!E2:E6"},
+		{"o'brien GET /", "'o''brien GET /'!E2:E6"},
+		{"it''s", "'it''''s'!E2:E6"},
+	}
+	for _, tt := range tests {
+		if got := sheetRange(tt.sheet, "E2", "E6"); got != tt.want {
+			t.Errorf("sheetRange(%q) = %q, want %q", tt.sheet, got, tt.want)
+		}
+	}
+}